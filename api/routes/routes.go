@@ -5,19 +5,19 @@ import (
 	"aken_reporting_service/internal/database"
 	"aken_reporting_service/internal/handlers"
 	"aken_reporting_service/internal/middleware"
-	"aken_reporting_service/internal/repositories"
 	"aken_reporting_service/internal/services"
+	"aken_reporting_service/internal/utils"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
 )
 
 var startTime = time.Now()
 
-// SetupRoutes initializes all API routes with dependency injection following the household project pattern
-func SetupRoutes(router *gin.Engine, db *gorm.DB, cacheService services.CacheService) {
+// SetupRoutes initializes all API routes with dependency injection following the household project pattern.
+// The transaction handler is built by the caller, which also runs its report scheduler.
+func SetupRoutes(router *gin.Engine, transactionHandler *handlers.TransactionHandler, cacheService services.CacheService) {
 	// Apply global middleware
 	router.Use(middleware.RequestIDMiddleware())
 	router.Use(middleware.ResponseHeadersMiddleware())
@@ -27,46 +27,43 @@ func SetupRoutes(router *gin.Engine, db *gorm.DB, cacheService services.CacheSer
 	v1 := router.Group("/api/v1")
 	v2 := router.Group("/api/v2")
 
-	// Initialize repositories with both databases
-	transactionRepo := repositories.NewTransactionRepository(database.DB, database.MySQLDB)
-
-	// Initialize services
-	transactionService := services.NewTransactionService(transactionRepo, cacheService)
-
 	// Initialize handlers
-	transactionHandler := handlers.NewTransactionHandler(transactionService)
 	authHandler := handlers.NewAuthHandler()
 
 	// Register authentication routes (for testing and development)
-	RegisterAuthRoutes(v2, authHandler)
+	RegisterAuthRoutes(v2, authHandler, cacheService)
 
 	// Register transaction routes
-	RegisterTransactionRoutes(v2, transactionHandler)
+	RegisterTransactionRoutes(v2, transactionHandler, cacheService)
+
+	// Register scheduled report routes
+	RegisterReportRoutes(v2, transactionHandler, cacheService)
 
 	// Register v1 transaction lookup route
-	RegisterV1TransactionRoutes(v1, transactionHandler)
+	RegisterV1TransactionRoutes(v1, transactionHandler, cacheService)
 
-	// Health check endpoint (supports both GET and HEAD)
+	// Health check endpoint (supports both GET and HEAD). This is the liveness probe: it
+	// returns 200 while the process is up and only reports dependency health in the body.
 	healthHandler := func(c *gin.Context) {
-		// Check database health
+		// Check database and cache health
 		dbHealth := database.CheckDatabaseHealth()
+		cacheHealth := services.CheckCacheHealth(cacheService)
 
-		// Determine overall status
+		// Determine overall status. The service works without the cache, so a cache outage
+		// only degrades it; a disabled cache does not.
 		status := "healthy"
-		httpStatus := http.StatusOK
-
-		if dbHealth.Status != "healthy" {
+		if dbHealth.Status != "healthy" || cacheHealth.Status == "unhealthy" {
 			status = "degraded"
-			httpStatus = http.StatusServiceUnavailable
 		}
 
-		c.JSON(httpStatus, gin.H{
+		c.JSON(http.StatusOK, gin.H{
 			"status":    status,
 			"service":   config.ServiceName,
 			"version":   config.APIVersion,
 			"timestamp": time.Now().UTC().Format(time.RFC3339),
 			"uptime":    time.Since(startTime).Seconds(),
 			"database":  dbHealth,
+			"cache":     cacheHealth,
 		})
 	}
 
@@ -74,6 +71,41 @@ func SetupRoutes(router *gin.Engine, db *gorm.DB, cacheService services.CacheSer
 	v2.GET("/health", healthHandler)
 	v2.HEAD("/health", healthHandler)
 
+	// Readiness probe: traffic is only routed here while PostgreSQL, which backs the v2
+	// APIs, is reachable. The optional MySQL is reported as degraded but never blocks it.
+	readyHandler := func(c *gin.Context) {
+		dependencies := database.CheckDependencies()
+
+		degraded := []string{}
+		for _, name := range []string{database.DependencyPostgreSQL, database.DependencyMySQL} {
+			if dependencies[name].Status != "healthy" {
+				degraded = append(degraded, name)
+			}
+		}
+
+		status := "ready"
+		httpStatus := http.StatusOK
+		if dependencies[database.DependencyPostgreSQL].Status != "healthy" {
+			status = "not_ready"
+			httpStatus = http.StatusServiceUnavailable
+		}
+
+		c.JSON(httpStatus, gin.H{
+			"status":       status,
+			"service":      config.ServiceName,
+			"version":      config.APIVersion,
+			"timestamp":    time.Now().UTC().Format(time.RFC3339),
+			"dependencies": dependencies,
+			"degraded":     degraded,
+		})
+	}
+
+	v2.GET("/ready", readyHandler)
+	v2.HEAD("/ready", readyHandler)
+
+	// Counters for scraping, such as error responses by endpoint and error code
+	v2.GET("/metrics", handlers.Metrics)
+
 	// API info endpoint
 	v2.GET("/info", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{
@@ -82,23 +114,41 @@ func SetupRoutes(router *gin.Engine, db *gorm.DB, cacheService services.CacheSer
 			"description": "Modern RESTful API for AKEN transaction reporting",
 			"endpoints": gin.H{
 				"transactions": gin.H{
-					"list":   "GET /api/v2/transactions",
-					"get":    "GET /api/v2/transactions/:id",
-					"search": "POST /api/v2/transactions/search",
-					"totals": "GET /api/v2/transactions/totals",
-					"export": "POST /api/v2/transactions/export (coming soon)",
-					"batch":  "POST /api/v2/transactions/batch (coming soon)",
+					"list":    "GET /api/v2/transactions",
+					"get":     "GET /api/v2/transactions/:id",
+					"receipt": "GET /api/v2/transactions/:id/receipt",
+					"search":  "POST /api/v2/transactions/search",
+					"totals":  "GET /api/v2/transactions/totals",
+					"export":  "POST /api/v2/transactions/export",
+					"batch":   "POST /api/v2/transactions/batch",
+					"by_stan": "GET /api/v2/transactions/by-stan?stan=&date=",
 				},
 				"merchants": gin.H{
 					"summary":      "GET /api/v2/merchants/:id/summary",
 					"transactions": "GET /api/v2/merchants/:id/transactions",
+					"devices":      "GET /api/v2/merchants/:id/devices",
 				},
 				"analytics": gin.H{
-					"summary": "GET /api/v2/analytics/summary (coming soon)",
-					"custom":  "POST /api/v2/analytics/custom (coming soon)",
+					"summaries":  "GET /api/v2/analytics/summaries",
+					"timeseries": "GET /api/v2/analytics/timeseries",
+					"summary":    "GET /api/v2/analytics/summary (coming soon)",
+					"custom":     "POST /api/v2/analytics/custom (coming soon)",
+				},
+				"exports": gin.H{
+					"list":   "GET /api/v2/exports",
+					"status": "GET /api/v2/exports/:export_id",
+				},
+				"reports": gin.H{
+					"create_schedule": "POST /api/v2/reports/schedules",
+					"list_schedules":  "GET /api/v2/reports/schedules",
+					"delete_schedule": "DELETE /api/v2/reports/schedules/:schedule_id",
+				},
+				"reference": gin.H{
+					"currencies": "GET /api/v2/currencies",
 				},
 				"system": gin.H{
 					"health": "GET /api/v2/health",
+					"ready":  "GET /api/v2/ready",
 					"info":   "GET /api/v2/info",
 				},
 			},
@@ -117,63 +167,94 @@ func SetupRoutes(router *gin.Engine, db *gorm.DB, cacheService services.CacheSer
 }
 
 // RegisterAuthRoutes sets up authentication routes for token generation and verification
-func RegisterAuthRoutes(rg *gin.RouterGroup, handler *handlers.AuthHandler) {
+func RegisterAuthRoutes(rg *gin.RouterGroup, handler *handlers.AuthHandler, cacheService services.CacheService) {
 	auth := rg.Group("/auth")
 	{
-		// Public endpoints for token generation (development/testing)
+		// Public endpoints for token generation (development/testing). Issued tokens are never
+		// stored for idempotent replay.
 		auth.POST("/generate-token", handler.GenerateToken)
 
+		// Exchanges a valid token for a new one; the token is validated by the handler, and the
+		// merchant's rate limit applies so a token can't be refreshed in a tight loop
+		auth.POST("/refresh", middleware.JWTAuthMiddleware(), middleware.RateLimitMiddleware(cacheService), handler.RefreshToken)
+
 		// Protected endpoint for token verification
 		auth.GET("/verify-token", middleware.JWTAuthMiddleware(), handler.VerifyToken)
 	}
 }
 
 // RegisterTransactionRoutes sets up all transaction-related routes
-func RegisterTransactionRoutes(rg *gin.RouterGroup, handler *handlers.TransactionHandler) {
+func RegisterTransactionRoutes(rg *gin.RouterGroup, handler *handlers.TransactionHandler, cacheService services.CacheService) {
 	// Apply JWT authentication to all transaction routes
 	// Dev mode handling is done at the middleware level in main.go
 	transactions := rg.Group("/transactions")
 	transactions.Use(middleware.JWTAuthMiddleware())
+	transactions.Use(middleware.RateLimitMiddleware(cacheService))
 	{
 		// Core transaction endpoints - each merchant can only see their own data
 		transactions.GET("", handler.GetTransactions)
 		transactions.GET("/:id", handler.GetTransactionByID)
+		transactions.GET("/:id/receipt", handler.GetTransactionReceipt)
 		transactions.POST("/search", handler.AdvancedTransactionSearch)
 		transactions.GET("/totals", handler.GetTransactionTotals)
-
-		// Future endpoints (placeholders)
-		transactions.POST("/export", handleNotImplemented("Transaction export"))
-		transactions.POST("/batch", handleNotImplemented("Batch operations"))
-		transactions.GET("/stream", handleNotImplemented("Real-time transaction stream"))
+		transactions.POST("/export", middleware.IdempotencyMiddleware(cacheService), handler.ExportTransactions)
+		transactions.GET("/stream", handler.StreamTransactions)
+		transactions.POST("/batch", handler.BatchGetTransactions)
+		transactions.GET("/by-stan", handler.GetTransactionsBySTAN)
 	}
 
 	// Merchant-specific routes - protected by JWT authentication
 	merchants := rg.Group("/merchants")
 	merchants.Use(middleware.JWTAuthMiddleware())
+	merchants.Use(middleware.RateLimitMiddleware(cacheService))
 	{
 		merchants.GET("/:merchant_id/summary", handler.GetMerchantSummary)
 		merchants.GET("/:merchant_id/transactions", handler.GetMerchantTransactions)
+		merchants.GET("/:merchant_id/devices", handler.GetMerchantDevices)
 	}
 
-	// Analytics routes (future features)
+	// Analytics routes
 	analytics := rg.Group("/analytics")
 	{
+		analytics.GET("/summaries", middleware.JWTAuthMiddleware(), middleware.RateLimitMiddleware(cacheService), handler.GetMerchantSummaries)
+		analytics.GET("/timeseries", middleware.JWTAuthMiddleware(), middleware.RateLimitMiddleware(cacheService), handler.GetTransactionTimeseries)
+
+		// Future endpoints (placeholders)
 		analytics.GET("/summary", handleNotImplemented("Analytics summary"))
 		analytics.POST("/custom", handleNotImplemented("Custom analytics"))
 	}
 
-	// Export management routes (future features)
+	// Reference data
+	rg.GET("/currencies", middleware.JWTAuthMiddleware(), middleware.RateLimitMiddleware(cacheService), handler.GetCurrencies)
+
+	// Export management routes
 	exports := rg.Group("/exports")
 	{
-		exports.GET("/:export_id", handleNotImplemented("Export status checking"))
+		exports.GET("", middleware.JWTAuthMiddleware(), middleware.RateLimitMiddleware(cacheService), handler.ListExports)
+		exports.GET("/:export_id", middleware.JWTAuthMiddleware(), middleware.RateLimitMiddleware(cacheService), handler.GetExport)
+
+		// Future endpoints (placeholders)
 		exports.GET("/:export_id/download", handleNotImplemented("Export download"))
 	}
 }
 
+// RegisterReportRoutes sets up the scheduled report routes, scoped to the authenticated merchant
+func RegisterReportRoutes(rg *gin.RouterGroup, handler *handlers.TransactionHandler, cacheService services.CacheService) {
+	schedules := rg.Group("/reports/schedules")
+	schedules.Use(middleware.JWTAuthMiddleware())
+	schedules.Use(middleware.RateLimitMiddleware(cacheService))
+	{
+		schedules.POST("", handler.CreateReportSchedule)
+		schedules.GET("", handler.ListReportSchedules)
+		schedules.DELETE("/:schedule_id", handler.DeleteReportSchedule)
+	}
+}
+
 // RegisterV1TransactionRoutes sets up v1 efinance transaction routes
-func RegisterV1TransactionRoutes(rg *gin.RouterGroup, handler *handlers.TransactionHandler) {
+func RegisterV1TransactionRoutes(rg *gin.RouterGroup, handler *handlers.TransactionHandler, cacheService services.CacheService) {
 	efinance := rg.Group("/efinance")
 	efinance.Use(middleware.JWTAuthMiddleware())
+	efinance.Use(middleware.RateLimitMiddleware(cacheService))
 	efinance.Use(middleware.UseMySQLMiddleware()) // Use MySQL for efinance APIs
 	{
 		transactions := efinance.Group("/transactions")
@@ -189,11 +270,6 @@ func RegisterV1TransactionRoutes(rg *gin.RouterGroup, handler *handlers.Transact
 // handleNotImplemented returns a 501 Not Implemented response for future features
 func handleNotImplemented(feature string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		c.JSON(http.StatusNotImplemented, gin.H{
-			"code":       config.ErrorCodeNotImplemented,
-			"message":    feature + " not yet implemented",
-			"timestamp":  time.Now().UTC().Format(time.RFC3339),
-			"request_id": c.GetHeader("X-Request-ID"),
-		})
+		utils.SendError(c, http.StatusNotImplemented, config.ErrorCodeNotImplemented, feature+" not yet implemented", nil)
 	}
 }
@@ -4,6 +4,9 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -100,6 +103,40 @@ func IsDevMode() bool {
 	return os.Getenv("ENV") == "development" || os.Getenv("DISABLE_AUTH") == "true"
 }
 
+// ValidateAuthSettings refuses DISABLE_AUTH=true outside development, so a leaked
+// flag cannot open the service to unauthenticated requests in production.
+func ValidateAuthSettings() error {
+	if os.Getenv("DISABLE_AUTH") == "true" && os.Getenv("ENV") != "development" {
+		return fmt.Errorf("DISABLE_AUTH=true is only allowed when ENV=development (ENV=%q)", os.Getenv("ENV"))
+	}
+	if algorithm := GetJWTAlgorithm(); !JWTAlgorithms[algorithm] {
+		return fmt.Errorf("unsupported JWT_ALGORITHM %q", algorithm)
+	}
+	return nil
+}
+
+// DefaultJWTAlgorithm is the algorithm tokens are signed with unless JWT_ALGORITHM is set
+const DefaultJWTAlgorithm = "HS256"
+
+// JWTAlgorithms are the supported JWT_ALGORITHM values
+var JWTAlgorithms = map[string]bool{
+	"HS256": true,
+}
+
+// GetJWTAlgorithm returns the algorithm tokens are signed and verified with
+func GetJWTAlgorithm() string {
+	return strings.ToUpper(GetEnvOrDefault("JWT_ALGORITHM", DefaultJWTAlgorithm))
+}
+
+// GetJWTExpiry returns how long issued tokens are valid for
+func GetJWTExpiry() time.Duration {
+	hours, err := strconv.Atoi(GetEnvOrDefault("JWT_EXPIRY_HOURS", "24"))
+	if err != nil || hours < 1 {
+		hours = 24
+	}
+	return time.Duration(hours) * time.Hour
+}
+
 // GetJWTSecret returns the JWT signing secret
 func GetJWTSecret() string {
 	secret := os.Getenv("JWT_SECRET")
@@ -110,6 +147,33 @@ func GetJWTSecret() string {
 	return secret
 }
 
+// GetJWTSecretForAlgorithm returns the signing key for a JWT algorithm from JWT_SECRET_<ALGORITHM>
+// (e.g. JWT_SECRET_HS256), falling back to JWT_SECRET
+func GetJWTSecretForAlgorithm(algorithm string) string {
+	if secret := os.Getenv("JWT_SECRET_" + strings.ToUpper(algorithm)); secret != "" {
+		return secret
+	}
+	return GetJWTSecret()
+}
+
+// GetCursorSigningKey returns the key pagination cursors are signed with. It defaults to the
+// JWT secret so cursors stay valid across instances without extra configuration.
+func GetCursorSigningKey() []byte {
+	if key := os.Getenv("CURSOR_SIGNING_KEY"); key != "" {
+		return []byte(key)
+	}
+	return []byte(GetJWTSecret())
+}
+
+// GetIdempotencyFingerprintKey returns the key request fingerprints stored with idempotent
+// responses are signed with. It defaults to the JWT secret, like the cursor signing key.
+func GetIdempotencyFingerprintKey() []byte {
+	if key := os.Getenv("IDEMPOTENCY_FINGERPRINT_KEY"); key != "" {
+		return []byte(key)
+	}
+	return []byte(GetJWTSecret())
+}
+
 // GetJWTIssuer returns the JWT issuer
 func GetJWTIssuer() string {
 	issuer := os.Getenv("JWT_ISSUER")
@@ -119,6 +183,26 @@ func GetJWTIssuer() string {
 	return issuer
 }
 
+// GetJWTRefreshMinTTL returns how long after issuance a token must be before it can be refreshed,
+// so a token can't be refreshed again straight after it was issued
+func GetJWTRefreshMinTTL() time.Duration {
+	seconds, err := strconv.Atoi(GetEnvOrDefault("JWT_REFRESH_MIN_TTL", "300"))
+	if err != nil || seconds < 0 {
+		seconds = 300
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// GetIdempotencyTTL returns how long the response to a request with an Idempotency-Key is
+// replayed for duplicates of that request
+func GetIdempotencyTTL() time.Duration {
+	seconds, err := strconv.Atoi(GetEnvOrDefault("IDEMPOTENCY_TTL_SECONDS", "86400"))
+	if err != nil || seconds < 1 {
+		seconds = 86400
+	}
+	return time.Duration(seconds) * time.Second
+}
+
 // GetEnvOrDefault returns environment variable value or default if not set
 func GetEnvOrDefault(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
@@ -137,6 +221,30 @@ func GetPostgreSQLConfig() (host, port, user, password, dbname string) {
 	return
 }
 
+// RollupDatabase is an additional PostgreSQL shard merged into provisioner roll-ups
+type RollupDatabase struct {
+	Name string // Reported in the warning when the shard is skipped
+	URL  string // postgres:// connection URL
+}
+
+// GetRollupDatabases returns the shards configured with ROLLUP_DATABASES as comma-separated
+// name=url pairs, in order. Entries without a name or URL are ignored.
+func GetRollupDatabases() []RollupDatabase {
+	var databases []RollupDatabase
+	for _, entry := range strings.Split(GetEnvOrDefault("ROLLUP_DATABASES", ""), ",") {
+		parts := strings.SplitN(strings.TrimSpace(entry), "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		name, url := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+		if name == "" || url == "" {
+			continue
+		}
+		databases = append(databases, RollupDatabase{Name: name, URL: url})
+	}
+	return databases
+}
+
 // GetMySQLConfig returns MySQL configuration
 func GetMySQLConfig() (mysql_host, port, user, password, database string) {
 	mysql_host = GetEnvOrDefault(ATLAS_DB_HOST, "")
@@ -147,8 +255,431 @@ func GetMySQLConfig() (mysql_host, port, user, password, database string) {
 	return
 }
 
+// GetDBPoolConfig returns the connection pool limits applied to each database:
+// DB_MAX_OPEN_CONNS, DB_MAX_IDLE_CONNS (capped at the open limit) and DB_CONN_MAX_LIFETIME,
+// given in seconds or as a duration such as 30m
+func GetDBPoolConfig() (maxOpen, maxIdle int, connMaxLifetime time.Duration) {
+	maxOpen, err := strconv.Atoi(GetEnvOrDefault("DB_MAX_OPEN_CONNS", "25"))
+	if err != nil || maxOpen < 1 {
+		maxOpen = 25
+	}
+
+	maxIdle, err = strconv.Atoi(GetEnvOrDefault("DB_MAX_IDLE_CONNS", "10"))
+	if err != nil || maxIdle < 0 {
+		maxIdle = 10
+	}
+	if maxIdle > maxOpen {
+		maxIdle = maxOpen
+	}
+
+	lifetime := GetEnvOrDefault("DB_CONN_MAX_LIFETIME", "1800")
+	if seconds, err := strconv.Atoi(lifetime); err == nil {
+		connMaxLifetime = time.Duration(seconds) * time.Second
+	} else {
+		connMaxLifetime, err = time.ParseDuration(lifetime)
+		if err != nil {
+			connMaxLifetime = 0
+		}
+	}
+	if connMaxLifetime <= 0 {
+		connMaxLifetime = 30 * time.Minute
+	}
+	return
+}
+
 // GetGinMode returns the Gin mode configuration
 func GetGinMode() string {
 	return GetEnvOrDefault("GIN_MODE", "release")
 }
 
+// logLevels are the LOG_LEVEL values
+var logLevels = map[string]bool{"trace": true, "debug": true, "info": true, "warn": true, "error": true}
+
+// GetLogLevel returns the log level from LOG_LEVEL (trace, debug, info, warn or error).
+// Without a valid one, release mode logs at info so request query strings and other trace
+// output stay out of production logs; debug and test modes log at trace.
+func GetLogLevel() string {
+	if level := strings.ToLower(strings.TrimSpace(os.Getenv("LOG_LEVEL"))); logLevels[level] {
+		return level
+	}
+	if GetGinMode() == "release" {
+		return "info"
+	}
+	return "trace"
+}
+
+// pageSizeEnvVars maps endpoints to the env var overriding their default page size
+var pageSizeEnvVars = map[string]string{
+	PageSizeEndpointList:   "DEFAULT_PAGE_SIZE_LIST",
+	PageSizeEndpointSearch: "DEFAULT_PAGE_SIZE_SEARCH",
+}
+
+// GetDefaultPageSize returns the page size used when a request to endpoint gives no limit,
+// falling back to DefaultPageSize for unknown endpoints and unset or invalid values
+func GetDefaultPageSize(endpoint string) int {
+	envVar, exists := pageSizeEnvVars[endpoint]
+	if !exists {
+		return DefaultPageSize
+	}
+	pageSize, err := strconv.Atoi(GetEnvOrDefault(envVar, ""))
+	if err != nil || pageSize < MinPageSize || pageSize > MaxPageSize {
+		return DefaultPageSize
+	}
+	return pageSize
+}
+
+// GetMaxFilterOrClauses returns the maximum number of OR branches allowed in a filter expression
+func GetMaxFilterOrClauses() int {
+	maxClauses, err := strconv.Atoi(GetEnvOrDefault("MAX_FILTER_OR_CLAUSES", "20"))
+	if err != nil || maxClauses < 1 {
+		return 20
+	}
+	return maxClauses
+}
+
+// GetMaxSearchBodyBytes returns the maximum size in bytes of a search request body
+func GetMaxSearchBodyBytes() int64 {
+	maxBytes, err := strconv.ParseInt(GetEnvOrDefault("MAX_SEARCH_BODY_BYTES", "1048576"), 10, 64)
+	if err != nil || maxBytes < 1 {
+		return 1048576
+	}
+	return maxBytes
+}
+
+// GetMaxSearchQueryDepth returns how deeply bool clauses may be nested in a search query
+func GetMaxSearchQueryDepth() int {
+	maxDepth, err := strconv.Atoi(GetEnvOrDefault("MAX_SEARCH_QUERY_DEPTH", "8"))
+	if err != nil || maxDepth < 1 {
+		return 8
+	}
+	return maxDepth
+}
+
+// GetMaxAggregationBuckets returns the maximum number of buckets a timeseries aggregation may return
+func GetMaxAggregationBuckets() int {
+	maxBuckets, err := strconv.Atoi(GetEnvOrDefault("MAX_AGGREGATION_BUCKETS", "1000"))
+	if err != nil || maxBuckets < 1 {
+		return 1000
+	}
+	return maxBuckets
+}
+
+// GetRequestLogSampleRate returns N where one in N successful requests is logged by the
+// request logging middleware; 1 logs every request
+func GetRequestLogSampleRate() int {
+	rate, err := strconv.Atoi(GetEnvOrDefault("REQUEST_LOG_SAMPLE_RATE", "1"))
+	if err != nil || rate < 1 {
+		return 1
+	}
+	return rate
+}
+
+// GetMaxResponseBytes returns the size in bytes above which a transaction list response is
+// refused with a 400; 0 turns the cap off
+func GetMaxResponseBytes() int {
+	maxBytes, err := strconv.Atoi(GetEnvOrDefault("MAX_RESPONSE_BYTES", "10485760"))
+	if err != nil || maxBytes < 0 {
+		return 10485760
+	}
+	return maxBytes
+}
+
+// GetGzipLevel returns the gzip level of compressed responses, from 1 (fastest) to 9
+// (smallest); 0 turns response compression off
+func GetGzipLevel() int {
+	level, err := strconv.Atoi(GetEnvOrDefault("GZIP_LEVEL", "6"))
+	if err != nil || level < 0 || level > 9 {
+		return 6
+	}
+	return level
+}
+
+// GetGzipMinSize returns the size in bytes below which responses are sent uncompressed
+func GetGzipMinSize() int {
+	minSize, err := strconv.Atoi(GetEnvOrDefault("GZIP_MIN_SIZE_BYTES", "1024"))
+	if err != nil || minSize < 0 {
+		return 1024
+	}
+	return minSize
+}
+
+// GetMaxQueryRangeDays returns the maximum number of days a bounded tx_date_time range may span
+func GetMaxQueryRangeDays() int {
+	maxDays, err := strconv.Atoi(GetEnvOrDefault("MAX_QUERY_RANGE_DAYS", "366"))
+	if err != nil || maxDays < 1 {
+		return 366
+	}
+	return maxDays
+}
+
+// GetDefaultQueryRangeDays returns how many days back a filter without a tx_date_time range
+// reads, capped at GetMaxQueryRangeDays
+func GetDefaultQueryRangeDays() int {
+	days, err := strconv.Atoi(GetEnvOrDefault("DEFAULT_QUERY_RANGE_DAYS", "30"))
+	if err != nil || days < 1 {
+		days = 30
+	}
+	if maxDays := GetMaxQueryRangeDays(); days > maxDays {
+		return maxDays
+	}
+	return days
+}
+
+// GetEfinanceMaxRangeDays returns the maximum number of days, inclusive, a v1 efinance
+// date_from/date_to range may span on the MySQL iso_trx table
+func GetEfinanceMaxRangeDays() int {
+	maxDays, err := strconv.Atoi(GetEnvOrDefault("EFINANCE_MAX_RANGE_DAYS", "31"))
+	if err != nil || maxDays < 1 {
+		return 31
+	}
+	return maxDays
+}
+
+// GetEfinanceDefaultRangeDays returns the number of days, ending today, searched by a v1
+// efinance search without a date, never more than GetEfinanceMaxRangeDays
+func GetEfinanceDefaultRangeDays() int {
+	days, err := strconv.Atoi(GetEnvOrDefault("EFINANCE_DEFAULT_RANGE_DAYS", "7"))
+	if err != nil || days < 1 {
+		days = 7
+	}
+	if maxDays := GetEfinanceMaxRangeDays(); days > maxDays {
+		return maxDays
+	}
+	return days
+}
+
+// GetMaxConcurrentQueries returns how many database queries a single request may run at once,
+// e.g. the data page alongside facet queries or the roll-up sources of a summary
+func GetMaxConcurrentQueries() int {
+	maxQueries, err := strconv.Atoi(GetEnvOrDefault("MAX_CONCURRENT_QUERIES", "2"))
+	if err != nil || maxQueries < 1 {
+		return 2
+	}
+	return maxQueries
+}
+
+// GetStreamMaxDuration returns how long a transaction stream may stay open before it is ended
+func GetStreamMaxDuration() time.Duration {
+	seconds, err := strconv.Atoi(GetEnvOrDefault("STREAM_MAX_DURATION_SECONDS", "600"))
+	if err != nil || seconds < 1 {
+		seconds = 600
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// GetStreamHeartbeatInterval returns how long a transaction stream may go without output
+// before a keep-alive line is written, so proxies do not close idle connections
+func GetStreamHeartbeatInterval() time.Duration {
+	seconds, err := strconv.Atoi(GetEnvOrDefault("STREAM_HEARTBEAT_SECONDS", "15"))
+	if err != nil || seconds < 1 {
+		seconds = 15
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// GetStreamMaxConcurrent returns how many transaction streams one merchant may hold open at once
+func GetStreamMaxConcurrent() int {
+	maxStreams, err := strconv.Atoi(GetEnvOrDefault("STREAM_MAX_CONCURRENT_PER_MERCHANT", "2"))
+	if err != nil || maxStreams < 1 {
+		return 2
+	}
+	return maxStreams
+}
+
+// GetStreamMaxConcurrentTotal returns how many transaction streams the instance holds open at
+// once across all merchants. A stream can hold a database connection for its whole life, so
+// the limit defaults to half of DB_MAX_OPEN_CONNS and is kept below it, leaving connections
+// for other requests.
+func GetStreamMaxConcurrentTotal() int {
+	maxOpen, _, _ := GetDBPoolConfig()
+	limit := maxOpen - 1
+	if limit < 1 {
+		limit = 1
+	}
+
+	maxStreams, err := strconv.Atoi(GetEnvOrDefault("STREAM_MAX_CONCURRENT_TOTAL", ""))
+	if err != nil || maxStreams < 1 {
+		maxStreams = maxOpen / 2
+	}
+	if maxStreams > limit {
+		return limit
+	}
+	if maxStreams < 1 {
+		return 1
+	}
+	return maxStreams
+}
+
+// GetStreamPollInterval returns how long a followed transaction stream waits between polls
+// for new rows
+func GetStreamPollInterval() time.Duration {
+	seconds, err := strconv.Atoi(GetEnvOrDefault("STREAM_POLL_INTERVAL_SECONDS", "5"))
+	if err != nil || seconds < 1 {
+		seconds = 5
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// GetStreamPollBatchSize returns the maximum rows a followed transaction stream reads per poll
+func GetStreamPollBatchSize() int {
+	batchSize, err := strconv.Atoi(GetEnvOrDefault("STREAM_POLL_BATCH_SIZE", "100"))
+	if err != nil || batchSize < 1 {
+		return 100
+	}
+	return batchSize
+}
+
+// GetReportSchedulerInterval returns how often the report scheduler checks for due schedules
+func GetReportSchedulerInterval() time.Duration {
+	seconds, err := strconv.Atoi(GetEnvOrDefault("REPORT_SCHEDULER_INTERVAL", "60"))
+	if err != nil || seconds < 1 {
+		seconds = 60
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// GetShutdownTimeout returns how long in-flight requests are given to finish on SIGINT/SIGTERM
+func GetShutdownTimeout() time.Duration {
+	seconds, err := strconv.Atoi(GetEnvOrDefault("SHUTDOWN_TIMEOUT_SECONDS", "30"))
+	if err != nil || seconds < 1 {
+		seconds = 30
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// GetEstimatedRowsPerSecond returns the export throughput, in rows per second, used to turn a
+// result-set size estimate into the X-Estimated-Duration header
+func GetEstimatedRowsPerSecond() int {
+	rate, err := strconv.Atoi(GetEnvOrDefault("ESTIMATED_ROWS_PER_SECOND", "10000"))
+	if err != nil || rate < 1 {
+		rate = 10000
+	}
+	return rate
+}
+
+// GetBatchInListThreshold returns the id list size above which batch lookups switch from
+// an IN (...) clause to a join against a single array parameter. It must stay below
+// MaxBatchIDs for the array path to be used.
+func GetBatchInListThreshold() int {
+	threshold, err := strconv.Atoi(GetEnvOrDefault("BATCH_IN_LIST_THRESHOLD", "100"))
+	if err != nil || threshold < 1 {
+		return 100
+	}
+	return threshold
+}
+
+// IsSettlementColumnsEnabled returns true if payment_tx_log has the settlement_status and
+// settlement_date columns, which are not present in every schema
+func IsSettlementColumnsEnabled() bool {
+	return GetEnvOrDefault("SETTLEMENT_COLUMNS_ENABLED", "false") == "true"
+}
+
+// IsReadOnlyModeEnabled returns true unless READ_ONLY_MODE is "false". In read-only mode
+// the database connections refuse writes to the source transaction tables.
+func IsReadOnlyModeEnabled() bool {
+	return GetEnvOrDefault("READ_ONLY_MODE", "true") != "false"
+}
+
+// IsPANDisabled returns true if PAN_DISABLED is "true". Card data is then never selected
+// or computed, whatever fields and pan_format are requested, and PANPlaceholder is returned.
+func IsPANDisabled() bool {
+	return GetEnvOrDefault("PAN_DISABLED", "false") == "true"
+}
+
+// Strategies for joining the currency table, selected with CURRENCY_JOIN_STRATEGY
+const (
+	CurrencyJoinLateral = "lateral" // Lateral subquery returning at most one currency row per code
+	CurrencyJoinDirect  = "join"    // Plain join, only correct while currency.curr_code is unique
+)
+
+// GetCurrencyJoinStrategy returns how transaction queries join the currency table. The
+// source schema does not make curr_code unique, so anything but "join" uses the lateral join.
+func GetCurrencyJoinStrategy() string {
+	if strings.ToLower(GetEnvOrDefault("CURRENCY_JOIN_STRATEGY", CurrencyJoinLateral)) == CurrencyJoinDirect {
+		return CurrencyJoinDirect
+	}
+	return CurrencyJoinLateral
+}
+
+// Rounding modes of derived amounts
+const (
+	RoundingNone     = "none"      // Amounts are returned unrounded
+	RoundingHalfUp   = "half_up"   // Halves round away from zero
+	RoundingHalfEven = "half_even" // Halves round to the even digit (banker's rounding)
+	RoundingTruncate = "truncate"  // Digits past the precision are dropped
+)
+
+// GetAmountRoundingMode returns how derived amounts, such as averages and totals in major
+// units, are rounded. Anything but "half_up", "half_even" or "truncate" leaves them unrounded.
+func GetAmountRoundingMode() string {
+	switch mode := strings.ToLower(GetEnvOrDefault("AMOUNT_ROUNDING_MODE", RoundingNone)); mode {
+	case RoundingHalfUp, RoundingHalfEven, RoundingTruncate:
+		return mode
+	}
+	return RoundingNone
+}
+
+// DefaultNoStoreRoutes are the PAN-bearing routes that must never be stored by clients
+const DefaultNoStoreRoutes = "/api/v2/transactions/:id"
+
+// GetNoStoreRoutes returns the route patterns whose responses are sent with Cache-Control: no-store
+func GetNoStoreRoutes() []string {
+	var routes []string
+	for _, route := range strings.Split(GetEnvOrDefault("NO_STORE_ROUTES", DefaultNoStoreRoutes), ",") {
+		if route = strings.TrimSpace(route); route != "" {
+			routes = append(routes, route)
+		}
+	}
+	return routes
+}
+
+// DefaultAllowedOrigins are the frontends allowed to call the API cross-origin
+const DefaultAllowedOrigins = "http://localhost:8080,http://localhost:5173,http://localhost:3000,http://localhost:3001,https://aken-eu.staging.wizzitdigital.com"
+
+// GetAllowedOrigins returns the origins allowed by CORS, from the comma-separated
+// CORS_ALLOWED_ORIGINS
+func GetAllowedOrigins() []string {
+	var origins []string
+	for _, origin := range strings.Split(GetEnvOrDefault("CORS_ALLOWED_ORIGINS", DefaultAllowedOrigins), ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	return origins
+}
+
+// GetMerchantRateLimit returns the hourly request limit for a merchant. Tiers are assigned with
+// RATE_LIMIT_TIERS as comma-separated merchant_id:tier pairs (standard, premium or enterprise);
+// merchants without an entry get the standard tier.
+func GetMerchantRateLimit(merchantID string) int {
+	for _, entry := range strings.Split(GetEnvOrDefault("RATE_LIMIT_TIERS", ""), ",") {
+		parts := strings.SplitN(strings.TrimSpace(entry), ":", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) != merchantID {
+			continue
+		}
+
+		switch strings.ToLower(strings.TrimSpace(parts[1])) {
+		case "premium":
+			return RateLimitPremium
+		case "enterprise":
+			return RateLimitEnterprise
+		}
+		return RateLimitStandard
+	}
+
+	return RateLimitStandard
+}
+
+// IsAdminMerchant returns true if the merchant is listed in ADMIN_MERCHANT_IDS (comma-separated).
+// Admins can use debugging features that expose internals, such as ?debug_sql=true.
+func IsAdminMerchant(merchantID string) bool {
+	if merchantID == "" {
+		return false
+	}
+	for _, id := range strings.Split(GetEnvOrDefault("ADMIN_MERCHANT_IDS", ""), ",") {
+		if strings.TrimSpace(id) == merchantID {
+			return true
+		}
+	}
+	return false
+}
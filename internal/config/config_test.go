@@ -0,0 +1,374 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetDefaultPageSize(t *testing.T) {
+	t.Setenv("DEFAULT_PAGE_SIZE_LIST", "250")
+	t.Setenv("DEFAULT_PAGE_SIZE_SEARCH", "")
+
+	assert.Equal(t, 250, GetDefaultPageSize(PageSizeEndpointList))
+	assert.Equal(t, DefaultPageSize, GetDefaultPageSize(PageSizeEndpointSearch))
+	assert.Equal(t, DefaultPageSize, GetDefaultPageSize("efinance"))
+
+	for _, invalid := range []string{"abc", "0", "10001"} {
+		t.Setenv("DEFAULT_PAGE_SIZE_SEARCH", invalid)
+		assert.Equal(t, DefaultPageSize, GetDefaultPageSize(PageSizeEndpointSearch), invalid)
+	}
+}
+
+func TestGetShutdownTimeout(t *testing.T) {
+	t.Setenv("SHUTDOWN_TIMEOUT_SECONDS", "")
+	assert.Equal(t, 30*time.Second, GetShutdownTimeout())
+
+	t.Setenv("SHUTDOWN_TIMEOUT_SECONDS", "5")
+	assert.Equal(t, 5*time.Second, GetShutdownTimeout())
+
+	for _, invalid := range []string{"abc", "0", "-3"} {
+		t.Setenv("SHUTDOWN_TIMEOUT_SECONDS", invalid)
+		assert.Equal(t, 30*time.Second, GetShutdownTimeout(), invalid)
+	}
+}
+
+func TestGetEstimatedRowsPerSecond(t *testing.T) {
+	t.Setenv("ESTIMATED_ROWS_PER_SECOND", "")
+	assert.Equal(t, 10000, GetEstimatedRowsPerSecond())
+
+	t.Setenv("ESTIMATED_ROWS_PER_SECOND", "2500")
+	assert.Equal(t, 2500, GetEstimatedRowsPerSecond())
+
+	for _, invalid := range []string{"abc", "0", "-3"} {
+		t.Setenv("ESTIMATED_ROWS_PER_SECOND", invalid)
+		assert.Equal(t, 10000, GetEstimatedRowsPerSecond(), invalid)
+	}
+}
+
+func TestValidateAuthSettings_JWTAlgorithm(t *testing.T) {
+	t.Setenv("DISABLE_AUTH", "")
+
+	t.Setenv("JWT_ALGORITHM", "hs256")
+	assert.NoError(t, ValidateAuthSettings())
+	assert.Equal(t, "HS256", GetJWTAlgorithm())
+
+	t.Setenv("JWT_ALGORITHM", "none")
+	err := ValidateAuthSettings()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "JWT_ALGORITHM")
+}
+
+func TestGetJWTExpiry(t *testing.T) {
+	t.Setenv("JWT_EXPIRY_HOURS", "")
+	assert.Equal(t, 24*time.Hour, GetJWTExpiry())
+
+	t.Setenv("JWT_EXPIRY_HOURS", "8")
+	assert.Equal(t, 8*time.Hour, GetJWTExpiry())
+
+	for _, invalid := range []string{"abc", "0", "-1"} {
+		t.Setenv("JWT_EXPIRY_HOURS", invalid)
+		assert.Equal(t, 24*time.Hour, GetJWTExpiry(), invalid)
+	}
+}
+
+func TestGetJWTSecretForAlgorithm(t *testing.T) {
+	t.Setenv("JWT_SECRET", "shared-secret")
+	t.Setenv("JWT_SECRET_HS256", "")
+	assert.Equal(t, "shared-secret", GetJWTSecretForAlgorithm("HS256"))
+
+	t.Setenv("JWT_SECRET_HS256", "hs256-secret")
+	assert.Equal(t, "hs256-secret", GetJWTSecretForAlgorithm("HS256"))
+	assert.Equal(t, "hs256-secret", GetJWTSecretForAlgorithm("hs256"))
+	assert.Equal(t, "shared-secret", GetJWTSecret())
+}
+
+func TestGetIdempotencyTTL(t *testing.T) {
+	t.Setenv("IDEMPOTENCY_TTL_SECONDS", "")
+	assert.Equal(t, 24*time.Hour, GetIdempotencyTTL())
+
+	t.Setenv("IDEMPOTENCY_TTL_SECONDS", "600")
+	assert.Equal(t, 10*time.Minute, GetIdempotencyTTL())
+
+	for _, invalid := range []string{"abc", "0", "-3"} {
+		t.Setenv("IDEMPOTENCY_TTL_SECONDS", invalid)
+		assert.Equal(t, 24*time.Hour, GetIdempotencyTTL(), invalid)
+	}
+}
+
+func TestGetDBPoolConfig(t *testing.T) {
+	t.Setenv("DB_MAX_OPEN_CONNS", "")
+	t.Setenv("DB_MAX_IDLE_CONNS", "")
+	t.Setenv("DB_CONN_MAX_LIFETIME", "")
+	maxOpen, maxIdle, lifetime := GetDBPoolConfig()
+	assert.Equal(t, 25, maxOpen)
+	assert.Equal(t, 10, maxIdle)
+	assert.Equal(t, 30*time.Minute, lifetime)
+
+	t.Setenv("DB_MAX_OPEN_CONNS", "50")
+	t.Setenv("DB_MAX_IDLE_CONNS", "0")
+	t.Setenv("DB_CONN_MAX_LIFETIME", "600")
+	maxOpen, maxIdle, lifetime = GetDBPoolConfig()
+	assert.Equal(t, 50, maxOpen)
+	assert.Equal(t, 0, maxIdle)
+	assert.Equal(t, 10*time.Minute, lifetime)
+
+	t.Setenv("DB_MAX_OPEN_CONNS", "5")
+	t.Setenv("DB_MAX_IDLE_CONNS", "20")
+	t.Setenv("DB_CONN_MAX_LIFETIME", "1h")
+	maxOpen, maxIdle, lifetime = GetDBPoolConfig()
+	assert.Equal(t, 5, maxOpen)
+	assert.Equal(t, 5, maxIdle, "idle connections are capped at the open limit")
+	assert.Equal(t, time.Hour, lifetime)
+
+	t.Setenv("DB_MAX_OPEN_CONNS", "0")
+	t.Setenv("DB_MAX_IDLE_CONNS", "-1")
+	t.Setenv("DB_CONN_MAX_LIFETIME", "forever")
+	maxOpen, maxIdle, lifetime = GetDBPoolConfig()
+	assert.Equal(t, 25, maxOpen)
+	assert.Equal(t, 10, maxIdle)
+	assert.Equal(t, 30*time.Minute, lifetime)
+}
+
+func TestGetRequestLogSampleRate(t *testing.T) {
+	t.Setenv("REQUEST_LOG_SAMPLE_RATE", "")
+	assert.Equal(t, 1, GetRequestLogSampleRate())
+
+	t.Setenv("REQUEST_LOG_SAMPLE_RATE", "100")
+	assert.Equal(t, 100, GetRequestLogSampleRate())
+
+	t.Setenv("REQUEST_LOG_SAMPLE_RATE", "0")
+	assert.Equal(t, 1, GetRequestLogSampleRate())
+}
+
+func TestGetMaxResponseBytes(t *testing.T) {
+	t.Setenv("MAX_RESPONSE_BYTES", "")
+	assert.Equal(t, 10485760, GetMaxResponseBytes())
+
+	t.Setenv("MAX_RESPONSE_BYTES", "2048")
+	assert.Equal(t, 2048, GetMaxResponseBytes())
+
+	t.Setenv("MAX_RESPONSE_BYTES", "0")
+	assert.Equal(t, 0, GetMaxResponseBytes(), "0 turns the cap off")
+
+	t.Setenv("MAX_RESPONSE_BYTES", "-1")
+	assert.Equal(t, 10485760, GetMaxResponseBytes())
+}
+
+func TestGzipSettings(t *testing.T) {
+	t.Setenv("GZIP_LEVEL", "")
+	t.Setenv("GZIP_MIN_SIZE_BYTES", "")
+	assert.Equal(t, 6, GetGzipLevel())
+	assert.Equal(t, 1024, GetGzipMinSize())
+
+	t.Setenv("GZIP_LEVEL", "0")
+	t.Setenv("GZIP_MIN_SIZE_BYTES", "0")
+	assert.Equal(t, 0, GetGzipLevel(), "0 turns compression off")
+	assert.Equal(t, 0, GetGzipMinSize())
+
+	t.Setenv("GZIP_LEVEL", "10")
+	t.Setenv("GZIP_MIN_SIZE_BYTES", "-1")
+	assert.Equal(t, 6, GetGzipLevel())
+	assert.Equal(t, 1024, GetGzipMinSize())
+}
+
+func TestGetMaxQueryRangeDays(t *testing.T) {
+	t.Setenv("MAX_QUERY_RANGE_DAYS", "")
+	assert.Equal(t, 366, GetMaxQueryRangeDays())
+
+	t.Setenv("MAX_QUERY_RANGE_DAYS", "31")
+	assert.Equal(t, 31, GetMaxQueryRangeDays())
+
+	t.Setenv("MAX_QUERY_RANGE_DAYS", "0")
+	assert.Equal(t, 366, GetMaxQueryRangeDays())
+}
+
+func TestStreamLimits(t *testing.T) {
+	t.Setenv("STREAM_MAX_DURATION_SECONDS", "")
+	t.Setenv("STREAM_HEARTBEAT_SECONDS", "")
+	t.Setenv("STREAM_MAX_CONCURRENT_PER_MERCHANT", "")
+	assert.Equal(t, 10*time.Minute, GetStreamMaxDuration())
+	assert.Equal(t, 15*time.Second, GetStreamHeartbeatInterval())
+	assert.Equal(t, 2, GetStreamMaxConcurrent())
+
+	t.Setenv("STREAM_MAX_DURATION_SECONDS", "60")
+	t.Setenv("STREAM_HEARTBEAT_SECONDS", "5")
+	t.Setenv("STREAM_MAX_CONCURRENT_PER_MERCHANT", "4")
+	assert.Equal(t, time.Minute, GetStreamMaxDuration())
+	assert.Equal(t, 5*time.Second, GetStreamHeartbeatInterval())
+	assert.Equal(t, 4, GetStreamMaxConcurrent())
+
+	t.Setenv("STREAM_MAX_DURATION_SECONDS", "0")
+	t.Setenv("STREAM_HEARTBEAT_SECONDS", "abc")
+	t.Setenv("STREAM_MAX_CONCURRENT_PER_MERCHANT", "-1")
+	assert.Equal(t, 10*time.Minute, GetStreamMaxDuration())
+	assert.Equal(t, 15*time.Second, GetStreamHeartbeatInterval())
+	assert.Equal(t, 2, GetStreamMaxConcurrent())
+}
+
+func TestStreamPolling(t *testing.T) {
+	t.Setenv("STREAM_POLL_INTERVAL_SECONDS", "")
+	t.Setenv("STREAM_POLL_BATCH_SIZE", "")
+	assert.Equal(t, 5*time.Second, GetStreamPollInterval())
+	assert.Equal(t, 100, GetStreamPollBatchSize())
+
+	t.Setenv("STREAM_POLL_INTERVAL_SECONDS", "2")
+	t.Setenv("STREAM_POLL_BATCH_SIZE", "25")
+	assert.Equal(t, 2*time.Second, GetStreamPollInterval())
+	assert.Equal(t, 25, GetStreamPollBatchSize())
+
+	t.Setenv("STREAM_POLL_INTERVAL_SECONDS", "0")
+	t.Setenv("STREAM_POLL_BATCH_SIZE", "many")
+	assert.Equal(t, 5*time.Second, GetStreamPollInterval())
+	assert.Equal(t, 100, GetStreamPollBatchSize())
+}
+
+func TestIsPANDisabled(t *testing.T) {
+	t.Setenv("PAN_DISABLED", "")
+	assert.False(t, IsPANDisabled())
+
+	t.Setenv("PAN_DISABLED", "true")
+	assert.True(t, IsPANDisabled())
+}
+
+func TestGetCurrencyJoinStrategy(t *testing.T) {
+	t.Setenv("CURRENCY_JOIN_STRATEGY", "")
+	assert.Equal(t, CurrencyJoinLateral, GetCurrencyJoinStrategy())
+
+	t.Setenv("CURRENCY_JOIN_STRATEGY", "JOIN")
+	assert.Equal(t, CurrencyJoinDirect, GetCurrencyJoinStrategy())
+
+	t.Setenv("CURRENCY_JOIN_STRATEGY", "subquery")
+	assert.Equal(t, CurrencyJoinLateral, GetCurrencyJoinStrategy())
+}
+
+func TestGetAmountRoundingMode(t *testing.T) {
+	t.Setenv("AMOUNT_ROUNDING_MODE", "")
+	assert.Equal(t, RoundingNone, GetAmountRoundingMode(), "amounts stay unrounded unless a mode is configured")
+
+	t.Setenv("AMOUNT_ROUNDING_MODE", "half_up")
+	assert.Equal(t, RoundingHalfUp, GetAmountRoundingMode())
+
+	t.Setenv("AMOUNT_ROUNDING_MODE", "HALF_EVEN")
+	assert.Equal(t, RoundingHalfEven, GetAmountRoundingMode())
+
+	t.Setenv("AMOUNT_ROUNDING_MODE", "truncate")
+	assert.Equal(t, RoundingTruncate, GetAmountRoundingMode())
+
+	t.Setenv("AMOUNT_ROUNDING_MODE", "ceiling")
+	assert.Equal(t, RoundingNone, GetAmountRoundingMode())
+}
+
+func TestGetLogLevel(t *testing.T) {
+	t.Setenv("LOG_LEVEL", "")
+	t.Setenv("GIN_MODE", "release")
+	assert.Equal(t, "info", GetLogLevel())
+
+	t.Setenv("GIN_MODE", "debug")
+	assert.Equal(t, "trace", GetLogLevel())
+
+	t.Setenv("LOG_LEVEL", " WARN ")
+	assert.Equal(t, "warn", GetLogLevel())
+
+	t.Setenv("GIN_MODE", "release")
+	t.Setenv("LOG_LEVEL", "trace")
+	assert.Equal(t, "trace", GetLogLevel())
+
+	t.Setenv("LOG_LEVEL", "verbose")
+	assert.Equal(t, "info", GetLogLevel())
+}
+
+func TestGetEfinanceRangeDays(t *testing.T) {
+	t.Setenv("EFINANCE_MAX_RANGE_DAYS", "")
+	t.Setenv("EFINANCE_DEFAULT_RANGE_DAYS", "")
+	assert.Equal(t, 31, GetEfinanceMaxRangeDays())
+	assert.Equal(t, 7, GetEfinanceDefaultRangeDays())
+
+	t.Setenv("EFINANCE_MAX_RANGE_DAYS", "0")
+	t.Setenv("EFINANCE_DEFAULT_RANGE_DAYS", "nope")
+	assert.Equal(t, 31, GetEfinanceMaxRangeDays())
+	assert.Equal(t, 7, GetEfinanceDefaultRangeDays())
+
+	t.Setenv("EFINANCE_MAX_RANGE_DAYS", "5")
+	t.Setenv("EFINANCE_DEFAULT_RANGE_DAYS", "10")
+	assert.Equal(t, 5, GetEfinanceDefaultRangeDays(), "the default range never exceeds the maximum")
+}
+
+func TestGetBatchInListThreshold(t *testing.T) {
+	t.Setenv("BATCH_IN_LIST_THRESHOLD", "")
+	assert.Less(t, GetBatchInListThreshold(), MaxBatchIDs, "a full batch must take the array path")
+
+	t.Setenv("BATCH_IN_LIST_THRESHOLD", "0")
+	assert.Equal(t, 100, GetBatchInListThreshold())
+
+	t.Setenv("BATCH_IN_LIST_THRESHOLD", "250")
+	assert.Equal(t, 250, GetBatchInListThreshold())
+}
+
+func TestGetStreamMaxConcurrentTotal(t *testing.T) {
+	t.Setenv("DB_MAX_OPEN_CONNS", "25")
+	t.Setenv("STREAM_MAX_CONCURRENT_TOTAL", "")
+	assert.Equal(t, 12, GetStreamMaxConcurrentTotal())
+
+	t.Setenv("STREAM_MAX_CONCURRENT_TOTAL", "20")
+	assert.Equal(t, 20, GetStreamMaxConcurrentTotal())
+
+	t.Setenv("STREAM_MAX_CONCURRENT_TOTAL", "40")
+	assert.Equal(t, 24, GetStreamMaxConcurrentTotal(), "streams must leave a connection free")
+
+	t.Setenv("DB_MAX_OPEN_CONNS", "1")
+	t.Setenv("STREAM_MAX_CONCURRENT_TOTAL", "")
+	assert.Equal(t, 1, GetStreamMaxConcurrentTotal())
+}
+
+func TestGetAllowedOrigins(t *testing.T) {
+	t.Setenv("CORS_ALLOWED_ORIGINS", "")
+	assert.Contains(t, GetAllowedOrigins(), "http://localhost:3001")
+	assert.Contains(t, GetAllowedOrigins(), "https://aken-eu.staging.wizzitdigital.com")
+
+	t.Setenv("CORS_ALLOWED_ORIGINS", " https://app.example.com, ,https://admin.example.com ")
+	assert.Equal(t, []string{"https://app.example.com", "https://admin.example.com"}, GetAllowedOrigins())
+}
+
+func TestGetRollupDatabases(t *testing.T) {
+	t.Setenv("ROLLUP_DATABASES", "")
+	assert.Empty(t, GetRollupDatabases())
+
+	t.Setenv("ROLLUP_DATABASES", " shard-2 = postgres://u:p@db2:5432/pay?sslmode=disable, bad,=postgres://db4,shard-3=postgres://db3/pay")
+	assert.Equal(t, []RollupDatabase{
+		{Name: "shard-2", URL: "postgres://u:p@db2:5432/pay?sslmode=disable"},
+		{Name: "shard-3", URL: "postgres://db3/pay"},
+	}, GetRollupDatabases())
+}
+
+func TestValidateAuthSettings(t *testing.T) {
+	tests := []struct {
+		name        string
+		disableAuth string
+		env         string
+		wantErr     bool
+	}{
+		{name: "auth enabled in production", disableAuth: "", env: "production", wantErr: false},
+		{name: "auth disabled in development", disableAuth: "true", env: "development", wantErr: false},
+		{name: "auth disabled in production", disableAuth: "true", env: "production", wantErr: true},
+		{name: "auth disabled without ENV", disableAuth: "true", env: "", wantErr: true},
+		{name: "auth explicitly enabled", disableAuth: "false", env: "", wantErr: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DISABLE_AUTH", tt.disableAuth)
+			t.Setenv("ENV", tt.env)
+			t.Setenv("JWT_ALGORITHM", "")
+
+			err := ValidateAuthSettings()
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), "DISABLE_AUTH")
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
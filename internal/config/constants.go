@@ -1,6 +1,12 @@
 package config
 
-import "strings"
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+)
 
 // API version constants
 const (
@@ -15,6 +21,19 @@ const (
 	MinPageSize     = 1
 )
 
+// MaxBatchIDs is the maximum number of ids a batch transaction lookup accepts
+const MaxBatchIDs = 500
+
+// MaxBatchRRNMatches is the maximum number of transactions a batch RRN lookup returns. An
+// RRN is not unique, so a batch can match many more rows than it has values.
+const MaxBatchRRNMatches = 2000
+
+// Endpoints with their own default page size, see GetDefaultPageSize
+const (
+	PageSizeEndpointList   = "list"
+	PageSizeEndpointSearch = "search"
+)
+
 // Field mapping constants for transaction queries
 var FieldMappings = map[string]string{
 	"payment_tx_log_id": "p.payment_tx_log_id",
@@ -24,6 +43,8 @@ var FieldMappings = map[string]string{
 	"merchant_id":       "m.merchant_id",
 	"merchant_name":     "m.name",
 	"device_id":         "p.device_id",
+	"profile_id":        "p.profile_id",
+	"payment_tx_ref":    "p.payment_tx_ref",
 	"response_code":     "p.result_code",
 	"auth_code":         "p.auth_code",
 	"rrn":               "p.rrn",
@@ -31,6 +52,7 @@ var FieldMappings = map[string]string{
 	"reversed":          "CASE WHEN p.reversed_tx_log_id IS NOT NULL THEN true ELSE false END",
 	"settlement_status": "COALESCE(p.settlement_status, 'pending')",
 	"stan":              "p.stan",
+	"bin_id":            "p.bin_id",
 	"user_ref":          "p.meta->>'reference'",
 	"meta":              "p.meta",
 	"settlement_date":   "p.settlement_date",
@@ -38,6 +60,56 @@ var FieldMappings = map[string]string{
 	"currency_info":     "p.currency_code", // Currency info is computed from currency_code
 }
 
+// Mapped fields whose column can be NULL, the only fields the isnull and isnotnull filter
+// operators accept
+var NullableFilterFields = map[string]bool{
+	"auth_code":      true,
+	"response_code":  true,
+	"device_id":      true,
+	"profile_id":     true,
+	"payment_tx_ref": true,
+	"bin_id":         true,
+	"user_ref":       true,
+}
+
+// Selectable fields that are plain payment_tx_log columns without an entry in FieldMappings
+var ColumnFields = map[string]string{
+	"created_at":  "p.created_at",
+	"updated_at":  "p.updated_at",
+	"description": "p.description",
+}
+
+// Computed fields that can be used in sort but don't map to a single column,
+// keyed by field name with the SQL expression to order by
+var SortableComputedFields = map[string]string{
+	"success": "CASE WHEN p.result_code IN ('00', '10') THEN 1 ELSE 0 END",
+}
+
+// Facets that can be requested on the transaction list with ?facets=
+var SupportedFacets = map[string]bool{
+	"day": true,
+}
+
+// Metrics the merchant summary can be limited to with ?metrics=
+var SummaryMetrics = map[string]bool{
+	"count":        true,
+	"sum":          true,
+	"success_rate": true,
+	"date_range":   true,
+}
+
+// Columns the transaction list can filter and sort dates on with ?date_field=
+var DateFields = map[string]bool{
+	"created_at": true,
+	"updated_at": true,
+}
+
+// Bucket sizes accepted by the analytics timeseries endpoint with ?interval=
+var TimeseriesIntervals = map[string]bool{
+	"day":  true,
+	"hour": true,
+}
+
 // Filter operator mappings
 var FilterOperators = map[string]string{
 	"eq":        "=",
@@ -53,6 +125,7 @@ var FilterOperators = map[string]string{
 	"isnull":    "IS NULL",
 	"isnotnull": "IS NOT NULL",
 	"between":   "BETWEEN",
+	"near":      "BETWEEN", // amount:near:center,tolerance
 }
 
 // PAN format mappings
@@ -61,12 +134,60 @@ var PANFormats = map[string]string{
 	"pan_id_only":       "CONCAT('***** ',p.pan_id)",
 }
 
+// PANPlaceholder is returned in place of the PAN when PAN_DISABLED is set
+const PANPlaceholder = "**** **** **** ****"
+
 // Default fields to return if none specified
 var DefaultFields = []string{
 	"payment_tx_log_id", "tx_log_type", "tx_date_time", "amount",
 	"merchant_name", "response_code", "rrn", "pan", "currency_info",
 }
 
+// Fields of a scheduled report that selects none: DefaultFields without pan, since reports
+// leave the service unattended
+var DefaultReportFields = []string{
+	"payment_tx_log_id", "tx_log_type", "tx_date_time", "amount",
+	"merchant_name", "response_code", "rrn", "currency_info",
+}
+
+// Named field presets, requested as fields=@name
+var FieldPresets = map[string][]string{
+	"minimal": {
+		"payment_tx_log_id", "tx_date_time", "amount", "response_code",
+	},
+	"reconciliation": {
+		"payment_tx_log_id", "tx_date_time", "amount", "currency_info", "rrn", "stan",
+		"auth_code", "response_code", "merchant_id", "device_id",
+	},
+}
+
+// ExpandFieldPresets replaces @preset entries in fields with the preset's field list,
+// dropping duplicates. An unknown preset name is an error.
+func ExpandFieldPresets(fields []string) ([]string, error) {
+	expanded := make([]string, 0, len(fields))
+	seen := make(map[string]bool)
+
+	for _, field := range fields {
+		names := []string{field}
+		if strings.HasPrefix(field, "@") {
+			preset, exists := FieldPresets[strings.TrimPrefix(field, "@")]
+			if !exists {
+				return nil, fmt.Errorf("unknown field preset: %s", field)
+			}
+			names = preset
+		}
+
+		for _, name := range names {
+			if !seen[name] {
+				seen[name] = true
+				expanded = append(expanded, name)
+			}
+		}
+	}
+
+	return expanded, nil
+}
+
 // Error codes
 const (
 	ErrorCodeAuthFailed         = "AUTHENTICATION_FAILED"
@@ -74,6 +195,7 @@ const (
 	ErrorCodeInvalidFilter      = "INVALID_FILTER"
 	ErrorCodeInvalidField       = "INVALID_FIELD"
 	ErrorCodeInvalidSort        = "INVALID_SORT"
+	ErrorCodeInvalidTimezone    = "INVALID_TIMEZONE"
 	ErrorCodeNotFound           = "NOT_FOUND"
 	ErrorCodeTxNotFound         = "TRANSACTION_NOT_FOUND"
 	ErrorCodeMerchantNotFound   = "MERCHANT_NOT_FOUND"
@@ -83,6 +205,7 @@ const (
 	ErrorCodeBadRequest         = "BAD_REQUEST"
 	ErrorCodeInvalidRequest     = "INVALID_REQUEST"
 	ErrorCodeServiceUnavailable = "SERVICE_UNAVAILABLE"
+	ErrorCodeRateLimited        = "RATE_LIMIT_EXCEEDED"
 )
 
 // User-friendly error messages
@@ -92,6 +215,7 @@ var ErrorMessages = map[string]string{
 	ErrorCodeInvalidFilter:      "Invalid filter expression. Please check your filter syntax.",
 	ErrorCodeInvalidField:       "Invalid field specified. Please check the field name.",
 	ErrorCodeInvalidSort:        "Invalid sort expression. Please check your sort syntax.",
+	ErrorCodeInvalidTimezone:    "Invalid timezone. Please use an IANA time zone name such as Africa/Johannesburg.",
 	ErrorCodeNotFound:           "The requested resource was not found.",
 	ErrorCodeTxNotFound:         "Transaction not found.",
 	ErrorCodeMerchantNotFound:   "Merchant not found.",
@@ -101,6 +225,7 @@ var ErrorMessages = map[string]string{
 	ErrorCodeBadRequest:         "Invalid request. Please check your parameters.",
 	ErrorCodeInvalidRequest:     "Invalid request format or missing required fields.",
 	ErrorCodeServiceUnavailable: "Service temporarily unavailable. Please try again later.",
+	ErrorCodeRateLimited:        "Rate limit exceeded. Please retry after the time given in Retry-After.",
 }
 
 // Rate limiting constants
@@ -111,6 +236,84 @@ const (
 	RateLimitWindow     = 3600  // seconds (1 hour)
 )
 
+// WriteMethods are the HTTP methods that modify data; a successful write invalidates the
+// merchant's cached responses
+var WriteMethods = []string{http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+// CORSAllowedMethods are the HTTP methods browsers may use cross-origin: reads, preflights
+// and every write method
+var CORSAllowedMethods = append([]string{http.MethodGet, http.MethodOptions}, WriteMethods...)
+
+// CurrencySymbols maps ISO 4217 numeric currency codes, without leading zeros, to the
+// symbol amounts are formatted with
+var CurrencySymbols = map[string]string{
+	"710": "R",   // ZAR
+	"818": "E£",  // EGP
+	"840": "$",   // USD
+	"978": "€",   // EUR
+	"826": "£",   // GBP
+	"392": "¥",   // JPY
+	"356": "₹",   // INR
+	"404": "KSh", // KES
+	"566": "₦",   // NGN
+	"936": "GH₵", // GHS
+	"72":  "P",   // BWP
+	"516": "N$",  // NAD
+	"894": "K",   // ZMW
+	"454": "MK",  // MWK
+	"834": "TSh", // TZS
+	"800": "USh", // UGX
+	"480": "Rs",  // MUR
+}
+
+// CurrencySymbol returns the symbol of a numeric currency code such as "710" or "0710". An
+// unknown currency falls back to its ISO alphabetic code (the currency table's curr_short)
+// when known, and otherwise to the numeric code.
+func CurrencySymbol(code, alphaCode string) string {
+	if symbol, exists := CurrencySymbols[strings.TrimLeft(strings.TrimSpace(code), "0")]; exists {
+		return symbol
+	}
+	if alphaCode != "" {
+		return alphaCode
+	}
+	return code
+}
+
+// RoundAmount rounds a derived amount to the given number of decimal places with a
+// rounding mode from GetAmountRoundingMode; amounts in minor units use 0 places. The value
+// is first fixed to 6 places so binary noise (1.005 stored as 1.00499...) does not decide
+// which way a half rounds. RoundingNone returns the value as is.
+func RoundAmount(value float64, decimals int, mode string) float64 {
+	if mode == RoundingNone {
+		return value
+	}
+
+	scale := math.Pow10(decimals)
+	scaled, err := strconv.ParseFloat(strconv.FormatFloat(value*scale, 'f', 6, 64), 64)
+	if err != nil {
+		scaled = value * scale
+	}
+
+	switch mode {
+	case RoundingHalfEven:
+		scaled = math.RoundToEven(scaled)
+	case RoundingTruncate:
+		scaled = math.Trunc(scaled)
+	default:
+		scaled = math.Round(scaled)
+	}
+	return scaled / scale
+}
+
+// CORSAllowedHeaders are the request headers browsers may send cross-origin
+var CORSAllowedHeaders = []string{"Origin", "Content-Type", "Accept", "Authorization", "X-API-Key", "X-Request-ID", "Idempotency-Key", "If-None-Match"}
+
+// CORSExposedHeaders are the response headers cross-origin scripts may read
+var CORSExposedHeaders = []string{
+	"ETag", "Retry-After", "X-Request-ID", "Idempotent-Replayed", "X-Export-ID", "X-Estimated-Duration",
+	"X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset", "X-RateLimit-Window",
+}
+
 // GetUserFriendlyMessage returns a user-friendly error message for the given error code
 func GetUserFriendlyMessage(errorCode string) string {
 	if message, exists := ErrorMessages[errorCode]; exists {
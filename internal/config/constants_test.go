@@ -24,6 +24,7 @@ func TestFieldMappings(t *testing.T) {
 		"merchant_id", "merchant_name", "device_id", "response_code",
 		"auth_code", "rrn", "pan", "reversed", "settlement_status",
 		"stan", "user_ref", "meta", "settlement_date", "card_type",
+		"profile_id", "payment_tx_ref",
 	}
 
 	for _, field := range expectedFields {
@@ -39,7 +40,7 @@ func TestFilterOperators(t *testing.T) {
 	// Test that all expected operators exist
 	expectedOperators := []string{
 		"eq", "ne", "gt", "gte", "lt", "lte",
-		"like", "ilike", "in", "nin", "isnull", "isnotnull", "between",
+		"like", "ilike", "in", "nin", "isnull", "isnotnull", "between", "near",
 	}
 
 	for _, op := range expectedOperators {
@@ -84,7 +85,7 @@ func TestErrorCodes(t *testing.T) {
 	// Test that all error codes are defined and not empty
 	errorCodes := []string{
 		ErrorCodeAuthFailed, ErrorCodeAuthzFailed, ErrorCodeInvalidFilter,
-		ErrorCodeInvalidField, ErrorCodeInvalidSort, ErrorCodeNotFound,
+		ErrorCodeInvalidField, ErrorCodeInvalidSort, ErrorCodeInvalidTimezone, ErrorCodeNotFound,
 		ErrorCodeTxNotFound, ErrorCodeMerchantNotFound, ErrorCodeDatabaseError,
 		ErrorCodeInternalError, ErrorCodeNotImplemented, ErrorCodeBadRequest,
 	}
@@ -147,6 +148,7 @@ func TestFilterOperatorValues(t *testing.T) {
 		{"in", "IN"},
 		{"nin", "NOT IN"},
 		{"between", "BETWEEN"},
+		{"near", "BETWEEN"},
 		{"isnull", "IS NULL"},
 		{"isnotnull", "IS NOT NULL"},
 	}
@@ -159,3 +161,92 @@ func TestFilterOperatorValues(t *testing.T) {
 		})
 	}
 }
+
+func TestGetMaxFilterOrClauses(t *testing.T) {
+	t.Setenv("MAX_FILTER_OR_CLAUSES", "")
+	assert.Equal(t, 20, GetMaxFilterOrClauses())
+
+	t.Setenv("MAX_FILTER_OR_CLAUSES", "5")
+	assert.Equal(t, 5, GetMaxFilterOrClauses())
+
+	t.Setenv("MAX_FILTER_OR_CLAUSES", "invalid")
+	assert.Equal(t, 20, GetMaxFilterOrClauses())
+}
+
+func TestGetMaxSearchLimits(t *testing.T) {
+	t.Setenv("MAX_SEARCH_BODY_BYTES", "")
+	t.Setenv("MAX_SEARCH_QUERY_DEPTH", "")
+	assert.Equal(t, int64(1048576), GetMaxSearchBodyBytes())
+	assert.Equal(t, 8, GetMaxSearchQueryDepth())
+
+	t.Setenv("MAX_SEARCH_BODY_BYTES", "4096")
+	t.Setenv("MAX_SEARCH_QUERY_DEPTH", "3")
+	assert.Equal(t, int64(4096), GetMaxSearchBodyBytes())
+	assert.Equal(t, 3, GetMaxSearchQueryDepth())
+
+	t.Setenv("MAX_SEARCH_BODY_BYTES", "0")
+	t.Setenv("MAX_SEARCH_QUERY_DEPTH", "invalid")
+	assert.Equal(t, int64(1048576), GetMaxSearchBodyBytes())
+	assert.Equal(t, 8, GetMaxSearchQueryDepth())
+}
+
+func TestExpandFieldPresets(t *testing.T) {
+	fields, err := ExpandFieldPresets([]string{"@minimal"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"payment_tx_log_id", "tx_date_time", "amount", "response_code"}, fields)
+
+	fields, err = ExpandFieldPresets([]string{"merchant_name", "@reconciliation", "amount"})
+	assert.NoError(t, err)
+	assert.Equal(t, append([]string{"merchant_name"}, FieldPresets["reconciliation"]...), fields)
+
+	_, err = ExpandFieldPresets([]string{"@unknown"})
+	assert.Error(t, err)
+}
+
+func TestFieldPresets_OnlyKnownFields(t *testing.T) {
+	for name, fields := range FieldPresets {
+		for _, field := range fields {
+			_, mapped := FieldMappings[field]
+			assert.True(t, mapped || field == "currency_info", "preset %s has unknown field %s", name, field)
+		}
+	}
+}
+
+func TestCurrencySymbol(t *testing.T) {
+	assert.Equal(t, "R", CurrencySymbol("710", "ZAR"))
+	assert.Equal(t, "R", CurrencySymbol("0710", "ZAR"), "leading zeros are ignored")
+	assert.Equal(t, "E£", CurrencySymbol("818", "EGP"))
+	assert.Equal(t, "P", CurrencySymbol("072", "BWP"))
+	assert.Equal(t, "AED", CurrencySymbol("784", "AED"), "unknown currencies fall back to the ISO code")
+	assert.Equal(t, "784", CurrencySymbol("784", ""))
+}
+
+func TestRoundAmount(t *testing.T) {
+	tests := []struct {
+		value    float64
+		decimals int
+		halfUp   float64
+		halfEven float64
+		truncate float64
+	}{
+		{value: 2.5, decimals: 0, halfUp: 3, halfEven: 2, truncate: 2},
+		{value: 3.5, decimals: 0, halfUp: 4, halfEven: 4, truncate: 3},
+		{value: 3.49, decimals: 0, halfUp: 3, halfEven: 3, truncate: 3},
+		{value: 3.51, decimals: 0, halfUp: 4, halfEven: 4, truncate: 3},
+		{value: -2.5, decimals: 0, halfUp: -3, halfEven: -2, truncate: -2},
+		{value: 1.005, decimals: 2, halfUp: 1.01, halfEven: 1, truncate: 1},
+		{value: 1.015, decimals: 2, halfUp: 1.02, halfEven: 1.02, truncate: 1.01},
+		{value: 12.3456, decimals: 2, halfUp: 12.35, halfEven: 12.35, truncate: 12.34},
+		{value: 100, decimals: 2, halfUp: 100, halfEven: 100, truncate: 100},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.halfUp, RoundAmount(tt.value, tt.decimals, RoundingHalfUp), "half_up %v", tt.value)
+		assert.Equal(t, tt.halfEven, RoundAmount(tt.value, tt.decimals, RoundingHalfEven), "half_even %v", tt.value)
+		assert.Equal(t, tt.truncate, RoundAmount(tt.value, tt.decimals, RoundingTruncate), "truncate %v", tt.value)
+	}
+
+	assert.Equal(t, 3.0, RoundAmount(2.5, 0, "unknown"), "unknown modes round half-up")
+	assert.Equal(t, 1.005, RoundAmount(1.005, 2, RoundingNone))
+	assert.Equal(t, 2.5, RoundAmount(2.5, 0, RoundingNone))
+}
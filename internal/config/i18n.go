@@ -0,0 +1,120 @@
+package config
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultLanguage is the language of ErrorMessages, used when no requested language is supported
+const DefaultLanguage = "en"
+
+// LocalizedErrorMessages holds translations of ErrorMessages keyed by language.
+// Codes missing from a language fall back to English.
+var LocalizedErrorMessages = map[string]map[string]string{
+	"ar": {
+		ErrorCodeAuthFailed:         "فشلت المصادقة. يرجى التحقق من بيانات الاعتماد الخاصة بك.",
+		ErrorCodeAuthzFailed:        "تم رفض الوصول. ليس لديك إذن للوصول إلى هذا المورد.",
+		ErrorCodeInvalidFilter:      "تعبير التصفية غير صالح. يرجى التحقق من صيغة التصفية.",
+		ErrorCodeInvalidField:       "تم تحديد حقل غير صالح. يرجى التحقق من اسم الحقل.",
+		ErrorCodeInvalidSort:        "تعبير الترتيب غير صالح. يرجى التحقق من صيغة الترتيب.",
+		ErrorCodeInvalidTimezone:    "المنطقة الزمنية غير صالحة. يرجى استخدام اسم منطقة زمنية من IANA مثل Africa/Cairo.",
+		ErrorCodeNotFound:           "لم يتم العثور على المورد المطلوب.",
+		ErrorCodeTxNotFound:         "لم يتم العثور على المعاملة.",
+		ErrorCodeMerchantNotFound:   "لم يتم العثور على التاجر.",
+		ErrorCodeDatabaseError:      "الخدمة غير متاحة مؤقتًا. يرجى المحاولة مرة أخرى لاحقًا.",
+		ErrorCodeInternalError:      "حدث خطأ داخلي. يرجى المحاولة مرة أخرى لاحقًا.",
+		ErrorCodeNotImplemented:     "هذه الميزة غير متوفرة بعد.",
+		ErrorCodeBadRequest:         "طلب غير صالح. يرجى التحقق من المعلمات.",
+		ErrorCodeInvalidRequest:     "تنسيق الطلب غير صالح أو توجد حقول مطلوبة مفقودة.",
+		ErrorCodeServiceUnavailable: "الخدمة غير متاحة مؤقتًا. يرجى المحاولة مرة أخرى لاحقًا.",
+		ErrorCodeRateLimited:        "تم تجاوز حد الطلبات. يرجى إعادة المحاولة بعد الوقت المحدد في Retry-After.",
+	},
+	"fr": {
+		ErrorCodeAuthFailed:         "Échec de l'authentification. Veuillez vérifier vos identifiants.",
+		ErrorCodeAuthzFailed:        "Accès refusé. Vous n'êtes pas autorisé à accéder à cette ressource.",
+		ErrorCodeInvalidFilter:      "Expression de filtre invalide. Veuillez vérifier la syntaxe du filtre.",
+		ErrorCodeInvalidField:       "Champ spécifié invalide. Veuillez vérifier le nom du champ.",
+		ErrorCodeInvalidSort:        "Expression de tri invalide. Veuillez vérifier la syntaxe du tri.",
+		ErrorCodeInvalidTimezone:    "Fuseau horaire invalide. Veuillez utiliser un nom de fuseau IANA tel que Europe/Paris.",
+		ErrorCodeNotFound:           "La ressource demandée est introuvable.",
+		ErrorCodeTxNotFound:         "Transaction introuvable.",
+		ErrorCodeMerchantNotFound:   "Marchand introuvable.",
+		ErrorCodeDatabaseError:      "Service temporairement indisponible. Veuillez réessayer plus tard.",
+		ErrorCodeInternalError:      "Une erreur interne s'est produite. Veuillez réessayer plus tard.",
+		ErrorCodeNotImplemented:     "Cette fonctionnalité n'est pas encore disponible.",
+		ErrorCodeBadRequest:         "Requête invalide. Veuillez vérifier vos paramètres.",
+		ErrorCodeInvalidRequest:     "Format de requête invalide ou champs obligatoires manquants.",
+		ErrorCodeServiceUnavailable: "Service temporairement indisponible. Veuillez réessayer plus tard.",
+		ErrorCodeRateLimited:        "Limite de requêtes dépassée. Veuillez réessayer après le délai indiqué dans Retry-After.",
+	},
+}
+
+// ResolveLanguage returns the most preferred language of an Accept-Language header that
+// error messages are available in, or DefaultLanguage
+func ResolveLanguage(acceptLanguage string) string {
+	for _, language := range parseAcceptLanguage(acceptLanguage) {
+		if language == DefaultLanguage {
+			return DefaultLanguage
+		}
+		if _, exists := LocalizedErrorMessages[language]; exists {
+			return language
+		}
+	}
+	return DefaultLanguage
+}
+
+// GetLocalizedMessage returns the user-friendly message for errorCode in language, falling
+// back to English when the language or the code has no translation
+func GetLocalizedMessage(errorCode, language string) string {
+	if message, exists := LocalizedErrorMessages[language][errorCode]; exists {
+		return message
+	}
+	return GetUserFriendlyMessage(errorCode)
+}
+
+// parseAcceptLanguage returns the primary subtags of an Accept-Language header, lowercased
+// and ordered by quality. Languages with q=0 and malformed entries are skipped.
+func parseAcceptLanguage(header string) []string {
+	type weighted struct {
+		language string
+		quality  float64
+	}
+
+	var languages []weighted
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(strings.TrimSpace(part), ";")
+		tag := strings.ToLower(strings.TrimSpace(params[0]))
+		if tag == "" {
+			continue
+		}
+
+		quality := 1.0
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if value, ok := strings.CutPrefix(param, "q="); ok {
+				parsed, err := strconv.ParseFloat(value, 64)
+				if err != nil {
+					parsed = 0
+				}
+				quality = parsed
+			}
+		}
+		if quality <= 0 {
+			continue
+		}
+
+		language, _, _ := strings.Cut(tag, "-")
+		languages = append(languages, weighted{language: language, quality: quality})
+	}
+
+	sort.SliceStable(languages, func(i, j int) bool {
+		return languages[i].quality > languages[j].quality
+	})
+
+	result := make([]string, len(languages))
+	for i, l := range languages {
+		result[i] = l.language
+	}
+	return result
+}
@@ -0,0 +1,46 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocalizedErrorMessages_CoverEveryErrorCode(t *testing.T) {
+	for language, messages := range LocalizedErrorMessages {
+		for code := range ErrorMessages {
+			assert.NotEmpty(t, messages[code], "%s translation missing for %s", language, code)
+		}
+	}
+}
+
+func TestResolveLanguage(t *testing.T) {
+	tests := map[string]string{
+		"":                        "en",
+		"ar":                      "ar",
+		"ar-EG,ar;q=0.9,en;q=0.8": "ar",
+		"fr-FR":                   "fr",
+		"de-DE,fr;q=0.7":          "fr",
+		"en-US,ar;q=0.9":          "en",
+		"ar;q=0.5,fr;q=0.8":       "fr",
+		"ar;q=0,fr;q=0":           "en",
+		"de-DE,es;q=0.9,*;q=0.1":  "en",
+		"ar;q=abc,fr;q=0.2":       "fr",
+		" FR ; q=1 , en ; q=0.5 ": "fr",
+	}
+
+	for header, expected := range tests {
+		assert.Equal(t, expected, ResolveLanguage(header), header)
+	}
+}
+
+func TestGetLocalizedMessage(t *testing.T) {
+	assert.Equal(t, "لم يتم العثور على المعاملة.", GetLocalizedMessage(ErrorCodeTxNotFound, "ar"))
+	assert.Equal(t, "Transaction introuvable.", GetLocalizedMessage(ErrorCodeTxNotFound, "fr"))
+}
+
+func TestGetLocalizedMessage_FallsBackToEnglish(t *testing.T) {
+	assert.Equal(t, ErrorMessages[ErrorCodeTxNotFound], GetLocalizedMessage(ErrorCodeTxNotFound, "en"))
+	assert.Equal(t, ErrorMessages[ErrorCodeTxNotFound], GetLocalizedMessage(ErrorCodeTxNotFound, "de"))
+	assert.Equal(t, GetUserFriendlyMessage("UNKNOWN_CODE"), GetLocalizedMessage("UNKNOWN_CODE", "ar"))
+}
@@ -53,6 +53,16 @@ func GetRedisTTL() time.Duration {
 	return time.Duration(ttl) * time.Second
 }
 
+// GetCurrencyCacheTTL returns how long the currency reference data is cached; the currency
+// table rarely changes, so the default is a day
+func GetCurrencyCacheTTL() time.Duration {
+	ttl, err := strconv.Atoi(getEnvOrDefault("CURRENCY_CACHE_TTL_SECONDS", "86400"))
+	if err != nil || ttl < 1 {
+		ttl = 86400
+	}
+	return time.Duration(ttl) * time.Second
+}
+
 // GetRedisKeyPrefix returns the prefix for Redis keys
 func GetRedisKeyPrefix() string {
 	return getEnvOrDefault("REDIS_KEY_PREFIX", "aken:reporting:")
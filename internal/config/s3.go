@@ -0,0 +1,48 @@
+package config
+
+import (
+	"strconv"
+	"time"
+)
+
+// S3Config holds the S3-compatible object storage used for export delivery
+type S3Config struct {
+	Endpoint        string // host[:port], without scheme
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+	Region          string
+	UseSSL          bool
+	KeyPrefix       string        // Prepended to every object key by the uploader
+	PresignTTL      time.Duration // Lifetime of download URLs returned by the export status endpoint
+}
+
+// GetS3Config returns S3 export storage configuration from environment variables
+func GetS3Config() *S3Config {
+	useSSL, err := strconv.ParseBool(GetEnvOrDefault("S3_USE_SSL", "true"))
+	if err != nil {
+		useSSL = true
+	}
+
+	presignTTL, err := strconv.Atoi(GetEnvOrDefault("S3_PRESIGN_TTL", "900"))
+	if err != nil || presignTTL < 1 {
+		presignTTL = 900
+	}
+
+	return &S3Config{
+		Endpoint:        GetEnvOrDefault("S3_ENDPOINT", ""),
+		Bucket:          GetEnvOrDefault("S3_BUCKET", ""),
+		AccessKeyID:     GetEnvOrDefault("S3_ACCESS_KEY_ID", ""),
+		SecretAccessKey: GetEnvOrDefault("S3_SECRET_ACCESS_KEY", ""),
+		Region:          GetEnvOrDefault("S3_REGION", ""),
+		UseSSL:          useSSL,
+		KeyPrefix:       GetEnvOrDefault("S3_KEY_PREFIX", "exports/"),
+		PresignTTL:      time.Duration(presignTTL) * time.Second,
+	}
+}
+
+// IsS3ExportEnabled reports whether exports can be delivered to object storage
+func IsS3ExportEnabled() bool {
+	cfg := GetS3Config()
+	return cfg.Endpoint != "" && cfg.Bucket != ""
+}
@@ -15,10 +15,19 @@ import (
 var DB *gorm.DB      // PostgreSQL database for v2 APIs
 var MySQLDB *gorm.DB // MySQL database for efinance v1 APIs
 
-// ConnectDB initializes both database connections
+// RollupDB is an additional PostgreSQL shard merged into provisioner roll-ups
+type RollupDB struct {
+	Name string
+	DB   *gorm.DB
+}
+
+var RollupDBs []RollupDB // Shards from ROLLUP_DATABASES, in configured order
+
+// ConnectDB initializes the database connections
 func ConnectDB() {
 	connectPostgreSQL()
 	connectMySQL()
+	connectRollupDatabases()
 }
 
 // connectPostgreSQL initializes the PostgreSQL connection for v2 APIs
@@ -53,6 +62,8 @@ func connectPostgreSQL() {
 		log.Printf("Continuing without PostgreSQL connection...")
 	} else {
 		log.Println("✅ PostgreSQL database connection established successfully")
+		configurePool(DB, "PostgreSQL")
+		applyReadOnlyGuard(DB, "PostgreSQL")
 	}
 }
 
@@ -88,7 +99,77 @@ func connectMySQL() {
 		log.Printf("Continuing without MySQL connection...")
 	} else {
 		log.Println("✅ MySQL database connection established successfully")
+		configurePool(MySQLDB, "MySQL")
+		applyReadOnlyGuard(MySQLDB, "MySQL")
+	}
+}
+
+// connectRollupDatabases opens the roll-up shards. They are opened without the initial
+// ping, so a shard that is down at startup is still queried and reported as skipped in the
+// summaries instead of silently leaving its data out.
+func connectRollupDatabases() {
+	for _, rollup := range config.GetRollupDatabases() {
+		db, err := gorm.Open(postgres.Open(rollup.URL), &gorm.Config{DisableAutomaticPing: true})
+		if err != nil {
+			log.Printf("⚠️ Failed to open roll-up database %s: %v", rollup.Name, err)
+			continue
+		}
+		log.Printf("✅ Roll-up database %s configured", rollup.Name)
+		name := "roll-up " + rollup.Name
+		configurePool(db, name)
+		applyReadOnlyGuard(db, name)
+		RollupDBs = append(RollupDBs, RollupDB{Name: rollup.Name, DB: db})
+	}
+}
+
+// configurePool applies the connection pool limits from config to db, so load can't open
+// more connections than the database allows
+func configurePool(db *gorm.DB, name string) {
+	sqlDB, err := db.DB()
+	if err != nil {
+		log.Printf("⚠️ Failed to configure the %s connection pool: %v", name, err)
+		return
+	}
+
+	maxOpen, maxIdle, connMaxLifetime := config.GetDBPoolConfig()
+	sqlDB.SetMaxOpenConns(maxOpen)
+	sqlDB.SetMaxIdleConns(maxIdle)
+	sqlDB.SetConnMaxLifetime(connMaxLifetime)
+	log.Printf("%s connection pool: max_open=%d max_idle=%d conn_max_lifetime=%s", name, maxOpen, maxIdle, connMaxLifetime)
+}
+
+// applyReadOnlyGuard registers the read-only guard on db when read-only mode is enabled
+func applyReadOnlyGuard(db *gorm.DB, name string) {
+	if !config.IsReadOnlyModeEnabled() {
+		log.Printf("⚠️ Read-only mode disabled: writes to %s source tables are allowed", name)
+		return
+	}
+	if err := RegisterReadOnlyGuard(db); err != nil {
+		log.Printf("⚠️ Failed to enable read-only mode on %s: %v", name, err)
+	}
+}
+
+// CloseDB closes the connection pools of the databases. Databases that were never
+// connected are skipped.
+func CloseDB() error {
+	var firstErr error
+	dbs := map[string]*gorm.DB{"PostgreSQL": DB, "MySQL": MySQLDB}
+	for _, rollup := range RollupDBs {
+		dbs["roll-up "+rollup.Name] = rollup.DB
+	}
+	for name, db := range dbs {
+		if db == nil {
+			continue
+		}
+		sqlDB, err := db.DB()
+		if err == nil {
+			err = sqlDB.Close()
+		}
+		if err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("failed to close %s connection: %v", name, err)
+		}
 	}
+	return firstErr
 }
 
 // testConnection verifies the database connection works
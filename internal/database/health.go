@@ -9,10 +9,22 @@ import (
 
 // HealthStatus represents the health status of the database
 type HealthStatus struct {
-	Status    string    `json:"status"`
-	Message   string    `json:"message"`
-	Timestamp time.Time `json:"timestamp"`
-	Latency   int64     `json:"latency_ms"`
+	Status    string               `json:"status"`
+	Message   string               `json:"message"`
+	Timestamp time.Time            `json:"timestamp"`
+	Latency   int64                `json:"latency_ms"`
+	Pools     map[string]PoolStats `json:"pools,omitempty"` // Connection pool of each connected database
+}
+
+// PoolStats is a snapshot of a connection pool, to diagnose pool starvation: a growing
+// wait_count with in_use at max_open means requests are queueing for a connection
+type PoolStats struct {
+	MaxOpen        int   `json:"max_open"`
+	Open           int   `json:"open"`
+	InUse          int   `json:"in_use"`
+	Idle           int   `json:"idle"`
+	WaitCount      int64 `json:"wait_count"`
+	WaitDurationMs int64 `json:"wait_duration_ms"`
 }
 
 // CheckDatabaseHealth checks if both databases are healthy
@@ -25,6 +37,7 @@ func CheckDatabaseHealth() HealthStatus {
 
 	postgresHealthy := checkSingleDatabase(DB, "PostgreSQL", ctx)
 	mysqlHealthy := checkSingleDatabase(MySQLDB, "MySQL", ctx)
+	pools := poolStats(map[string]*gorm.DB{DependencyPostgreSQL: DB, DependencyMySQL: MySQLDB})
 
 	// Determine overall health status
 	if postgresHealthy && mysqlHealthy {
@@ -33,6 +46,7 @@ func CheckDatabaseHealth() HealthStatus {
 			Message:   "Both databases are responding normally",
 			Timestamp: time.Now(),
 			Latency:   time.Since(start).Milliseconds(),
+			Pools:     pools,
 		}
 	} else if postgresHealthy || mysqlHealthy {
 		return HealthStatus{
@@ -40,6 +54,7 @@ func CheckDatabaseHealth() HealthStatus {
 			Message:   "One database is unavailable but service can continue",
 			Timestamp: time.Now(),
 			Latency:   time.Since(start).Milliseconds(),
+			Pools:     pools,
 		}
 	} else {
 		return HealthStatus{
@@ -47,10 +62,49 @@ func CheckDatabaseHealth() HealthStatus {
 			Message:   "Both databases are unavailable",
 			Timestamp: time.Now(),
 			Latency:   time.Since(start).Milliseconds(),
+			Pools:     pools,
 		}
 	}
 }
 
+// Dependency names reported by CheckDependencies
+const (
+	DependencyPostgreSQL = "postgresql"
+	DependencyMySQL      = "mysql"
+)
+
+// CheckDependencies checks each database separately. PostgreSQL backs the v2 APIs; MySQL is
+// only used by the v1 efinance APIs.
+func CheckDependencies() map[string]HealthStatus {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return map[string]HealthStatus{
+		DependencyPostgreSQL: checkDependency(ctx, DB, "PostgreSQL"),
+		DependencyMySQL:      checkDependency(ctx, MySQLDB, "MySQL"),
+	}
+}
+
+// checkDependency returns the health of a single database
+func checkDependency(ctx context.Context, db *gorm.DB, dbType string) HealthStatus {
+	start := time.Now()
+
+	if checkSingleDatabase(db, dbType, ctx) {
+		return HealthStatus{
+			Status:    "healthy",
+			Message:   dbType + " is responding normally",
+			Timestamp: time.Now(),
+			Latency:   time.Since(start).Milliseconds(),
+		}
+	}
+	return HealthStatus{
+		Status:    "unhealthy",
+		Message:   dbType + " is unavailable",
+		Timestamp: time.Now(),
+		Latency:   time.Since(start).Milliseconds(),
+	}
+}
+
 // checkSingleDatabase checks the health of a single database
 func checkSingleDatabase(db *gorm.DB, dbType string, ctx context.Context) bool {
 	if db == nil {
@@ -68,6 +122,35 @@ func checkSingleDatabase(db *gorm.DB, dbType string, ctx context.Context) bool {
 	return err == nil
 }
 
+// poolStats returns the pool stats of each connected database, keyed by dependency name,
+// or nil when none is connected
+func poolStats(dbs map[string]*gorm.DB) map[string]PoolStats {
+	var pools map[string]PoolStats
+	for name, db := range dbs {
+		if db == nil {
+			continue
+		}
+		sqlDB, err := db.DB()
+		if err != nil {
+			continue
+		}
+
+		stats := sqlDB.Stats()
+		if pools == nil {
+			pools = make(map[string]PoolStats)
+		}
+		pools[name] = PoolStats{
+			MaxOpen:        stats.MaxOpenConnections,
+			Open:           stats.OpenConnections,
+			InUse:          stats.InUse,
+			Idle:           stats.Idle,
+			WaitCount:      stats.WaitCount,
+			WaitDurationMs: stats.WaitDuration.Milliseconds(),
+		}
+	}
+	return pools
+}
+
 // IsDatabaseHealthy returns true if database is healthy
 func IsDatabaseHealthy() bool {
 	health := CheckDatabaseHealth()
@@ -0,0 +1,18 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckDependencies_Unconnected(t *testing.T) {
+	DB, MySQLDB = nil, nil
+
+	dependencies := CheckDependencies()
+
+	assert.Len(t, dependencies, 2)
+	assert.Equal(t, "unhealthy", dependencies[DependencyPostgreSQL].Status)
+	assert.Equal(t, "PostgreSQL is unavailable", dependencies[DependencyPostgreSQL].Message)
+	assert.Equal(t, "unhealthy", dependencies[DependencyMySQL].Status)
+}
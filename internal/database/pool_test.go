@@ -0,0 +1,49 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestConfigurePool(t *testing.T) {
+	t.Setenv("DB_MAX_OPEN_CONNS", "7")
+	t.Setenv("DB_MAX_IDLE_CONNS", "3")
+	t.Setenv("DB_CONN_MAX_LIFETIME", "5m")
+
+	db, err := gorm.Open(postgres.New(postgres.Config{
+		DSN: "host=localhost user=test dbname=test sslmode=disable",
+	}), &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	require.NoError(t, err)
+
+	configurePool(db, "PostgreSQL")
+
+	pools := poolStats(map[string]*gorm.DB{DependencyPostgreSQL: db, DependencyMySQL: nil})
+	require.Len(t, pools, 1)
+	assert.Equal(t, PoolStats{MaxOpen: 7}, pools[DependencyPostgreSQL])
+}
+
+func TestConnectRollupDatabases_KeepsUnreachableShards(t *testing.T) {
+	t.Setenv("ROLLUP_DATABASES", "shard-2=postgres://test@127.0.0.1:1/test?sslmode=disable&connect_timeout=1")
+	RollupDBs = nil
+	defer func() { RollupDBs = nil }()
+
+	connectRollupDatabases()
+
+	// The shard is kept so summaries can report it as skipped
+	require.Len(t, RollupDBs, 1)
+	assert.Equal(t, "shard-2", RollupDBs[0].Name)
+	assert.Error(t, RollupDBs[0].DB.Exec("SELECT 1").Error)
+}
+
+func TestCheckDatabaseHealth_NoPoolsWhenUnconnected(t *testing.T) {
+	DB, MySQLDB = nil, nil
+
+	status := CheckDatabaseHealth()
+
+	assert.Equal(t, "unhealthy", status.Status)
+	assert.Nil(t, status.Pools)
+}
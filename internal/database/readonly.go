@@ -0,0 +1,90 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// ProtectedTables are the source transaction tables this service only ever reads. Writable
+// features must keep their data in their own tables.
+var ProtectedTables = []string{"payment_tx_log", "iso_trx"}
+
+// ErrReadOnlyTable is returned for a write to a protected table in read-only mode
+var ErrReadOnlyTable = errors.New("write to read-only table refused")
+
+// protectedWritePattern matches raw SQL statements that modify a protected table. Table
+// names may be schema-qualified and quoted with double quotes (PostgreSQL) or backticks (MySQL).
+var protectedWritePattern = regexp.MustCompile(
+	`(?i)\b(?:insert\s+into|update|delete\s+from|truncate(?:\s+table)?|alter\s+table|drop\s+table)\s+(?:only\s+)?` +
+		`(?:` + quotedIdent(`\w+`) + `\.)?` + quotedIdent(`(`+strings.Join(ProtectedTables, "|")+`)`) + `(?:\s|$|\(|;)`)
+
+// quotedIdent matches the identifier pattern with optional quotes
+func quotedIdent(pattern string) string {
+	return "[\"`]?" + pattern + "[\"`]?"
+}
+
+// RegisterReadOnlyGuard makes db refuse creates, updates and deletes on ProtectedTables,
+// including those issued as raw SQL with Exec. The guard runs before any other callback so
+// a refused write never opens a transaction.
+func RegisterReadOnlyGuard(db *gorm.DB) error {
+	callbacks := db.Callback()
+	if err := callbacks.Create().Before("*").Register("readonly:create", guardModelWrite); err != nil {
+		return err
+	}
+	if err := callbacks.Update().Before("*").Register("readonly:update", guardModelWrite); err != nil {
+		return err
+	}
+	if err := callbacks.Delete().Before("*").Register("readonly:delete", guardModelWrite); err != nil {
+		return err
+	}
+	return callbacks.Raw().Before("*").Register("readonly:raw", guardRawWrite)
+}
+
+// guardModelWrite rejects a create, update or delete whose target is a protected table.
+// Table("payment_tx_log p") leaves only the alias in Statement.Table, so the table
+// expression is checked as well.
+func guardModelWrite(db *gorm.DB) {
+	stmt := db.Statement
+	tables := []string{stmt.Table}
+	if stmt.Schema != nil {
+		tables = append(tables, stmt.Schema.Table)
+	}
+	if stmt.TableExpr != nil {
+		if fields := strings.Fields(stmt.TableExpr.SQL); len(fields) > 0 {
+			tables = append(tables, fields[0])
+		}
+	}
+
+	for _, table := range tables {
+		if isProtectedTable(table) {
+			db.AddError(fmt.Errorf("%w: %s", ErrReadOnlyTable, table))
+			return
+		}
+	}
+}
+
+// guardRawWrite rejects a raw SQL statement that modifies a protected table
+func guardRawWrite(db *gorm.DB) {
+	if match := protectedWritePattern.FindStringSubmatch(db.Statement.SQL.String()); match != nil {
+		db.AddError(fmt.Errorf("%w: %s", ErrReadOnlyTable, strings.ToLower(match[1])))
+	}
+}
+
+// isProtectedTable reports whether table, optionally schema-qualified or quoted, is one
+// of ProtectedTables
+func isProtectedTable(table string) bool {
+	table = strings.ToLower(strings.NewReplacer(`"`, "", "`", "").Replace(table))
+	if i := strings.LastIndex(table, "."); i >= 0 {
+		table = table[i+1:]
+	}
+	for _, protected := range ProtectedTables {
+		if table == protected {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,52 @@
+package database
+
+import (
+	"testing"
+
+	"aken_reporting_service/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// newGuardedDB returns a dry-run connection with the read-only guard registered
+func newGuardedDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(postgres.New(postgres.Config{
+		DSN: "host=localhost user=test dbname=test sslmode=disable",
+	}), &gorm.Config{DryRun: true, DisableAutomaticPing: true, SkipDefaultTransaction: true})
+	require.NoError(t, err)
+	require.NoError(t, RegisterReadOnlyGuard(db))
+	return db
+}
+
+func TestReadOnlyGuard_RefusesWritesToProtectedTables(t *testing.T) {
+	db := newGuardedDB(t)
+
+	writes := map[string]*gorm.DB{
+		"create model":      db.Create(&models.Transaction{ID: "tx-1"}),
+		"update model":      db.Model(&models.Transaction{ID: "tx-1"}).Update("amount", 1),
+		"delete model":      db.Delete(&models.Transaction{ID: "tx-1"}),
+		"update table":      db.Table("payment_tx_log p").Where("p.payment_tx_log_id = ?", "tx-1").Update("amount", 1),
+		"create iso_trx":    db.Table("public.iso_trx").Create(map[string]interface{}{"rrn": "001"}),
+		"raw update":        db.Exec("UPDATE payment_tx_log SET amount = 0"),
+		"raw delete quoted": db.Exec(`delete from "public"."iso_trx" where id = 1`),
+		"raw truncate":      db.Exec("TRUNCATE TABLE payment_tx_log"),
+		"raw insert mysql":  db.Exec("INSERT INTO `iso_trx` (rrn) VALUES (?)", "001"),
+	}
+	for name, result := range writes {
+		assert.ErrorIs(t, result.Error, ErrReadOnlyTable, name)
+	}
+}
+
+func TestReadOnlyGuard_AllowsReadsAndOtherTables(t *testing.T) {
+	db := newGuardedDB(t)
+
+	assert.NoError(t, db.Table("payment_tx_log p").Where("p.amount > ?", 0).Find(&[]models.Transaction{}).Error)
+	assert.NoError(t, db.Table("saved_queries").Create(map[string]interface{}{"name": "daily"}).Error)
+	assert.NoError(t, db.Exec("UPDATE saved_queries SET name = ? WHERE name = ?", "weekly", "payment_tx_log").Error)
+	assert.NoError(t, db.Exec("INSERT INTO payment_tx_log_tags (tag) VALUES (?)", "vip").Error)
+}
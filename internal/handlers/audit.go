@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"reflect"
+	"strings"
+
+	"aken_reporting_service/internal/config"
+	"aken_reporting_service/internal/models"
+	"aken_reporting_service/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// auditDataAccess writes the audit entry of a request that returned transaction data.
+// The filter is summarised by the names of the fields it constrains, never their values.
+func auditDataAccess(c *gin.Context, merchantID, endpoint string, filter *models.TransactionFilter, rowCount int, fields []string) {
+	utils.LogAudit("Transaction data accessed", dataAccessEntry(merchantID, utils.GetRequestID(c), endpoint, filter, rowCount, fields))
+}
+
+// auditReportRun writes the audit entry of a scheduled report run. There is no request, so
+// the export job id stands in for the request id.
+func auditReportRun(schedule models.ReportSchedule, exportID string, filter *models.TransactionFilter, rowCount int, fields []string) {
+	entry := dataAccessEntry(schedule.MerchantID, exportID, "scheduled report", filter, rowCount, fields)
+	entry["schedule_id"] = schedule.ID
+	entry["delivery"] = schedule.Delivery.Type
+	utils.LogAudit("Transaction data accessed", entry)
+}
+
+// dataAccessEntry builds the fields of a data access audit entry
+func dataAccessEntry(merchantID, requestID, endpoint string, filter *models.TransactionFilter, rowCount int, fields []string) map[string]interface{} {
+	return map[string]interface{}{
+		"merchant_id":   merchantID,
+		"request_id":    requestID,
+		"endpoint":      endpoint,
+		"filter":        filterSummary(filter),
+		"row_count":     rowCount,
+		"pan_requested": panRequested(fields),
+	}
+}
+
+// panRequested reports whether the response includes the pan field, which it does when
+// no fields are selected because pan is one of the default fields
+func panRequested(fields []string) bool {
+	if len(fields) == 0 {
+		fields = config.DefaultFields
+	}
+	for _, field := range fields {
+		if field == "pan" {
+			return true
+		}
+	}
+	return false
+}
+
+// filterSummary returns the JSON names of the filter fields that are set, plus "search"
+// when a search query is applied
+func filterSummary(filter *models.TransactionFilter) []string {
+	summary := []string{}
+	if filter == nil {
+		return summary
+	}
+
+	value := reflect.ValueOf(filter).Elem()
+	for i := 0; i < value.NumField(); i++ {
+		name, _, _ := strings.Cut(value.Type().Field(i).Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		if field := value.Field(i); !field.IsZero() && !(field.Kind() == reflect.Slice && field.Len() == 0) {
+			summary = append(summary, name)
+		}
+	}
+
+	if filter.Search != nil {
+		summary = append(summary, "search")
+	}
+	return summary
+}
@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"aken_reporting_service/internal/middleware"
+	"aken_reporting_service/internal/models"
+	"aken_reporting_service/internal/services"
+	"aken_reporting_service/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// captureAuditEntries collects the meta of audit entries logged while fn runs
+func captureAuditEntries(t *testing.T, fn func()) []map[string]interface{} {
+	t.Helper()
+
+	var buf bytes.Buffer
+	utils.Logger.SetOutput(&buf)
+	defer utils.Logger.SetOutput(os.Stderr)
+
+	fn()
+
+	var entries []map[string]interface{}
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var entry struct {
+			Meta map[string]interface{} `json:"meta"`
+		}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &entry))
+		if entry.Meta["audit"] == true {
+			entries = append(entries, entry.Meta)
+		}
+	}
+	return entries
+}
+
+func TestAuditDataAccess_TransactionReads(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	pan := "411111******1111"
+	repo := &fakeTransactionRepo{
+		pages: [][]models.Transaction{{{ID: "tx-1", PAN: &pan}, {ID: "tx-2", PAN: &pan}}},
+		byID:  map[string]models.Transaction{"tx-1": {ID: "tx-1", PAN: &pan}},
+	}
+	handler := NewTransactionHandler(services.NewTransactionService(repo, nil))
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("merchantID", "merchant-1")
+	})
+	router.GET("/transactions", handler.GetTransactions)
+	router.GET("/transactions/:id", handler.GetTransactionByID)
+	router.POST("/transactions/search", handler.AdvancedTransactionSearch)
+	router.GET("/transactions/stream", handler.StreamTransactions)
+	router.POST("/transactions/export", handler.ExportTransactions)
+	router.GET("/transactions/:id/receipt", handler.GetTransactionReceipt)
+
+	tests := []struct {
+		name         string
+		method       string
+		url          string
+		body         string
+		endpoint     string
+		filter       []interface{}
+		rowCount     float64
+		panRequested bool
+	}{
+		{"list with default fields", "GET", "/transactions?filter=response_code:eq:00", "", "GET /transactions", []interface{}{"response_code", "datetime_from"}, 2, true},
+		{"list without pan", "GET", "/transactions?fields=payment_tx_log_id,amount", "", "GET /transactions", []interface{}{"datetime_from"}, 2, false},
+		{"by id", "GET", "/transactions/tx-1?fields=pan", "", "GET /transactions/:id", []interface{}{}, 1, true},
+		{"search", "POST", "/transactions/search", `{"query":{"bool":{"must":[{"term":{"rrn":"123456"}}]}},"fields":["amount"]}`, "POST /transactions/search", []interface{}{"search"}, 2, false},
+		{"stream", "GET", "/transactions/stream?filter=response_code:eq:00", "", "GET /transactions/stream", []interface{}{"response_code", "datetime_from"}, 2, true},
+		{"export", "POST", "/transactions/export?fields=payment_tx_log_id,amount", "", "POST /transactions/export", []interface{}{"datetime_from"}, 2, false},
+		{"receipt", "GET", "/transactions/tx-1/receipt", "", "GET /transactions/:id/receipt", []interface{}{}, 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo.calls = 0
+			var w *httptest.ResponseRecorder
+			entries := captureAuditEntries(t, func() {
+				req, _ := http.NewRequest(tt.method, tt.url, strings.NewReader(tt.body))
+				req.Header.Set("Content-Type", "application/json")
+				req.Header.Set("X-Request-ID", "req-audit")
+				w = httptest.NewRecorder()
+				router.ServeHTTP(w, req)
+			})
+
+			require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+			require.Len(t, entries, 1)
+			entry := entries[0]
+			assert.Equal(t, "merchant-1", entry["merchant_id"])
+			assert.Equal(t, "req-audit", entry["request_id"])
+			assert.Equal(t, tt.endpoint, entry["endpoint"])
+			assert.Equal(t, tt.filter, entry["filter"])
+			assert.Equal(t, tt.rowCount, entry["row_count"])
+			assert.Equal(t, tt.panRequested, entry["pan_requested"])
+
+			raw, _ := json.Marshal(entry)
+			assert.NotContains(t, string(raw), pan)
+			assert.NotContains(t, string(raw), "123456")
+		})
+	}
+}
+
+func TestAuditDataAccess_GeneratedRequestID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	repo := &fakeTransactionRepo{byID: map[string]models.Transaction{"tx-1": {ID: "tx-1"}}}
+	handler := NewTransactionHandler(services.NewTransactionService(repo, nil))
+	router := gin.New()
+	router.Use(middleware.RequestIDMiddleware(), middleware.ResponseHeadersMiddleware())
+	router.GET("/transactions/:id", func(c *gin.Context) {
+		c.Set("merchantID", "merchant-1")
+		handler.GetTransactionByID(c)
+	})
+
+	// Without an X-Request-ID from the client, the entry carries the generated one
+	w := httptest.NewRecorder()
+	entries := captureAuditEntries(t, func() {
+		req, _ := http.NewRequest("GET", "/transactions/tx-1", nil)
+		router.ServeHTTP(w, req)
+	})
+
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Len(t, entries, 1)
+	assert.NotEmpty(t, entries[0]["request_id"])
+	assert.Equal(t, w.Header().Get("X-Request-ID"), entries[0]["request_id"])
+}
+
+func TestAuditDataAccess_NotLoggedOnError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	handler := NewTransactionHandler(services.NewTransactionService(&fakeTransactionRepo{}, nil))
+	router := gin.New()
+	router.GET("/transactions/:id", func(c *gin.Context) {
+		c.Set("merchantID", "merchant-1")
+		handler.GetTransactionByID(c)
+	})
+
+	entries := captureAuditEntries(t, func() {
+		req, _ := http.NewRequest("GET", "/transactions/missing", nil)
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	})
+	assert.Empty(t, entries)
+}
+
+func TestFilterSummary(t *testing.T) {
+	amount := int64(100)
+	code := "00"
+
+	assert.Equal(t, []string{}, filterSummary(nil))
+	assert.Equal(t, []string{}, filterSummary(&models.TransactionFilter{IncludeInactive: true, ResponseCodeIn: []string{}}))
+	assert.Equal(t,
+		[]string{"response_code", "amount_min", "currency_code_in", "search"},
+		filterSummary(&models.TransactionFilter{
+			ResponseCode:   &code,
+			AmountMin:      &amount,
+			CurrencyCodeIn: []string{"EGP"},
+			Search:         &models.SearchBoolQuery{},
+		}))
+}
@@ -1,23 +1,34 @@
 package handlers
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"aken_reporting_service/internal/config"
 	"aken_reporting_service/internal/database"
+	"aken_reporting_service/internal/middleware"
 	"aken_reporting_service/internal/models"
+	"aken_reporting_service/internal/utils"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 )
 
 // AuthHandler handles authentication-related requests
-type AuthHandler struct{}
+type AuthHandler struct {
+	merchantActive func(merchantID string) (bool, error)
+}
 
 // NewAuthHandler creates a new authentication handler
 func NewAuthHandler() *AuthHandler {
-	return &AuthHandler{}
+	return &AuthHandler{merchantActive: isActiveMerchant}
 }
 
 // TokenClaims represents the claims in our JWT token
@@ -44,42 +55,20 @@ type TokenResponse struct {
 func (ah *AuthHandler) GenerateToken(c *gin.Context) {
 	var req GenerateTokenRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": gin.H{
-				"code":       config.ErrorCodeBadRequest,
-				"message":    "Invalid request body",
-				"details":    err.Error(),
-				"timestamp":  time.Now().UTC().Format(time.RFC3339),
-				"request_id": c.GetHeader("X-Request-ID"),
-			},
-		})
+		utils.SendError(c, http.StatusBadRequest, config.ErrorCodeBadRequest, "Invalid request body", gin.H{"reason": err.Error()})
 		return
 	}
 
 	// Validate merchant credentials (simplified for development)
 	if !isValidMerchantCredentials(req.MerchantID, req.Password) {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": gin.H{
-				"code":       config.ErrorCodeAuthFailed,
-				"message":    "Invalid merchant credentials",
-				"timestamp":  time.Now().UTC().Format(time.RFC3339),
-				"request_id": c.GetHeader("X-Request-ID"),
-			},
-		})
+		utils.SendError(c, http.StatusUnauthorized, config.ErrorCodeAuthFailed, "Invalid merchant credentials", nil)
 		return
 	}
 
 	// Generate JWT token
 	token, expiresIn, err := generateJWTToken(req.MerchantID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": gin.H{
-				"code":       config.ErrorCodeInternalError,
-				"message":    "Failed to generate token",
-				"timestamp":  time.Now().UTC().Format(time.RFC3339),
-				"request_id": c.GetHeader("X-Request-ID"),
-			},
-		})
+		utils.SendError(c, http.StatusInternalServerError, config.ErrorCodeInternalError, "Failed to generate token", nil)
 		return
 	}
 
@@ -104,8 +93,76 @@ func (ah *AuthHandler) VerifyToken(c *gin.Context) {
 	})
 }
 
+// RefreshToken handles POST /api/v2/auth/refresh
+// It exchanges a valid, unexpired Bearer token for a new one with a fresh expiry and token ID,
+// keeping the merchant claims. Tokens issued within JWT_REFRESH_MIN_TTL can't be refreshed yet,
+// and tokens of a merchant that has since been deactivated or removed can't be refreshed at all.
+func (ah *AuthHandler) RefreshToken(c *gin.Context) {
+	claims, err := parseBearerToken(c.GetHeader("Authorization"))
+	if err != nil {
+		utils.SendError(c, http.StatusUnauthorized, config.ErrorCodeAuthFailed, err.Error(), nil)
+		return
+	}
+
+	if claims.IssuedAt != nil {
+		if wait := time.Until(claims.IssuedAt.Add(config.GetJWTRefreshMinTTL())); wait > 0 {
+			retryAfter := int(math.Ceil(wait.Seconds()))
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			utils.SendError(c, http.StatusTooManyRequests, config.ErrorCodeRateLimited, fmt.Sprintf("Token was issued too recently to refresh; retry in %d seconds", retryAfter), nil)
+			return
+		}
+	}
+
+	active, err := ah.merchantActive(claims.MerchantID)
+	if err != nil {
+		utils.LogError("Failed to check merchant status for token refresh", err, map[string]interface{}{
+			"merchant_id": claims.MerchantID,
+		})
+		utils.SendError(c, http.StatusInternalServerError, config.ErrorCodeInternalError, "Failed to generate token", nil)
+		return
+	}
+	if !active {
+		utils.SendError(c, http.StatusUnauthorized, config.ErrorCodeAuthFailed, "Merchant is inactive or does not exist", nil)
+		return
+	}
+
+	token, expiresIn, err := signJWTToken(claims.MerchantID, claims.MerchantName)
+	if err != nil {
+		utils.SendError(c, http.StatusInternalServerError, config.ErrorCodeInternalError, "Failed to generate token", nil)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"token":      token,
+		"expires_in": expiresIn,
+		"token_type": "Bearer",
+	})
+}
+
 // Helper functions
 
+// parseBearerToken validates a "Bearer <token>" Authorization header and returns its claims
+func parseBearerToken(authHeader string) (*TokenClaims, error) {
+	if authHeader == "" {
+		return nil, errors.New("Missing Authorization header")
+	}
+	if !strings.HasPrefix(authHeader, "Bearer ") {
+		return nil, errors.New("Invalid Authorization header format. Expected Bearer token")
+	}
+
+	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+	claims := &TokenClaims{}
+	token, err := middleware.ParseToken(tokenString, claims)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid token: %v", err)
+	}
+	if !token.Valid || claims.MerchantID == "" {
+		return nil, errors.New("Invalid token")
+	}
+
+	return claims, nil
+}
+
 func isValidMerchantCredentials(merchantID, password string) bool {
 	// Look up merchant in database
 	var merchant models.Merchant
@@ -119,12 +176,37 @@ func isValidMerchantCredentials(merchantID, password string) bool {
 	return password == merchant.Password
 }
 
+// isActiveMerchant reports whether the merchant exists and is active
+func isActiveMerchant(merchantID string) (bool, error) {
+	if database.DB == nil {
+		return false, errors.New("database is not connected")
+	}
+
+	var count int64
+	err := database.DB.Model(&models.Merchant{}).Where("merchant_id = ? AND active = ?", merchantID, true).Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
 func generateJWTToken(merchantID string) (string, int64, error) {
 	// Get merchant name (in production, fetch from database)
 	merchantName := getMerchantNameByID(merchantID)
 
-	// Set token expiration (24 hours)
-	expirationTime := time.Now().Add(24 * time.Hour)
+	return signJWTToken(merchantID, merchantName)
+}
+
+// signJWTToken issues a token for the merchant, valid for JWT_EXPIRY_HOURS, with a unique
+// token ID (jti)
+func signJWTToken(merchantID, merchantName string) (string, int64, error) {
+	tokenID, err := newTokenID()
+	if err != nil {
+		return "", 0, err
+	}
+
+	// Set token expiration
+	expirationTime := time.Now().Add(config.GetJWTExpiry())
 	expiresIn := expirationTime.Unix() - time.Now().Unix()
 
 	// Create the claims
@@ -136,14 +218,12 @@ func generateJWTToken(merchantID string) (string, int64, error) {
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			Issuer:    config.GetJWTIssuer(),
 			Subject:   merchantID,
+			ID:        tokenID,
 		},
 	}
 
-	// Create token with claims
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-
-	// Sign the token with secret
-	tokenString, err := token.SignedString([]byte(config.GetJWTSecret()))
+	// Sign the token with the configured algorithm
+	tokenString, err := middleware.SignToken(claims)
 	if err != nil {
 		return "", 0, err
 	}
@@ -151,6 +231,15 @@ func generateJWTToken(merchantID string) (string, int64, error) {
 	return tokenString, expiresIn, nil
 }
 
+// newTokenID returns a random identifier for the jti claim
+func newTokenID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
+
 func getMerchantNameByID(merchantID string) string {
 	// Fetch merchant name from database
 	var merchant models.Merchant
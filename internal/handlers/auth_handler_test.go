@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"aken_reporting_service/internal/config"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// signTestToken signs a token for merchant-1 issued at issuedAt and valid for ttl
+func signTestToken(t *testing.T, issuedAt time.Time, ttl time.Duration) string {
+	t.Helper()
+
+	claims := TokenClaims{
+		MerchantID:   "merchant-1",
+		MerchantName: "Coffee Co",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(issuedAt.Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(issuedAt),
+			Issuer:    config.GetJWTIssuer(),
+			Subject:   "merchant-1",
+			ID:        "original-token",
+		},
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(config.GetJWTSecret()))
+	require.NoError(t, err)
+	return token
+}
+
+func performRefresh(authHeader string) *httptest.ResponseRecorder {
+	handler := &AuthHandler{merchantActive: func(string) (bool, error) { return true, nil }}
+	return performRefreshWith(handler, authHeader)
+}
+
+func performRefreshWith(handler *AuthHandler, authHeader string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/auth/refresh", handler.RefreshToken)
+
+	req, _ := http.NewRequest("POST", "/auth/refresh", nil)
+	if authHeader != "" {
+		req.Header.Set("Authorization", authHeader)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRefreshToken_IssuesNewToken(t *testing.T) {
+	t.Setenv("JWT_REFRESH_MIN_TTL", "300")
+	issuedAt := time.Now().Add(-time.Hour)
+
+	w := performRefresh("Bearer " + signTestToken(t, issuedAt, 2*time.Hour))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var response map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "Bearer", response["token_type"])
+	assert.InDelta(t, (24 * time.Hour).Seconds(), response["expires_in"], 1)
+
+	claims, err := parseBearerToken("Bearer " + response["token"].(string))
+	require.NoError(t, err)
+	assert.Equal(t, "merchant-1", claims.MerchantID)
+	assert.Equal(t, "Coffee Co", claims.MerchantName)
+	assert.NotEmpty(t, claims.ID)
+	assert.NotEqual(t, "original-token", claims.ID)
+	assert.True(t, claims.IssuedAt.After(issuedAt))
+	assert.WithinDuration(t, time.Now().Add(24*time.Hour), claims.ExpiresAt.Time, 5*time.Second)
+}
+
+func TestRefreshToken_RejectsExpiredToken(t *testing.T) {
+	w := performRefresh("Bearer " + signTestToken(t, time.Now().Add(-25*time.Hour), 24*time.Hour))
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), config.ErrorCodeAuthFailed)
+	assert.Contains(t, w.Body.String(), "expired")
+}
+
+func TestRefreshToken_RejectsInvalidTokens(t *testing.T) {
+	valid := signTestToken(t, time.Now().Add(-time.Hour), 2*time.Hour)
+
+	for name, header := range map[string]string{
+		"missing header": "",
+		"basic auth":     "Basic bWVyY2hhbnQ6cGFzcw==",
+		"tampered token": "Bearer " + valid + "x",
+		"not a jwt":      "Bearer abc.def.ghi",
+	} {
+		w := performRefresh(header)
+		assert.Equal(t, http.StatusUnauthorized, w.Code, name)
+	}
+}
+
+func TestRefreshToken_RejectsRecentlyIssuedToken(t *testing.T) {
+	t.Setenv("JWT_REFRESH_MIN_TTL", "600")
+
+	w := performRefresh("Bearer " + signTestToken(t, time.Now().Add(-time.Minute), time.Hour))
+
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "540", w.Header().Get("Retry-After"))
+
+	t.Setenv("JWT_REFRESH_MIN_TTL", "0")
+	w = performRefresh("Bearer " + signTestToken(t, time.Now(), time.Hour))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestRefreshToken_RejectsInactiveMerchant(t *testing.T) {
+	var checked string
+	handler := &AuthHandler{merchantActive: func(merchantID string) (bool, error) {
+		checked = merchantID
+		return false, nil
+	}}
+
+	w := performRefreshWith(handler, "Bearer "+signTestToken(t, time.Now().Add(-time.Hour), 2*time.Hour))
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, "merchant-1", checked)
+	assert.NotContains(t, w.Body.String(), `"token"`)
+}
+
+func TestRefreshToken_MerchantLookupFailure(t *testing.T) {
+	handler := &AuthHandler{merchantActive: func(string) (bool, error) {
+		return false, errors.New("connection refused")
+	}}
+
+	w := performRefreshWith(handler, "Bearer "+signTestToken(t, time.Now().Add(-time.Hour), 2*time.Hour))
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+func TestSignJWTToken_ConfiguredExpiry(t *testing.T) {
+	t.Setenv("JWT_EXPIRY_HOURS", "2")
+
+	token, expiresIn, err := signJWTToken("merchant-1", "Coffee Co")
+	require.NoError(t, err)
+	assert.InDelta(t, (2 * time.Hour).Seconds(), expiresIn, 1)
+
+	claims, err := parseBearerToken("Bearer " + token)
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(2*time.Hour), claims.ExpiresAt.Time, 5*time.Second)
+
+	// A token minted for longer than the configured lifetime is refused
+	w := performRefresh("Bearer " + signTestToken(t, time.Now(), 24*time.Hour))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "too far in the future")
+}
+
+func TestSignJWTToken_UniqueTokenIDs(t *testing.T) {
+	first, _, err := signJWTToken("merchant-1", "Coffee Co")
+	require.NoError(t, err)
+	second, _, err := signJWTToken("merchant-1", "Coffee Co")
+	require.NoError(t, err)
+
+	firstClaims, err := parseBearerToken("Bearer " + first)
+	require.NoError(t, err)
+	secondClaims, err := parseBearerToken("Bearer " + second)
+	require.NoError(t, err)
+
+	assert.Len(t, firstClaims.ID, 32)
+	assert.NotEqual(t, firstClaims.ID, secondClaims.ID)
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"aken_reporting_service/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// transactionETag returns the entity tag of a transaction representation. It changes when
+// the transaction's updated_at does, and differs between field selections, timezones and
+// PAN formats of the same transaction.
+func transactionETag(tx *models.Transaction, fields []string, timezone, panFormat string) string {
+	hash := sha256.New()
+	for _, part := range []string{tx.ID, tx.UpdatedAt.UTC().Format(time.RFC3339Nano), strings.Join(fields, ","), timezone, panFormat} {
+		hash.Write([]byte(part))
+		hash.Write([]byte{0})
+	}
+	return `"` + hex.EncodeToString(hash.Sum(nil))[:32] + `"`
+}
+
+// setValidators sets the ETag and Last-Modified headers of a response
+func setValidators(c *gin.Context, etag string, lastModified time.Time) {
+	c.Header("ETag", etag)
+	c.Header("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+}
+
+// notModified reports whether the request's conditional headers match the current
+// representation. If-None-Match takes precedence over If-Modified-Since, as in RFC 9110.
+func notModified(c *gin.Context, etag string, lastModified time.Time) bool {
+	if c.GetHeader("If-None-Match") != "" {
+		return etagMatches(c, etag)
+	}
+
+	if ifModifiedSince := c.GetHeader("If-Modified-Since"); ifModifiedSince != "" {
+		since, err := http.ParseTime(ifModifiedSince)
+		if err != nil {
+			return false
+		}
+		// Last-Modified only has second precision
+		return !lastModified.Truncate(time.Second).After(since)
+	}
+	return false
+}
+
+// etagMatches reports whether the request's If-None-Match lists etag, weakly compared, or is *
+func etagMatches(c *gin.Context, etag string) bool {
+	for _, candidate := range strings.Split(c.GetHeader("If-None-Match"), ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
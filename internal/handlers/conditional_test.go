@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"aken_reporting_service/internal/models"
+	"aken_reporting_service/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetTransactionByID_ConditionalRequests(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	updatedAt := time.Date(2025, 1, 15, 10, 30, 0, 250000000, time.UTC)
+	repo := &fakeTransactionRepo{byID: map[string]models.Transaction{
+		"tx-1": {ID: "tx-1", Amount: 1500, UpdatedAt: updatedAt},
+	}}
+	handler := NewTransactionHandler(services.NewTransactionService(repo, nil))
+	router := gin.New()
+	router.GET("/transactions/:id", func(c *gin.Context) {
+		c.Set("merchantID", "merchant-1")
+		handler.GetTransactionByID(c)
+	})
+
+	get := func(url string, headers map[string]string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", url, nil)
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	first := get("/transactions/tx-1", nil)
+	require.Equal(t, http.StatusOK, first.Code)
+	etag := first.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+	assert.Equal(t, "Wed, 15 Jan 2025 10:30:00 GMT", first.Header().Get("Last-Modified"))
+
+	t.Run("matching If-None-Match returns 304", func(t *testing.T) {
+		w := get("/transactions/tx-1", map[string]string{"If-None-Match": etag})
+		assert.Equal(t, http.StatusNotModified, w.Code)
+		assert.Empty(t, w.Body.String())
+		assert.Equal(t, etag, w.Header().Get("ETag"))
+	})
+
+	t.Run("weak and listed etags match", func(t *testing.T) {
+		w := get("/transactions/tx-1", map[string]string{"If-None-Match": `"other", W/` + etag})
+		assert.Equal(t, http.StatusNotModified, w.Code)
+	})
+
+	t.Run("If-Modified-Since at Last-Modified returns 304", func(t *testing.T) {
+		w := get("/transactions/tx-1", map[string]string{"If-Modified-Since": "Wed, 15 Jan 2025 10:30:00 GMT"})
+		assert.Equal(t, http.StatusNotModified, w.Code)
+		assert.Empty(t, w.Body.String())
+	})
+
+	t.Run("older If-Modified-Since returns the transaction", func(t *testing.T) {
+		w := get("/transactions/tx-1", map[string]string{"If-Modified-Since": "Wed, 15 Jan 2025 10:29:59 GMT"})
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("If-None-Match takes precedence over If-Modified-Since", func(t *testing.T) {
+		w := get("/transactions/tx-1", map[string]string{
+			"If-None-Match":     `"stale"`,
+			"If-Modified-Since": "Wed, 15 Jan 2025 10:30:00 GMT",
+		})
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("another field selection has another etag", func(t *testing.T) {
+		w := get("/transactions/tx-1?fields=amount", map[string]string{"If-None-Match": etag})
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.NotEqual(t, etag, w.Header().Get("ETag"))
+	})
+
+	t.Run("an update changes the etag", func(t *testing.T) {
+		repo.byID["tx-1"] = models.Transaction{ID: "tx-1", Amount: 1500, UpdatedAt: updatedAt.Add(time.Minute)}
+		defer func() { repo.byID["tx-1"] = models.Transaction{ID: "tx-1", Amount: 1500, UpdatedAt: updatedAt} }()
+
+		w := get("/transactions/tx-1", map[string]string{"If-None-Match": etag})
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotEqual(t, etag, w.Header().Get("ETag"))
+	})
+}
+
+func TestGetMerchantSummary_ConditionalRequests(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	repo := &fakeTransactionRepo{summary: &models.MerchantSummary{MerchantID: testMerchantID, TotalTransactions: 3}}
+	handler := NewTransactionHandler(services.NewTransactionService(repo, nil))
+	router := gin.New()
+	router.GET("/merchants/:merchant_id/summary", func(c *gin.Context) {
+		c.Set("merchantID", testMerchantID)
+		handler.GetMerchantSummary(c)
+	})
+
+	get := func(url, ifNoneMatch string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", url, nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	url := "/merchants/" + testMerchantID + "/summary"
+
+	first := get(url, "")
+	require.Equal(t, http.StatusOK, first.Code)
+	etag := first.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+
+	t.Run("matching If-None-Match returns 304", func(t *testing.T) {
+		w := get(url, etag)
+		assert.Equal(t, http.StatusNotModified, w.Code)
+		assert.Empty(t, w.Body.String())
+		assert.Equal(t, etag, w.Header().Get("ETag"))
+	})
+
+	t.Run("If-Modified-Since alone does not return 304", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", url, nil)
+		req.Header.Set("If-Modified-Since", "Wed, 15 Jan 2025 10:30:00 GMT")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("other metrics have another etag", func(t *testing.T) {
+		w := get(url+"?metrics=count", etag)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.NotEqual(t, etag, w.Header().Get("ETag"))
+	})
+
+	t.Run("a changed summary returns the new body", func(t *testing.T) {
+		repo.summary = &models.MerchantSummary{MerchantID: testMerchantID, TotalTransactions: 4}
+
+		w := get(url, etag)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.NotEqual(t, etag, w.Header().Get("ETag"))
+	})
+}
@@ -0,0 +1,515 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"aken_reporting_service/internal/config"
+	"aken_reporting_service/internal/models"
+	"aken_reporting_service/internal/services"
+	"aken_reporting_service/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// exportPageSize is the number of rows fetched per internal page while exporting
+var exportPageSize = config.MaxPageSize
+
+// estimatedDurationHeader carries the estimated response duration in milliseconds
+const estimatedDurationHeader = "X-Estimated-Duration"
+
+// SetExportJobStore replaces the in-memory export job store the handler starts with
+func (h *TransactionHandler) SetExportJobStore(store services.ExportJobStore) {
+	h.exportJobs = store
+}
+
+// ExportTransactions handles POST /api/v2/transactions/export
+// It accepts the same filter, fields, sort and timezone parameters as GetTransactions and
+// returns every matching transaction as a CSV (default) or .xlsx attachment, paging internally.
+// With delivery=s3 the file is uploaded to object storage instead and the export job is returned.
+func (h *TransactionHandler) ExportTransactions(c *gin.Context) {
+	merchantID := getMerchantID(c)
+	if merchantID == "" {
+		h.sendErrorResponse(c, http.StatusUnauthorized, config.ErrorCodeAuthFailed, "Invalid or missing authentication credentials", nil)
+		return
+	}
+
+	fieldsParam := c.Query("fields")
+	filterParam := c.Query("filter")
+	sortParam := c.Query("sort")
+	timezone := c.DefaultQuery("timezone", "UTC")
+	panFormat := c.DefaultQuery("pan_format", "bin_id_and_pan_id")
+	format := strings.ToLower(c.DefaultQuery("format", "csv"))
+	delivery := strings.ToLower(c.DefaultQuery("delivery", models.ExportDeliveryDownload))
+
+	if format != "csv" && format != "xlsx" {
+		h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeBadRequest, "Invalid format parameter (must be csv or xlsx)", nil)
+		return
+	}
+
+	if delivery != models.ExportDeliveryDownload && delivery != models.ExportDeliveryS3 {
+		h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeBadRequest, "Invalid delivery parameter (must be download or s3)", nil)
+		return
+	}
+	if delivery == models.ExportDeliveryS3 && h.exportUploader == nil {
+		h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeBadRequest, "S3 export delivery is not configured", nil)
+		return
+	}
+
+	if err := h.transactionService.ValidateTimezone(timezone); err != nil {
+		h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeInvalidTimezone, err.Error(), nil)
+		return
+	}
+
+	fields, err := parseFields(fieldsParam)
+	if err != nil {
+		h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeInvalidField, err.Error(), nil)
+		return
+	}
+	if len(fields) == 0 {
+		fields = config.DefaultFields
+	}
+
+	filter, err := h.transactionService.ParseAdvancedFilter(filterParam, timezone)
+	if err != nil {
+		h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeInvalidFilter, fmt.Sprintf("Invalid filter expression: %v", err), nil)
+		return
+	}
+
+	if err := parseInclusionFlags(c, filter); err != nil {
+		h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeBadRequest, err.Error(), nil)
+		return
+	}
+
+	sort, err := h.transactionService.ParseSort(sortParam)
+	if err != nil {
+		h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeInvalidSort, fmt.Sprintf("Invalid sort expression: %v", err), sortErrorDetails(err))
+		return
+	}
+
+	params := &services.GetTransactionsParams{
+		Filter:    filter,
+		Fields:    fields,
+		Sort:      sort,
+		Page:      1,
+		Limit:     exportPageSize,
+		Timezone:  timezone,
+		PANFormat: panFormat,
+		SkipCount: true, // Pages are walked until a short page is returned
+	}
+
+	job, err := h.exportJobs.Create(merchantID, format)
+	if err != nil {
+		utils.LogError("Failed to record export job", err, map[string]interface{}{
+			"merchant_id": merchantID,
+		})
+		h.sendErrorResponse(c, http.StatusInternalServerError, config.ErrorCodeInternalError, "", nil)
+		return
+	}
+
+	objectKey := ""
+	if delivery == models.ExportDeliveryS3 {
+		objectKey = services.ExportObjectKey(merchantID, job.ID, format)
+	}
+	if err := h.exportJobs.SetDelivery(job.ID, delivery, objectKey); err != nil {
+		utils.LogError("Failed to record export delivery", err, map[string]interface{}{
+			"merchant_id": merchantID,
+			"export_id":   job.ID,
+		})
+		h.finishExport(job.ID, models.ExportStatusFailed, 0)
+		h.sendErrorResponse(c, http.StatusInternalServerError, config.ErrorCodeInternalError, "", nil)
+		return
+	}
+	h.setEstimatedDuration(c, merchantID, filter)
+
+	// Fetch the first page before writing headers so errors can still be reported as JSON
+	result, err := h.transactionService.GetTransactions(merchantID, params)
+	if err != nil {
+		h.finishExport(job.ID, models.ExportStatusFailed, 0)
+
+		utils.LogError("Database error in ExportTransactions", err, map[string]interface{}{
+			"merchant_id": merchantID,
+			"filter":      filterParam,
+		})
+
+		if config.IsInternalError(err) {
+			h.sendErrorResponse(c, http.StatusServiceUnavailable, config.ErrorCodeServiceUnavailable, "",
+				gin.H{"retry_after": 30})
+		} else {
+			h.sendErrorResponse(c, http.StatusInternalServerError, config.ErrorCodeDatabaseError, "", nil)
+		}
+		return
+	}
+
+	if delivery == models.ExportDeliveryS3 {
+		h.uploadExport(c, job.ID, objectKey, format, params, result)
+		return
+	}
+
+	writer := newExportWriter(format, c.Writer, fields, merchantID, filter)
+
+	filename := fmt.Sprintf("transactions_%s.%s", time.Now().UTC().Format("20060102T150405Z"), format)
+	c.Header("X-Export-ID", job.ID)
+	c.Header("Content-Type", writer.ContentType())
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Status(http.StatusOK)
+
+	rowCount, err := h.writeExport(merchantID, params, result, writer)
+	auditDataAccess(c, merchantID, "POST /transactions/export", filter, rowCount, fields)
+	if err != nil {
+		// Headers are already sent; the truncated file is the only signal we can give
+		utils.LogError("Transaction export aborted", err, map[string]interface{}{
+			"merchant_id": merchantID,
+			"page":        params.Page,
+			"rows":        rowCount,
+		})
+		h.finishExport(job.ID, models.ExportStatusFailed, rowCount)
+		c.Abort()
+		return
+	}
+	h.finishExport(job.ID, models.ExportStatusCompleted, rowCount)
+
+	utils.LogTrace("Transaction export completed", map[string]interface{}{
+		"merchant_id": merchantID,
+		"format":      format,
+		"rows":        rowCount,
+		"pages":       params.Page,
+	})
+}
+
+// setEstimatedDuration sets the X-Estimated-Duration header so clients can pick a timeout for
+// a long-running response. The header is left out when the estimate cannot be made.
+func (h *TransactionHandler) setEstimatedDuration(c *gin.Context, merchantID string, filter *models.TransactionFilter) {
+	estimate, err := h.transactionService.EstimateDuration(merchantID, filter)
+	if err != nil {
+		utils.LogWarn("Failed to estimate response duration", map[string]interface{}{
+			"merchant_id": merchantID,
+			"error":       err.Error(),
+		})
+		return
+	}
+
+	c.Header(estimatedDurationHeader, strconv.FormatInt(estimate.Milliseconds(), 10))
+}
+
+// uploadExport streams the export to object storage under objectKey and responds with the
+// finished export job. It is then fetched through the pre-signed URL returned by GetExport.
+func (h *TransactionHandler) uploadExport(c *gin.Context, jobID, objectKey, format string, params *services.GetTransactionsParams, result *services.TransactionServiceResult) {
+	merchantID := getMerchantID(c)
+
+	rowCount, err := h.pipeExport(merchantID, format, params, result, func(contentType string, body io.Reader) error {
+		return h.exportUploader.Upload(c.Request.Context(), objectKey, contentType, body)
+	})
+	if err != nil {
+		utils.LogError("Transaction export upload failed", err, map[string]interface{}{
+			"merchant_id": merchantID,
+			"export_id":   jobID,
+			"rows":        rowCount,
+		})
+		h.finishExport(jobID, models.ExportStatusFailed, rowCount)
+		h.sendErrorResponse(c, http.StatusInternalServerError, config.ErrorCodeInternalError, "", nil)
+		return
+	}
+	h.finishExport(jobID, models.ExportStatusCompleted, rowCount)
+	auditDataAccess(c, merchantID, "POST /transactions/export", params.Filter, rowCount, params.Fields)
+
+	utils.LogTrace("Transaction export uploaded", map[string]interface{}{
+		"merchant_id": merchantID,
+		"export_id":   jobID,
+		"format":      format,
+		"rows":        rowCount,
+	})
+
+	job, _, _ := h.exportJobs.Get(merchantID, jobID)
+	c.Header("X-Export-ID", jobID)
+	c.JSON(http.StatusCreated, gin.H{
+		"data": job,
+		"links": gin.H{
+			"status": "/api/v2/exports/" + jobID,
+		},
+		"meta": gin.H{
+			"timestamp": time.Now().UTC().Format(time.RFC3339),
+			"version":   config.APIVersion,
+		},
+	})
+}
+
+// pipeExport renders the export into a pipe that send reads from, so only what send holds
+// is ever in memory, and returns the number of rows written. An error from send stops the
+// rendering; an error from rendering fails the body send is reading.
+func (h *TransactionHandler) pipeExport(merchantID, format string, params *services.GetTransactionsParams, result *services.TransactionServiceResult, send func(contentType string, body io.Reader) error) (int, error) {
+	reader, pipe := io.Pipe()
+	writer := newExportWriter(format, pipe, params.Fields, merchantID, params.Filter)
+
+	type written struct {
+		rows int
+		err  error
+	}
+	done := make(chan written, 1)
+	go func() {
+		rowCount, err := h.writeExport(merchantID, params, result, writer)
+		// A nil error ends the body; any other fails it
+		pipe.CloseWithError(err)
+		done <- written{rowCount, err}
+	}()
+
+	err := send(writer.ContentType(), reader)
+	// Stops the rendering if send returned before reading all of it
+	reader.CloseWithError(err)
+	out := <-done
+	if err != nil {
+		return out.rows, err
+	}
+	return out.rows, out.err
+}
+
+// finishExport records the outcome of an export job. A failure to record it is logged
+// rather than returned, as the export itself has already succeeded or failed.
+func (h *TransactionHandler) finishExport(jobID, status string, rowCount int) {
+	if err := h.exportJobs.Finish(jobID, status, rowCount); err != nil {
+		utils.LogError("Failed to record export job outcome", err, map[string]interface{}{
+			"export_id": jobID,
+			"status":    status,
+		})
+	}
+}
+
+// writeExport writes the header row, the already fetched first page and every following
+// page to writer, then closes it. It returns the number of rows written.
+func (h *TransactionHandler) writeExport(merchantID string, params *services.GetTransactionsParams, result *services.TransactionServiceResult, writer exportWriter) (int, error) {
+	if err := writer.WriteHeader(); err != nil {
+		return 0, err
+	}
+
+	rowCount := 0
+	for {
+		if err := writer.WriteTransactions(result.Transactions); err != nil {
+			return rowCount, err
+		}
+		rowCount += len(result.Transactions)
+
+		if !result.HasMore {
+			break
+		}
+
+		params.Page++
+		var err error
+		if result, err = h.transactionService.GetTransactions(merchantID, params); err != nil {
+			return rowCount, err
+		}
+	}
+
+	return rowCount, writer.Close()
+}
+
+// ListExports handles GET /api/v2/exports
+// It returns a page of the authenticated merchant's past exports, newest first.
+func (h *TransactionHandler) ListExports(c *gin.Context) {
+	merchantID := getMerchantID(c)
+	if merchantID == "" {
+		h.sendErrorResponse(c, http.StatusUnauthorized, config.ErrorCodeAuthFailed, "Invalid or missing authentication credentials", nil)
+		return
+	}
+
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeBadRequest, "Invalid page parameter", nil)
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit < 1 || limit > config.MaxPageSize {
+		h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeBadRequest, fmt.Sprintf("Invalid limit parameter (must be 1-%d)", config.MaxPageSize), nil)
+		return
+	}
+
+	jobs, total, err := h.exportJobs.List(merchantID, page, limit)
+	if err != nil {
+		utils.LogError("Failed to list exports", err, map[string]interface{}{
+			"merchant_id": merchantID,
+		})
+		h.sendErrorResponse(c, http.StatusInternalServerError, config.ErrorCodeInternalError, "", nil)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": jobs,
+		"meta": gin.H{
+			"pagination": gin.H{
+				"page":        page,
+				"limit":       limit,
+				"total":       total,
+				"total_pages": (total + limit - 1) / limit,
+			},
+			"timestamp": time.Now().UTC().Format(time.RFC3339),
+			"version":   config.APIVersion,
+		},
+	})
+}
+
+// GetExport handles GET /api/v2/exports/:export_id
+// It returns one of the authenticated merchant's exports. Completed exports delivered to
+// object storage include a pre-signed download_url.
+func (h *TransactionHandler) GetExport(c *gin.Context) {
+	merchantID := getMerchantID(c)
+	if merchantID == "" {
+		h.sendErrorResponse(c, http.StatusUnauthorized, config.ErrorCodeAuthFailed, "Invalid or missing authentication credentials", nil)
+		return
+	}
+
+	job, ok, err := h.exportJobs.Get(merchantID, c.Param("export_id"))
+	if err != nil {
+		utils.LogError("Failed to read export", err, map[string]interface{}{
+			"merchant_id": merchantID,
+		})
+		h.sendErrorResponse(c, http.StatusInternalServerError, config.ErrorCodeInternalError, "", nil)
+		return
+	}
+	if !ok {
+		h.sendErrorResponse(c, http.StatusNotFound, config.ErrorCodeNotFound, "Export not found", nil)
+		return
+	}
+
+	status := models.ExportJobStatus{ExportJob: *job}
+	if job.Delivery == models.ExportDeliveryS3 && job.Status == models.ExportStatusCompleted && h.exportUploader != nil {
+		downloadURL, err := h.exportUploader.PresignGetURL(job.ObjectKey)
+		if err != nil {
+			utils.LogError("Failed to presign export download", err, map[string]interface{}{
+				"merchant_id": merchantID,
+				"export_id":   job.ID,
+			})
+			h.sendErrorResponse(c, http.StatusInternalServerError, config.ErrorCodeInternalError, "", nil)
+			return
+		}
+		status.DownloadURL = downloadURL
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": status,
+		"meta": gin.H{
+			"timestamp": time.Now().UTC().Format(time.RFC3339),
+			"version":   config.APIVersion,
+		},
+	})
+}
+
+// exportWriter renders exported transactions in a specific file format
+type exportWriter interface {
+	ContentType() string
+	WriteHeader() error
+	WriteTransactions(transactions []models.Transaction) error
+	Close() error
+}
+
+// newExportWriter creates the writer for an export format (csv or xlsx)
+func newExportWriter(format string, out io.Writer, fields []string, merchantID string, filter *models.TransactionFilter) exportWriter {
+	if format == "xlsx" {
+		return newXLSXExportWriter(out, fields, merchantID, filter)
+	}
+	return newCSVExportWriter(out, fields)
+}
+
+// csvExportWriter streams rows as CSV, flushing to HTTP clients after every page
+type csvExportWriter struct {
+	out    io.Writer
+	writer *csv.Writer
+	fields []string
+}
+
+func newCSVExportWriter(out io.Writer, fields []string) *csvExportWriter {
+	return &csvExportWriter{out: out, writer: csv.NewWriter(out), fields: fields}
+}
+
+func (w *csvExportWriter) ContentType() string {
+	return csvContentType
+}
+
+func (w *csvExportWriter) WriteHeader() error {
+	return w.writer.Write(w.fields)
+}
+
+func (w *csvExportWriter) WriteTransactions(transactions []models.Transaction) error {
+	for i := range transactions {
+		if err := w.writer.Write(buildCSVRow(&transactions[i], w.fields)); err != nil {
+			return err
+		}
+	}
+	w.writer.Flush()
+	if flusher, ok := w.out.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return w.writer.Error()
+}
+
+func (w *csvExportWriter) Close() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+// buildCSVRow renders a transaction as CSV cells in the order of the requested fields.
+// When currency_info is requested, amounts are formatted with the currency exponent.
+func buildCSVRow(tx *models.Transaction, fields []string) []string {
+	values := tx.FilterFields(fields)
+	formatAmounts := containsField(fields, "currency_info") && tx.CurrencyInfo != nil
+
+	row := make([]string, len(fields))
+	for i, field := range fields {
+		if field == "amount" && formatAmounts {
+			row[i] = tx.CurrencyInfo.FormatAmount(tx.Amount)
+			continue
+		}
+		row[i] = formatCSVValue(values[field])
+	}
+
+	return row
+}
+
+// formatCSVValue converts a FilterFields value into its CSV cell representation
+func formatCSVValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case *string:
+		if v == nil {
+			return ""
+		}
+		return *v
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case bool:
+		return strconv.FormatBool(v)
+	case *time.Time:
+		if v == nil {
+			return ""
+		}
+		return v.UTC().Format("2006-01-02T15:04:05.000Z")
+	case *models.CurrencyInfo:
+		if v == nil {
+			return ""
+		}
+		return v.Code
+	case json.RawMessage:
+		return string(v)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+func containsField(fields []string, field string) bool {
+	for _, f := range fields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,503 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"aken_reporting_service/internal/config"
+	"aken_reporting_service/internal/models"
+	"aken_reporting_service/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xuri/excelize/v2"
+)
+
+func TestExportTransactions_StreamsAllPagesAsCSV(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	originalPageSize := exportPageSize
+	exportPageSize = 2
+	defer func() { exportPageSize = originalPageSize }()
+
+	rrn := func(s string) models.Transaction { return models.Transaction{ID: "tx-" + s, RRN: s, Amount: 1050} }
+	repo := &fakeTransactionRepo{pages: [][]models.Transaction{
+		{rrn("001"), rrn("002")},
+		{rrn("003")},
+	}}
+	handler := NewTransactionHandler(services.NewTransactionService(repo, nil))
+
+	router := gin.New()
+	router.POST("/export", func(c *gin.Context) {
+		c.Set("merchantID", "merchant-1")
+		handler.ExportTransactions(c)
+	})
+
+	req, _ := http.NewRequest("POST", "/export?fields=payment_tx_log_id,rrn,amount", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/csv", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Header().Get("Content-Disposition"), "attachment; filename=")
+
+	records, err := csv.NewReader(strings.NewReader(w.Body.String())).ReadAll()
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"payment_tx_log_id", "rrn", "amount"},
+		{"tx-001", "001", "1050"},
+		{"tx-002", "002", "1050"},
+		{"tx-003", "003", "1050"},
+	}, records)
+	assert.Equal(t, 2, repo.calls, "export should page until a short page is returned")
+}
+
+func TestListExports_RecordsExportsPerMerchant(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	repo := &fakeTransactionRepo{pages: [][]models.Transaction{{{ID: "tx-1"}, {ID: "tx-2"}}}}
+	handler := NewTransactionHandler(services.NewTransactionService(repo, nil))
+
+	router := gin.New()
+	authed := func(merchantID string, next gin.HandlerFunc) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			c.Set("merchantID", merchantID)
+			next(c)
+		}
+	}
+	router.POST("/m1/export", authed("merchant-1", handler.ExportTransactions))
+	router.GET("/m1/exports", authed("merchant-1", handler.ListExports))
+	router.GET("/m2/exports", authed("merchant-2", handler.ListExports))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/m1/export?format=csv", nil)
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	exportID := w.Header().Get("X-Export-ID")
+	require.NotEmpty(t, exportID)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/m1/exports?page=1&limit=10", nil)
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		Data []map[string]interface{} `json:"data"`
+		Meta struct {
+			Pagination map[string]interface{} `json:"pagination"`
+		} `json:"meta"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Len(t, response.Data, 1)
+	assert.Equal(t, exportID, response.Data[0]["id"])
+	assert.Equal(t, models.ExportStatusCompleted, response.Data[0]["status"])
+	assert.Equal(t, "csv", response.Data[0]["format"])
+	assert.Equal(t, float64(2), response.Data[0]["row_count"])
+	assert.NotEmpty(t, response.Data[0]["created_at"])
+	assert.NotContains(t, response.Data[0], "merchant_id")
+	assert.Equal(t, float64(1), response.Meta.Pagination["total"])
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/m2/exports", nil)
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Empty(t, response.Data, "exports must not leak across merchants")
+	assert.Equal(t, float64(0), response.Meta.Pagination["total"])
+}
+
+func TestListExports_Validation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	handler := NewTransactionHandler(services.NewTransactionService(&fakeTransactionRepo{}, nil))
+	router := gin.New()
+	router.GET("/exports", handler.ListExports)
+	router.GET("/authed/exports", func(c *gin.Context) {
+		c.Set("merchantID", "merchant-1")
+		handler.ListExports(c)
+	})
+
+	tests := map[string]int{
+		"/exports":                                         http.StatusUnauthorized,
+		"/authed/exports?page=0":                           http.StatusBadRequest,
+		"/authed/exports?limit=abc":                        http.StatusBadRequest,
+		"/authed/exports?limit=1000000":                    http.StatusBadRequest,
+		"/authed/exports?page=4611686018427387904&limit=4": http.StatusOK,
+	}
+
+	for url, status := range tests {
+		req, _ := http.NewRequest("GET", url, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, status, w.Code, url)
+	}
+}
+
+func TestBuildCSVRow_FormatsAmountWithCurrencyExponent(t *testing.T) {
+	tx := &models.Transaction{
+		ID:     "tx-1",
+		Amount: 123456,
+		CurrencyInfo: &models.CurrencyInfo{
+			Code:     "818",
+			Symbol:   "E£",
+			Exponent: 2,
+		},
+	}
+
+	row := buildCSVRow(tx, []string{"payment_tx_log_id", "amount", "currency_info"})
+
+	assert.Equal(t, []string{"tx-1", "E£ 1234.56", "818"}, row)
+}
+
+func TestBuildCSVRow_RawAmountWithoutCurrencyInfo(t *testing.T) {
+	tx := &models.Transaction{
+		ID:           "tx-1",
+		Amount:       123456,
+		CurrencyInfo: &models.CurrencyInfo{Code: "818", Exponent: 2},
+	}
+
+	row := buildCSVRow(tx, []string{"payment_tx_log_id", "amount"})
+
+	assert.Equal(t, []string{"tx-1", "123456"}, row)
+}
+
+func TestFormatCSVValue(t *testing.T) {
+	value := "abc"
+	var nilString *string
+
+	tests := []struct {
+		name     string
+		input    interface{}
+		expected string
+	}{
+		{"nil", nil, ""},
+		{"string", "x", "x"},
+		{"string pointer", &value, "abc"},
+		{"nil string pointer", nilString, ""},
+		{"int", 7, "7"},
+		{"int64", int64(42), "42"},
+		{"bool", true, "true"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, formatCSVValue(tt.input))
+		})
+	}
+}
+
+func TestExportTransactions_XLSX(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	bin := "012345"
+	repo := &fakeTransactionRepo{pages: [][]models.Transaction{{
+		{ID: "tx-1", MerchantName: "Coffee Co", RRN: "000123", STAN: "000042", BinID: &bin, Amount: 123456,
+			TxDateTime: "2025-01-05T10:00:00.000Z", CurrencyInfo: &models.CurrencyInfo{Code: "0710", Exponent: 2}},
+		{ID: "tx-2", MerchantName: "Coffee Co", RRN: "000124", STAN: "000043", BinID: &bin, Amount: 500,
+			TxDateTime: "2025-01-20T10:00:00.000Z", CurrencyInfo: &models.CurrencyInfo{Code: "0710", Exponent: 2}},
+	}}}
+	handler := NewTransactionHandler(services.NewTransactionService(repo, nil))
+
+	router := gin.New()
+	router.POST("/export", func(c *gin.Context) {
+		c.Set("merchantID", "merchant-1")
+		handler.ExportTransactions(c)
+	})
+
+	req, _ := http.NewRequest("POST", "/export?format=xlsx&fields=payment_tx_log_id,rrn,stan,bin_id,amount&filter=tx_date_time:between:2025-01-05,2025-01-20", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, xlsxContentType, w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Header().Get("Content-Disposition"), ".xlsx")
+
+	file, err := excelize.OpenReader(bytes.NewReader(w.Body.Bytes()))
+	require.NoError(t, err)
+	defer file.Close()
+
+	sheet := "Coffee Co 20250105-20250120"
+	assert.Equal(t, []string{sheet}, file.GetSheetList())
+
+	rows, err := file.GetRows(sheet, excelize.Options{RawCellValue: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"payment_tx_log_id", "rrn", "stan", "bin_id", "amount"}, rows[0])
+	assert.Equal(t, []string{"tx-1", "000123", "000042", "012345", "1234.56"}, rows[1])
+
+	cellType, err := file.GetCellType(sheet, "E2")
+	require.NoError(t, err)
+	assert.NotEqual(t, excelize.CellTypeSharedString, cellType, "amounts must be numeric cells")
+
+	style, err := file.GetCellStyle(sheet, "C2")
+	require.NoError(t, err)
+	stanStyle, err := file.GetStyle(style)
+	require.NoError(t, err)
+	assert.Equal(t, xlsxTextNumberFormat, stanStyle.NumFmt, "STAN must be text formatted")
+}
+
+func TestExportTransactions_EstimatedDurationHeader(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("ESTIMATED_ROWS_PER_SECOND", "10000")
+
+	export := func(repo *fakeTransactionRepo) *httptest.ResponseRecorder {
+		handler := NewTransactionHandler(services.NewTransactionService(repo, nil))
+		router := gin.New()
+		router.POST("/export", func(c *gin.Context) {
+			c.Set("merchantID", "merchant-1")
+			handler.ExportTransactions(c)
+		})
+
+		req, _ := http.NewRequest("POST", "/export", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := export(&fakeTransactionRepo{estimate: 250000})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "25000", w.Header().Get(estimatedDurationHeader))
+
+	w = export(&fakeTransactionRepo{estimateErr: errors.New("explain failed")})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get(estimatedDurationHeader), "a failed estimate must not fail the export")
+}
+
+func TestExportTransactions_InvalidFormat(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	handler := NewTransactionHandler(services.NewTransactionService(&fakeTransactionRepo{}, nil))
+	router := gin.New()
+	router.POST("/export", func(c *gin.Context) {
+		c.Set("merchantID", "merchant-1")
+		handler.ExportTransactions(c)
+	})
+
+	req, _ := http.NewRequest("POST", "/export?format=pdf", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+// fakeExportUploader records uploads in memory
+type fakeExportUploader struct {
+	uploads      map[string][]byte
+	contentTypes map[string]string
+	err          error
+}
+
+func (u *fakeExportUploader) Upload(ctx context.Context, key, contentType string, body io.Reader) error {
+	if u.err != nil {
+		return u.err
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	if u.uploads == nil {
+		u.uploads = make(map[string][]byte)
+		u.contentTypes = make(map[string]string)
+	}
+	u.uploads[key] = data
+	u.contentTypes[key] = contentType
+	return nil
+}
+
+func (u *fakeExportUploader) PresignGetURL(key string) (string, error) {
+	return "https://storage.example.com/exports/" + key + "?X-Amz-Signature=test", nil
+}
+
+func TestExportTransactions_S3DeliveryUploadsAndStatusReturnsPresignedURL(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	repo := &fakeTransactionRepo{pages: [][]models.Transaction{{{ID: "tx-1", RRN: "001"}, {ID: "tx-2", RRN: "002"}}}}
+	handler := NewTransactionHandler(services.NewTransactionService(repo, nil))
+	uploader := &fakeExportUploader{}
+	handler.exportUploader = uploader
+
+	router := gin.New()
+	authed := func(merchantID string, next gin.HandlerFunc) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			c.Set("merchantID", merchantID)
+			next(c)
+		}
+	}
+	router.POST("/m1/export", authed("merchant-1", handler.ExportTransactions))
+	router.GET("/m1/exports/:export_id", authed("merchant-1", handler.GetExport))
+	router.GET("/m2/exports/:export_id", authed("merchant-2", handler.GetExport))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/m1/export?delivery=s3&fields=payment_tx_log_id,rrn", nil)
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	var created struct {
+		Data  map[string]interface{} `json:"data"`
+		Links map[string]string      `json:"links"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	exportID := created.Data["id"].(string)
+	assert.Equal(t, exportID, w.Header().Get("X-Export-ID"))
+	assert.Equal(t, models.ExportStatusCompleted, created.Data["status"])
+	assert.Equal(t, models.ExportDeliveryS3, created.Data["delivery"])
+	assert.Equal(t, float64(2), created.Data["row_count"])
+	assert.Equal(t, "/api/v2/exports/"+exportID, created.Links["status"])
+
+	key := "merchant-1/" + exportID + ".csv"
+	require.Contains(t, uploader.uploads, key)
+	assert.Equal(t, "text/csv", uploader.contentTypes[key])
+	records, err := csv.NewReader(bytes.NewReader(uploader.uploads[key])).ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"payment_tx_log_id", "rrn"}, {"tx-1", "001"}, {"tx-2", "002"}}, records)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/m1/exports/"+exportID, nil)
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var status struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+	assert.Equal(t, exportID, status.Data["id"])
+	assert.Equal(t, "https://storage.example.com/exports/"+key+"?X-Amz-Signature=test", status.Data["download_url"])
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/m2/exports/"+exportID, nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code, "exports must not leak across merchants")
+}
+
+func TestExportTransactions_S3UploadFailureMarksJobFailed(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	repo := &fakeTransactionRepo{pages: [][]models.Transaction{{{ID: "tx-1"}}}}
+	handler := NewTransactionHandler(services.NewTransactionService(repo, nil))
+	handler.exportUploader = &fakeExportUploader{err: errors.New("bucket unavailable")}
+
+	router := gin.New()
+	router.POST("/export", func(c *gin.Context) {
+		c.Set("merchantID", "merchant-1")
+		handler.ExportTransactions(c)
+	})
+
+	req, _ := http.NewRequest("POST", "/export?delivery=s3", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+
+	jobs, total, err := handler.exportJobs.List("merchant-1", 1, 10)
+	require.NoError(t, err)
+	require.Equal(t, 1, total)
+	assert.Equal(t, models.ExportStatusFailed, jobs[0].Status)
+	assert.Equal(t, models.ExportDeliveryS3, jobs[0].Delivery)
+}
+
+func TestExportTransactions_DeliveryValidation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	handler := NewTransactionHandler(services.NewTransactionService(&fakeTransactionRepo{}, nil))
+	handler.exportUploader = nil
+	router := gin.New()
+	router.POST("/export", func(c *gin.Context) {
+		c.Set("merchantID", "merchant-1")
+		handler.ExportTransactions(c)
+	})
+
+	for _, url := range []string{"/export?delivery=ftp", "/export?delivery=s3"} {
+		req, _ := http.NewRequest("POST", url, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, url)
+	}
+}
+
+func TestGetExport_DownloadDeliveryHasNoURL(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	handler := NewTransactionHandler(services.NewTransactionService(&fakeTransactionRepo{}, nil))
+	handler.exportUploader = &fakeExportUploader{}
+	job, err := handler.exportJobs.Create("merchant-1", "csv")
+	require.NoError(t, err)
+	require.NoError(t, handler.exportJobs.SetDelivery(job.ID, models.ExportDeliveryDownload, ""))
+	require.NoError(t, handler.exportJobs.Finish(job.ID, models.ExportStatusCompleted, 3))
+
+	router := gin.New()
+	router.GET("/exports/:export_id", func(c *gin.Context) {
+		c.Set("merchantID", "merchant-1")
+		handler.GetExport(c)
+	})
+
+	req, _ := http.NewRequest("GET", "/exports/"+job.ID, nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var status struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+	assert.Equal(t, models.ExportDeliveryDownload, status.Data["delivery"])
+	assert.NotContains(t, status.Data, "download_url")
+
+	req, _ = http.NewRequest("GET", "/exports/unknown", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestXLSXExportWriter_RoundsAmounts(t *testing.T) {
+	for _, mode := range []string{"", config.RoundingHalfUp, config.RoundingHalfEven, config.RoundingTruncate} {
+		t.Run(mode, func(t *testing.T) {
+			t.Setenv("AMOUNT_ROUNDING_MODE", mode)
+			w := newXLSXExportWriter(io.Discard, []string{"amount"}, "merchant-1", nil)
+			require.NoError(t, w.err)
+
+			// 0.29 is 0.28999... in binary, which truncation must not turn into 0.28
+			row, err := w.buildRow(&models.Transaction{Amount: 29, CurrencyInfo: &models.CurrencyInfo{Exponent: 2}})
+			require.NoError(t, err)
+			assert.Equal(t, 0.29, row[0].(excelize.Cell).Value)
+		})
+	}
+}
+
+func TestXLSXAmountExponent(t *testing.T) {
+	tests := []struct {
+		name     string
+		tx       models.Transaction
+		expected int
+	}{
+		{"currency info", models.Transaction{CurrencyInfo: &models.CurrencyInfo{Code: "0710", Exponent: 2}}, 2},
+		{"zero exponent currency info", models.Transaction{CurrencyInfo: &models.CurrencyInfo{Code: "0392", Exponent: 0}, CurrDelim: 2}, 0},
+		{"joined currency", models.Transaction{CurrencyName: "BHD", CurrDelim: 3}, 3},
+		{"zero exponent joined currency", models.Transaction{CurrencyName: "JPY", CurrDelim: 0}, 0},
+		{"no currency", models.Transaction{}, xlsxDefaultExponent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, xlsxAmountExponent(&tt.tx))
+		})
+	}
+}
+
+func TestBuildXLSXSheetName(t *testing.T) {
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, "Coffee Co 20250101-20250131", buildXLSXSheetName("Coffee Co", "m-1", &from, &to))
+	assert.Equal(t, "A Very Long M 20250101-20250131", buildXLSXSheetName("A Very Long Merchant Name Ltd", "m-1", &from, &to))
+	assert.Equal(t, "CoffeeTea 20250101-", buildXLSXSheetName("Coffee/Tea?", "m-1", &from, nil))
+	assert.Equal(t, "m-1", buildXLSXSheetName("", "m-1", nil, nil))
+	assert.LessOrEqual(t, len(buildXLSXSheetName("A Very Long Merchant Name Ltd", "m-1", &from, &to)), xlsxMaxSheetName)
+}
@@ -0,0 +1,261 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	"aken_reporting_service/internal/config"
+	"aken_reporting_service/internal/models"
+
+	"github.com/xuri/excelize/v2"
+)
+
+const (
+	xlsxContentType      = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	xlsxDefaultSheet     = "Sheet1"
+	xlsxMaxSheetName     = 31 // Excel limit on worksheet name length
+	xlsxDefaultExponent  = 2
+	xlsxTextNumberFormat = 49 // Built-in "@" (text) number format
+)
+
+// xlsxTextFields are exported as text cells so leading zeros survive in Excel
+var xlsxTextFields = map[string]bool{
+	"pan":    true,
+	"bin_id": true,
+	"stan":   true,
+	"rrn":    true,
+}
+
+// xlsxExportWriter builds an .xlsx workbook with a stream writer and sends it on Close.
+// The worksheet is named after the merchant and the exported date range.
+type xlsxExportWriter struct {
+	out        io.Writer
+	fields     []string
+	merchantID string
+
+	file         *excelize.File
+	stream       *excelize.StreamWriter
+	textStyle    int
+	amountStyles map[int]int // Number format style per currency exponent
+	roundingMode string      // config.GetAmountRoundingMode, read once per export
+	row          int
+
+	merchantName string
+	dateFrom     *time.Time
+	dateTo       *time.Time
+	fixedRange   bool // Date range comes from the filter rather than the rows
+	err          error
+}
+
+func newXLSXExportWriter(out io.Writer, fields []string, merchantID string, filter *models.TransactionFilter) *xlsxExportWriter {
+	w := &xlsxExportWriter{
+		out:          out,
+		fields:       fields,
+		merchantID:   merchantID,
+		file:         excelize.NewFile(),
+		amountStyles: make(map[int]int),
+		roundingMode: config.GetAmountRoundingMode(),
+		row:          1,
+	}
+
+	if filter != nil && (filter.DateTimeFrom != nil || filter.DateTimeTo != nil) {
+		w.dateFrom, w.dateTo = filter.DateTimeFrom, filter.DateTimeTo
+		w.fixedRange = true
+	}
+
+	w.stream, w.err = w.file.NewStreamWriter(xlsxDefaultSheet)
+	if w.err == nil {
+		w.textStyle, w.err = w.file.NewStyle(&excelize.Style{NumFmt: xlsxTextNumberFormat})
+	}
+
+	return w
+}
+
+func (w *xlsxExportWriter) ContentType() string {
+	return xlsxContentType
+}
+
+func (w *xlsxExportWriter) WriteHeader() error {
+	if w.err != nil {
+		return w.err
+	}
+
+	header := make([]interface{}, len(w.fields))
+	for i, field := range w.fields {
+		header[i] = field
+	}
+
+	return w.writeRow(header)
+}
+
+func (w *xlsxExportWriter) WriteTransactions(transactions []models.Transaction) error {
+	for i := range transactions {
+		tx := &transactions[i]
+		w.trackSheetName(tx)
+
+		row, err := w.buildRow(tx)
+		if err != nil {
+			return err
+		}
+		if err := w.writeRow(row); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (w *xlsxExportWriter) Close() error {
+	defer w.file.Close()
+
+	if err := w.stream.Flush(); err != nil {
+		return err
+	}
+
+	if err := w.file.SetSheetName(xlsxDefaultSheet, buildXLSXSheetName(w.merchantName, w.merchantID, w.dateFrom, w.dateTo)); err != nil {
+		return err
+	}
+
+	_, err := w.file.WriteTo(w.out)
+	return err
+}
+
+func (w *xlsxExportWriter) writeRow(values []interface{}) error {
+	cell, err := excelize.CoordinatesToCellName(1, w.row)
+	if err != nil {
+		return err
+	}
+	w.row++
+
+	return w.stream.SetRow(cell, values)
+}
+
+// buildRow renders a transaction as typed cells: text for identifier columns and
+// numeric amounts in major units
+func (w *xlsxExportWriter) buildRow(tx *models.Transaction) ([]interface{}, error) {
+	values := tx.FilterFields(w.fields)
+	row := make([]interface{}, len(w.fields))
+
+	for i, field := range w.fields {
+		switch {
+		case field == "amount":
+			exponent := xlsxAmountExponent(tx)
+			style, err := w.amountStyle(exponent)
+			if err != nil {
+				return nil, err
+			}
+			amount := config.RoundAmount(float64(tx.Amount)/math.Pow10(exponent), exponent, w.roundingMode)
+			row[i] = excelize.Cell{StyleID: style, Value: amount}
+		case xlsxTextFields[field]:
+			row[i] = excelize.Cell{StyleID: w.textStyle, Value: formatCSVValue(values[field])}
+		default:
+			row[i] = formatCSVValue(values[field])
+		}
+	}
+
+	return row, nil
+}
+
+// amountStyle returns a numeric style showing the given number of decimal places
+func (w *xlsxExportWriter) amountStyle(exponent int) (int, error) {
+	if style, exists := w.amountStyles[exponent]; exists {
+		return style, nil
+	}
+
+	format := "0"
+	if exponent > 0 {
+		format += "." + strings.Repeat("0", exponent)
+	}
+
+	style, err := w.file.NewStyle(&excelize.Style{CustomNumFmt: &format})
+	if err != nil {
+		return 0, err
+	}
+	w.amountStyles[exponent] = style
+
+	return style, nil
+}
+
+// trackSheetName records the merchant name and, without a filter range, the span of exported dates
+func (w *xlsxExportWriter) trackSheetName(tx *models.Transaction) {
+	if w.merchantName == "" && tx.MerchantName != "" {
+		w.merchantName = tx.MerchantName
+	}
+
+	if w.fixedRange {
+		return
+	}
+
+	txTime := tx.UpdatedAt
+	if tx.TxDateTime != "" {
+		if parsed, err := time.Parse("2006-01-02T15:04:05.000Z", tx.TxDateTime); err == nil {
+			txTime = parsed
+		}
+	}
+	if txTime.IsZero() {
+		return
+	}
+
+	if w.dateFrom == nil || txTime.Before(*w.dateFrom) {
+		w.dateFrom = &txTime
+	}
+	if w.dateTo == nil || txTime.After(*w.dateTo) {
+		to := txTime
+		w.dateTo = &to
+	}
+}
+
+// xlsxAmountExponent returns the currency exponent for a transaction amount. An exponent of
+// 0 (e.g. JPY) is kept; only transactions without currency data get xlsxDefaultExponent.
+func xlsxAmountExponent(tx *models.Transaction) int {
+	if tx.CurrencyInfo != nil {
+		return tx.CurrencyInfo.Exponent
+	}
+	// The joined currency name is set whenever the currency row was found
+	if tx.CurrencyName != "" {
+		return tx.CurrDelim
+	}
+	return xlsxDefaultExponent
+}
+
+// buildXLSXSheetName builds a worksheet name such as "Coffee Co 20250101-20250131",
+// truncating the merchant name to fit Excel's 31 character limit
+func buildXLSXSheetName(merchantName, merchantID string, dateFrom, dateTo *time.Time) string {
+	name := merchantName
+	if name == "" {
+		name = merchantID
+	}
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\'`, r) {
+			return -1
+		}
+		return r
+	}, name)
+
+	dateRange := ""
+	if dateFrom != nil || dateTo != nil {
+		from, to := "", ""
+		if dateFrom != nil {
+			from = dateFrom.UTC().Format("20060102")
+		}
+		if dateTo != nil {
+			to = dateTo.UTC().Format("20060102")
+		}
+		dateRange = fmt.Sprintf(" %s-%s", from, to)
+	}
+
+	maxNameLength := xlsxMaxSheetName - len(dateRange)
+	if runes := []rune(name); len(runes) > maxNameLength {
+		name = string(runes[:maxNameLength])
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = "Transactions"
+	}
+
+	return name + dateRange
+}
@@ -0,0 +1,19 @@
+package handlers
+
+import (
+	"net/http"
+
+	"aken_reporting_service/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Metrics handles GET /api/v2/metrics, serving the service counters in the Prometheus
+// text format
+func Metrics(c *gin.Context) {
+	c.Header("Content-Type", utils.MetricsContentType)
+	c.Status(http.StatusOK)
+	if err := utils.WriteMetrics(c.Writer); err != nil {
+		utils.LogError("Failed to write metrics", err, nil)
+	}
+}
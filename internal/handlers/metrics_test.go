@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"aken_reporting_service/internal/config"
+	"aken_reporting_service/internal/services"
+	"aken_reporting_service/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorMetrics_LabelledByEndpointAndCode(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	handler := NewTransactionHandler(services.NewTransactionService(&fakeTransactionRepo{}, nil))
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set("merchantID", testMerchantID) })
+	router.GET("/transactions", handler.GetTransactions)
+	router.GET("/transactions/:id", handler.GetTransactionByID)
+	router.GET("/metrics", Metrics)
+	router.NoRoute(NotFound)
+
+	invalidFilterBefore := utils.ErrorCount("GET /transactions", config.ErrorCodeInvalidFilter)
+	notFoundBefore := utils.ErrorCount("GET /transactions/:id", config.ErrorCodeTxNotFound)
+	unmatchedBefore := utils.ErrorCount("GET unmatched", config.ErrorCodeNotFound)
+
+	for _, path := range []string{
+		"/transactions?filter=amount:between:abc",
+		"/transactions?filter=amount:between:abc",
+		"/transactions/missing-1",
+		"/no-such-route",
+	} {
+		req, _ := http.NewRequest("GET", path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.GreaterOrEqual(t, w.Code, http.StatusBadRequest, path)
+	}
+
+	assert.Equal(t, invalidFilterBefore+2, utils.ErrorCount("GET /transactions", config.ErrorCodeInvalidFilter))
+	assert.Equal(t, notFoundBefore+1, utils.ErrorCount("GET /transactions/:id", config.ErrorCodeTxNotFound), "the route pattern, not the id, is the label")
+	assert.Equal(t, unmatchedBefore+1, utils.ErrorCount("GET unmatched", config.ErrorCodeNotFound))
+
+	req, _ := http.NewRequest("GET", "/metrics", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, utils.MetricsContentType, w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), "# TYPE aken_http_errors_total counter\n")
+	assert.Contains(t, w.Body.String(), `aken_http_errors_total{endpoint="GET /transactions",code="INVALID_FILTER"} `)
+	assert.Contains(t, w.Body.String(), `aken_http_errors_total{endpoint="GET /transactions/:id",code="`+config.ErrorCodeTxNotFound+`"} `)
+}
@@ -0,0 +1,17 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"aken_reporting_service/internal/config"
+	"aken_reporting_service/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NotFound handles requests that match no route
+func NotFound(c *gin.Context) {
+	utils.SendError(c, http.StatusNotFound, config.ErrorCodeNotFound,
+		fmt.Sprintf("Endpoint %s %s not found", c.Request.Method, c.Request.URL.Path), nil)
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"aken_reporting_service/internal/config"
+	"aken_reporting_service/internal/models"
+	"aken_reporting_service/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// receiptFields are the fields loaded to render a receipt. Selecting pan makes the
+// repository mask it with the requested pan_format.
+var receiptFields = []string{
+	"payment_tx_log_id", "merchant_name", "tx_log_type", "updated_at", "amount",
+	"currency_info", "pan", "rrn", "stan", "auth_code", "response_code",
+}
+
+// GetTransactionReceipt handles GET /api/v2/transactions/:id/receipt. The receipt is JSON
+// unless the client prefers text/plain, which returns a printable block.
+func (h *TransactionHandler) GetTransactionReceipt(c *gin.Context) {
+	merchantID := getMerchantID(c)
+	if merchantID == "" {
+		h.sendErrorResponse(c, http.StatusUnauthorized, config.ErrorCodeAuthFailed, "Invalid or missing authentication credentials", nil)
+		return
+	}
+
+	transactionID := c.Param("id")
+	if transactionID == "" {
+		h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeBadRequest, "Transaction ID is required", nil)
+		return
+	}
+
+	timezone := c.DefaultQuery("timezone", "UTC")
+	panFormat := c.DefaultQuery("pan_format", "bin_id_and_pan_id")
+
+	if err := h.transactionService.ValidateTimezone(timezone); err != nil {
+		h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeInvalidTimezone, err.Error(), nil)
+		return
+	}
+	loc, _ := time.LoadLocation(timezone)
+
+	if _, exists := config.PANFormats[panFormat]; !exists {
+		h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeBadRequest, fmt.Sprintf("Invalid pan_format '%s' (must be bin_id_and_pan_id or pan_id_only)", panFormat), nil)
+		return
+	}
+
+	transaction, err := h.transactionService.GetTransactionByID(merchantID, transactionID, receiptFields, timezone, panFormat)
+	if err != nil {
+		h.sendErrorResponse(c, http.StatusInternalServerError, config.ErrorCodeDatabaseError, fmt.Sprintf("Failed to retrieve transaction: %v", err), nil)
+		return
+	}
+	if transaction == nil {
+		h.sendErrorResponse(c, http.StatusNotFound, config.ErrorCodeTxNotFound, fmt.Sprintf("Transaction with ID %s not found", transactionID), nil)
+		return
+	}
+
+	receipt := models.NewTransactionReceipt(transaction, loc)
+	auditDataAccess(c, merchantID, "GET /transactions/:id/receipt", nil, 1, receiptFields)
+
+	utils.AddVary(c, "Accept")
+	if c.NegotiateFormat(gin.MIMEJSON, gin.MIMEPlain) == gin.MIMEPlain {
+		c.String(http.StatusOK, receipt.Text())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": receipt,
+		"meta": gin.H{
+			"timestamp": time.Now().UTC().Format(time.RFC3339),
+			"version":   config.APIVersion,
+		},
+	})
+}
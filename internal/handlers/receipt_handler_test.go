@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"aken_reporting_service/internal/config"
+	"aken_reporting_service/internal/models"
+	"aken_reporting_service/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newReceiptRouter(repo *fakeTransactionRepo) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	handler := NewTransactionHandler(services.NewTransactionService(repo, nil))
+	router := gin.New()
+	router.GET("/transactions/:id/receipt", func(c *gin.Context) {
+		c.Set("merchantID", "merchant-1")
+		handler.GetTransactionReceipt(c)
+	})
+	return router
+}
+
+func receiptTransaction() models.Transaction {
+	pan := "***** 1234"
+	authCode := "A1B2C3"
+	responseCode := "00"
+	return models.Transaction{
+		ID:           "tx-1",
+		MerchantName: "Corner Cafe",
+		Type:         "payment",
+		Amount:       12345,
+		CurrencyCode: "710",
+		CurrencyInfo: &models.CurrencyInfo{Code: "710", Symbol: "R", Exponent: 2},
+		PAN:          &pan,
+		RRN:          "123456789012",
+		STAN:         "000042",
+		AuthCode:     &authCode,
+		ResponseCode: &responseCode,
+		UpdatedAt:    time.Date(2025, 1, 15, 22, 30, 0, 0, time.UTC),
+	}
+}
+
+func TestGetTransactionReceipt_JSON(t *testing.T) {
+	repo := &fakeTransactionRepo{byID: map[string]models.Transaction{"tx-1": receiptTransaction()}}
+	router := newReceiptRouter(repo)
+
+	req, _ := http.NewRequest("GET", "/transactions/tx-1/receipt?timezone=Africa/Johannesburg&pan_format=pan_id_only", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "pan_id_only", repo.lastPanFormat)
+	assert.Equal(t, []string{"Accept"}, w.Header().Values("Vary"))
+
+	var response struct {
+		Data models.TransactionReceipt `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "R 123.45", response.Data.FormattedAmount)
+	assert.Equal(t, "R", response.Data.CurrencySymbol)
+	assert.Equal(t, "***** 1234", *response.Data.PAN)
+	assert.Equal(t, "000042", response.Data.STAN)
+	assert.Equal(t, "2025-01-16T00:30:00+02:00", response.Data.DateTime)
+	assert.Equal(t, "Africa/Johannesburg", response.Data.Timezone)
+}
+
+func TestGetTransactionReceipt_PlainText(t *testing.T) {
+	repo := &fakeTransactionRepo{byID: map[string]models.Transaction{"tx-1": receiptTransaction()}}
+	router := newReceiptRouter(repo)
+
+	req, _ := http.NewRequest("GET", "/transactions/tx-1/receipt", nil)
+	req.Header.Set("Accept", "text/plain")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/plain")
+	assert.Equal(t, []string{"Accept"}, w.Header().Values("Vary"))
+	assert.Contains(t, w.Body.String(), "TRANSACTION RECEIPT")
+	assert.Contains(t, w.Body.String(), "Amount:      R 123.45")
+	assert.Contains(t, w.Body.String(), "Auth code:   A1B2C3")
+}
+
+func TestGetTransactionReceipt_Errors(t *testing.T) {
+	router := newReceiptRouter(&fakeTransactionRepo{byID: map[string]models.Transaction{"tx-1": receiptTransaction()}})
+
+	tests := []struct {
+		name   string
+		url    string
+		status int
+		code   string
+	}{
+		{"other merchant's or unknown transaction", "/transactions/tx-2/receipt", http.StatusNotFound, config.ErrorCodeTxNotFound},
+		{"invalid pan_format", "/transactions/tx-1/receipt?pan_format=full", http.StatusBadRequest, config.ErrorCodeBadRequest},
+		{"invalid timezone", "/transactions/tx-1/receipt?timezone=Mars/Base", http.StatusBadRequest, config.ErrorCodeInvalidTimezone},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", tt.url, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.status, w.Code)
+			assert.Contains(t, w.Body.String(), tt.code)
+		})
+	}
+}
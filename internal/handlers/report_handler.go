@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"aken_reporting_service/internal/config"
+	"aken_reporting_service/internal/models"
+	"aken_reporting_service/internal/services"
+	"aken_reporting_service/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetReportScheduleStore replaces the in-memory report schedule store the handler starts with
+func (h *TransactionHandler) SetReportScheduleStore(store services.ReportScheduleStore) {
+	h.reportSchedules = store
+}
+
+// NewReportScheduler creates a scheduler for the handler's report schedules that renders
+// reports with RunReport and stores output with the handler's export uploader
+func (h *TransactionHandler) NewReportScheduler() *services.ReportScheduler {
+	return services.NewReportScheduler(h.reportSchedules, h.RunReport, h.exportUploader)
+}
+
+// RunReport renders a scheduled report with the export machinery, streaming it to deliver,
+// and records it as an export job, returning the job id. It is the scheduler's
+// services.ReportRunner. Reports that select no fields get config.DefaultReportFields.
+func (h *TransactionHandler) RunReport(schedule models.ReportSchedule, deliver services.ReportDeliverer) (string, error) {
+	fields := schedule.Fields
+	if len(fields) == 0 {
+		fields = config.DefaultReportFields
+	}
+
+	filter, err := h.transactionService.ParseAdvancedFilter(schedule.Filter, schedule.Timezone)
+	if err != nil {
+		return "", err
+	}
+
+	params := &services.GetTransactionsParams{
+		Filter:    filter,
+		Fields:    fields,
+		Page:      1,
+		Limit:     exportPageSize,
+		Timezone:  schedule.Timezone,
+		PANFormat: "bin_id_and_pan_id",
+		SkipCount: true,
+	}
+
+	job, err := h.exportJobs.Create(schedule.MerchantID, schedule.Format)
+	if err != nil {
+		return "", err
+	}
+
+	// Stored reports land where the export status endpoint can link to them
+	delivery, objectKey := models.ExportDeliveryWebhook, ""
+	if schedule.Delivery.Type != models.ReportDeliveryWebhook {
+		delivery = models.ExportDeliveryS3
+		objectKey = services.ExportObjectKey(schedule.MerchantID, job.ID, schedule.Format)
+	}
+	if err := h.exportJobs.SetDelivery(job.ID, delivery, objectKey); err != nil {
+		h.finishExport(job.ID, models.ExportStatusFailed, 0)
+		return job.ID, err
+	}
+
+	result, err := h.transactionService.GetTransactions(schedule.MerchantID, params)
+	if err != nil {
+		h.finishExport(job.ID, models.ExportStatusFailed, 0)
+		return job.ID, err
+	}
+
+	rowCount, err := h.pipeExport(schedule.MerchantID, schedule.Format, params, result, func(_ string, body io.Reader) error {
+		return deliver(job.ID, body)
+	})
+	auditReportRun(schedule, job.ID, filter, rowCount, fields)
+	if err != nil {
+		h.finishExport(job.ID, models.ExportStatusFailed, rowCount)
+		return job.ID, err
+	}
+	h.finishExport(job.ID, models.ExportStatusCompleted, rowCount)
+
+	return job.ID, nil
+}
+
+// CreateReportSchedule handles POST /api/v2/reports/schedules
+// It validates the report definition and stores a schedule for the authenticated merchant.
+func (h *TransactionHandler) CreateReportSchedule(c *gin.Context) {
+	merchantID := getMerchantID(c)
+	if merchantID == "" {
+		h.sendErrorResponse(c, http.StatusUnauthorized, config.ErrorCodeAuthFailed, "Invalid or missing authentication credentials", nil)
+		return
+	}
+
+	var req models.CreateReportScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeBadRequest, fmt.Sprintf("Invalid request body: %v", err), nil)
+		return
+	}
+
+	schedule, err := h.buildReportSchedule(merchantID, &req)
+	if err != nil {
+		h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeInvalidRequest, err.Error(), nil)
+		return
+	}
+
+	created, err := h.reportSchedules.Create(*schedule)
+	if err != nil {
+		if errors.Is(err, services.ErrTooManySchedules) {
+			h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeInvalidRequest, err.Error(), nil)
+			return
+		}
+		utils.LogError("Failed to create report schedule", err, map[string]interface{}{
+			"merchant_id": merchantID,
+		})
+		h.sendErrorResponse(c, http.StatusInternalServerError, config.ErrorCodeInternalError, "", nil)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"data": created,
+		"meta": gin.H{
+			"timestamp": time.Now().UTC().Format(time.RFC3339),
+			"version":   config.APIVersion,
+		},
+	})
+}
+
+// ListReportSchedules handles GET /api/v2/reports/schedules
+func (h *TransactionHandler) ListReportSchedules(c *gin.Context) {
+	merchantID := getMerchantID(c)
+	if merchantID == "" {
+		h.sendErrorResponse(c, http.StatusUnauthorized, config.ErrorCodeAuthFailed, "Invalid or missing authentication credentials", nil)
+		return
+	}
+
+	schedules, err := h.reportSchedules.List(merchantID)
+	if err != nil {
+		utils.LogError("Failed to list report schedules", err, map[string]interface{}{
+			"merchant_id": merchantID,
+		})
+		h.sendErrorResponse(c, http.StatusInternalServerError, config.ErrorCodeInternalError, "", nil)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": schedules,
+		"meta": gin.H{
+			"timestamp": time.Now().UTC().Format(time.RFC3339),
+			"version":   config.APIVersion,
+		},
+	})
+}
+
+// DeleteReportSchedule handles DELETE /api/v2/reports/schedules/:schedule_id
+func (h *TransactionHandler) DeleteReportSchedule(c *gin.Context) {
+	merchantID := getMerchantID(c)
+	if merchantID == "" {
+		h.sendErrorResponse(c, http.StatusUnauthorized, config.ErrorCodeAuthFailed, "Invalid or missing authentication credentials", nil)
+		return
+	}
+
+	scheduleID := c.Param("schedule_id")
+	deleted, err := h.reportSchedules.Delete(merchantID, scheduleID)
+	if err != nil {
+		utils.LogError("Failed to delete report schedule", err, map[string]interface{}{
+			"merchant_id": merchantID,
+			"schedule_id": scheduleID,
+		})
+		h.sendErrorResponse(c, http.StatusInternalServerError, config.ErrorCodeInternalError, "", nil)
+		return
+	}
+	if !deleted {
+		h.sendErrorResponse(c, http.StatusNotFound, config.ErrorCodeNotFound, fmt.Sprintf("Report schedule %s not found", scheduleID), nil)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// buildReportSchedule validates a create request and applies defaults
+func (h *TransactionHandler) buildReportSchedule(merchantID string, req *models.CreateReportScheduleRequest) (*models.ReportSchedule, error) {
+	format := strings.ToLower(req.Format)
+	if format == "" {
+		format = "csv"
+	}
+	if format != "csv" && format != "xlsx" {
+		return nil, fmt.Errorf("invalid format '%s' (must be csv or xlsx)", req.Format)
+	}
+
+	timezone := req.Timezone
+	if timezone == "" {
+		timezone = "UTC"
+	}
+	if err := h.transactionService.ValidateTimezone(timezone); err != nil {
+		return nil, err
+	}
+	loc, _ := time.LoadLocation(timezone)
+
+	fields, err := config.ExpandFieldPresets(req.Fields)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := h.transactionService.ParseAdvancedFilter(req.Filter, timezone); err != nil {
+		return nil, fmt.Errorf("invalid filter expression: %v", err)
+	}
+
+	if err := req.Schedule.Validate(); err != nil {
+		return nil, err
+	}
+
+	delivery := req.Delivery
+	if delivery.Type == "" {
+		delivery.Type = models.ReportDeliveryStore
+	}
+	switch delivery.Type {
+	case models.ReportDeliveryStore:
+		if h.exportUploader == nil {
+			return nil, errors.New("store delivery requires object storage to be configured")
+		}
+		delivery.URL = ""
+	case models.ReportDeliveryWebhook:
+		if err := services.ValidateWebhookURL(delivery.URL); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("invalid delivery type '%s' (must be store or webhook)", delivery.Type)
+	}
+
+	now := time.Now().UTC()
+	return &models.ReportSchedule{
+		MerchantID: merchantID,
+		Name:       req.Name,
+		Filter:     req.Filter,
+		Fields:     fields,
+		Format:     format,
+		Timezone:   timezone,
+		Schedule:   req.Schedule,
+		Delivery:   delivery,
+		CreatedAt:  now,
+		NextRunAt:  req.Schedule.Next(now, loc),
+	}, nil
+}
@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"aken_reporting_service/internal/config"
+	"aken_reporting_service/internal/models"
+	"aken_reporting_service/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newReportRouter(handler *TransactionHandler) *gin.Engine {
+	router := gin.New()
+	for _, merchantID := range []string{"merchant-1", "merchant-2"} {
+		merchantID := merchantID
+		group := router.Group("/" + merchantID)
+		group.Use(func(c *gin.Context) {
+			c.Set("merchantID", merchantID)
+			c.Next()
+		})
+		group.POST("/schedules", handler.CreateReportSchedule)
+		group.GET("/schedules", handler.ListReportSchedules)
+		group.DELETE("/schedules/:schedule_id", handler.DeleteReportSchedule)
+	}
+	return router
+}
+
+func TestReportSchedules_CreateListDeleteScopedPerMerchant(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	handler := NewTransactionHandler(services.NewTransactionService(&fakeTransactionRepo{}, nil))
+	handler.exportUploader = &fakeExportUploader{}
+	router := newReportRouter(handler)
+
+	body := `{"name": "Daily approvals", "filter": "response_code:eq:00", "fields": ["payment_tx_log_id", "amount"],
+		"timezone": "Africa/Johannesburg", "schedule": {"frequency": "daily", "hour": 6}}`
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/merchant-1/schedules", strings.NewReader(body))
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	var created struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	scheduleID := created.Data["id"].(string)
+	assert.NotEmpty(t, scheduleID)
+	assert.Equal(t, "csv", created.Data["format"])
+	assert.Equal(t, models.ReportDeliveryStore, created.Data["delivery"].(map[string]interface{})["type"])
+	assert.NotEmpty(t, created.Data["next_run_at"])
+
+	listCount := func(merchantID string) int {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/"+merchantID+"/schedules", nil)
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		var response struct {
+			Data []map[string]interface{} `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return len(response.Data)
+	}
+	assert.Equal(t, 1, listCount("merchant-1"))
+	assert.Equal(t, 0, listCount("merchant-2"))
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("DELETE", "/merchant-2/schedules/"+scheduleID, nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("DELETE", "/merchant-1/schedules/"+scheduleID, nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, 0, listCount("merchant-1"))
+}
+
+func TestCreateReportSchedule_Validation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	handler := NewTransactionHandler(services.NewTransactionService(&fakeTransactionRepo{}, nil))
+	router := newReportRouter(handler)
+
+	tests := map[string]string{
+		"missing name":     `{"schedule": {"frequency": "daily"}}`,
+		"bad frequency":    `{"name": "r", "schedule": {"frequency": "monthly"}}`,
+		"bad hour":         `{"name": "r", "schedule": {"frequency": "daily", "hour": 25}}`,
+		"bad format":       `{"name": "r", "format": "pdf", "schedule": {"frequency": "daily"}}`,
+		"bad timezone":     `{"name": "r", "timezone": "Mars/Base", "schedule": {"frequency": "daily"}}`,
+		"bad filter":       `{"name": "r", "filter": "bogus:eq", "schedule": {"frequency": "daily"}}`,
+		"bad preset":       `{"name": "r", "fields": ["@nope"], "schedule": {"frequency": "daily"}}`,
+		"http webhook":     `{"name": "r", "schedule": {"frequency": "daily"}, "delivery": {"type": "webhook", "url": "http://example.com/hook"}}`,
+		"loopback webhook": `{"name": "r", "schedule": {"frequency": "daily"}, "delivery": {"type": "webhook", "url": "https://127.0.0.1/hook"}}`,
+		"metadata webhook": `{"name": "r", "schedule": {"frequency": "daily"}, "delivery": {"type": "webhook", "url": "https://169.254.169.254/latest"}}`,
+		"unknown delivery": `{"name": "r", "schedule": {"frequency": "daily"}, "delivery": {"type": "email"}}`,
+		"store no storage": `{"name": "r", "schedule": {"frequency": "daily"}, "delivery": {"type": "store"}}`,
+		"malformed json":   `{"name": `,
+	}
+
+	for name, body := range tests {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/merchant-1/schedules", strings.NewReader(body))
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, name)
+	}
+}
+
+func TestRunReport_WritesCSVAndRecordsExportJob(t *testing.T) {
+	repo := &fakeTransactionRepo{pages: [][]models.Transaction{{{ID: "tx-1", RRN: "001"}, {ID: "tx-2", RRN: "002"}}}}
+	handler := NewTransactionHandler(services.NewTransactionService(repo, nil))
+
+	schedule := models.ReportSchedule{
+		ID:         "schedule-1",
+		MerchantID: "merchant-1",
+		Filter:     "response_code:eq:00",
+		Fields:     []string{"payment_tx_log_id", "rrn"},
+		Format:     "csv",
+		Timezone:   "UTC",
+		Delivery:   models.ReportDelivery{Type: models.ReportDeliveryStore},
+	}
+
+	var out []byte
+	var deliveredID, exportID string
+	entries := captureAuditEntries(t, func() {
+		var err error
+		exportID, err = handler.RunReport(schedule, func(id string, body io.Reader) error {
+			deliveredID = id
+			out, err = io.ReadAll(body)
+			return err
+		})
+		require.NoError(t, err)
+	})
+
+	assert.Equal(t, exportID, deliveredID)
+	records, err := csv.NewReader(bytes.NewReader(out)).ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"payment_tx_log_id", "rrn"}, {"tx-1", "001"}, {"tx-2", "002"}}, records)
+
+	jobs, total, err := handler.exportJobs.List("merchant-1", 1, 10)
+	require.NoError(t, err)
+	require.Equal(t, 1, total)
+	assert.Equal(t, exportID, jobs[0].ID)
+	assert.Equal(t, models.ExportStatusCompleted, jobs[0].Status)
+	assert.Equal(t, models.ExportDeliveryS3, jobs[0].Delivery)
+	assert.Equal(t, 2, jobs[0].RowCount)
+
+	job, _, err := handler.exportJobs.Get("merchant-1", exportID)
+	require.NoError(t, err)
+	assert.Equal(t, "merchant-1/"+exportID+".csv", job.ObjectKey)
+
+	require.Len(t, entries, 1)
+	assert.Equal(t, "scheduled report", entries[0]["endpoint"])
+	assert.Equal(t, "schedule-1", entries[0]["schedule_id"])
+	assert.Equal(t, exportID, entries[0]["request_id"])
+	assert.Equal(t, float64(2), entries[0]["row_count"])
+	assert.Equal(t, false, entries[0]["pan_requested"])
+}
+
+func TestRunReport_DefaultFieldsLeaveOutPAN(t *testing.T) {
+	repo := &fakeTransactionRepo{pages: [][]models.Transaction{{{ID: "tx-1"}}}}
+	handler := NewTransactionHandler(services.NewTransactionService(repo, nil))
+
+	schedule := models.ReportSchedule{
+		MerchantID: "merchant-1",
+		Format:     "csv",
+		Timezone:   "UTC",
+		Delivery:   models.ReportDelivery{Type: models.ReportDeliveryWebhook, URL: "https://example.com/hook"},
+	}
+
+	var header []string
+	entries := captureAuditEntries(t, func() {
+		_, err := handler.RunReport(schedule, func(_ string, body io.Reader) error {
+			var err error
+			header, err = csv.NewReader(body).Read()
+			io.Copy(io.Discard, body)
+			return err
+		})
+		require.NoError(t, err)
+	})
+
+	assert.Equal(t, config.DefaultReportFields, header)
+	assert.NotContains(t, header, "pan")
+	require.Len(t, entries, 1)
+	assert.Equal(t, false, entries[0]["pan_requested"])
+
+	jobs, _, err := handler.exportJobs.List("merchant-1", 1, 10)
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+	assert.Equal(t, models.ExportDeliveryWebhook, jobs[0].Delivery)
+}
@@ -0,0 +1,370 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"aken_reporting_service/internal/config"
+	"aken_reporting_service/internal/models"
+	"aken_reporting_service/internal/services"
+	"aken_reporting_service/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+const ndjsonContentType = "application/x-ndjson"
+
+// streamStatusTrailer is the trailer that tells a stream consumer how the stream ended
+const streamStatusTrailer = "X-Stream-Status"
+
+const (
+	streamStatusComplete    = "complete"
+	streamStatusMaxDuration = "max_duration"
+	streamStatusShutdown    = "shutdown"
+	streamStatusError       = "error"
+)
+
+// streamFlushRows is the number of rows written between flushes of a transaction stream
+var streamFlushRows = 500
+
+// streamLimiter holds the limits and polling settings of transaction streams and counts
+// the open streams of each merchant
+type streamLimiter struct {
+	maxDuration       time.Duration
+	heartbeatInterval time.Duration
+	maxPerMerchant    int
+	maxTotal          int
+	pollInterval      time.Duration
+	pollBatchSize     int
+
+	// shutdown is cancelled by closeAll to end every open stream
+	shutdown context.Context
+	closeAll context.CancelFunc
+
+	mu     sync.Mutex
+	active map[string]int
+	total  int
+}
+
+func newStreamLimiter() *streamLimiter {
+	shutdown, closeAll := context.WithCancel(context.Background())
+	return &streamLimiter{
+		maxDuration:       config.GetStreamMaxDuration(),
+		heartbeatInterval: config.GetStreamHeartbeatInterval(),
+		maxPerMerchant:    config.GetStreamMaxConcurrent(),
+		maxTotal:          config.GetStreamMaxConcurrentTotal(),
+		pollInterval:      config.GetStreamPollInterval(),
+		pollBatchSize:     config.GetStreamPollBatchSize(),
+		shutdown:          shutdown,
+		closeAll:          closeAll,
+		active:            make(map[string]int),
+	}
+}
+
+// CloseStreams ends every open transaction stream with the shutdown status, so consumers
+// reconnect elsewhere instead of holding up a graceful shutdown for the maximum stream
+// duration. It is registered with http.Server.RegisterOnShutdown.
+func (h *TransactionHandler) CloseStreams() {
+	h.streams.closeAll()
+}
+
+// Errors returned by streamLimiter.acquire
+var (
+	errMerchantStreamLimit = errors.New("merchant stream limit reached")
+	errStreamLimit         = errors.New("instance stream limit reached")
+)
+
+// acquire reserves a stream for the merchant. It fails when the merchant already has the
+// maximum number open, or the instance has its maximum open across merchants.
+func (l *streamLimiter) acquire(merchantID string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.active[merchantID] >= l.maxPerMerchant {
+		return errMerchantStreamLimit
+	}
+	if l.total >= l.maxTotal {
+		return errStreamLimit
+	}
+	l.active[merchantID]++
+	l.total++
+	return nil
+}
+
+// release frees a stream reserved by acquire
+func (l *streamLimiter) release(merchantID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.total--
+	l.active[merchantID]--
+	if l.active[merchantID] <= 0 {
+		delete(l.active, merchantID)
+	}
+}
+
+// streamWriter serializes the rows and heartbeats written to a stream. Headers are written
+// with the first output, so a query that fails up front can still be reported as JSON.
+type streamWriter struct {
+	c       *gin.Context
+	encoder *json.Encoder
+
+	mu      sync.Mutex
+	started bool
+	idle    bool // nothing was written since the last heartbeat tick
+	rows    int
+}
+
+func newStreamWriter(c *gin.Context) *streamWriter {
+	return &streamWriter{c: c, encoder: json.NewEncoder(c.Writer), idle: true}
+}
+
+// start writes the stream headers; the caller must hold mu
+func (w *streamWriter) start() {
+	if w.started {
+		return
+	}
+	w.started = true
+	w.c.Header("Content-Type", ndjsonContentType)
+	w.c.Header("Trailer", streamStatusTrailer)
+	w.c.Status(http.StatusOK)
+}
+
+func (w *streamWriter) writeRow(line interface{}) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.start()
+	if err := w.encoder.Encode(line); err != nil {
+		return err
+	}
+	w.idle = false
+	w.rows++
+	if w.rows%streamFlushRows == 0 {
+		w.c.Writer.Flush()
+	}
+	return nil
+}
+
+// heartbeat writes a blank line when no row was written since the previous tick, so
+// proxies do not close a stream that is waiting on a slow query
+func (w *streamWriter) heartbeat() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.idle {
+		w.start()
+		w.c.Writer.WriteString("\n")
+		w.c.Writer.Flush()
+	}
+	w.idle = true
+}
+
+// finish flushes what is buffered, writing the headers if nothing was written yet, and
+// sets the status trailer
+func (w *streamWriter) finish(status string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.start()
+	w.c.Writer.Flush()
+	w.c.Writer.Header().Set(streamStatusTrailer, status)
+}
+
+// hasStarted reports whether the headers were written
+func (w *streamWriter) hasStarted() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.started
+}
+
+// rowCount returns the number of rows written
+func (w *streamWriter) rowCount() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.rows
+}
+
+// StreamTransactions handles GET /api/v2/transactions/stream
+// It writes every matching transaction as newline-delimited JSON, one transaction per line,
+// reading rows from a database cursor so the result set is never held in memory. It accepts
+// the filter, fields, sort, timezone and pan_format parameters of GetTransactions; there is
+// no pagination. A client disconnect cancels the request context, which aborts the query.
+// A stream is ended cleanly after the maximum duration, a merchant may only hold a limited
+// number open at once, and a blank line is written when the stream is idle; the
+// X-Stream-Status trailer reports whether the stream was complete. With follow=true the
+// stream is ordered by updated_at and keeps polling for new rows until it is closed.
+func (h *TransactionHandler) StreamTransactions(c *gin.Context) {
+	merchantID := getMerchantID(c)
+	if merchantID == "" {
+		h.sendErrorResponse(c, http.StatusUnauthorized, config.ErrorCodeAuthFailed, "Invalid or missing authentication credentials", nil)
+		return
+	}
+
+	filterParam := c.Query("filter")
+	timezone := c.DefaultQuery("timezone", "UTC")
+	panFormat := c.DefaultQuery("pan_format", "bin_id_and_pan_id")
+
+	if err := h.transactionService.ValidateTimezone(timezone); err != nil {
+		h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeInvalidTimezone, err.Error(), nil)
+		return
+	}
+
+	fields, err := parseFields(c.Query("fields"))
+	if err != nil {
+		h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeInvalidField, err.Error(), nil)
+		return
+	}
+
+	filter, err := h.transactionService.ParseAdvancedFilter(filterParam, timezone)
+	if err != nil {
+		h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeInvalidFilter, fmt.Sprintf("Invalid filter expression: %v", err), nil)
+		return
+	}
+
+	if err := parseInclusionFlags(c, filter); err != nil {
+		h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeBadRequest, err.Error(), nil)
+		return
+	}
+
+	follow, err := strconv.ParseBool(c.DefaultQuery("follow", "false"))
+	if err != nil {
+		h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeBadRequest, "Invalid follow parameter (must be true or false)", nil)
+		return
+	}
+	if follow && c.Query("sort") != "" {
+		h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeBadRequest, "sort is not supported with follow=true; followed streams are ordered by updated_at", nil)
+		return
+	}
+
+	sort, err := h.transactionService.ParseSort(c.Query("sort"))
+	if err != nil {
+		h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeInvalidSort, fmt.Sprintf("Invalid sort expression: %v", err), sortErrorDetails(err))
+		return
+	}
+
+	params := &services.GetTransactionsParams{
+		Filter:    filter,
+		Fields:    fields,
+		Sort:      sort,
+		Timezone:  timezone,
+		PANFormat: panFormat,
+	}
+	h.setEstimatedDuration(c, merchantID, filter)
+
+	if err := h.streams.acquire(merchantID); err != nil {
+		if errors.Is(err, errStreamLimit) {
+			// The instance is saturated, not the merchant; another instance may have room
+			h.sendErrorResponse(c, http.StatusServiceUnavailable, config.ErrorCodeServiceUnavailable,
+				"Too many transaction streams are open; retry later", gin.H{"retry_after": 30})
+			return
+		}
+		h.sendErrorResponse(c, http.StatusTooManyRequests, config.ErrorCodeRateLimited,
+			fmt.Sprintf("At most %d transaction streams may be open at once", h.streams.maxPerMerchant), nil)
+		return
+	}
+	defer h.streams.release(merchantID)
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.streams.maxDuration)
+	defer cancel()
+	stopOnShutdown := context.AfterFunc(h.streams.shutdown, cancel)
+	defer stopOnShutdown()
+
+	writer := newStreamWriter(c)
+	heartbeatDone := make(chan struct{})
+	go func() {
+		defer close(heartbeatDone)
+		ticker := time.NewTicker(h.streams.heartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				writer.heartbeat()
+			}
+		}
+	}()
+
+	writeRow := func(tx *models.Transaction) error {
+		var line interface{} = tx
+		if len(fields) > 0 {
+			line = tx.FilterFields(fields)
+		}
+		return writer.writeRow(line)
+	}
+	if follow {
+		err = h.transactionService.FollowTransactions(ctx, merchantID, params, h.streams.pollInterval, h.streams.pollBatchSize, writeRow)
+	} else {
+		err = h.transactionService.StreamTransactions(ctx, merchantID, params, writeRow)
+	}
+
+	// The heartbeat must stop before the response is finished
+	cancel()
+	<-heartbeatDone
+
+	// Rows sent before a failure were still read
+	if err == nil || writer.rowCount() > 0 {
+		auditDataAccess(c, merchantID, "GET /transactions/stream", filter, writer.rowCount(), fields)
+	}
+
+	if err != nil {
+		if c.Request.Context().Err() != nil {
+			utils.LogTrace("Transaction stream cancelled by client", map[string]interface{}{
+				"merchant_id": merchantID,
+				"rows":        writer.rowCount(),
+			})
+			c.Abort()
+			return
+		}
+
+		if h.streams.shutdown.Err() != nil {
+			utils.LogInfo("Transaction stream closed for shutdown", map[string]interface{}{
+				"merchant_id": merchantID,
+				"rows":        writer.rowCount(),
+			})
+			writer.finish(streamStatusShutdown)
+			return
+		}
+
+		if ctx.Err() == context.DeadlineExceeded {
+			utils.LogInfo("Transaction stream reached its maximum duration", map[string]interface{}{
+				"merchant_id":  merchantID,
+				"rows":         writer.rowCount(),
+				"max_duration": h.streams.maxDuration.String(),
+			})
+			writer.finish(streamStatusMaxDuration)
+			return
+		}
+
+		utils.LogError("Database error in StreamTransactions", err, map[string]interface{}{
+			"merchant_id": merchantID,
+			"filter":      filterParam,
+			"rows":        writer.rowCount(),
+		})
+
+		if writer.hasStarted() {
+			// Output was already sent; the trailer and the truncated stream are the only
+			// signal we can give
+			writer.finish(streamStatusError)
+			c.Abort()
+			return
+		}
+
+		if config.IsInternalError(err) {
+			h.sendErrorResponse(c, http.StatusServiceUnavailable, config.ErrorCodeServiceUnavailable, "",
+				gin.H{"retry_after": 30})
+		} else {
+			h.sendErrorResponse(c, http.StatusInternalServerError, config.ErrorCodeDatabaseError, "", nil)
+		}
+		return
+	}
+
+	writer.finish(streamStatusComplete)
+
+	utils.LogTrace("Transaction stream completed", map[string]interface{}{
+		"merchant_id": merchantID,
+		"rows":        writer.rowCount(),
+	})
+}
@@ -0,0 +1,272 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"aken_reporting_service/internal/config"
+	"aken_reporting_service/internal/models"
+	"aken_reporting_service/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newStreamRouter(repo *fakeTransactionRepo) *gin.Engine {
+	return newStreamHandlerRouter(NewTransactionHandler(services.NewTransactionService(repo, nil)))
+}
+
+func newStreamHandlerRouter(handler *TransactionHandler) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.GET("/transactions/stream", func(c *gin.Context) {
+		c.Set("merchantID", "merchant-1")
+		handler.StreamTransactions(c)
+	})
+	return router
+}
+
+func TestStreamTransactions_WritesOneTransactionPerLine(t *testing.T) {
+	originalFlushRows := streamFlushRows
+	streamFlushRows = 2
+	defer func() { streamFlushRows = originalFlushRows }()
+
+	repo := &fakeTransactionRepo{pages: [][]models.Transaction{
+		{{ID: "tx-1", RRN: "001", Amount: 100}, {ID: "tx-2", RRN: "002", Amount: 200}},
+		{{ID: "tx-3", RRN: "003", Amount: 300}},
+	}}
+	router := newStreamRouter(repo)
+
+	req, _ := http.NewRequest("GET", "/transactions/stream?fields=payment_tx_log_id,rrn&filter=amount:gte:100&timezone=Africa/Cairo", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, ndjsonContentType, w.Header().Get("Content-Type"))
+	assert.True(t, w.Flushed)
+
+	var lines []map[string]interface{}
+	scanner := bufio.NewScanner(strings.NewReader(w.Body.String()))
+	for scanner.Scan() {
+		var line map[string]interface{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &line))
+		lines = append(lines, line)
+	}
+	assert.Equal(t, []map[string]interface{}{
+		{"payment_tx_log_id": "tx-1", "rrn": "001"},
+		{"payment_tx_log_id": "tx-2", "rrn": "002"},
+		{"payment_tx_log_id": "tx-3", "rrn": "003"},
+	}, lines)
+
+	assert.Equal(t, "merchant-1", repo.lastMerchantID)
+	assert.Equal(t, "Africa/Cairo", repo.lastTimezone)
+	require.NotNil(t, repo.lastFilter)
+	require.NotNil(t, repo.lastFilter.AmountMin)
+	assert.Equal(t, int64(100), *repo.lastFilter.AmountMin)
+}
+
+func TestStreamTransactions_EmptyResult(t *testing.T) {
+	router := newStreamRouter(&fakeTransactionRepo{})
+
+	req, _ := http.NewRequest("GET", "/transactions/stream", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, ndjsonContentType, w.Header().Get("Content-Type"))
+	assert.Empty(t, w.Body.String())
+}
+
+func TestStreamTransactions_ErrorBeforeFirstRowIsJSON(t *testing.T) {
+	router := newStreamRouter(&fakeTransactionRepo{streamErr: errors.New("query failed")})
+
+	req, _ := http.NewRequest("GET", "/transactions/stream", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+
+	assert.Equal(t, config.ErrorCodeDatabaseError, decodeErrorResponse(t, w)["code"])
+}
+
+func TestStreamTransactions_ErrorAfterRowsTruncatesStream(t *testing.T) {
+	repo := &fakeTransactionRepo{
+		pages:     [][]models.Transaction{{{ID: "tx-1"}}},
+		streamErr: errors.New("query failed"),
+	}
+	router := newStreamRouter(repo)
+
+	req, _ := http.NewRequest("GET", "/transactions/stream?fields=payment_tx_log_id", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "{\"payment_tx_log_id\":\"tx-1\"}\n", w.Body.String())
+	assert.Equal(t, streamStatusError, w.Result().Trailer.Get(streamStatusTrailer))
+}
+
+func TestStreamTransactions_HeartbeatWhileIdle(t *testing.T) {
+	repo := &fakeTransactionRepo{
+		pages: [][]models.Transaction{{{ID: "tx-1"}}},
+		delay: 60 * time.Millisecond,
+	}
+	handler := NewTransactionHandler(services.NewTransactionService(repo, nil))
+	handler.streams.heartbeatInterval = 10 * time.Millisecond
+	router := newStreamHandlerRouter(handler)
+
+	req, _ := http.NewRequest("GET", "/transactions/stream?fields=payment_tx_log_id", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, ndjsonContentType, w.Header().Get("Content-Type"))
+	body := w.Body.String()
+	assert.True(t, strings.HasPrefix(body, "\n"), "expected heartbeats before the first row, got %q", body)
+	assert.Equal(t, "{\"payment_tx_log_id\":\"tx-1\"}\n", strings.TrimLeft(body, "\n"))
+	assert.Equal(t, streamStatusComplete, w.Result().Trailer.Get(streamStatusTrailer))
+}
+
+func TestStreamTransactions_MaxDurationEndsStream(t *testing.T) {
+	repo := &fakeTransactionRepo{
+		pages: [][]models.Transaction{{{ID: "tx-1"}}},
+		delay: time.Second,
+	}
+	handler := NewTransactionHandler(services.NewTransactionService(repo, nil))
+	handler.streams.maxDuration = 30 * time.Millisecond
+	router := newStreamHandlerRouter(handler)
+
+	req, _ := http.NewRequest("GET", "/transactions/stream", nil)
+	w := httptest.NewRecorder()
+	start := time.Now()
+	router.ServeHTTP(w, req)
+
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, ndjsonContentType, w.Header().Get("Content-Type"))
+	assert.Empty(t, w.Body.String())
+	assert.Equal(t, streamStatusMaxDuration, w.Result().Trailer.Get(streamStatusTrailer))
+}
+
+func TestStreamTransactions_ConcurrentStreamLimit(t *testing.T) {
+	repo := &fakeTransactionRepo{}
+	handler := NewTransactionHandler(services.NewTransactionService(repo, nil))
+	handler.streams.maxPerMerchant = 1
+	router := newStreamHandlerRouter(handler)
+
+	require.NoError(t, handler.streams.acquire("merchant-1"))
+
+	req, _ := http.NewRequest("GET", "/transactions/stream", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, config.ErrorCodeRateLimited, decodeErrorResponse(t, w)["code"])
+	assert.Equal(t, 0, repo.calls)
+
+	// Another merchant is not affected, and the slot is usable again once released
+	assert.NoError(t, handler.streams.acquire("merchant-2"))
+	handler.streams.release("merchant-1")
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 1, repo.calls)
+	assert.Empty(t, handler.streams.active["merchant-1"])
+}
+
+func TestStreamTransactions_InstanceStreamLimit(t *testing.T) {
+	repo := &fakeTransactionRepo{}
+	handler := NewTransactionHandler(services.NewTransactionService(repo, nil))
+	handler.streams.maxTotal = 2
+	router := newStreamHandlerRouter(handler)
+
+	require.NoError(t, handler.streams.acquire("merchant-2"))
+	require.NoError(t, handler.streams.acquire("merchant-3"))
+
+	req, _ := http.NewRequest("GET", "/transactions/stream", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, config.ErrorCodeServiceUnavailable, decodeErrorResponse(t, w)["code"])
+	assert.Equal(t, 0, repo.calls)
+
+	handler.streams.release("merchant-3")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 1, handler.streams.total)
+}
+
+func TestStreamTransactions_FollowPollsUntilClosed(t *testing.T) {
+	updatedAt := time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)
+	repo := &fakeTransactionRepo{pages: [][]models.Transaction{
+		{{ID: "tx-1", UpdatedAt: updatedAt}, {ID: "tx-2", UpdatedAt: updatedAt}},
+	}}
+	handler := NewTransactionHandler(services.NewTransactionService(repo, nil))
+	handler.streams.maxDuration = 50 * time.Millisecond
+	handler.streams.pollInterval = 5 * time.Millisecond
+	router := newStreamHandlerRouter(handler)
+
+	req, _ := http.NewRequest("GET", "/transactions/stream?follow=true&fields=payment_tx_log_id", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "{\"payment_tx_log_id\":\"tx-1\"}\n{\"payment_tx_log_id\":\"tx-2\"}\n", w.Body.String())
+	assert.Greater(t, repo.calls, 1, "the stream should keep polling")
+	assert.Equal(t, streamStatusMaxDuration, w.Result().Trailer.Get(streamStatusTrailer))
+}
+
+func TestStreamTransactions_CloseStreamsEndsOpenStreams(t *testing.T) {
+	updatedAt := time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)
+	repo := &fakeTransactionRepo{pages: [][]models.Transaction{{{ID: "tx-1", UpdatedAt: updatedAt}}}}
+	handler := NewTransactionHandler(services.NewTransactionService(repo, nil))
+	handler.streams.pollInterval = 5 * time.Millisecond
+	router := newStreamHandlerRouter(handler)
+
+	time.AfterFunc(30*time.Millisecond, handler.CloseStreams)
+
+	req, _ := http.NewRequest("GET", "/transactions/stream?follow=true&fields=payment_tx_log_id", nil)
+	w := httptest.NewRecorder()
+	start := time.Now()
+	router.ServeHTTP(w, req)
+
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+	assert.Equal(t, "{\"payment_tx_log_id\":\"tx-1\"}\n", w.Body.String())
+	assert.Equal(t, streamStatusShutdown, w.Result().Trailer.Get(streamStatusTrailer))
+}
+
+func TestStreamTransactions_FollowRejectsSort(t *testing.T) {
+	repo := &fakeTransactionRepo{}
+	router := newStreamRouter(repo)
+
+	for _, query := range []string{"follow=true&sort=amount:desc", "follow=yes"} {
+		req, _ := http.NewRequest("GET", "/transactions/stream?"+query, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+	assert.Equal(t, 0, repo.calls)
+}
+
+func TestStreamTransactions_InvalidFilter(t *testing.T) {
+	repo := &fakeTransactionRepo{}
+	router := newStreamRouter(repo)
+
+	req, _ := http.NewRequest("GET", "/transactions/stream?filter=amount:between:abc", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, 0, repo.calls)
+}
@@ -1,8 +1,13 @@
 package handlers
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -18,16 +23,31 @@ import (
 
 type TransactionHandler struct {
 	transactionService services.TransactionService
+	exportJobs         services.ExportJobStore
+	exportUploader     services.ExportUploader // nil when S3 export delivery is not configured
+	reportSchedules    services.ReportScheduleStore
+	streams            *streamLimiter
 }
 
 func NewTransactionHandler(transactionService services.TransactionService) *TransactionHandler {
+	var exportUploader services.ExportUploader
+	if config.IsS3ExportEnabled() {
+		exportUploader = services.NewS3Uploader(config.GetS3Config())
+	}
+
 	return &TransactionHandler{
 		transactionService: transactionService,
+		exportJobs:         services.NewExportJobStore(nil),
+		exportUploader:     exportUploader,
+		reportSchedules:    services.NewReportScheduleStore(nil),
+		streams:            newStreamLimiter(),
 	}
 }
 
 // GetTransactions handles GET /api/v2/transactions
 func (h *TransactionHandler) GetTransactions(c *gin.Context) {
+	startTime := time.Now()
+
 	merchantID := getMerchantID(c)
 	if merchantID == "" {
 		utils.LogWarn("Unauthorized transaction request - missing merchant ID", map[string]interface{}{
@@ -49,9 +69,10 @@ func (h *TransactionHandler) GetTransactions(c *gin.Context) {
 	filterParam := c.Query("filter")
 	sortParam := c.Query("sort")
 	pageParam := c.DefaultQuery("page", "1")
-	limitParam := c.DefaultQuery("limit", "100")
+	limitParam := c.DefaultQuery("limit", strconv.Itoa(config.GetDefaultPageSize(config.PageSizeEndpointList)))
 	timezone := c.DefaultQuery("timezone", "UTC")
 	panFormat := c.DefaultQuery("pan_format", "bin_id_and_pan_id")
+	includeTotalParam := c.DefaultQuery("include_total", "true")
 
 	// Parse pagination
 	page, err := strconv.Atoi(pageParam)
@@ -66,10 +87,56 @@ func (h *TransactionHandler) GetTransactions(c *gin.Context) {
 		return
 	}
 
+	includeTotal, err := strconv.ParseBool(includeTotalParam)
+	if err != nil {
+		h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeBadRequest, "Invalid include_total parameter (must be true or false)", nil)
+		return
+	}
+
+	includeTotals, err := strconv.ParseBool(c.DefaultQuery("include_totals", "false"))
+	if err != nil {
+		h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeBadRequest, "Invalid include_totals parameter (must be true or false)", nil)
+		return
+	}
+
+	// Count only: run the count query and return no rows
+	countOnly, err := strconv.ParseBool(c.DefaultQuery("count_only", "false"))
+	if err != nil {
+		h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeBadRequest, "Invalid count_only parameter (must be true or false)", nil)
+		return
+	}
+
+	// Response format: ?format wins over the Accept header
+	format := strings.ToLower(c.Query("format"))
+	switch format {
+	case "":
+		utils.AddVary(c, "Accept")
+		if c.NegotiateFormat(gin.MIMEJSON, csvContentType) == csvContentType {
+			format = "csv"
+		}
+	case "json", "csv":
+	default:
+		h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeBadRequest, "Invalid format parameter (must be json or csv)", nil)
+		return
+	}
+
+	// Suggestions cost an extra query, so they are only computed on request
+	includeSuggestions, err := strconv.ParseBool(c.DefaultQuery("suggestions", "false"))
+	if err != nil {
+		h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeBadRequest, "Invalid suggestions parameter (must be true or false)", nil)
+		return
+	}
+
+	if err := h.transactionService.ValidateTimezone(timezone); err != nil {
+		h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeInvalidTimezone, err.Error(), nil)
+		return
+	}
+
 	// Parse fields
-	var fields []string
-	if fieldsParam != "" {
-		fields = parseCommaSeparated(fieldsParam)
+	fields, err := parseFields(fieldsParam)
+	if err != nil {
+		h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeInvalidField, err.Error(), nil)
+		return
 	}
 
 	// Parse filter
@@ -79,22 +146,89 @@ func (h *TransactionHandler) GetTransactions(c *gin.Context) {
 		return
 	}
 
+	if err := parseInclusionFlags(c, filter); err != nil {
+		h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeBadRequest, err.Error(), nil)
+		return
+	}
+
 	// Parse sort
 	sort, err := h.transactionService.ParseSort(sortParam)
 	if err != nil {
-		h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeInvalidSort, fmt.Sprintf("Invalid sort expression: %v", err), nil)
+		h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeInvalidSort, fmt.Sprintf("Invalid sort expression: %v", err), sortErrorDetails(err))
+		return
+	}
+
+	// Parse date field - the column date filters and the tx_date_time sort apply to
+	dateField := c.DefaultQuery("date_field", models.DateFieldUpdatedAt)
+	if !config.DateFields[dateField] {
+		h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeBadRequest, fmt.Sprintf("Invalid date_field '%s' (must be created_at or updated_at)", dateField), nil)
 		return
 	}
+	filter.DateField = dateField
+
+	if countOnly {
+		h.sendTransactionCount(c, merchantID, filter, filterParam, startTime)
+		return
+	}
+
+	// Parse facets
+	var facets []string
+	if facetsParam := c.Query("facets"); facetsParam != "" {
+		facets = parseCommaSeparated(facetsParam)
+		for _, facet := range facets {
+			if !config.SupportedFacets[facet] {
+				h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeBadRequest, fmt.Sprintf("Invalid facet '%s' (supported: day)", facet), nil)
+				return
+			}
+		}
+	}
+
+	// Parse cursor - its presence (even empty, for the first page) opts in to keyset pagination
+	cursorParam, cursorMode := c.GetQuery("cursor")
+	var cursor *models.TransactionCursor
+	if cursorMode {
+		if sortParam != "" && sortParam != "tx_date_time:desc" {
+			h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeInvalidSort, "Cursor pagination only supports sort=tx_date_time:desc", nil)
+			return
+		}
+		if dateField != models.DateFieldUpdatedAt {
+			h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeBadRequest, "Cursor pagination only supports date_field=updated_at", nil)
+			return
+		}
+		if cursorParam != "" {
+			cursor, err = models.DecodeTransactionCursor(cursorParam, config.GetCursorSigningKey(), merchantID)
+			if err != nil {
+				h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeBadRequest, "Invalid cursor parameter", nil)
+				return
+			}
+		}
+	}
+
+	// Generated SQL is only returned to admins; the parameter is ignored for everyone else
+	debugSQL := false
+	if config.IsAdminMerchant(merchantID) {
+		debugSQL, err = strconv.ParseBool(c.DefaultQuery("debug_sql", "false"))
+		if err != nil {
+			h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeBadRequest, "Invalid debug_sql parameter (must be true or false)", nil)
+			return
+		}
+	}
 
 	// Prepare service parameters
 	params := &services.GetTransactionsParams{
-		Filter:    filter,
-		Fields:    fields,
-		Sort:      sort,
-		Page:      page,
-		Limit:     limit,
-		Timezone:  timezone,
-		PANFormat: panFormat,
+		Filter:        filter,
+		Fields:        fields,
+		Sort:          sort,
+		Page:          page,
+		Limit:         limit,
+		Timezone:      timezone,
+		PANFormat:     panFormat,
+		SkipCount:     !includeTotal,
+		CursorMode:    cursorMode,
+		Cursor:        cursor,
+		Facets:        facets,
+		IncludeTotals: includeTotals,
+		DebugSQL:      debugSQL,
 	}
 
 	// Get transactions
@@ -123,84 +257,744 @@ func (h *TransactionHandler) GetTransactions(c *gin.Context) {
 		"total_count": result.TotalCount,
 		"page":        result.Page,
 	})
+	if format == "csv" {
+		if h.sendCSVPage(c, result.Transactions, fields) {
+			auditDataAccess(c, merchantID, "GET /transactions", filter, len(result.Transactions), fields)
+		}
+		return
+	}
 
 	// Build response with proper field handling
-	var responseData interface{}
+	responseData := buildResponseData(result.Transactions, fields)
 
+	response := gin.H{
+		"data": responseData,
+		"meta": gin.H{
+			"pagination":        buildPaginationMeta(result),
+			"timestamp":         time.Now().UTC().Format(time.RFC3339),
+			"version":           config.APIVersion,
+			"execution_time_ms": time.Since(startTime).Milliseconds(),
+			"cached":            result.Cached,
+		},
+		"links": h.buildPaginationLinks(c, result),
+	}
+
+	if debugSQL {
+		response["meta"].(gin.H)["debug"] = gin.H{"sql": result.DebugSQL}
+	}
+
+	if includeTotals && result.TotalAmount != nil {
+		response["meta"].(gin.H)["totals"] = gin.H{"total_amount": *result.TotalAmount}
+	}
+
+	// Only an empty first page means nothing matched; later pages can run past the end
+	if includeSuggestions && len(result.Transactions) == 0 && page == 1 && cursor == nil {
+		if suggestions := h.emptyResultSuggestions(merchantID, filter, timezone); suggestions != nil {
+			response["meta"].(gin.H)["suggestions"] = suggestions
+		}
+	}
+
+	if len(facets) > 0 {
+		dayFacets := result.DayFacets
+		if dayFacets == nil {
+			dayFacets = []models.DayFacet{}
+		}
+		response["meta"].(gin.H)["facets"] = gin.H{"day": dayFacets}
+	}
+
+	if h.sendBoundedJSON(c, response) {
+		auditDataAccess(c, merchantID, "GET /transactions", filter, len(result.Transactions), fields)
+	}
+}
+
+// sendTransactionCount responds to a count_only list request with the number of matching
+// transactions in meta.total and an empty data array
+func (h *TransactionHandler) sendTransactionCount(c *gin.Context, merchantID string, filter *models.TransactionFilter, filterParam string, startTime time.Time) {
+	total, err := h.transactionService.CountTransactions(merchantID, filter)
+	if err != nil {
+		utils.LogError("Database error in GetTransactions count", err, map[string]interface{}{
+			"merchant_id": merchantID,
+			"filter":      filterParam,
+		})
+
+		if config.IsInternalError(err) {
+			h.sendErrorResponse(c, http.StatusServiceUnavailable, config.ErrorCodeServiceUnavailable, "",
+				gin.H{"retry_after": 30})
+		} else {
+			h.sendErrorResponse(c, http.StatusInternalServerError, config.ErrorCodeDatabaseError, "", nil)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": []interface{}{},
+		"meta": gin.H{
+			"total":             total,
+			"timestamp":         time.Now().UTC().Format(time.RFC3339),
+			"version":           config.APIVersion,
+			"execution_time_ms": time.Since(startTime).Milliseconds(),
+		},
+	})
+}
+
+// GetTransactionByID handles GET /api/v2/transactions/:id
+// The response carries ETag and Last-Modified validators derived from updated_at; a request
+// whose If-None-Match or If-Modified-Since matches them gets a 304 without a body.
+func (h *TransactionHandler) GetTransactionByID(c *gin.Context) {
+	merchantID := getMerchantID(c)
+	if merchantID == "" {
+		h.sendErrorResponse(c, http.StatusUnauthorized, config.ErrorCodeAuthFailed, "Invalid or missing authentication credentials", nil)
+		return
+	}
+
+	transactionID := c.Param("id")
+	if transactionID == "" {
+		h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeBadRequest, "Transaction ID is required", nil)
+		return
+	}
+
+	fieldsParam := c.Query("fields")
+	timezone := c.DefaultQuery("timezone", "UTC")
+	panFormat := c.DefaultQuery("pan_format", "bin_id_and_pan_id")
+
+	if err := h.transactionService.ValidateTimezone(timezone); err != nil {
+		h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeInvalidTimezone, err.Error(), nil)
+		return
+	}
+
+	fields, err := parseFields(fieldsParam)
+	if err != nil {
+		h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeInvalidField, err.Error(), nil)
+		return
+	}
+
+	// updated_at is always read, for the conditional request validators
+	queryFields := fields
+	if len(queryFields) == 0 {
+		queryFields = config.DefaultFields
+	}
+	if !containsField(queryFields, "updated_at") {
+		queryFields = append(append([]string{}, queryFields...), "updated_at")
+	}
+
+	transaction, err := h.transactionService.GetTransactionByID(merchantID, transactionID, queryFields, timezone, panFormat)
+	if err != nil {
+		h.sendErrorResponse(c, http.StatusInternalServerError, config.ErrorCodeDatabaseError, fmt.Sprintf("Failed to retrieve transaction: %v", err), nil)
+		return
+	}
+
+	// Not-found must be checked before field filtering so it is never masked by an empty object
+	if transaction == nil {
+		h.sendErrorResponse(c, http.StatusNotFound, config.ErrorCodeTxNotFound, fmt.Sprintf("Transaction with ID %s not found", transactionID), nil)
+		return
+	}
+
+	// Polling clients send back the validators; an unchanged transaction gets a bodiless 304
+	if !transaction.UpdatedAt.IsZero() {
+		etag := transactionETag(transaction, fields, timezone, panFormat)
+		setValidators(c, etag, transaction.UpdatedAt)
+		if notModified(c, etag, transaction.UpdatedAt) {
+			c.Status(http.StatusNotModified)
+			return
+		}
+	}
+
+	auditDataAccess(c, merchantID, "GET /transactions/:id", nil, 1, fields)
+
+	// Apply the same field selection as the list endpoint
+	var responseData interface{} = transaction
 	if len(fields) > 0 {
-		// Specific fields requested - apply field filtering
-		var filteredData []map[string]interface{}
-		for _, tx := range result.Transactions {
-			filteredTx := tx.FilterFields(fields)
-			filteredData = append(filteredData, filteredTx)
+		responseData = transaction.FilterFields(fields)
+	}
+
+	response := gin.H{
+		"data": responseData,
+		"meta": gin.H{
+			"timestamp": time.Now().UTC().Format(time.RFC3339),
+			"version":   config.APIVersion,
+		},
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// BatchGetTransactions handles POST /api/v2/transactions/batch
+// It returns the merchant's transactions matching up to MaxBatchIDs ids, or RRNs from a
+// reconciliation file, in one query, and lists the requested values that matched none so
+// clients can reconcile. An RRN is not unique, so it may return several transactions; at
+// most MaxBatchRRNMatches are returned and meta.has_more reports that more matched.
+func (h *TransactionHandler) BatchGetTransactions(c *gin.Context) {
+	merchantID := getMerchantID(c)
+	if merchantID == "" {
+		h.sendErrorResponse(c, http.StatusUnauthorized, config.ErrorCodeAuthFailed, "Invalid or missing authentication credentials", nil)
+		return
+	}
+
+	var batchReq models.TransactionBatchRequest
+	if err := c.ShouldBindJSON(&batchReq); err != nil {
+		h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeBadRequest, fmt.Sprintf("Invalid request body: %v", err), nil)
+		return
+	}
+	if batchReq.IDs != nil && batchReq.RRNs != nil {
+		h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeBadRequest, "Send either ids or rrns, not both", nil)
+		return
+	}
+
+	byRRN := batchReq.RRNs != nil
+	values, key, noun := batchReq.IDs, "ids", "transaction IDs"
+	if byRRN {
+		values, key, noun = batchReq.RRNs, "rrns", "RRNs"
+	}
+	if len(values) == 0 {
+		h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeBadRequest, "ids or rrns must contain at least one value", nil)
+		return
+	}
+	if len(values) > config.MaxBatchIDs {
+		var details gin.H
+		if !byRRN {
+			details = gin.H{"max_ids": config.MaxBatchIDs}
+		}
+		h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeBadRequest,
+			fmt.Sprintf("%s may contain at most %d %s", key, config.MaxBatchIDs, noun), details)
+		return
+	}
+
+	// Transaction ids are looked up lowercased, the form they are stored in; not_found
+	// still reports them as sent
+	lookup := make([]string, len(values))
+	for i, value := range values {
+		value = strings.TrimSpace(value)
+		if !byRRN {
+			if !uuidPattern.MatchString(value) {
+				h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeBadRequest,
+					fmt.Sprintf("Invalid transaction ID '%s' (must be a UUID)", value), nil)
+				return
+			}
+			value = strings.ToLower(value)
 		}
-		responseData = filteredData
+		lookup[i] = value
+	}
+
+	timezone := c.DefaultQuery("timezone", "UTC")
+	panFormat := c.DefaultQuery("pan_format", "bin_id_and_pan_id")
+
+	if err := h.transactionService.ValidateTimezone(timezone); err != nil {
+		h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeInvalidTimezone, err.Error(), nil)
+		return
+	}
+
+	fields, err := parseFields(c.Query("fields"))
+	if err == nil && len(fields) > 0 {
+		err = h.transactionService.ValidateFields(fields)
+	}
+	if err != nil {
+		h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeInvalidField, err.Error(), nil)
+		return
+	}
+
+	var transactions []models.Transaction
+	var hasMore bool
+	if byRRN {
+		// rrn is always read, to tell which RRNs matched
+		queryFields := fields
+		if len(queryFields) > 0 && !containsField(queryFields, "rrn") {
+			queryFields = append(append([]string{}, queryFields...), "rrn")
+		}
+		transactions, hasMore, err = h.transactionService.GetTransactionsByRRNs(merchantID, lookup, queryFields, timezone, panFormat)
 	} else {
-		// No fields specified - return all fields in proper JSON format
-		// The postProcessTransactions should have set the computed fields correctly
-		responseData = result.Transactions
+		transactions, err = h.transactionService.GetTransactionsByIDs(merchantID, lookup, fields, timezone, panFormat)
+	}
+	if err != nil {
+		utils.LogError("Database error in BatchGetTransactions", err, map[string]interface{}{
+			"merchant_id": merchantID,
+			key:           len(values),
+		})
+		if config.IsInternalError(err) {
+			h.sendErrorResponse(c, http.StatusServiceUnavailable, config.ErrorCodeServiceUnavailable, "",
+				gin.H{"retry_after": 30})
+		} else {
+			h.sendErrorResponse(c, http.StatusInternalServerError, config.ErrorCodeDatabaseError, "", nil)
+		}
+		return
+	}
+
+	found := make(map[string]bool, len(transactions))
+	for _, tx := range transactions {
+		if byRRN {
+			found[tx.RRN] = true
+		} else {
+			found[strings.ToLower(tx.ID)] = true
+		}
+	}
+	notFound := []string{}
+	seen := make(map[string]bool, len(values))
+	for i, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" || seen[lookup[i]] {
+			continue
+		}
+		seen[lookup[i]] = true
+		if !found[lookup[i]] {
+			notFound = append(notFound, value)
+		}
+	}
+
+	sent := h.sendBoundedJSON(c, gin.H{
+		"data":      buildResponseData(transactions, fields),
+		"not_found": notFound,
+		"meta": gin.H{
+			"requested": len(seen),
+			"found":     len(seen) - len(notFound),
+			"has_more":  hasMore,
+			"timestamp": time.Now().UTC().Format(time.RFC3339),
+			"version":   config.APIVersion,
+		},
+	})
+	if sent {
+		auditDataAccess(c, merchantID, "POST /transactions/batch", nil, len(transactions), fields)
+	}
+}
+
+// GetCurrencies handles GET /api/v2/currencies
+// It returns the currency reference data that amounts are formatted with: curr_delim is
+// the exponent, the number of minor unit digits.
+func (h *TransactionHandler) GetCurrencies(c *gin.Context) {
+	result, err := h.transactionService.GetCurrencies()
+	if err != nil {
+		utils.LogError("Database error in GetCurrencies", err, nil)
+		if config.IsInternalError(err) {
+			h.sendErrorResponse(c, http.StatusServiceUnavailable, config.ErrorCodeServiceUnavailable, "",
+				gin.H{"retry_after": 30})
+		} else {
+			h.sendErrorResponse(c, http.StatusInternalServerError, config.ErrorCodeDatabaseError, "", nil)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": result.Currencies,
+		"meta": gin.H{
+			"count":      len(result.Currencies),
+			"timestamp":  time.Now().UTC().Format(time.RFC3339),
+			"version":    config.APIVersion,
+			"cached":     result.Cached,
+			"data_as_of": result.DataAsOf.Format(time.RFC3339),
+		},
+	})
+}
+
+// stanPattern matches a system trace audit number, up to six digits
+var stanPattern = regexp.MustCompile(`^[0-9]{1,6}$`)
+
+// GetTransactionsBySTAN handles GET /api/v2/transactions/by-stan
+// It returns the merchant's transactions with the STAN on the date (YYYY-MM-DD, in timezone)
+// from a receipt. A STAN is not unique, so data is always an array, empty when none match.
+// At most the default page size is returned; meta.has_more reports that more matched.
+func (h *TransactionHandler) GetTransactionsBySTAN(c *gin.Context) {
+	merchantID := getMerchantID(c)
+	if merchantID == "" {
+		h.sendErrorResponse(c, http.StatusUnauthorized, config.ErrorCodeAuthFailed, "Invalid or missing authentication credentials", nil)
+		return
+	}
+
+	stan := c.Query("stan")
+	date := c.Query("date")
+	if !stanPattern.MatchString(stan) {
+		h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeBadRequest, "stan parameter is required (up to 6 digits)", nil)
+		return
+	}
+	if date == "" {
+		h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeBadRequest, "Date parameter is required (format: YYYY-MM-DD)", nil)
+		return
+	}
+
+	timezone := c.DefaultQuery("timezone", "UTC")
+	panFormat := c.DefaultQuery("pan_format", "bin_id_and_pan_id")
+
+	if err := h.transactionService.ValidateTimezone(timezone); err != nil {
+		h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeInvalidTimezone, err.Error(), nil)
+		return
+	}
+
+	fields, err := parseFields(c.Query("fields"))
+	if err == nil && len(fields) > 0 {
+		err = h.transactionService.ValidateFields(fields)
+	}
+	if err != nil {
+		h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeInvalidField, err.Error(), nil)
+		return
 	}
 
+	transactions, hasMore, err := h.transactionService.GetTransactionsBySTAN(merchantID, stan, date, fields, timezone, panFormat)
+	if errors.Is(err, services.ErrInvalidLookupDate) {
+		h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeBadRequest, err.Error(), nil)
+		return
+	}
+	if err != nil {
+		utils.LogError("Database error in GetTransactionsBySTAN", err, map[string]interface{}{
+			"merchant_id": merchantID,
+			"date":        date,
+		})
+		if config.IsInternalError(err) {
+			h.sendErrorResponse(c, http.StatusServiceUnavailable, config.ErrorCodeServiceUnavailable, "",
+				gin.H{"retry_after": 30})
+		} else {
+			h.sendErrorResponse(c, http.StatusInternalServerError, config.ErrorCodeDatabaseError, "", nil)
+		}
+		return
+	}
+
+	auditDataAccess(c, merchantID, "GET /transactions/by-stan", &models.TransactionFilter{STAN: &stan}, len(transactions), fields)
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": buildResponseData(transactions, fields),
+		"meta": gin.H{
+			"count":     len(transactions),
+			"has_more":  hasMore,
+			"timestamp": time.Now().UTC().Format(time.RFC3339),
+			"version":   config.APIVersion,
+		},
+	})
+}
+
+// AdvancedTransactionSearch handles POST /api/v2/transactions/search
+func (h *TransactionHandler) AdvancedTransactionSearch(c *gin.Context) {
+	startTime := time.Now()
+
+	merchantID := getMerchantID(c)
+	if merchantID == "" {
+		h.sendErrorResponse(c, http.StatusUnauthorized, config.ErrorCodeAuthFailed, "Invalid or missing authentication credentials", nil)
+		return
+	}
+
+	// Bound the body before decoding; the query is an arbitrary JSON document
+	maxBodyBytes := config.GetMaxSearchBodyBytes()
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBodyBytes)
+
+	var searchReq models.TransactionSearchRequest
+	if err := c.ShouldBindJSON(&searchReq); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeBadRequest, fmt.Sprintf("Request body exceeds %d bytes", maxBodyBytes), nil)
+			return
+		}
+		h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeBadRequest, fmt.Sprintf("Invalid request body: %v", err), nil)
+		return
+	}
+
+	if len(searchReq.Fields) > 0 {
+		fields, err := config.ExpandFieldPresets(searchReq.Fields)
+		if err != nil {
+			h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeInvalidField, err.Error(), nil)
+			return
+		}
+		searchReq.Fields = fields
+	}
+
+	filter, err := h.transactionService.ParseSearchQuery(searchReq.Query)
+	if errors.Is(err, services.ErrSearchQueryTooDeep) {
+		h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeBadRequest, err.Error(), nil)
+		return
+	}
+	if err != nil {
+		h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeInvalidFilter, fmt.Sprintf("Invalid search query: %v", err), nil)
+		return
+	}
+
+	if err := parseInclusionFlags(c, filter); err != nil {
+		h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeBadRequest, err.Error(), nil)
+		return
+	}
+	searchReq.Filter = filter
+
+	timezone := c.DefaultQuery("timezone", "UTC")
+	panFormat := c.DefaultQuery("pan_format", "bin_id_and_pan_id")
+
+	if err := h.transactionService.ValidateTimezone(timezone); err != nil {
+		h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeInvalidTimezone, err.Error(), nil)
+		return
+	}
+
+	// Aggregations only: skip the row query and aggregate over every matching row
+	aggregationsOnly, err := strconv.ParseBool(c.DefaultQuery("aggregations_only", "false"))
+	if err != nil {
+		h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeBadRequest, "Invalid aggregations_only parameter (must be true or false)", nil)
+		return
+	}
+	if aggregationsOnly {
+		if len(searchReq.Aggregations) == 0 {
+			h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeBadRequest, "aggregations_only requires at least one aggregation in the request body", nil)
+			return
+		}
+		searchReq.Pagination.IncludeTotals = true
+		searchReq.Pagination.TotalsOnly = true
+	}
+
+	result, err := h.transactionService.SearchTransactions(merchantID, &searchReq, timezone, panFormat)
+	if err != nil {
+		h.sendErrorResponse(c, http.StatusInternalServerError, config.ErrorCodeDatabaseError, fmt.Sprintf("Failed to search transactions: %v", err), nil)
+		return
+	}
+
+	// Handle aggregations if requested
+	aggregationResults := make(map[string]interface{})
+	if aggregationsOnly {
+		aggregationResults = buildTotalsAggregations(searchReq.Aggregations, result)
+	} else if searchReq.Aggregations != nil {
+		// Simple aggregation implementation
+		if _, exists := searchReq.Aggregations["total_amount"]; exists {
+			total := int64(0)
+			for _, tx := range result.Transactions {
+				total += tx.Amount
+			}
+			aggregationResults["total_amount"] = map[string]interface{}{"value": total}
+		}
+
+		if _, exists := searchReq.Aggregations["avg_amount"]; exists {
+			if len(result.Transactions) > 0 {
+				total := int64(0)
+				for _, tx := range result.Transactions {
+					total += tx.Amount
+				}
+				avg := config.RoundAmount(float64(total)/float64(len(result.Transactions)), 0, config.GetAmountRoundingMode())
+				aggregationResults["avg_amount"] = map[string]interface{}{"value": avg}
+			} else {
+				aggregationResults["avg_amount"] = map[string]interface{}{"value": 0}
+			}
+		}
+	}
+
+	// Determine which fields to use for filtering
+	fieldsToUse := result.RequestedFields
+	if len(fieldsToUse) == 0 && len(searchReq.Fields) > 0 {
+		fieldsToUse = searchReq.Fields
+	}
+	// Build response with field filtering
+	responseData := buildResponseData(result.Transactions, fieldsToUse)
+
 	response := gin.H{
 		"data": responseData,
 		"meta": gin.H{
 			"pagination": gin.H{
-				"page":               result.Page,
-				"limit":              result.Limit,
-				"total":              result.TotalCount,
-				"total_pages":        result.TotalPages,
-				"current_page_count": result.CurrentPageCount,
-				"has_next":           result.HasNext,
-				"has_prev":           result.HasPrev,
+				"page":        result.Page,
+				"limit":       result.Limit,
+				"total":       result.TotalCount,
+				"total_pages": result.TotalPages,
 			},
 			"timestamp":         time.Now().UTC().Format(time.RFC3339),
 			"version":           config.APIVersion,
-			"execution_time_ms": 150, // In real implementation, measure actual time
-			"cached":            false,
+			"execution_time_ms": time.Since(startTime).Milliseconds(),
+		},
+		"aggregations": aggregationResults,
+	}
+
+	if h.sendBoundedJSON(c, response) {
+		auditDataAccess(c, merchantID, "POST /transactions/search", filter, len(result.Transactions), fieldsToUse)
+	}
+}
+
+// GetMerchantSummary handles GET /api/v2/merchants/:merchant_id/summary
+func (h *TransactionHandler) GetMerchantSummary(c *gin.Context) {
+	startTime := time.Now()
+
+	merchantID := getMerchantID(c)
+	if merchantID == "" {
+		h.sendErrorResponse(c, http.StatusUnauthorized, config.ErrorCodeAuthFailed, "Invalid or missing authentication credentials", nil)
+		return
+	}
+
+	requestedMerchantID, err := normalizeMerchantID(c.Param("merchant_id"))
+	if err != nil {
+		h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeBadRequest, err.Error(), nil)
+		return
+	}
+
+	// Verify merchant access: own data, or a sub-merchant's when the caller is its provisioner
+	allowed, err := h.transactionService.CanAccessMerchant(merchantID, requestedMerchantID)
+	if err != nil {
+		utils.LogError("Failed to check merchant access", err, map[string]interface{}{
+			"merchant_id":           merchantID,
+			"requested_merchant_id": requestedMerchantID,
+		})
+		h.sendErrorResponse(c, http.StatusInternalServerError, config.ErrorCodeDatabaseError, "", nil)
+		return
+	}
+	if !allowed {
+		h.sendErrorResponse(c, http.StatusForbidden, config.ErrorCodeAuthzFailed, "Access denied to this merchant data", nil)
+		return
+	}
+
+	// Parse optional filter parameters
+	filterParam := c.Query("filter")
+	filter, err := h.transactionService.ParseAdvancedFilter(filterParam, "UTC")
+	if err != nil {
+		h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeInvalidFilter, fmt.Sprintf("Invalid filter expression: %v", err), nil)
+		return
+	}
+
+	if err := parseInclusionFlags(c, filter); err != nil {
+		h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeBadRequest, err.Error(), nil)
+		return
+	}
+
+	// Parse metrics - when omitted every metric is computed
+	var metrics models.SummaryMetrics
+	if metricsParam := c.Query("metrics"); metricsParam != "" {
+		metrics = parseCommaSeparated(metricsParam)
+		for _, metric := range metrics {
+			if !config.SummaryMetrics[metric] {
+				h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeBadRequest, fmt.Sprintf("Invalid metric '%s' (supported: count, sum, success_rate, date_range)", metric), nil)
+				return
+			}
+		}
+	}
+
+	summary, err := h.transactionService.GetMerchantSummary(requestedMerchantID, filter, metrics)
+	if errors.Is(err, services.ErrDateRangeTooLarge) {
+		h.sendDateRangeTooLarge(c, err)
+		return
+	}
+	if err != nil {
+		// Log the actual error for debugging
+		fmt.Printf("Database error in GetMerchantSummary: %v\n", err)
+
+		// Check if this is an internal error that should be sanitized
+		if config.IsInternalError(err) {
+			h.sendErrorResponse(c, http.StatusServiceUnavailable, config.ErrorCodeServiceUnavailable, "", nil)
+		} else {
+			h.sendErrorResponse(c, http.StatusInternalServerError, config.ErrorCodeDatabaseError, "", nil)
+		}
+		return
+	}
+
+	// A dashboard polling an unchanged summary gets a bodiless 304
+	if summary.ETag != "" {
+		c.Header("ETag", summary.ETag)
+		if etagMatches(c, summary.ETag) {
+			c.Status(http.StatusNotModified)
+			return
+		}
+	}
+
+	// Warnings are populated when a roll-up source was degraded and the summary is partial
+	warnings := summary.Warnings
+	if warnings == nil {
+		warnings = []string{}
+	}
+
+	meta := gin.H{
+		"timestamp":         time.Now().UTC().Format(time.RFC3339),
+		"version":           config.APIVersion,
+		"execution_time_ms": time.Since(startTime).Milliseconds(),
+		"cached":            summary.Cached,
+		"warnings":          warnings,
+	}
+	// Summaries cached before computed_at was recorded have no age to report
+	if !summary.ComputedAt.IsZero() {
+		meta["data_as_of"] = summary.ComputedAt.Format(time.RFC3339)
+	}
+
+	response := gin.H{
+		"data": gin.H{
+			"merchant_id":   summary.MerchantID,
+			"merchant_name": summary.MerchantName,
+			"summary":       buildSummaryMetrics(summary, metrics),
 		},
-		"links": h.buildPaginationLinks(c, result.Page, result.TotalPages, result.Limit),
+		"meta": meta,
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// buildSummaryMetrics renders the requested metrics of a merchant summary; metrics that
+// were not requested are left out rather than reported as zero
+func buildSummaryMetrics(summary *models.MerchantSummary, metrics models.SummaryMetrics) gin.H {
+	result := gin.H{}
+
+	if metrics.Includes(models.SummaryMetricCount) {
+		breakdown := summary.ResponseCodeBreakdown
+		if breakdown == nil {
+			breakdown = map[string]int{}
+		}
+		result["total_transactions"] = summary.TotalTransactions
+		result["response_code_breakdown"] = breakdown
+	}
+	if metrics.Includes(models.SummaryMetricSuccessRate) {
+		result["successful_transactions"] = summary.SuccessfulTransactions
+		result["failed_transactions"] = summary.FailedTransactions
+		result["success_rate"] = summary.SuccessRate
+	}
+	if metrics.Includes(models.SummaryMetricSum) {
+		result["total_amount"] = summary.TotalAmount
+		result["average_amount"] = summary.AverageAmount
+	}
+	if metrics.Includes(models.SummaryMetricDateRange) {
+		result["date_range"] = gin.H{
+			"from": summary.DateFrom.Format(time.RFC3339),
+			"to":   summary.DateTo.Format(time.RFC3339),
+		}
 	}
 
-	c.JSON(http.StatusOK, response)
+	return result
 }
 
-// GetTransactionByID handles GET /api/v2/transactions/:id
-func (h *TransactionHandler) GetTransactionByID(c *gin.Context) {
+// GetMerchantSummaries handles GET /api/v2/analytics/summaries
+// It returns a paginated summary per merchant owned by the authenticated provisioner.
+func (h *TransactionHandler) GetMerchantSummaries(c *gin.Context) {
 	merchantID := getMerchantID(c)
 	if merchantID == "" {
 		h.sendErrorResponse(c, http.StatusUnauthorized, config.ErrorCodeAuthFailed, "Invalid or missing authentication credentials", nil)
 		return
 	}
 
-	transactionID := c.Param("id")
-	if transactionID == "" {
-		h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeBadRequest, "Transaction ID is required", nil)
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeBadRequest, "Invalid page parameter", nil)
 		return
 	}
 
-	fieldsParam := c.Query("fields")
-	timezone := c.DefaultQuery("timezone", "UTC")
-	panFormat := c.DefaultQuery("pan_format", "bin_id_and_pan_id")
-
-	var fields []string
-	if fieldsParam != "" {
-		fields = parseCommaSeparated(fieldsParam)
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit < 1 || limit > config.MaxPageSize {
+		h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeBadRequest, fmt.Sprintf("Invalid limit parameter (must be 1-%d)", config.MaxPageSize), nil)
+		return
 	}
 
-	transaction, err := h.transactionService.GetTransactionByID(merchantID, transactionID, fields, timezone, panFormat)
+	filterParam := c.Query("filter")
+	filter, err := h.transactionService.ParseAdvancedFilter(filterParam, "UTC")
 	if err != nil {
-		h.sendErrorResponse(c, http.StatusInternalServerError, config.ErrorCodeDatabaseError, fmt.Sprintf("Failed to retrieve transaction: %v", err), nil)
+		h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeInvalidFilter, fmt.Sprintf("Invalid filter expression: %v", err), nil)
 		return
 	}
 
-	if transaction == nil {
-		h.sendErrorResponse(c, http.StatusNotFound, config.ErrorCodeTxNotFound, fmt.Sprintf("Transaction with ID %s not found", transactionID), nil)
+	if err := parseInclusionFlags(c, filter); err != nil {
+		h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeBadRequest, err.Error(), nil)
+		return
+	}
+
+	result, err := h.transactionService.GetMerchantSummaries(merchantID, filter, page, limit)
+	if err != nil {
+		utils.LogError("Database error in GetMerchantSummaries", err, map[string]interface{}{
+			"merchant_id": merchantID,
+			"filter":      filterParam,
+		})
+
+		if config.IsInternalError(err) {
+			h.sendErrorResponse(c, http.StatusServiceUnavailable, config.ErrorCodeServiceUnavailable, "", nil)
+		} else {
+			h.sendErrorResponse(c, http.StatusInternalServerError, config.ErrorCodeDatabaseError, "", nil)
+		}
 		return
 	}
 
 	response := gin.H{
-		"data": transaction,
+		"data": result.Summaries,
 		"meta": gin.H{
+			"pagination": gin.H{
+				"page":        result.Page,
+				"limit":       result.Limit,
+				"total":       result.TotalCount,
+				"total_pages": result.TotalPages,
+			},
 			"timestamp": time.Now().UTC().Format(time.RFC3339),
 			"version":   config.APIVersion,
 		},
@@ -209,129 +1003,208 @@ func (h *TransactionHandler) GetTransactionByID(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
-// AdvancedTransactionSearch handles POST /api/v2/transactions/search
-func (h *TransactionHandler) AdvancedTransactionSearch(c *gin.Context) {
+// GetTransactionTimeseries handles GET /api/v2/analytics/timeseries
+// It returns transaction counts and amounts bucketed by day or hour in the requested timezone.
+func (h *TransactionHandler) GetTransactionTimeseries(c *gin.Context) {
 	merchantID := getMerchantID(c)
 	if merchantID == "" {
 		h.sendErrorResponse(c, http.StatusUnauthorized, config.ErrorCodeAuthFailed, "Invalid or missing authentication credentials", nil)
 		return
 	}
 
-	var searchReq models.TransactionSearchRequest
-	if err := c.ShouldBindJSON(&searchReq); err != nil {
-		h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeBadRequest, fmt.Sprintf("Invalid request body: %v", err), nil)
+	interval := c.DefaultQuery("interval", "day")
+	if !config.TimeseriesIntervals[interval] {
+		h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeBadRequest, "Invalid interval parameter (must be day or hour)", nil)
 		return
 	}
 
 	timezone := c.DefaultQuery("timezone", "UTC")
-	panFormat := c.DefaultQuery("pan_format", "bin_id_and_pan_id")
+	if err := h.transactionService.ValidateTimezone(timezone); err != nil {
+		h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeInvalidTimezone, err.Error(), nil)
+		return
+	}
 
-	result, err := h.transactionService.SearchTransactions(merchantID, &searchReq, timezone, panFormat)
+	filterParam := c.Query("filter")
+	filter, err := h.transactionService.ParseAdvancedFilter(filterParam, timezone)
 	if err != nil {
-		h.sendErrorResponse(c, http.StatusInternalServerError, config.ErrorCodeDatabaseError, fmt.Sprintf("Failed to search transactions: %v", err), nil)
+		h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeInvalidFilter, fmt.Sprintf("Invalid filter expression: %v", err), nil)
 		return
 	}
 
-	// Handle aggregations if requested
-	aggregationResults := make(map[string]interface{})
-	if searchReq.Aggregations != nil {
-		// Simple aggregation implementation
-		if _, exists := searchReq.Aggregations["total_amount"]; exists {
-			total := int64(0)
-			for _, tx := range result.Transactions {
-				total += tx.Amount
-			}
-			aggregationResults["total_amount"] = map[string]interface{}{"value": total}
-		}
+	if err := parseInclusionFlags(c, filter); err != nil {
+		h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeBadRequest, err.Error(), nil)
+		return
+	}
 
-		if _, exists := searchReq.Aggregations["avg_amount"]; exists {
-			if len(result.Transactions) > 0 {
-				total := int64(0)
-				for _, tx := range result.Transactions {
-					total += tx.Amount
-				}
-				avg := float64(total) / float64(len(result.Transactions))
-				aggregationResults["avg_amount"] = map[string]interface{}{"value": avg}
-			} else {
-				aggregationResults["avg_amount"] = map[string]interface{}{"value": 0}
-			}
+	if responseCode := c.Query("response_code"); responseCode != "" {
+		if filter == nil {
+			filter = &models.TransactionFilter{}
 		}
+		filter.ResponseCode = &responseCode
 	}
 
-	// Build response with field filtering
-	var responseData interface{}
-
-	// Determine which fields to use for filtering
-	fieldsToUse := result.RequestedFields
-	if len(fieldsToUse) == 0 && len(searchReq.Fields) > 0 {
-		fieldsToUse = searchReq.Fields
+	buckets, err := h.transactionService.GetTimeseries(merchantID, filter, interval, timezone)
+	if errors.Is(err, services.ErrDateRangeTooLarge) {
+		h.sendDateRangeTooLarge(c, err)
+		return
+	}
+	if errors.Is(err, services.ErrTooManyBuckets) {
+		maxBuckets := config.GetMaxAggregationBuckets()
+		h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeBadRequest,
+			fmt.Sprintf("The requested range has more than %d %s buckets; use a coarser interval or a narrower date range", maxBuckets, interval),
+			gin.H{"max_buckets": maxBuckets})
+		return
 	}
+	if err != nil {
+		utils.LogError("Database error in GetTransactionTimeseries", err, map[string]interface{}{
+			"merchant_id": merchantID,
+			"interval":    interval,
+			"filter":      filterParam,
+		})
 
-	if len(fieldsToUse) > 0 {
-		// Apply field filtering
-		var filteredData []map[string]interface{}
-		for _, tx := range result.Transactions {
-			filteredTx := tx.FilterFields(fieldsToUse)
-			filteredData = append(filteredData, filteredTx)
+		if config.IsInternalError(err) {
+			h.sendErrorResponse(c, http.StatusServiceUnavailable, config.ErrorCodeServiceUnavailable, "", nil)
+		} else {
+			h.sendErrorResponse(c, http.StatusInternalServerError, config.ErrorCodeDatabaseError, "", nil)
 		}
-		responseData = filteredData
-	} else {
-		// For search endpoint, if no fields specified, return all fields
-		responseData = result.Transactions
+		return
 	}
 
 	response := gin.H{
-		"data": responseData,
+		"data": buckets,
 		"meta": gin.H{
-			"pagination": gin.H{
-				"page":        result.Page,
-				"limit":       result.Limit,
-				"total":       result.TotalCount,
-				"total_pages": result.TotalPages,
-			},
+			"interval":  interval,
+			"timezone":  timezone,
 			"timestamp": time.Now().UTC().Format(time.RFC3339),
 			"version":   config.APIVersion,
 		},
-		"aggregations": aggregationResults,
 	}
 
 	c.JSON(http.StatusOK, response)
 }
 
-// GetMerchantSummary handles GET /api/v2/merchants/:merchant_id/summary
-func (h *TransactionHandler) GetMerchantSummary(c *gin.Context) {
+// sendBoundedJSON writes a 200 JSON response, unless it is larger than
+// config.GetMaxResponseBytes, in which case the client is told to select fewer fields or
+// lower the limit. It reports whether the response was sent.
+func (h *TransactionHandler) sendBoundedJSON(c *gin.Context, response gin.H) bool {
+	body, err := json.Marshal(response)
+	if err != nil {
+		utils.LogError("Failed to encode response", err, map[string]interface{}{"path": c.Request.URL.Path})
+		h.sendErrorResponse(c, http.StatusInternalServerError, config.ErrorCodeInternalError, "", nil)
+		return false
+	}
+
+	maxBytes := config.GetMaxResponseBytes()
+	if maxBytes > 0 && len(body) > maxBytes {
+		h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeBadRequest,
+			fmt.Sprintf("Response of %d bytes exceeds the maximum of %d bytes; select fewer fields or use a smaller limit", len(body), maxBytes),
+			gin.H{"response_bytes": len(body), "max_response_bytes": maxBytes})
+		return false
+	}
+
+	c.Data(http.StatusOK, "application/json; charset=utf-8", body)
+	return true
+}
+
+// sendDateRangeTooLarge responds to a services.ErrDateRangeTooLarge error with the cap
+func (h *TransactionHandler) sendDateRangeTooLarge(c *gin.Context, err error) {
+	h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeBadRequest, err.Error(),
+		gin.H{"max_range_days": config.GetMaxQueryRangeDays()})
+}
+
+// sendIsoDateError responds with a 400 to an invalid or too large v1 efinance date range,
+// reporting whether it did
+func (h *TransactionHandler) sendIsoDateError(c *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, services.ErrDateRangeTooLarge):
+		h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeBadRequest, err.Error(),
+			gin.H{"max_range_days": config.GetEfinanceMaxRangeDays()})
+	case errors.Is(err, services.ErrInvalidDateRange), errors.Is(err, services.ErrInvalidLookupDate):
+		h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeBadRequest, err.Error(), nil)
+	default:
+		return false
+	}
+	return true
+}
+
+// GetMerchantTransactions handles GET /api/v2/merchants/:merchant_id/transactions
+func (h *TransactionHandler) GetMerchantTransactions(c *gin.Context) {
+	requestedMerchantID, err := normalizeMerchantID(c.Param("merchant_id"))
+	if err != nil {
+		h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeBadRequest, err.Error(), nil)
+		return
+	}
+
+	// Add merchant filter to query parameters
+	query := c.Request.URL.Query()
+	if existingFilter := query.Get("filter"); existingFilter == "" {
+		query.Set("filter", "merchant_id:eq:"+requestedMerchantID)
+	} else {
+		query.Set("filter", existingFilter+" AND merchant_id:eq:"+requestedMerchantID)
+	}
+	c.Request.URL.RawQuery = query.Encode()
+
+	// Delegate to main GetTransactions handler
+	h.GetTransactions(c)
+}
+
+// GetMerchantDevices handles GET /api/v2/merchants/:merchant_id/devices
+// It lists the devices and terminals the merchant has transacted on, with counts and the
+// time of the latest transaction, for the transactions matching filter.
+func (h *TransactionHandler) GetMerchantDevices(c *gin.Context) {
 	merchantID := getMerchantID(c)
 	if merchantID == "" {
 		h.sendErrorResponse(c, http.StatusUnauthorized, config.ErrorCodeAuthFailed, "Invalid or missing authentication credentials", nil)
 		return
 	}
 
-	requestedMerchantID := c.Param("merchant_id")
-	if requestedMerchantID == "" {
-		h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeBadRequest, "Merchant ID is required", nil)
+	requestedMerchantID, err := normalizeMerchantID(c.Param("merchant_id"))
+	if err != nil {
+		h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeBadRequest, err.Error(), nil)
 		return
 	}
 
-	// Verify merchant access (can only access own data)
-	if requestedMerchantID != merchantID {
+	allowed, err := h.transactionService.CanAccessMerchant(merchantID, requestedMerchantID)
+	if err != nil {
+		utils.LogError("Failed to check merchant access", err, map[string]interface{}{
+			"merchant_id":           merchantID,
+			"requested_merchant_id": requestedMerchantID,
+		})
+		h.sendErrorResponse(c, http.StatusInternalServerError, config.ErrorCodeDatabaseError, "", nil)
+		return
+	}
+	if !allowed {
 		h.sendErrorResponse(c, http.StatusForbidden, config.ErrorCodeAuthzFailed, "Access denied to this merchant data", nil)
 		return
 	}
 
-	// Parse optional filter parameters
+	timezone := c.DefaultQuery("timezone", "UTC")
+	if err := h.transactionService.ValidateTimezone(timezone); err != nil {
+		h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeInvalidTimezone, err.Error(), nil)
+		return
+	}
+	loc, _ := time.LoadLocation(timezone)
+
 	filterParam := c.Query("filter")
-	filter, err := h.transactionService.ParseAdvancedFilter(filterParam, "UTC")
+	filter, err := h.transactionService.ParseAdvancedFilter(filterParam, timezone)
 	if err != nil {
 		h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeInvalidFilter, fmt.Sprintf("Invalid filter expression: %v", err), nil)
 		return
 	}
 
-	summary, err := h.transactionService.GetMerchantSummary(merchantID, filter)
+	if err := parseInclusionFlags(c, filter); err != nil {
+		h.sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeBadRequest, err.Error(), nil)
+		return
+	}
+
+	devices, err := h.transactionService.GetMerchantDevices(requestedMerchantID, filter)
 	if err != nil {
-		// Log the actual error for debugging
-		fmt.Printf("Database error in GetMerchantSummary: %v\n", err)
+		utils.LogError("Database error in GetMerchantDevices", err, map[string]interface{}{
+			"merchant_id":           merchantID,
+			"requested_merchant_id": requestedMerchantID,
+			"filter":                filterParam,
+		})
 
-		// Check if this is an internal error that should be sanitized
 		if config.IsInternalError(err) {
 			h.sendErrorResponse(c, http.StatusServiceUnavailable, config.ErrorCodeServiceUnavailable, "", nil)
 		} else {
@@ -340,51 +1213,27 @@ func (h *TransactionHandler) GetMerchantSummary(c *gin.Context) {
 		return
 	}
 
+	for i := range devices {
+		devices[i].LastTransactionAt = devices[i].LastTransactionAt.In(loc)
+	}
+
 	response := gin.H{
-		"data": gin.H{
-			"merchant_id":   summary.MerchantID,
-			"merchant_name": summary.MerchantName,
-			"summary": gin.H{
-				"total_transactions":      summary.TotalTransactions,
-				"successful_transactions": summary.SuccessfulTransactions,
-				"failed_transactions":     summary.FailedTransactions,
-				"total_amount":            summary.TotalAmount,
-				"average_amount":          summary.AverageAmount,
-				"success_rate":            summary.SuccessRate,
-				"date_range": gin.H{
-					"from": summary.DateFrom.Format(time.RFC3339),
-					"to":   summary.DateTo.Format(time.RFC3339),
-				},
-			},
-		},
+		"data": devices,
 		"meta": gin.H{
-			"timestamp": time.Now().UTC().Format(time.RFC3339),
-			"version":   config.APIVersion,
+			"merchant_id": requestedMerchantID,
+			"total":       len(devices),
+			"timezone":    timezone,
+			"timestamp":   time.Now().UTC().Format(time.RFC3339),
+			"version":     config.APIVersion,
 		},
 	}
 
 	c.JSON(http.StatusOK, response)
 }
 
-// GetMerchantTransactions handles GET /api/v2/merchants/:merchant_id/transactions
-func (h *TransactionHandler) GetMerchantTransactions(c *gin.Context) {
-	requestedMerchantID := c.Param("merchant_id")
-
-	// Add merchant filter to query parameters
-	existingFilter := c.Query("filter")
-	if existingFilter == "" {
-		c.Request.URL.RawQuery += fmt.Sprintf("filter=merchant_id:eq:%s", requestedMerchantID)
-	} else {
-		c.Request.URL.RawQuery += fmt.Sprintf("&filter=%s AND merchant_id:eq:%s", existingFilter, requestedMerchantID)
-	}
-
-	// Delegate to main GetTransactions handler
-	h.GetTransactions(c)
-}
-
 // Helper functions
 
-func (h *TransactionHandler) sendErrorResponse(c *gin.Context, statusCode int, errorCode, message string, details interface{}) {
+func (h *TransactionHandler) sendErrorResponse(c *gin.Context, statusCode int, errorCode, message string, details gin.H) {
 	merchantID := getMerchantID(c)
 
 	utils.LogWarn("Sending error response", map[string]interface{}{
@@ -395,29 +1244,121 @@ func (h *TransactionHandler) sendErrorResponse(c *gin.Context, statusCode int, e
 		"remote_addr": c.ClientIP(),
 	})
 
-	// Use user-friendly message if available
-	userMessage := config.GetUserFriendlyMessage(errorCode)
-	if message != "" {
-		userMessage = message
+	utils.SendError(c, statusCode, errorCode, message, details)
+}
+
+// buildTotalsAggregations computes the requested search aggregations from the totals over all
+// matching rows, as returned when the row query is skipped
+func buildTotalsAggregations(requested map[string]interface{}, result *services.TransactionServiceResult) map[string]interface{} {
+	var total int64
+	if result.TotalAmount != nil {
+		total = *result.TotalAmount
 	}
 
-	response := gin.H{
-		"code":       errorCode,
-		"message":    userMessage,
-		"timestamp":  time.Now().UTC().Format(time.RFC3339),
-		"request_id": getRequestID(c),
+	aggregationResults := make(map[string]interface{})
+	if _, exists := requested["total_amount"]; exists {
+		aggregationResults["total_amount"] = map[string]interface{}{"value": total}
+	}
+	if _, exists := requested["avg_amount"]; exists {
+		avg := float64(0)
+		if result.TotalCount > 0 {
+			avg = config.RoundAmount(float64(total)/float64(result.TotalCount), 0, config.GetAmountRoundingMode())
+		}
+		aggregationResults["avg_amount"] = map[string]interface{}{"value": avg}
+	}
+	return aggregationResults
+}
+
+// csvContentType is the media type of CSV list responses
+const csvContentType = "text/csv"
+
+// sendCSVPage responds with one page of transactions as CSV: a header row of the requested
+// fields (the default fields when none were requested) followed by a row per transaction.
+// It reports whether the page was sent.
+func (h *TransactionHandler) sendCSVPage(c *gin.Context, transactions []models.Transaction, fields []string) bool {
+	if len(fields) == 0 {
+		fields = config.DefaultFields
+	}
+
+	var buf bytes.Buffer
+	writer := newCSVExportWriter(&buf, fields)
+	err := writer.WriteHeader()
+	if err == nil {
+		err = writer.WriteTransactions(transactions)
+	}
+	if err == nil {
+		err = writer.Close()
+	}
+	if err != nil {
+		utils.LogError("Failed to render transactions as CSV", err, nil)
+		h.sendErrorResponse(c, http.StatusInternalServerError, config.ErrorCodeInternalError, "", nil)
+		return false
+	}
+
+	c.Data(http.StatusOK, csvContentType+"; charset=utf-8", buf.Bytes())
+	return true
+}
+
+// buildResponseData renders transactions for the "data" key of list responses.
+// Only the requested fields are included when fields is non-empty; otherwise all fields are returned.
+// The result is always a JSON array, never null, even when there are no transactions.
+func buildResponseData(transactions []models.Transaction, fields []string) interface{} {
+	if len(fields) == 0 {
+		if transactions == nil {
+			return []models.Transaction{}
+		}
+		return transactions
+	}
+
+	filteredData := make([]map[string]interface{}, 0, len(transactions))
+	for _, tx := range transactions {
+		filteredData = append(filteredData, tx.FilterFields(fields))
+	}
+	return filteredData
+}
+
+// buildPaginationMeta builds the meta.pagination block for list responses.
+// When the total count was not computed, total/total_pages are omitted and
+// has_more signals whether a full page was returned.
+func buildPaginationMeta(result *services.TransactionServiceResult) gin.H {
+	// Keyset pages have no page numbers or totals
+	if result.CursorMode {
+		return gin.H{
+			"limit":              result.Limit,
+			"current_page_count": result.CurrentPageCount,
+			"has_next":           result.HasNext,
+			"has_more":           result.HasMore,
+			"next_cursor":        nullableString(result.NextCursor),
+		}
+	}
+
+	pagination := gin.H{
+		"page":               result.Page,
+		"limit":              result.Limit,
+		"current_page_count": result.CurrentPageCount,
+		"has_next":           result.HasNext,
+		"has_prev":           result.HasPrev,
 	}
 
-	if details != nil {
-		response["details"] = details
+	if result.CountSkipped {
+		pagination["has_more"] = result.HasMore
+		return pagination
 	}
 
-	c.JSON(statusCode, response)
+	pagination["total"] = result.TotalCount
+	pagination["total_pages"] = result.TotalPages
+
+	return pagination
 }
 
-func (h *TransactionHandler) buildPaginationLinks(c *gin.Context, currentPage, totalPages, limit int) gin.H {
+func (h *TransactionHandler) buildPaginationLinks(c *gin.Context, result *services.TransactionServiceResult) gin.H {
 	baseURL := fmt.Sprintf("%s://%s%s", getScheme(c), c.Request.Host, c.Request.URL.Path)
 	query := c.Request.URL.Query()
+	currentPage := result.Page
+
+	if result.CursorMode {
+		return buildCursorLinks(baseURL, query, result)
+	}
 
 	// Remove page parameter for link building
 	delete(query, "page")
@@ -426,7 +1367,13 @@ func (h *TransactionHandler) buildPaginationLinks(c *gin.Context, currentPage, t
 	links := gin.H{
 		"self":  buildURL(baseURL, baseQuery, currentPage),
 		"first": buildURL(baseURL, baseQuery, 1),
-		"last":  buildURL(baseURL, baseQuery, totalPages),
+	}
+
+	// The last page is unknown when the total count was skipped
+	if result.CountSkipped {
+		links["last"] = nil
+	} else {
+		links["last"] = buildURL(baseURL, baseQuery, result.TotalPages)
 	}
 
 	if currentPage > 1 {
@@ -435,7 +1382,7 @@ func (h *TransactionHandler) buildPaginationLinks(c *gin.Context, currentPage, t
 		links["prev"] = nil
 	}
 
-	if currentPage < totalPages {
+	if result.HasNext {
 		links["next"] = buildURL(baseURL, baseQuery, currentPage+1)
 	} else {
 		links["next"] = nil
@@ -444,6 +1391,35 @@ func (h *TransactionHandler) buildPaginationLinks(c *gin.Context, currentPage, t
 	return links
 }
 
+// buildCursorLinks builds navigation links for keyset pagination, which pages forward by cursor
+func buildCursorLinks(baseURL string, query url.Values, result *services.TransactionServiceResult) gin.H {
+	delete(query, "page")
+	self := baseURL + "?" + query.Encode()
+
+	query.Set("cursor", "")
+	links := gin.H{
+		"self":        self,
+		"first":       baseURL + "?" + query.Encode(),
+		"next":        nil,
+		"next_cursor": nullableString(result.NextCursor),
+	}
+
+	if result.NextCursor != "" {
+		query.Set("cursor", result.NextCursor)
+		links["next"] = baseURL + "?" + query.Encode()
+	}
+
+	return links
+}
+
+// nullableString returns nil for an empty string so it is rendered as JSON null
+func nullableString(value string) interface{} {
+	if value == "" {
+		return nil
+	}
+	return value
+}
+
 func buildURL(baseURL, query string, page int) string {
 	if query == "" {
 		return fmt.Sprintf("%s?page=%d", baseURL, page)
@@ -451,6 +1427,21 @@ func buildURL(baseURL, query string, page int) string {
 	return fmt.Sprintf("%s?%s&page=%d", baseURL, query, page)
 }
 
+// uuidPattern matches a UUID in its 8-4-4-4-12 hex digit form, in either case
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// normalizeMerchantID validates a merchant id from a request path and returns it lowercased,
+// the form merchant ids are stored and issued in, so access checks compare like with like
+func normalizeMerchantID(id string) (string, error) {
+	if id == "" {
+		return "", errors.New("Merchant ID is required")
+	}
+	if !uuidPattern.MatchString(id) {
+		return "", fmt.Errorf("Invalid merchant ID '%s' (must be a UUID)", id)
+	}
+	return strings.ToLower(id), nil
+}
+
 func getMerchantID(c *gin.Context) string {
 	if merchantID, exists := c.Get("merchantID"); exists {
 		if id, ok := merchantID.(string); ok {
@@ -465,14 +1456,6 @@ func getMerchantID(c *gin.Context) string {
 	return ""
 }
 
-func getRequestID(c *gin.Context) string {
-	requestID := c.GetHeader("X-Request-ID")
-	if requestID == "" {
-		requestID = fmt.Sprintf("req_%d_%d", time.Now().Unix(), time.Now().Nanosecond())
-	}
-	return requestID
-}
-
 func getScheme(c *gin.Context) string {
 	if c.Request.TLS != nil {
 		return "https"
@@ -483,6 +1466,54 @@ func getScheme(c *gin.Context) string {
 	return "http"
 }
 
+// parseFields parses the fields query parameter, expanding @preset names
+func parseFields(input string) ([]string, error) {
+	if input == "" {
+		return nil, nil
+	}
+	return config.ExpandFieldPresets(parseCommaSeparated(input))
+}
+
+// parseInclusionFlags sets filter.IncludeInactive and filter.IncludeIncomplete from
+// ?include_inactive and ?include_incomplete. Inactive (soft-deleted) and incomplete
+// (in-flight) transactions are excluded unless the matching flag is true.
+func parseInclusionFlags(c *gin.Context, filter *models.TransactionFilter) error {
+	includeInactive, err := strconv.ParseBool(c.DefaultQuery("include_inactive", "false"))
+	if err != nil {
+		return fmt.Errorf("Invalid include_inactive parameter (must be true or false)")
+	}
+	includeIncomplete, err := strconv.ParseBool(c.DefaultQuery("include_incomplete", "false"))
+	if err != nil {
+		return fmt.Errorf("Invalid include_incomplete parameter (must be true or false)")
+	}
+	filter.IncludeInactive = includeInactive
+	filter.IncludeIncomplete = includeIncomplete
+	return nil
+}
+
+// emptyResultSuggestions explains an empty result for meta.suggestions. A failed lookup is
+// logged and returns nil so the response is still served.
+func (h *TransactionHandler) emptyResultSuggestions(merchantID string, filter *models.TransactionFilter, timezone string) []string {
+	suggestions, err := h.transactionService.GetEmptyResultSuggestions(merchantID, filter, timezone)
+	if err != nil {
+		utils.LogWarn("Failed to build empty result suggestions", map[string]interface{}{
+			"merchant_id": merchantID,
+			"error":       err.Error(),
+		})
+		return nil
+	}
+	return suggestions
+}
+
+// sortErrorDetails lists the allowed sort fields when err is an unknown sort field
+func sortErrorDetails(err error) gin.H {
+	var fieldErr *services.InvalidSortFieldError
+	if errors.As(err, &fieldErr) {
+		return gin.H{"allowed_fields": fieldErr.AllowedFields}
+	}
+	return nil
+}
+
 func parseCommaSeparated(input string) []string {
 	result := make([]string, 0)
 	parts := strings.Split(input, ",")
@@ -591,6 +1622,9 @@ func (h *TransactionHandler) GetTransactionLookup(c *gin.Context) {
 	
 	// Get transaction lookup data
 	result, err := h.transactionService.GetTransactionLookup(request)
+	if h.sendIsoDateError(c, err) {
+		return
+	}
 	if err != nil {
 		utils.LogError("Error getting transaction lookup", err, map[string]interface{}{
 			"date":      request.Date,
@@ -646,6 +1680,9 @@ func (h *TransactionHandler) SearchTransactionDetails(c *gin.Context) {
 	
 	// Get transaction search results
 	result, err := h.transactionService.SearchTransactionDetails(request)
+	if h.sendIsoDateError(c, err) {
+		return
+	}
 	if err != nil {
 		utils.LogError("Error searching transaction details", err, map[string]interface{}{
 			"date":           request.Date,
@@ -1,15 +1,26 @@
 package handlers
 
 import (
+	"context"
+	"encoding/csv"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"aken_reporting_service/internal/config"
+	"aken_reporting_service/internal/middleware"
+	"aken_reporting_service/internal/models"
+	"aken_reporting_service/internal/repositories"
+	"aken_reporting_service/internal/services"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestParseCommaSeparated(t *testing.T) {
@@ -84,10 +95,73 @@ func TestSendErrorResponse(t *testing.T) {
 	assert.Equal(t, "TEST_ERROR", errorObj["code"])
 	assert.Equal(t, "Test error message", errorObj["message"])
 
-	// Check if details exist (it's optional)
-	if details, exists := errorObj["detail"]; exists {
-		assert.Equal(t, "test detail", details)
+	assert.Equal(t, map[string]interface{}{"detail": "test detail"}, errorObj["details"])
+	assert.NotContains(t, errorObj, "detail")
+}
+
+func TestErrorResponses_ShareOneShape(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("ENV", "")
+	t.Setenv("DISABLE_AUTH", "")
+
+	handler := NewTransactionHandler(services.NewTransactionService(&fakeTransactionRepo{}, nil))
+	router := gin.New()
+	router.NoRoute(NotFound)
+	router.GET("/secured", middleware.JWTAuthMiddleware(), func(c *gin.Context) {})
+	router.GET("/transactions", func(c *gin.Context) {
+		c.Set("merchantID", "merchant-1")
+		handler.GetTransactions(c)
+	})
+	router.POST("/auth/token", NewAuthHandler().GenerateToken)
+
+	tests := []struct {
+		name   string
+		method string
+		url    string
+		status int
+		code   string
+	}{
+		{"middleware 401", "GET", "/secured", http.StatusUnauthorized, config.ErrorCodeAuthFailed},
+		{"unknown route 404", "GET", "/api/v2/unknown", http.StatusNotFound, config.ErrorCodeNotFound},
+		{"handler 400", "GET", "/transactions?page=0", http.StatusBadRequest, config.ErrorCodeBadRequest},
+		{"auth handler 400", "POST", "/auth/token", http.StatusBadRequest, config.ErrorCodeBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tt.method, tt.url, strings.NewReader("{"))
+			req.Header.Set("X-Request-ID", "req-1")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			require.Equal(t, tt.status, w.Code)
+
+			var body map[string]interface{}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			assert.Len(t, body, 1, "the error object is the only top-level key")
+
+			errorObj := decodeErrorResponse(t, w)
+			assert.Equal(t, tt.code, errorObj["code"])
+			assert.NotEmpty(t, errorObj["message"])
+			assert.NotEmpty(t, errorObj["timestamp"])
+			assert.Equal(t, "req-1", errorObj["request_id"])
+			for key := range errorObj {
+				assert.Contains(t, []string{"code", "message", "timestamp", "request_id", "details"}, key)
+			}
+		})
+	}
+}
+
+// decodeErrorResponse returns the error object of an error response body
+func decodeErrorResponse(t *testing.T, w *httptest.ResponseRecorder) map[string]interface{} {
+	t.Helper()
+
+	var response struct {
+		Error map[string]interface{} `json:"error"`
 	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.NotNil(t, response.Error, w.Body.String())
+	return response.Error
 }
 
 func TestTransactionHandler_Constructor(t *testing.T) {
@@ -193,3 +267,1942 @@ func TestPANFormatValidation(t *testing.T) {
 		})
 	}
 }
+
+func TestBuildPaginationMeta_WithTotal(t *testing.T) {
+	result := &services.TransactionServiceResult{
+		TotalCount:       250,
+		Page:             2,
+		Limit:            100,
+		TotalPages:       3,
+		CurrentPageCount: 100,
+		HasNext:          true,
+		HasPrev:          true,
+	}
+
+	meta := buildPaginationMeta(result)
+
+	assert.Equal(t, int64(250), meta["total"])
+	assert.Equal(t, 3, meta["total_pages"])
+	assert.Equal(t, true, meta["has_next"])
+	_, hasMore := meta["has_more"]
+	assert.False(t, hasMore, "has_more should only be present when the total is unknown")
+}
+
+func TestBuildPaginationMeta_StreamingShape(t *testing.T) {
+	tests := []struct {
+		name    string
+		hasMore bool
+	}{
+		{"full page returned", true},
+		{"partial page returned", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := &services.TransactionServiceResult{
+				Page:             1,
+				Limit:            100,
+				CurrentPageCount: 100,
+				HasNext:          tt.hasMore,
+				HasMore:          tt.hasMore,
+				CountSkipped:     true,
+			}
+
+			meta := buildPaginationMeta(result)
+
+			_, hasTotal := meta["total"]
+			_, hasTotalPages := meta["total_pages"]
+			assert.False(t, hasTotal, "total should be omitted when not computed")
+			assert.False(t, hasTotalPages, "total_pages should be omitted when not computed")
+			assert.Equal(t, tt.hasMore, meta["has_more"])
+			assert.Equal(t, tt.hasMore, meta["has_next"])
+		})
+	}
+}
+
+func TestBuildPaginationLinks_StreamingOmitsLast(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Request, _ = http.NewRequest("GET", "/api/v2/transactions?include_total=false&page=1", nil)
+
+	handler := &TransactionHandler{}
+	links := handler.buildPaginationLinks(ctx, &services.TransactionServiceResult{
+		Page:         1,
+		Limit:        100,
+		HasNext:      true,
+		HasMore:      true,
+		CountSkipped: true,
+	})
+
+	assert.Nil(t, links["last"])
+	assert.Nil(t, links["prev"])
+	assert.Contains(t, links["next"], "page=2")
+}
+
+// Merchant ids in merchant paths must be UUIDs
+const (
+	testMerchantID     = "0d6f3b52-8c1e-4a7f-9b23-5e4c1a9d7f60"
+	otherMerchantID    = "5a2e9c71-3f4b-4d08-8e6a-c1b7d2f94a35"
+	subMerchantID      = "9b41d7e3-6a2c-4f15-b8d9-07e3c5a1f642"
+	otherSubMerchantID = "e3c8a5f0-1d97-4b62-a4f3-8c2b6e7d0159"
+)
+
+// degradedSummaryService reports a skipped roll-up source on every merchant summary
+type degradedSummaryService struct {
+	services.TransactionService
+	source string
+}
+
+func (s *degradedSummaryService) GetMerchantSummary(merchantID string, filter *models.TransactionFilter, metrics models.SummaryMetrics) (*models.MerchantSummary, error) {
+	summary, err := s.TransactionService.GetMerchantSummary(merchantID, filter, metrics)
+	if err != nil {
+		return nil, err
+	}
+	summary.Warnings = append(summary.Warnings, fmt.Sprintf("data source '%s' is unavailable; results are partial", s.source))
+	return summary, nil
+}
+
+func TestGetMerchantSummary_DegradedSourceReturnsWarnings(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	primary := &fakeTransactionRepo{summary: &models.MerchantSummary{
+		MerchantID: testMerchantID, MerchantName: "Test Merchant", TotalTransactions: 3, SuccessfulTransactions: 3,
+		ResponseCodeBreakdown: map[string]int{"00": 3},
+	}}
+	service := &degradedSummaryService{TransactionService: services.NewTransactionService(primary, nil), source: "shard-2"}
+	handler := NewTransactionHandler(service)
+
+	router := gin.New()
+	router.GET("/merchants/:merchant_id/summary", func(c *gin.Context) {
+		c.Set("merchantID", testMerchantID)
+		handler.GetMerchantSummary(c)
+	})
+
+	req, _ := http.NewRequest("GET", "/merchants/"+testMerchantID+"/summary", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+
+	meta := response["meta"].(map[string]interface{})
+	assert.Equal(t, false, meta["cached"])
+	warnings := meta["warnings"].([]interface{})
+	assert.Len(t, warnings, 1)
+	assert.Contains(t, warnings[0], "shard-2")
+
+	summary := response["data"].(map[string]interface{})["summary"].(map[string]interface{})
+	assert.Equal(t, float64(3), summary["total_transactions"])
+	assert.Equal(t, map[string]interface{}{"00": float64(3)}, summary["response_code_breakdown"])
+}
+
+func TestGetMerchantSummary_MetricsLimitsSummary(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	repo := &fakeTransactionRepo{summary: &models.MerchantSummary{MerchantID: testMerchantID, TotalAmount: 123456}}
+	handler := NewTransactionHandler(services.NewTransactionService(repo, nil))
+
+	router := gin.New()
+	router.GET("/merchants/:merchant_id/summary", func(c *gin.Context) {
+		c.Set("merchantID", testMerchantID)
+		handler.GetMerchantSummary(c)
+	})
+
+	req, _ := http.NewRequest("GET", "/merchants/"+testMerchantID+"/summary?metrics=sum", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	summary := response["data"].(map[string]interface{})["summary"].(map[string]interface{})
+	assert.Equal(t, float64(123456), summary["total_amount"])
+	assert.Contains(t, summary, "average_amount")
+	assert.NotContains(t, summary, "total_transactions")
+	assert.NotContains(t, summary, "success_rate")
+	assert.NotContains(t, summary, "date_range")
+	assert.Equal(t, models.SummaryMetrics{"sum"}, repo.lastMetrics)
+	assert.NotEmpty(t, response["meta"].(map[string]interface{})["data_as_of"], "the summary reports when it was computed")
+
+	req, _ = http.NewRequest("GET", "/merchants/"+testMerchantID+"/summary?metrics=sum,median", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestGetMerchantSummary_ProvisionerAccess(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	repo := &fakeTransactionRepo{
+		summary:      &models.MerchantSummary{MerchantID: testMerchantID},
+		subMerchants: map[string]string{subMerchantID: "provisioner-1", otherSubMerchantID: "provisioner-2"},
+	}
+	handler := NewTransactionHandler(services.NewTransactionService(repo, nil))
+
+	tests := []struct {
+		name       string
+		merchantID string
+		requested  string
+		status     int
+	}{
+		{"own merchant", testMerchantID, testMerchantID, http.StatusOK},
+		{"provisioner's sub-merchant", "provisioner-1", subMerchantID, http.StatusOK},
+		{"another provisioner's sub-merchant", "provisioner-1", otherSubMerchantID, http.StatusForbidden},
+		{"unrelated merchant", testMerchantID, otherMerchantID, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo.lastMerchantID = ""
+			router := gin.New()
+			router.GET("/merchants/:merchant_id/summary", func(c *gin.Context) {
+				c.Set("merchantID", tt.merchantID)
+				handler.GetMerchantSummary(c)
+			})
+
+			req, _ := http.NewRequest("GET", "/merchants/"+tt.requested+"/summary", nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			require.Equal(t, tt.status, w.Code)
+			if tt.status == http.StatusOK {
+				assert.Equal(t, tt.requested, repo.lastMerchantID, "the summary is computed for the requested merchant")
+				return
+			}
+
+			response := decodeErrorResponse(t, w)
+			assert.Equal(t, config.ErrorCodeAuthzFailed, response["code"])
+			assert.Empty(t, repo.lastMerchantID)
+		})
+	}
+}
+
+func TestMerchantPathIDValidation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	repo := &fakeTransactionRepo{summary: &models.MerchantSummary{MerchantID: testMerchantID}}
+	handler := NewTransactionHandler(services.NewTransactionService(repo, nil))
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set("merchantID", testMerchantID) })
+	router.GET("/merchants/:merchant_id/summary", handler.GetMerchantSummary)
+	router.GET("/merchants/:merchant_id/transactions", handler.GetMerchantTransactions)
+
+	for _, malformed := range []string{"merchant-1", "0d6f3b52-8c1e-4a7f-9b23", "0d6f3b528c1e4a7f9b235e4c1a9d7f60", "zd6f3b52-8c1e-4a7f-9b23-5e4c1a9d7f60", "0d6f3b52-8c1e-4a7f-9b23-5e4c1a9d7f60%27%20OR%201=1"} {
+		for _, path := range []string{"/merchants/" + malformed + "/summary", "/merchants/" + malformed + "/transactions"} {
+			req, _ := http.NewRequest("GET", path, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code, path)
+			assert.Contains(t, w.Body.String(), config.ErrorCodeBadRequest, path)
+		}
+	}
+	assert.Equal(t, 0, repo.calls, "malformed ids must be rejected before any query")
+
+	t.Run("uppercase id is normalized before the access check", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/merchants/"+strings.ToUpper(testMerchantID)+"/summary", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, testMerchantID, repo.lastMerchantID)
+	})
+
+	t.Run("merchant transactions keep other query parameters", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/merchants/"+testMerchantID+"/transactions?page=1&filter=response_code:eq:00", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+}
+
+func TestSendErrorResponse_LocalizedByAcceptLanguage(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	handler := NewTransactionHandler(services.NewTransactionService(&fakeTransactionRepo{}, nil))
+	router := gin.New()
+	router.GET("/transactions", handler.GetTransactions)
+
+	req, _ := http.NewRequest("GET", "/transactions", nil)
+	req.Header.Set("Accept-Language", "ar-EG,ar;q=0.9,en;q=0.8")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, "ar", w.Header().Get("Content-Language"))
+	assert.Contains(t, w.Header().Values("Vary"), "Accept-Language")
+
+	response := decodeErrorResponse(t, w)
+	assert.Equal(t, config.LocalizedErrorMessages["ar"][config.ErrorCodeAuthFailed], response["message"])
+	assert.Equal(t, map[string]interface{}{"message": "Invalid or missing authentication credentials"}, response["details"])
+	assert.NotContains(t, response, "detail")
+
+	req, _ = http.NewRequest("GET", "/transactions", nil)
+	req.Header.Set("Accept-Language", "de-DE")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	response = decodeErrorResponse(t, w)
+	assert.Equal(t, "en", w.Header().Get("Content-Language"))
+	assert.Equal(t, "Invalid or missing authentication credentials", response["message"])
+	assert.NotContains(t, response, "details")
+	assert.Contains(t, w.Header().Values("Vary"), "Accept-Language")
+}
+
+func TestSendErrorResponse_LocalizedKeepsDetails(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	details := gin.H{"max_range_days": 31}
+	router := gin.New()
+	router.GET("/test", func(c *gin.Context) {
+		(&TransactionHandler{}).sendErrorResponse(c, http.StatusBadRequest, config.ErrorCodeBadRequest, "Date range too large", details)
+	})
+
+	req, _ := http.NewRequest("GET", "/test", nil)
+	req.Header.Set("Accept-Language", "fr")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	response := decodeErrorResponse(t, w)
+	assert.Equal(t, map[string]interface{}{"max_range_days": float64(31), "message": "Date range too large"}, response["details"])
+	assert.Equal(t, gin.H{"max_range_days": 31}, details, "the caller's details must not be modified")
+}
+
+// fakeTransactionRepo is a minimal TransactionRepository used to drive handlers through the real service.
+// Methods not overridden here panic via the embedded nil interface.
+type fakeTransactionRepo struct {
+	repositories.TransactionRepository
+
+	summary    *models.MerchantSummary
+	summaryErr error
+	pages      [][]models.Transaction
+	calls      int
+	byID       map[string]models.Transaction
+
+	lastPagination models.PaginationParams
+	lastFilter     *models.TransactionFilter
+	lastMerchantID string
+	summaries      []models.MerchantSummary
+	dayFacets      []models.DayFacet
+	totalAmount    *int64
+	totalCount     int64
+	buckets        []models.TimeseriesBucket
+	lastInterval   string
+	lastTimezone   string
+	lastMetrics    models.SummaryMetrics
+	lastPanFormat  string
+	lastFields     []string
+	delay          time.Duration
+	boundsCalls    int
+	subMerchants   map[string]string // Sub-merchant ID to provisioner ID
+	streamErr      error             // Returned by StreamTransactions after all pages are streamed
+	estimate       int64
+	estimateErr    error
+	devices        []models.MerchantDevice
+	countErr       error // Returned by GetTransactionCount
+	currencies     []models.Currency
+}
+
+func (f *fakeTransactionRepo) GetMerchantDevices(merchantID string, filter *models.TransactionFilter) ([]models.MerchantDevice, error) {
+	f.calls++
+	f.lastMerchantID = merchantID
+	f.lastFilter = filter
+	return f.devices, nil
+}
+
+func (f *fakeTransactionRepo) GetTransactionCount(merchantID string, filter *models.TransactionFilter) (int64, error) {
+	f.calls++
+	f.lastMerchantID = merchantID
+	f.lastFilter = filter
+	return f.totalCount, f.countErr
+}
+
+func (f *fakeTransactionRepo) EstimateTransactionCount(merchantID string, filter *models.TransactionFilter) (int64, error) {
+	return f.estimate, f.estimateErr
+}
+
+func (f *fakeTransactionRepo) GetTimeseries(merchantID string, filter *models.TransactionFilter, interval, timezone string, limit int) ([]models.TimeseriesBucket, error) {
+	f.calls++
+	f.lastMerchantID = merchantID
+	f.lastFilter = filter
+	f.lastInterval = interval
+	f.lastTimezone = timezone
+	if len(f.buckets) > limit {
+		return f.buckets[:limit], nil
+	}
+	return f.buckets, nil
+}
+
+func (f *fakeTransactionRepo) GetDateBounds(merchantID string, filter *models.TransactionFilter) (*time.Time, *time.Time, error) {
+	f.boundsCalls++
+	return nil, nil, nil
+}
+
+func (f *fakeTransactionRepo) GetDailyCounts(merchantID string, filter *models.TransactionFilter, timezone string) ([]models.DayFacet, error) {
+	return f.dayFacets, nil
+}
+
+func (f *fakeTransactionRepo) GetTransactionByID(merchantID, transactionID string, fields []string, timezone string, panFormat string) (*models.Transaction, error) {
+	f.lastPanFormat = panFormat
+	tx, ok := f.byID[transactionID]
+	if !ok {
+		return nil, nil
+	}
+	return &tx, nil
+}
+
+func (f *fakeTransactionRepo) GetCurrencies() ([]models.Currency, error) {
+	return f.currencies, nil
+}
+
+func (f *fakeTransactionRepo) GetTransactionsByIDs(merchantID string, ids []string, fields []string, timezone string, panFormat string) ([]models.Transaction, error) {
+	f.calls++
+	var rows []models.Transaction
+	for _, id := range ids {
+		if tx, ok := f.byID[id]; ok {
+			rows = append(rows, tx)
+		}
+	}
+	return rows, nil
+}
+
+func (f *fakeTransactionRepo) GetTransactionsByRRNs(merchantID string, rrns []string, fields []string, timezone string, panFormat string, limit int) ([]models.Transaction, error) {
+	f.calls++
+	f.lastFields = fields
+	var rows []models.Transaction
+	for _, rrn := range rrns {
+		for _, id := range []string{"tx-1", "tx-2", "tx-3"} {
+			if tx, ok := f.byID[id]; ok && tx.RRN == rrn {
+				rows = append(rows, tx)
+			}
+		}
+	}
+	return rows, nil
+}
+
+func (f *fakeTransactionRepo) SetUseMysql(useMysql bool) {}
+
+func (f *fakeTransactionRepo) GetTransactions(merchantID string, filter *models.TransactionFilter, fields []string, sort []models.SortParams, pagination models.PaginationParams, timezone string, panFormat string) (*repositories.TransactionListResult, error) {
+	f.calls++
+	f.lastMerchantID = merchantID
+	f.lastFilter = filter
+	f.lastPagination = pagination
+	time.Sleep(f.delay)
+	var rows []models.Transaction
+	if pagination.Page-1 < len(f.pages) && !pagination.TotalsOnly {
+		rows = f.pages[pagination.Page-1]
+	}
+	var debugSQL string
+	if pagination.DebugSQL {
+		debugSQL = "SELECT * FROM payment_tx_log p WHERE p.merchant_id = $1"
+	}
+	return &repositories.TransactionListResult{
+		Transactions:    rows,
+		TotalCount:      f.totalCount,
+		Page:            pagination.Page,
+		Limit:           pagination.Limit,
+		CountSkipped:    pagination.SkipCount,
+		TotalAmount:     f.totalAmount,
+		DebugSQL:        debugSQL,
+		RequestedFields: fields,
+	}, nil
+}
+
+func (f *fakeTransactionRepo) StreamTransactions(ctx context.Context, merchantID string, filter *models.TransactionFilter, fields []string, sort []models.SortParams, timezone string, panFormat string, fn func(*models.Transaction) error) error {
+	f.calls++
+	f.lastMerchantID = merchantID
+	f.lastFilter = filter
+	f.lastTimezone = timezone
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(f.delay):
+	}
+	for _, page := range f.pages {
+		for i := range page {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := fn(&page[i]); err != nil {
+				return err
+			}
+		}
+	}
+	return f.streamErr
+}
+
+func (f *fakeTransactionRepo) PollTransactions(ctx context.Context, merchantID string, filter *models.TransactionFilter, fields []string, after *models.TransactionCursor, limit int, timezone string, panFormat string) ([]models.Transaction, error) {
+	f.calls++
+	f.lastMerchantID = merchantID
+	f.lastFilter = filter
+	batch := []models.Transaction{}
+	for _, page := range f.pages {
+		for _, tx := range page {
+			if after != nil && (tx.UpdatedAt.Before(after.UpdatedAt) || tx.UpdatedAt.Equal(after.UpdatedAt) && tx.ID <= after.ID) {
+				continue
+			}
+			if len(batch) < limit {
+				batch = append(batch, tx)
+			}
+		}
+	}
+	return batch, f.streamErr
+}
+
+func (f *fakeTransactionRepo) SearchTransactions(merchantID string, searchReq *models.TransactionSearchRequest, timezone string, panFormat string) (*repositories.TransactionListResult, error) {
+	return f.GetTransactions(merchantID, searchReq.Filter, searchReq.Fields, searchReq.Sort, searchReq.Pagination, timezone, panFormat)
+}
+
+func (f *fakeTransactionRepo) GetMerchantSummaries(provisionerID string, filter *models.TransactionFilter, pagination models.PaginationParams) ([]models.MerchantSummary, int64, error) {
+	f.lastMerchantID = provisionerID
+	f.lastFilter = filter
+	f.lastPagination = pagination
+	return f.summaries, int64(len(f.summaries)), nil
+}
+
+func (f *fakeTransactionRepo) IsMerchantUnderProvisioner(provisionerID, merchantID string) (bool, error) {
+	return f.subMerchants[merchantID] == provisionerID, nil
+}
+
+func (f *fakeTransactionRepo) GetMerchantSummary(merchantID string, filter *models.TransactionFilter, metrics models.SummaryMetrics) (*models.MerchantSummary, error) {
+	f.lastMerchantID = merchantID
+	f.lastMetrics = metrics
+	time.Sleep(f.delay)
+	if f.summaryErr != nil {
+		return nil, f.summaryErr
+	}
+	summary := *f.summary
+	return &summary, nil
+}
+
+func TestListResponses_EmptyResultIsArray(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	handler := NewTransactionHandler(services.NewTransactionService(&fakeTransactionRepo{}, nil))
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("merchantID", "merchant-1")
+	})
+	router.GET("/transactions", handler.GetTransactions)
+	router.POST("/transactions/search", handler.AdvancedTransactionSearch)
+
+	tests := []struct {
+		name   string
+		method string
+		url    string
+		body   string
+	}{
+		{"list full", "GET", "/transactions", ""},
+		{"list field-filtered", "GET", "/transactions?fields=payment_tx_log_id,amount", ""},
+		{"search full", "POST", "/transactions/search", `{}`},
+		{"search field-filtered", "POST", "/transactions/search", `{"fields":["payment_tx_log_id","amount"]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tt.method, tt.url, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Contains(t, w.Body.String(), `"data":[]`)
+		})
+	}
+}
+
+func TestBuildResponseData_NeverNil(t *testing.T) {
+	assert.Equal(t, []models.Transaction{}, buildResponseData(nil, nil))
+	assert.Equal(t, []map[string]interface{}{}, buildResponseData(nil, []string{"amount"}))
+}
+
+func TestGetTransactionByID_FieldSelection(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	repo := &fakeTransactionRepo{byID: map[string]models.Transaction{
+		"tx-1": {ID: "tx-1", Amount: 1500},
+	}}
+	handler := NewTransactionHandler(services.NewTransactionService(repo, nil))
+	router := gin.New()
+	router.GET("/transactions/:id", func(c *gin.Context) {
+		c.Set("merchantID", "merchant-1")
+		handler.GetTransactionByID(c)
+	})
+
+	t.Run("not found with fields is still 404", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/transactions/missing?fields=payment_tx_log_id,amount", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("found with fields returns only those fields", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/transactions/tx-1?fields=payment_tx_log_id,amount", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response map[string]interface{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		data := response["data"].(map[string]interface{})
+		assert.Len(t, data, 2)
+		assert.Equal(t, "tx-1", data["payment_tx_log_id"])
+		assert.Equal(t, float64(1500), data["amount"])
+	})
+}
+
+func TestBatchGetTransactions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	const (
+		tx1     = "0b7c6a4e-2f1d-4c3b-9a8e-1d2c3b4a5f60"
+		tx2     = "5e4d3c2b-1a09-4f8e-b7d6-c5b4a3928170"
+		missing = "9f8e7d6c-5b4a-4392-8170-6f5e4d3c2b1a"
+	)
+	repo := &fakeTransactionRepo{byID: map[string]models.Transaction{
+		tx1: {ID: tx1, Amount: 1500},
+		tx2: {ID: tx2, Amount: 2500},
+	}}
+	handler := NewTransactionHandler(services.NewTransactionService(repo, nil))
+	router := gin.New()
+	router.POST("/transactions/batch", func(c *gin.Context) {
+		c.Set("merchantID", "merchant-1")
+		handler.BatchGetTransactions(c)
+	})
+
+	post := func(url, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", url, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("returns found transactions and lists missing ids", func(t *testing.T) {
+		w := post("/transactions/batch?fields=payment_tx_log_id,amount", `{"ids":["`+tx1+`","`+missing+`","`+tx2+`","`+tx1+`"]}`)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var response struct {
+			Data     []map[string]interface{} `json:"data"`
+			NotFound []string                 `json:"not_found"`
+			Meta     map[string]interface{}   `json:"meta"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		require.Len(t, response.Data, 2)
+		assert.Len(t, response.Data[0], 2)
+		assert.Equal(t, []string{missing}, response.NotFound)
+		assert.Equal(t, float64(3), response.Meta["requested"])
+		assert.Equal(t, float64(2), response.Meta["found"])
+	})
+
+	t.Run("all found returns an empty not_found list", func(t *testing.T) {
+		w := post("/transactions/batch", `{"ids":["`+tx1+`"]}`)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"not_found":[]`)
+	})
+
+	t.Run("matches ids in either case and reports them as sent", func(t *testing.T) {
+		upper := strings.ToUpper(tx1)
+		w := post("/transactions/batch", `{"ids":["`+upper+`","`+tx1+`","`+strings.ToUpper(missing)+`"]}`)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var response struct {
+			Data     []map[string]interface{} `json:"data"`
+			NotFound []string                 `json:"not_found"`
+			Meta     map[string]interface{}   `json:"meta"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		require.Len(t, response.Data, 1)
+		assert.Equal(t, []string{strings.ToUpper(missing)}, response.NotFound)
+		assert.Equal(t, float64(2), response.Meta["requested"])
+		assert.Equal(t, float64(1), response.Meta["found"])
+	})
+
+	t.Run("rejects ids that are not UUIDs", func(t *testing.T) {
+		repo.calls = 0
+		w := post("/transactions/batch", `{"ids":["`+tx1+`","tx-1"]}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, config.ErrorCodeBadRequest, decodeErrorResponse(t, w)["code"])
+		assert.Contains(t, decodeErrorResponse(t, w)["message"], "tx-1")
+		assert.Equal(t, 0, repo.calls)
+	})
+
+	t.Run("rejects an empty id list", func(t *testing.T) {
+		w := post("/transactions/batch", `{"ids":[]}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, config.ErrorCodeBadRequest, decodeErrorResponse(t, w)["code"])
+	})
+
+	t.Run("rejects more than the maximum ids", func(t *testing.T) {
+		ids := make([]string, config.MaxBatchIDs+1)
+		for i := range ids {
+			ids[i] = fmt.Sprintf("00000000-0000-4000-8000-%012d", i)
+		}
+		body, _ := json.Marshal(models.TransactionBatchRequest{IDs: ids})
+		repo.calls = 0
+
+		w := post("/transactions/batch", string(body))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, 0, repo.calls)
+	})
+
+	t.Run("rejects unknown fields", func(t *testing.T) {
+		w := post("/transactions/batch?fields=not_a_field", `{"ids":["`+tx1+`"]}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, config.ErrorCodeInvalidField, decodeErrorResponse(t, w)["code"])
+	})
+
+	t.Run("rejects ids and rrns together", func(t *testing.T) {
+		w := post("/transactions/batch", `{"ids":["`+tx1+`"],"rrns":["000012345678"]}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, config.ErrorCodeBadRequest, decodeErrorResponse(t, w)["code"])
+	})
+}
+
+func TestBatchGetTransactions_ByRRN(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	repo := &fakeTransactionRepo{byID: map[string]models.Transaction{
+		"tx-1": {ID: "tx-1", RRN: "000012345678", Amount: 1500},
+		"tx-2": {ID: "tx-2", RRN: "000012345678", Amount: -1500},
+		"tx-3": {ID: "tx-3", RRN: "000087654321", Amount: 2500},
+	}}
+	handler := NewTransactionHandler(services.NewTransactionService(repo, nil))
+	router := gin.New()
+	router.POST("/transactions/batch", func(c *gin.Context) {
+		c.Set("merchantID", "merchant-1")
+		handler.BatchGetTransactions(c)
+	})
+
+	post := func(url, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", url, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("returns every match and lists missing rrns", func(t *testing.T) {
+		w := post("/transactions/batch?fields=payment_tx_log_id,amount",
+			`{"rrns":["000012345678","12345678","000087654321","000012345678"]}`)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var response struct {
+			Data     []map[string]interface{} `json:"data"`
+			NotFound []string                 `json:"not_found"`
+			Meta     map[string]interface{}   `json:"meta"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		require.Len(t, response.Data, 3, "an RRN shared by two transactions returns both")
+		assert.Equal(t, "tx-1", response.Data[0]["payment_tx_log_id"])
+		assert.NotContains(t, response.Data[0], "rrn", "rrn is read for matching but not returned unless requested")
+		assert.Equal(t, []string{"12345678"}, response.NotFound, "RRNs match exactly, leading zeros included")
+		assert.Equal(t, float64(3), response.Meta["requested"])
+		assert.Equal(t, float64(2), response.Meta["found"])
+		assert.Equal(t, false, response.Meta["has_more"])
+		assert.Contains(t, repo.lastFields, "rrn")
+	})
+
+	t.Run("rejects an empty rrn list", func(t *testing.T) {
+		w := post("/transactions/batch", `{"rrns":[]}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("rejects more than the maximum rrns", func(t *testing.T) {
+		rrns := make([]string, config.MaxBatchIDs+1)
+		for i := range rrns {
+			rrns[i] = fmt.Sprintf("%012d", i)
+		}
+		body, _ := json.Marshal(models.TransactionBatchRequest{RRNs: rrns})
+		repo.calls = 0
+
+		w := post("/transactions/batch", string(body))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, 0, repo.calls)
+		assert.NotContains(t, w.Body.String(), "max_ids")
+	})
+}
+
+func TestEfinanceDateRangeCap(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("EFINANCE_MAX_RANGE_DAYS", "31")
+
+	// The fake repository has no iso_trx queries, so reaching it would panic
+	handler := NewTransactionHandler(services.NewTransactionService(&fakeTransactionRepo{}, nil))
+	router := gin.New()
+	router.POST("/efinance/transactions/totals", handler.GetTransactionLookup)
+	router.POST("/efinance/transactions/lookup", handler.SearchTransactionDetails)
+
+	tests := []struct {
+		name    string
+		url     string
+		body    string
+		maxDays bool
+	}{
+		{"totals over the maximum", "/efinance/transactions/totals", `{"date_from":"2025-01-01","date_to":"2025-02-01"}`, true},
+		{"lookup over the maximum", "/efinance/transactions/lookup", `{"date_from":"2025-01-01","date_to":"2025-03-01","trx_rrn":"123456"}`, true},
+		{"totals without a date", "/efinance/transactions/totals", `{"device_id":"DEV1"}`, false},
+		{"lookup with a reversed range", "/efinance/transactions/lookup", `{"date_from":"2025-02-01","date_to":"2025-01-01"}`, false},
+		{"lookup with a bad date", "/efinance/transactions/lookup", `{"date":"01-02-2025"}`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("POST", tt.url, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+			errorBody := decodeErrorResponse(t, w)
+			assert.Equal(t, config.ErrorCodeBadRequest, errorBody["code"])
+			if tt.maxDays {
+				assert.Equal(t, map[string]interface{}{"max_range_days": float64(31)}, errorBody["details"])
+			}
+		})
+	}
+}
+
+func TestGetTransactionsBySTAN(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	repo := &fakeTransactionRepo{pages: [][]models.Transaction{{
+		{ID: "tx-1", STAN: "123456", Amount: 1500},
+		{ID: "tx-2", STAN: "123456", Amount: 2500},
+	}}}
+	handler := NewTransactionHandler(services.NewTransactionService(repo, nil))
+	router := gin.New()
+	router.GET("/transactions/by-stan", func(c *gin.Context) {
+		c.Set("merchantID", "merchant-1")
+		handler.GetTransactionsBySTAN(c)
+	})
+
+	get := func(url string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", url, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("returns every match", func(t *testing.T) {
+		w := get("/transactions/by-stan?stan=123456&date=2025-01-15&fields=payment_tx_log_id,stan")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var response struct {
+			Data []map[string]interface{} `json:"data"`
+			Meta map[string]interface{}   `json:"meta"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		require.Len(t, response.Data, 2)
+		assert.Equal(t, "tx-1", response.Data[0]["payment_tx_log_id"])
+		assert.Equal(t, "tx-2", response.Data[1]["payment_tx_log_id"])
+		assert.Equal(t, float64(2), response.Meta["count"])
+		assert.Equal(t, false, response.Meta["has_more"])
+
+		require.NotNil(t, repo.lastFilter.STAN)
+		assert.Equal(t, "123456", *repo.lastFilter.STAN)
+		assert.Equal(t, "merchant-1", repo.lastMerchantID)
+	})
+
+	t.Run("no match returns an empty array", func(t *testing.T) {
+		repo.pages = nil
+		w := get("/transactions/by-stan?stan=654321&date=2025-01-15")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"data":[]`)
+	})
+
+	for _, url := range []string{
+		"/transactions/by-stan?date=2025-01-15",
+		"/transactions/by-stan?stan=12a456&date=2025-01-15",
+		"/transactions/by-stan?stan=123456",
+		"/transactions/by-stan?stan=123456&date=15-01-2025",
+	} {
+		t.Run("rejects "+url, func(t *testing.T) {
+			w := get(url)
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Equal(t, config.ErrorCodeBadRequest, decodeErrorResponse(t, w)["code"])
+		})
+	}
+}
+
+func TestGetCurrencies(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	repo := &fakeTransactionRepo{currencies: []models.Currency{
+		{CurrencyCode: "0710", CurrencyName: "ZAR", CurrDelim: 2},
+		{CurrencyCode: "0392", CurrencyName: "JPY", CurrDelim: 0},
+	}}
+	handler := NewTransactionHandler(services.NewTransactionService(repo, nil))
+	router := gin.New()
+	router.GET("/currencies", handler.GetCurrencies)
+
+	req, _ := http.NewRequest("GET", "/currencies", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response struct {
+		Data []map[string]interface{} `json:"data"`
+		Meta map[string]interface{}   `json:"meta"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Len(t, response.Data, 2)
+	assert.Equal(t, map[string]interface{}{"currency_code": "0710", "currency_name": "ZAR", "curr_delim": float64(2)}, response.Data[0])
+	assert.Equal(t, float64(0), response.Data[1]["curr_delim"])
+	assert.Equal(t, float64(2), response.Meta["count"])
+	assert.Equal(t, false, response.Meta["cached"])
+	assert.NotEmpty(t, response.Meta["data_as_of"])
+}
+
+// fakeCurrencyCache serves a cached currency list
+type fakeCurrencyCache struct {
+	services.CacheService
+
+	result *services.CurrenciesResult
+}
+
+func (f *fakeCurrencyCache) Get(key string, dest interface{}) error {
+	data, err := json.Marshal(f.result)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, dest)
+}
+
+func TestGetCurrencies_CachedDataAsOf(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	currencies := []models.Currency{{CurrencyCode: "0710", CurrencyName: "ZAR", CurrDelim: 2}}
+	readAt := time.Now().UTC().Add(-2 * time.Hour)
+	cache := &fakeCurrencyCache{result: &services.CurrenciesResult{Currencies: currencies, DataAsOf: readAt}}
+	handler := NewTransactionHandler(services.NewTransactionService(&fakeTransactionRepo{currencies: currencies}, cache))
+	router := gin.New()
+	router.GET("/currencies", handler.GetCurrencies)
+
+	req, _ := http.NewRequest("GET", "/currencies", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response struct {
+		Meta struct {
+			Cached    bool      `json:"cached"`
+			Timestamp time.Time `json:"timestamp"`
+			DataAsOf  time.Time `json:"data_as_of"`
+		} `json:"meta"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.True(t, response.Meta.Cached)
+	assert.Equal(t, readAt.Format(time.RFC3339), response.Meta.DataAsOf.Format(time.RFC3339))
+	assert.True(t, response.Meta.DataAsOf.Before(response.Meta.Timestamp), "cached data is older than the response")
+}
+
+func TestBuildPaginationLinks_CursorMode(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Request, _ = http.NewRequest("GET", "/api/v2/transactions?cursor=abc&limit=2", nil)
+
+	handler := &TransactionHandler{}
+	result := &services.TransactionServiceResult{Limit: 2, CursorMode: true, HasNext: true, HasMore: true, NextCursor: "def"}
+	links := handler.buildPaginationLinks(ctx, result)
+
+	assert.Equal(t, "def", links["next_cursor"])
+	assert.Contains(t, links["next"], "cursor=def")
+	assert.Contains(t, links["first"], "cursor=&")
+	assert.NotContains(t, links, "last")
+	assert.NotContains(t, links["next"], "page=")
+
+	meta := buildPaginationMeta(result)
+	assert.Equal(t, "def", meta["next_cursor"])
+	assert.NotContains(t, meta, "page")
+	assert.NotContains(t, meta, "total")
+}
+
+func TestGetTransactions_CursorValidation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	repo := &fakeTransactionRepo{}
+	handler := NewTransactionHandler(services.NewTransactionService(repo, nil))
+	router := gin.New()
+	router.GET("/transactions", func(c *gin.Context) {
+		c.Set("merchantID", "merchant-1")
+		handler.GetTransactions(c)
+	})
+
+	tests := []struct {
+		name   string
+		url    string
+		status int
+	}{
+		{"first cursor page", "/transactions?cursor=", http.StatusOK},
+		{"invalid cursor", "/transactions?cursor=not-a-cursor", http.StatusBadRequest},
+		{"unsupported sort", "/transactions?cursor=&sort=amount:asc", http.StatusBadRequest},
+		{"signed cursor", "/transactions?cursor=" + signedCursor("merchant-1"), http.StatusOK},
+		{"tampered cursor", "/transactions?cursor=" + signedCursor("merchant-1") + "x", http.StatusBadRequest},
+		{"other merchant's cursor", "/transactions?cursor=" + signedCursor("merchant-2"), http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", tt.url, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.status, w.Code)
+		})
+	}
+	assert.True(t, repo.lastPagination.CursorMode)
+	assert.True(t, repo.lastPagination.SkipCount)
+}
+
+func TestGetTransactions_CountOnly(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	repo := &fakeTransactionRepo{totalCount: 42, pages: [][]models.Transaction{{{ID: "tx-1"}}}}
+	handler := NewTransactionHandler(services.NewTransactionService(repo, nil))
+	router := gin.New()
+	router.GET("/transactions", func(c *gin.Context) {
+		c.Set("merchantID", "merchant-1")
+		handler.GetTransactions(c)
+	})
+
+	t.Run("returns the count without rows", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/transactions?count_only=true&filter=response_code:eq:05&date_field=created_at", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var response map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, []interface{}{}, response["data"])
+		meta := response["meta"].(map[string]interface{})
+		assert.Equal(t, float64(42), meta["total"])
+		assert.NotContains(t, meta, "pagination")
+
+		assert.Equal(t, 1, repo.calls, "only the count query runs")
+		assert.Equal(t, "merchant-1", repo.lastMerchantID)
+		require.NotNil(t, repo.lastFilter.ResponseCode)
+		assert.Equal(t, "05", *repo.lastFilter.ResponseCode)
+		assert.Equal(t, models.DateFieldCreatedAt, repo.lastFilter.DateField)
+	})
+
+	t.Run("invalid value", func(t *testing.T) {
+		repo.calls = 0
+		req, _ := http.NewRequest("GET", "/transactions?count_only=maybe", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, 0, repo.calls)
+	})
+
+	t.Run("database error", func(t *testing.T) {
+		repo.countErr = errors.New("query failed")
+		defer func() { repo.countErr = nil }()
+
+		req, _ := http.NewRequest("GET", "/transactions?count_only=true", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Equal(t, config.ErrorCodeDatabaseError, decodeErrorResponse(t, w)["code"])
+	})
+}
+
+func TestGetTransactions_InvalidSortFieldListsAllowedFields(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	repo := &fakeTransactionRepo{}
+	handler := NewTransactionHandler(services.NewTransactionService(repo, nil))
+	router := gin.New()
+	router.GET("/transactions", func(c *gin.Context) {
+		c.Set("merchantID", "merchant-1")
+		handler.GetTransactions(c)
+	})
+
+	req, _ := http.NewRequest("GET", "/transactions?sort=created:desc", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, 0, repo.calls)
+
+	response := decodeErrorResponse(t, w)
+	assert.Equal(t, config.ErrorCodeInvalidSort, response["code"])
+
+	var allowed []string
+	for _, field := range response["details"].(map[string]interface{})["allowed_fields"].([]interface{}) {
+		allowed = append(allowed, field.(string))
+	}
+	assert.Equal(t, services.AllowedSortFields(), allowed)
+	assert.Contains(t, allowed, "tx_date_time")
+}
+
+func TestGetTransactions_DateFieldValidation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	repo := &fakeTransactionRepo{}
+	handler := NewTransactionHandler(services.NewTransactionService(repo, nil))
+	router := gin.New()
+	router.GET("/transactions", func(c *gin.Context) {
+		c.Set("merchantID", "merchant-1")
+		handler.GetTransactions(c)
+	})
+
+	tests := []struct {
+		name   string
+		url    string
+		status int
+	}{
+		{"default", "/transactions", http.StatusOK},
+		{"updated_at", "/transactions?date_field=updated_at&filter=tx_date_time:between:2025-01-01,2025-01-31", http.StatusOK},
+		{"created_at", "/transactions?date_field=created_at&filter=tx_date_time:between:2025-01-01,2025-01-31", http.StatusOK},
+		{"unknown column", "/transactions?date_field=settlement_date", http.StatusBadRequest},
+		{"created_at with cursor", "/transactions?date_field=created_at&cursor=", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", tt.url, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.status, w.Code)
+		})
+	}
+}
+
+// signedCursor returns a valid cursor token issued to merchantID
+func signedCursor(merchantID string) string {
+	cursor := models.TransactionCursor{UpdatedAt: time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC), ID: "tx-9", MerchantID: merchantID}
+	return cursor.Encode(config.GetCursorSigningKey())
+}
+
+func TestGetTransactions_DayFacets(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	repo := &fakeTransactionRepo{dayFacets: []models.DayFacet{{Date: "2025-01-01", Count: 3}}}
+	handler := NewTransactionHandler(services.NewTransactionService(repo, nil))
+	router := gin.New()
+	router.GET("/transactions", func(c *gin.Context) {
+		c.Set("merchantID", "merchant-1")
+		handler.GetTransactions(c)
+	})
+
+	req, _ := http.NewRequest("GET", "/transactions?facets=day", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"facets":{"day":[{"date":"2025-01-01","count":3}]}`)
+
+	req, _ = http.NewRequest("GET", "/transactions?facets=hour", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestGetTransactions_IncludeTotals(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	totalAmount := int64(123450)
+	repo := &fakeTransactionRepo{totalAmount: &totalAmount}
+	handler := NewTransactionHandler(services.NewTransactionService(repo, nil))
+	router := gin.New()
+	router.GET("/transactions", func(c *gin.Context) {
+		c.Set("merchantID", "merchant-1")
+		handler.GetTransactions(c)
+	})
+
+	req, _ := http.NewRequest("GET", "/transactions?include_totals=true", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, repo.lastPagination.IncludeTotals)
+	assert.Contains(t, w.Body.String(), `"totals":{"total_amount":123450}`)
+
+	req, _ = http.NewRequest("GET", "/transactions?include_totals=maybe", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestParseFields_Presets(t *testing.T) {
+	fields, err := parseFields("@minimal, merchant_name")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"payment_tx_log_id", "tx_date_time", "amount", "response_code", "merchant_name"}, fields)
+
+	fields, err = parseFields("")
+	assert.NoError(t, err)
+	assert.Nil(t, fields)
+
+	_, err = parseFields("@nope")
+	assert.Error(t, err)
+}
+
+func TestAdvancedTransactionSearch_Query(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	repo := &fakeTransactionRepo{}
+	handler := NewTransactionHandler(services.NewTransactionService(repo, nil))
+	router := gin.New()
+	router.POST("/transactions/search", func(c *gin.Context) {
+		c.Set("merchantID", "merchant-1")
+		handler.AdvancedTransactionSearch(c)
+	})
+
+	t.Run("supported clauses reach the repository", func(t *testing.T) {
+		body := `{"query": {"bool": {"should": [{"term": {"response_code": "00"}}, {"match": {"description": "refund"}}]}}}`
+		req, _ := http.NewRequest("POST", "/transactions/search", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		if assert.NotNil(t, repo.lastFilter) && assert.NotNil(t, repo.lastFilter.Search) {
+			assert.Len(t, repo.lastFilter.Search.Should, 2)
+		}
+	})
+
+	t.Run("unsupported clause is rejected", func(t *testing.T) {
+		body := `{"query": {"bool": {"must": [{"wildcard": {"merchant_name": "co*"}}]}}}`
+		req, _ := http.NewRequest("POST", "/transactions/search", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), config.ErrorCodeInvalidFilter)
+		assert.Contains(t, w.Body.String(), "wildcard")
+	})
+}
+
+func TestAdvancedTransactionSearch_RejectsPathologicalBodies(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	repo := &fakeTransactionRepo{}
+	handler := NewTransactionHandler(services.NewTransactionService(repo, nil))
+	router := gin.New()
+	router.POST("/transactions/search", func(c *gin.Context) {
+		c.Set("merchantID", "merchant-1")
+		handler.AdvancedTransactionSearch(c)
+	})
+
+	search := func(body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/transactions/search", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("deeply nested bool query", func(t *testing.T) {
+		depth := 1000
+		body := `{"query": ` + strings.Repeat(`{"bool": {"must": [`, depth) + `{"match_all": {}}` + strings.Repeat(`]}}`, depth) + `}`
+
+		w := search(body)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), config.ErrorCodeBadRequest)
+		assert.Contains(t, w.Body.String(), "nested too deeply")
+		assert.Equal(t, 0, repo.calls)
+	})
+
+	t.Run("oversized body", func(t *testing.T) {
+		t.Setenv("MAX_SEARCH_BODY_BYTES", "1024")
+		body := `{"query": {"match": {"description": "` + strings.Repeat("x", 2048) + `"}}}`
+
+		w := search(body)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), config.ErrorCodeBadRequest)
+		assert.Contains(t, w.Body.String(), "exceeds 1024 bytes")
+		assert.Equal(t, 0, repo.calls)
+	})
+}
+
+func TestAdvancedTransactionSearch_AggregationsOnly(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	totalAmount := int64(12000)
+	repo := &fakeTransactionRepo{
+		pages:       [][]models.Transaction{{{ID: "tx-1", Amount: 100}}},
+		totalAmount: &totalAmount,
+		totalCount:  40,
+	}
+	handler := NewTransactionHandler(services.NewTransactionService(repo, nil))
+	router := gin.New()
+	router.POST("/transactions/search", func(c *gin.Context) {
+		c.Set("merchantID", "merchant-1")
+		handler.AdvancedTransactionSearch(c)
+	})
+
+	body := `{"query": {"match_all": {}}, "aggregations": {"total_amount": {}, "avg_amount": {}}}`
+	req, _ := http.NewRequest("POST", "/transactions/search?aggregations_only=true", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, repo.lastPagination.TotalsOnly)
+	assert.True(t, repo.lastPagination.IncludeTotals)
+
+	var response map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Empty(t, response["data"])
+	aggregations := response["aggregations"].(map[string]interface{})
+	assert.Equal(t, float64(12000), aggregations["total_amount"].(map[string]interface{})["value"])
+	assert.Equal(t, float64(300), aggregations["avg_amount"].(map[string]interface{})["value"])
+
+	t.Run("requires aggregations", func(t *testing.T) {
+		req, _ := http.NewRequest("POST", "/transactions/search?aggregations_only=true", strings.NewReader(`{}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("invalid value", func(t *testing.T) {
+		req, _ := http.NewRequest("POST", "/transactions/search?aggregations_only=maybe", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestGetMerchantSummaries_ScopedToAuthenticatedProvisioner(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	repo := &fakeTransactionRepo{summaries: []models.MerchantSummary{
+		{MerchantID: "child-1", TotalTransactions: 3},
+		{MerchantID: "child-2", TotalTransactions: 1},
+	}}
+	handler := NewTransactionHandler(services.NewTransactionService(repo, nil))
+	router := gin.New()
+	router.GET("/analytics/summaries", func(c *gin.Context) {
+		c.Set("merchantID", "provisioner-1")
+		handler.GetMerchantSummaries(c)
+	})
+
+	req, _ := http.NewRequest("GET", "/analytics/summaries?page=1&limit=10&merchant_id=someone-else&filter=response_code:eq:00", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "provisioner-1", repo.lastMerchantID)
+	assert.Equal(t, "00", *repo.lastFilter.ResponseCode)
+	assert.Equal(t, 10, repo.lastPagination.Limit)
+
+	var response map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	data := response["data"].([]interface{})
+	assert.Len(t, data, 2)
+	assert.Equal(t, "child-1", data[0].(map[string]interface{})["merchant_id"])
+	pagination := response["meta"].(map[string]interface{})["pagination"].(map[string]interface{})
+	assert.Equal(t, float64(2), pagination["total"])
+	assert.Equal(t, float64(1), pagination["total_pages"])
+}
+
+func TestGetMerchantSummaries_Validation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	handler := NewTransactionHandler(services.NewTransactionService(&fakeTransactionRepo{}, nil))
+	router := gin.New()
+	router.GET("/analytics/summaries", handler.GetMerchantSummaries)
+	router.GET("/authed/summaries", func(c *gin.Context) {
+		c.Set("merchantID", "provisioner-1")
+		handler.GetMerchantSummaries(c)
+	})
+
+	tests := map[string]int{
+		"/analytics/summaries":              http.StatusUnauthorized,
+		"/authed/summaries?page=0":          http.StatusBadRequest,
+		"/authed/summaries?limit=abc":       http.StatusBadRequest,
+		"/authed/summaries?filter=bogus:eq": http.StatusBadRequest,
+	}
+
+	for url, status := range tests {
+		req, _ := http.NewRequest("GET", url, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, status, w.Code, url)
+	}
+}
+
+func TestGetTransactionTimeseries(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	repo := &fakeTransactionRepo{buckets: []models.TimeseriesBucket{
+		{Bucket: "2025-01-15T00:00:00", Count: 4, TotalAmount: 2500},
+		{Bucket: "2025-01-15T01:00:00", Count: 1, TotalAmount: 100},
+	}}
+	handler := NewTransactionHandler(services.NewTransactionService(repo, nil))
+	router := gin.New()
+	router.GET("/analytics/timeseries", func(c *gin.Context) {
+		c.Set("merchantID", "merchant-1")
+		handler.GetTransactionTimeseries(c)
+	})
+
+	req, _ := http.NewRequest("GET", "/analytics/timeseries?interval=hour&timezone=Africa/Johannesburg&response_code=05&filter=tx_date_time:between:2025-01-15,2025-01-16", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "merchant-1", repo.lastMerchantID)
+	assert.Equal(t, "hour", repo.lastInterval)
+	assert.Equal(t, "Africa/Johannesburg", repo.lastTimezone)
+	assert.Equal(t, "05", *repo.lastFilter.ResponseCode)
+	assert.NotNil(t, repo.lastFilter.DateTimeFrom)
+
+	var response map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	data := response["data"].([]interface{})
+	assert.Len(t, data, 2)
+	assert.Equal(t, "2025-01-15T00:00:00", data[0].(map[string]interface{})["bucket"])
+	assert.Equal(t, float64(2500), data[0].(map[string]interface{})["total_amount"])
+	assert.Equal(t, "hour", response["meta"].(map[string]interface{})["interval"])
+}
+
+func TestInvalidTimezoneRejectedBeforeQuery(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	repo := &fakeTransactionRepo{}
+	handler := NewTransactionHandler(services.NewTransactionService(repo, nil))
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("merchantID", "merchant-1")
+		c.Next()
+	})
+	router.GET("/transactions", handler.GetTransactions)
+	router.GET("/transactions/:id", handler.GetTransactionByID)
+	router.POST("/transactions/search", handler.AdvancedTransactionSearch)
+
+	requests := []*http.Request{
+		httptest.NewRequest("GET", "/transactions?timezone=Mars/Base", nil),
+		httptest.NewRequest("GET", "/transactions/tx-1?timezone=UTC')%20OR%201=1%20--", nil),
+		httptest.NewRequest("POST", "/transactions/search?timezone=Local", strings.NewReader(`{"query": {"match_all": {}}}`)),
+	}
+
+	for _, req := range requests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, req.URL.String())
+		assert.Contains(t, w.Body.String(), config.ErrorCodeInvalidTimezone, req.URL.String())
+	}
+	assert.Zero(t, repo.calls, "no query should run with an invalid timezone")
+}
+
+func TestGetTransactionTimeseries_Validation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	handler := NewTransactionHandler(services.NewTransactionService(&fakeTransactionRepo{}, nil))
+	router := gin.New()
+	router.GET("/analytics/timeseries", handler.GetTransactionTimeseries)
+	router.GET("/authed/timeseries", func(c *gin.Context) {
+		c.Set("merchantID", "merchant-1")
+		handler.GetTransactionTimeseries(c)
+	})
+
+	tests := map[string]int{
+		"/analytics/timeseries":                 http.StatusUnauthorized,
+		"/authed/timeseries?interval=week":      http.StatusBadRequest,
+		"/authed/timeseries?timezone=Mars/Base": http.StatusBadRequest,
+		"/authed/timeseries?filter=bogus:eq":    http.StatusBadRequest,
+		"/authed/timeseries":                    http.StatusOK,
+	}
+
+	for url, status := range tests {
+		req, _ := http.NewRequest("GET", url, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, status, w.Code, url)
+	}
+}
+
+func TestIncludeInactive(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	repo := &fakeTransactionRepo{}
+	handler := NewTransactionHandler(services.NewTransactionService(repo, nil))
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set("merchantID", "merchant-1") })
+	router.GET("/analytics/timeseries", handler.GetTransactionTimeseries)
+	router.POST("/transactions/search", handler.AdvancedTransactionSearch)
+	router.GET("/transactions", handler.GetTransactions)
+
+	tests := []struct {
+		name            string
+		method          string
+		url             string
+		includeInactive bool
+	}{
+		{"timeseries default", "GET", "/analytics/timeseries", false},
+		{"timeseries override", "GET", "/analytics/timeseries?include_inactive=true", true},
+		{"search default", "POST", "/transactions/search", false},
+		{"search override", "POST", "/transactions/search?include_inactive=true", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tt.method, tt.url, strings.NewReader(`{}`))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			require.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, tt.includeInactive, repo.lastFilter.IncludeInactive)
+		})
+	}
+
+	req, _ := http.NewRequest("GET", "/transactions?include_inactive=sometimes", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestIncludeIncomplete(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	repo := &fakeTransactionRepo{}
+	handler := NewTransactionHandler(services.NewTransactionService(repo, nil))
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set("merchantID", "merchant-1") })
+	router.GET("/analytics/timeseries", handler.GetTransactionTimeseries)
+	router.GET("/transactions", handler.GetTransactions)
+
+	for _, includeIncomplete := range []bool{false, true} {
+		url := "/analytics/timeseries"
+		if includeIncomplete {
+			url += "?include_incomplete=true"
+		}
+		req, _ := http.NewRequest("GET", url, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, includeIncomplete, repo.lastFilter.IncludeIncomplete)
+	}
+
+	req, _ := http.NewRequest("GET", "/transactions?include_incomplete=sometimes", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestGetTransactions_EmptyResultSuggestions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name            string
+		query           string
+		pages           [][]models.Transaction
+		wantSuggestions bool
+	}{
+		{"opted in and empty", "suggestions=true", nil, true},
+		{"not opted in", "", nil, false},
+		{"opted in with results", "suggestions=true", [][]models.Transaction{{{ID: "tx-1"}}}, false},
+		{"opted in past the last page", "suggestions=true&page=3", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeTransactionRepo{pages: tt.pages}
+			handler := NewTransactionHandler(services.NewTransactionService(repo, nil))
+			router := gin.New()
+			router.GET("/transactions", func(c *gin.Context) {
+				c.Set("merchantID", "merchant-1")
+				handler.GetTransactions(c)
+			})
+
+			req, _ := http.NewRequest("GET", "/transactions?"+tt.query, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			require.Equal(t, http.StatusOK, w.Code)
+
+			var response map[string]interface{}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			suggestions, exists := response["meta"].(map[string]interface{})["suggestions"]
+			assert.Equal(t, tt.wantSuggestions, exists)
+			if tt.wantSuggestions {
+				assert.Equal(t, []interface{}{"no transactions exist for this merchant"}, suggestions)
+				assert.Equal(t, 1, repo.boundsCalls)
+			} else {
+				assert.Zero(t, repo.boundsCalls)
+			}
+		})
+	}
+}
+
+func TestGetTransactions_CSV(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	repo := &fakeTransactionRepo{pages: [][]models.Transaction{
+		{{ID: "tx-1", RRN: "001", Amount: 1050}, {ID: "tx-2", RRN: "002, split", Amount: 99}},
+	}}
+	handler := NewTransactionHandler(services.NewTransactionService(repo, nil))
+	router := gin.New()
+	router.GET("/transactions", func(c *gin.Context) {
+		c.Set("merchantID", "merchant-1")
+		handler.GetTransactions(c)
+	})
+
+	want := [][]string{
+		{"payment_tx_log_id", "rrn", "amount"},
+		{"tx-1", "001", "1050"},
+		{"tx-2", "002, split", "99"},
+	}
+
+	tests := []struct {
+		name   string
+		query  string
+		accept string
+	}{
+		{"format parameter", "format=csv", ""},
+		{"accept header", "", "text/csv"},
+		{"format parameter wins over accept", "format=csv", "application/json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "/transactions?fields=payment_tx_log_id,rrn,amount&"+tt.query, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			require.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+			// The format only depends on Accept when no format parameter is given
+			if tt.query == "" {
+				assert.Equal(t, []string{"Accept"}, w.Header().Values("Vary"))
+			} else {
+				assert.Empty(t, w.Header().Values("Vary"))
+			}
+
+			records, err := csv.NewReader(strings.NewReader(w.Body.String())).ReadAll()
+			require.NoError(t, err)
+			assert.Equal(t, want, records)
+		})
+	}
+
+	t.Run("json by default", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/transactions", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Header().Get("Content-Type"), "application/json")
+		assert.Equal(t, []string{"Accept"}, w.Header().Values("Vary"))
+	})
+
+	t.Run("invalid format", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/transactions?format=xml", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestGetTransactionTimeseries_TooManyBuckets(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("MAX_AGGREGATION_BUCKETS", "2")
+
+	repo := &fakeTransactionRepo{buckets: []models.TimeseriesBucket{
+		{Bucket: "2025-01-15T00:00:00", Count: 1},
+		{Bucket: "2025-01-15T01:00:00", Count: 1},
+		{Bucket: "2025-01-15T02:00:00", Count: 1},
+	}}
+	handler := NewTransactionHandler(services.NewTransactionService(repo, nil))
+	router := gin.New()
+	router.GET("/analytics/timeseries", func(c *gin.Context) {
+		c.Set("merchantID", "merchant-1")
+		handler.GetTransactionTimeseries(c)
+	})
+
+	// Open-ended range: detected from the query result
+	since := time.Now().UTC().AddDate(0, 0, -1).Format("2006-01-02")
+	req, _ := http.NewRequest("GET", "/analytics/timeseries?interval=hour&filter=tx_date_time:gte:"+since, nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, 1, repo.calls)
+
+	response := decodeErrorResponse(t, w)
+	assert.Equal(t, config.ErrorCodeBadRequest, response["code"])
+	assert.Contains(t, response["message"], "coarser interval")
+	assert.Equal(t, float64(2), response["details"].(map[string]interface{})["max_buckets"])
+
+	// Bounded range: rejected before querying
+	req, _ = http.NewRequest("GET", "/analytics/timeseries?interval=hour&filter=tx_date_time:gte:2025-01-15%20AND%20tx_date_time:lte:2025-01-16", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, 1, repo.calls)
+
+	// The same range fits with a coarser interval
+	req, _ = http.NewRequest("GET", "/analytics/timeseries?interval=day&filter=tx_date_time:gte:2025-01-15%20AND%20tx_date_time:lte:2025-01-16", nil)
+	repo.buckets = repo.buckets[:2]
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 2, repo.calls)
+}
+
+func TestGetTransactions_DebugSQLAdminOnly(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("ADMIN_MERCHANT_IDS", "admin-1, admin-2")
+
+	tests := []struct {
+		name       string
+		merchantID string
+		query      string
+		status     int
+		expectSQL  bool
+	}{
+		{"admin gets sql", "admin-2", "debug_sql=true", http.StatusOK, true},
+		{"admin without flag", "admin-1", "", http.StatusOK, false},
+		{"admin with invalid flag", "admin-1", "debug_sql=maybe", http.StatusBadRequest, false},
+		{"non-admin is ignored", "merchant-1", "debug_sql=true", http.StatusOK, false},
+		{"non-admin invalid flag is ignored", "merchant-1", "debug_sql=maybe", http.StatusOK, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeTransactionRepo{}
+			handler := NewTransactionHandler(services.NewTransactionService(repo, nil))
+			router := gin.New()
+			router.GET("/transactions", func(c *gin.Context) {
+				c.Set("merchantID", tt.merchantID)
+				handler.GetTransactions(c)
+			})
+
+			req, _ := http.NewRequest("GET", "/transactions?"+tt.query, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.status, w.Code)
+			if tt.status != http.StatusOK {
+				return
+			}
+
+			var response map[string]interface{}
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			debug, exists := response["meta"].(map[string]interface{})["debug"]
+			assert.Equal(t, tt.expectSQL, exists)
+			assert.Equal(t, tt.expectSQL, repo.lastPagination.DebugSQL)
+			if tt.expectSQL {
+				assert.Contains(t, debug.(map[string]interface{})["sql"], "$1")
+			}
+		})
+	}
+}
+
+func TestExecutionTimeIsMeasured(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	repo := &fakeTransactionRepo{
+		summary: &models.MerchantSummary{MerchantID: testMerchantID},
+		delay:   20 * time.Millisecond,
+	}
+	handler := NewTransactionHandler(services.NewTransactionService(repo, nil))
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set("merchantID", testMerchantID) })
+	router.GET("/transactions", handler.GetTransactions)
+	router.POST("/transactions/search", handler.AdvancedTransactionSearch)
+	router.GET("/merchants/:merchant_id/summary", handler.GetMerchantSummary)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+	}{
+		{"list", "GET", "/transactions", ""},
+		{"search", "POST", "/transactions/search", `{"query": {"match_all": {}}}`},
+		{"summary", "GET", "/merchants/" + testMerchantID + "/summary", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			require.Equal(t, http.StatusOK, w.Code)
+
+			var response map[string]interface{}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			elapsed, ok := response["meta"].(map[string]interface{})["execution_time_ms"].(float64)
+			require.True(t, ok)
+			assert.GreaterOrEqual(t, elapsed, float64(20))
+		})
+	}
+}
+
+func TestMaxQueryRange_Rejected(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("MAX_QUERY_RANGE_DAYS", "366")
+
+	repo := &fakeTransactionRepo{summary: &models.MerchantSummary{MerchantID: testMerchantID}}
+	handler := NewTransactionHandler(services.NewTransactionService(repo, nil))
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set("merchantID", testMerchantID) })
+	router.GET("/transactions", handler.GetTransactions)
+	router.GET("/merchants/:merchant_id/summary", handler.GetMerchantSummary)
+	router.GET("/analytics/timeseries", handler.GetTransactionTimeseries)
+
+	filter := "?filter=tx_date_time:between:2010-01-01,2030-01-01"
+	for _, path := range []string{"/transactions", "/merchants/" + testMerchantID + "/summary", "/analytics/timeseries"} {
+		t.Run(path, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", path+filter, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			errorBody := decodeErrorResponse(t, w)
+			assert.Equal(t, config.ErrorCodeInvalidFilter, errorBody["code"])
+			assert.Contains(t, errorBody["message"], "maximum of 366")
+		})
+	}
+	assert.Equal(t, 0, repo.calls)
+}
+
+func TestMaxResponseBytes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	rows := make([]models.Transaction, 50)
+	for i := range rows {
+		rows[i] = models.Transaction{ID: fmt.Sprintf("tx-%d", i), Amount: 1500}
+	}
+	repo := &fakeTransactionRepo{pages: [][]models.Transaction{rows}}
+	handler := NewTransactionHandler(services.NewTransactionService(repo, nil))
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set("merchantID", testMerchantID) })
+	router.GET("/transactions", handler.GetTransactions)
+
+	get := func(url string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", url, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("rejects a response over the cap", func(t *testing.T) {
+		t.Setenv("MAX_RESPONSE_BYTES", "2048")
+
+		var w *httptest.ResponseRecorder
+		entries := captureAuditEntries(t, func() { w = get("/transactions") })
+		require.Equal(t, http.StatusBadRequest, w.Code)
+		// Nothing was returned, so there is no data access to audit
+		assert.Empty(t, entries)
+		errorBody := decodeErrorResponse(t, w)
+		assert.Equal(t, config.ErrorCodeBadRequest, errorBody["code"])
+		assert.Contains(t, errorBody["message"], "select fewer fields or use a smaller limit")
+		details := errorBody["details"].(map[string]interface{})
+		assert.Equal(t, float64(2048), details["max_response_bytes"])
+		assert.Greater(t, details["response_bytes"], float64(2048))
+	})
+
+	t.Run("field selection brings the response under the cap", func(t *testing.T) {
+		t.Setenv("MAX_RESPONSE_BYTES", "4096")
+
+		w := get("/transactions?fields=payment_tx_log_id")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+
+		var response map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Len(t, response["data"], 50)
+	})
+
+	t.Run("0 turns the cap off", func(t *testing.T) {
+		t.Setenv("MAX_RESPONSE_BYTES", "0")
+
+		w := get("/transactions")
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+}
+
+func TestGetMerchantDevices(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	deviceID, terminalID := "dev-1", "term-1"
+	repo := &fakeTransactionRepo{
+		devices: []models.MerchantDevice{
+			{DeviceID: &deviceID, TerminalID: &terminalID, TransactionCount: 12, LastTransactionAt: time.Date(2025, 1, 15, 22, 30, 0, 0, time.UTC)},
+			{DeviceID: &deviceID, TransactionCount: 1, LastTransactionAt: time.Date(2025, 1, 10, 8, 0, 0, 0, time.UTC)},
+		},
+		subMerchants: map[string]string{subMerchantID: testMerchantID},
+	}
+	handler := NewTransactionHandler(services.NewTransactionService(repo, nil))
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set("merchantID", testMerchantID) })
+	router.GET("/merchants/:merchant_id/devices", handler.GetMerchantDevices)
+
+	req, _ := http.NewRequest("GET", "/merchants/"+subMerchantID+"/devices?timezone=Africa/Johannesburg&filter=tx_date_time:between:2025-01-01,2025-01-31", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, subMerchantID, repo.lastMerchantID)
+	require.NotNil(t, repo.lastFilter)
+	assert.NotNil(t, repo.lastFilter.DateTimeFrom)
+
+	var response map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	data := response["data"].([]interface{})
+	require.Len(t, data, 2)
+	assert.Equal(t, map[string]interface{}{
+		"device_id":           "dev-1",
+		"terminal_id":         "term-1",
+		"transaction_count":   float64(12),
+		"last_transaction_at": "2025-01-16T00:30:00+02:00",
+	}, data[0])
+	assert.Nil(t, data[1].(map[string]interface{})["terminal_id"])
+	assert.Equal(t, float64(2), response["meta"].(map[string]interface{})["total"])
+}
+
+func TestGetMerchantDevices_Validation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	repo := &fakeTransactionRepo{}
+	handler := NewTransactionHandler(services.NewTransactionService(repo, nil))
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set("merchantID", testMerchantID) })
+	router.GET("/merchants/:merchant_id/devices", handler.GetMerchantDevices)
+
+	tests := map[string]int{
+		"/merchants/merchant-1/devices":                           http.StatusBadRequest,
+		"/merchants/" + otherMerchantID + "/devices":              http.StatusForbidden,
+		"/merchants/" + testMerchantID + "/devices?timezone=Mars": http.StatusBadRequest,
+		"/merchants/" + testMerchantID + "/devices?filter=bad:eq": http.StatusBadRequest,
+	}
+	for path, status := range tests {
+		req, _ := http.NewRequest("GET", path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, status, w.Code, path)
+	}
+	assert.Zero(t, repo.calls)
+}
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"aken_reporting_service/internal/config"
+	"aken_reporting_service/internal/utils"
 
 	"github.com/gin-gonic/gin"
 )
@@ -100,11 +101,7 @@ func ResponseHeadersMiddleware() gin.HandlerFunc {
 			}
 		}
 
-		// Rate limiting headers (placeholder values)
-		c.Header("X-RateLimit-Limit", "1000")
-		c.Header("X-RateLimit-Remaining", "999")
-		c.Header("X-RateLimit-Reset", fmt.Sprintf("%d", time.Now().Unix()+3600))
-		c.Header("X-RateLimit-Window", "3600")
+		// X-RateLimit-* headers are set by RateLimitMiddleware on authenticated routes
 
 		c.Next()
 	})
@@ -115,18 +112,11 @@ func CORSMiddleware() gin.HandlerFunc {
 	return gin.HandlerFunc(func(c *gin.Context) {
 		origin := c.Request.Header.Get("Origin")
 
-		// Allow specific origins or all origins in development
-		allowedOrigins := []string{
-			"http://localhost:3000",
-			"http://localhost:8080",
-			"http://localhost:5173",
-			"https://aken-eu.staging.wizzitdigital.com",
-		}
-
+		// Allow the configured origins, or all origins in development
 		if config.IsDevMode() {
 			c.Header("Access-Control-Allow-Origin", "*")
 		} else {
-			for _, allowedOrigin := range allowedOrigins {
+			for _, allowedOrigin := range config.GetAllowedOrigins() {
 				if origin == allowedOrigin {
 					c.Header("Access-Control-Allow-Origin", origin)
 					break
@@ -134,8 +124,9 @@ func CORSMiddleware() gin.HandlerFunc {
 			}
 		}
 
-		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Accept, Authorization, X-API-Key, X-Request-ID")
+		c.Header("Access-Control-Allow-Methods", strings.Join(config.CORSAllowedMethods, ", "))
+		c.Header("Access-Control-Allow-Headers", strings.Join(config.CORSAllowedHeaders, ", "))
+		c.Header("Access-Control-Expose-Headers", strings.Join(config.CORSExposedHeaders, ", "))
 		c.Header("Access-Control-Allow-Credentials", "true")
 		c.Header("Access-Control-Max-Age", "86400")
 
@@ -152,14 +143,7 @@ func CORSMiddleware() gin.HandlerFunc {
 // Helper functions
 
 func sendAuthError(c *gin.Context, message string) {
-	c.JSON(http.StatusUnauthorized, gin.H{
-		"error": gin.H{
-			"code":       config.ErrorCodeAuthFailed,
-			"message":    message,
-			"timestamp":  time.Now().UTC().Format(time.RFC3339),
-			"request_id": c.GetHeader("X-Request-ID"),
-		},
-	})
+	utils.SendError(c, http.StatusUnauthorized, config.ErrorCodeAuthFailed, message, nil)
 	c.Abort()
 }
 
@@ -240,6 +240,79 @@ func TestResponseHeadersMiddleware_WithRequestID(t *testing.T) {
 	assert.Equal(t, "test-request-id", w.Header().Get("X-Request-ID"))
 }
 
+func TestCORSMiddleware_ConfiguredOrigins(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("ENV", "production")
+	t.Setenv("DISABLE_AUTH", "")
+	t.Setenv("CORS_ALLOWED_ORIGINS", "https://app.example.com")
+
+	router := gin.New()
+	router.Use(CORSMiddleware())
+	router.GET("/test", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	request := func(origin string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/test", nil)
+		req.Header.Set("Origin", origin)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, "https://app.example.com", request("https://app.example.com").Header().Get("Access-Control-Allow-Origin"))
+	assert.Empty(t, request("http://localhost:3000").Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestCORSMiddleware_PatchPreflight(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("ENV", "production")
+	t.Setenv("DISABLE_AUTH", "")
+	t.Setenv("CORS_ALLOWED_ORIGINS", "https://app.example.com")
+
+	router := gin.New()
+	router.Use(CORSMiddleware())
+	router.PATCH("/test", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	req, _ := http.NewRequest(http.MethodOptions, "/test", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodPatch)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "https://app.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Contains(t, w.Header().Get("Access-Control-Allow-Methods"), http.MethodPatch)
+}
+
+func TestCORSMiddleware_IdempotencyAndRateLimitHeaders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("ENV", "production")
+	t.Setenv("DISABLE_AUTH", "")
+	t.Setenv("CORS_ALLOWED_ORIGINS", "https://app.example.com")
+
+	router := gin.New()
+	router.Use(CORSMiddleware())
+	router.POST("/test", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	req, _ := http.NewRequest(http.MethodOptions, "/test", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	req.Header.Set("Access-Control-Request-Headers", "idempotency-key")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Contains(t, w.Header().Get("Access-Control-Allow-Headers"), IdempotencyKeyHeader)
+	for _, name := range []string{"ETag", "X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset"} {
+		assert.Contains(t, w.Header().Get("Access-Control-Expose-Headers"), name)
+	}
+}
+
 func TestAuthMiddleware_DevelopmentMode(t *testing.T) {
 	// This test is skipped because we can't easily mock the dev mode
 	// In a real implementation, we would temporarily enable dev mode for testing
@@ -41,6 +41,19 @@ func CacheMiddleware(cacheService services.CacheService) gin.HandlerFunc {
 			return
 		}
 
+		// Export history and report schedules change with every export and schedule update
+		if strings.Contains(c.Request.URL.Path, "/exports") || strings.Contains(c.Request.URL.Path, "/reports") {
+			c.Next()
+			return
+		}
+
+		// Merchant summaries are cached by the service, and the handler must run to answer
+		// If-None-Match with a 304
+		if strings.Contains(c.Request.URL.Path, "/merchants/") && strings.HasSuffix(c.Request.URL.Path, "/summary") {
+			c.Next()
+			return
+		}
+
 		// Generate cache key from request
 		cacheKey := generateCacheKey(c)
 
@@ -144,8 +157,7 @@ func generateCacheKey(c *gin.Context) string {
 
 // shouldInvalidateCache determines if cache should be invalidated
 func shouldInvalidateCache(method string) bool {
-	invalidationMethods := []string{http.MethodPost, http.MethodPut, http.MethodDelete, http.MethodPatch}
-	for _, m := range invalidationMethods {
+	for _, m := range config.WriteMethods {
 		if method == m {
 			return true
 		}
@@ -163,13 +175,48 @@ func getMerchantID(c *gin.Context) string {
 	return ""
 }
 
+// queryVaryingParams are query parameters that change the response body. Shared caches
+// can't vary on query parameters, so responses that depend on them must not be stored.
+var queryVaryingParams = []string{"timezone", "pan_format"}
+
 // CacheControlMiddleware adds cache control headers
 func CacheControlMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Add cache control headers
-		c.Header("Cache-Control", "private, max-age=300") // 5 minutes
+		if isNoStoreRoute(c) {
+			c.Header("Cache-Control", "no-store")
+		} else if dependsOnQueryParams(c) {
+			c.Header("Cache-Control", "private, no-store")
+		} else {
+			c.Header("Cache-Control", "private, max-age=300") // 5 minutes
+		}
 		c.Header("Vary", "Accept, Authorization")
 
 		c.Next()
 	}
 }
+
+// dependsOnQueryParams reports whether the request sets a query parameter in queryVaryingParams
+func dependsOnQueryParams(c *gin.Context) bool {
+	query := c.Request.URL.Query()
+	for _, param := range queryVaryingParams {
+		if _, exists := query[param]; exists {
+			return true
+		}
+	}
+	return false
+}
+
+// isNoStoreRoute reports whether the matched route is configured as sensitive (e.g. returns PAN data)
+func isNoStoreRoute(c *gin.Context) bool {
+	route := c.FullPath()
+	if route == "" {
+		return false
+	}
+	for _, noStoreRoute := range config.GetNoStoreRoutes() {
+		if route == noStoreRoute {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,143 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"aken_reporting_service/internal/config"
+	"aken_reporting_service/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCacheControlMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(CacheControlMiddleware())
+	router.GET("/test", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "success"})
+	})
+
+	tests := []struct {
+		name         string
+		url          string
+		cacheControl string
+	}{
+		{"no varying params", "/test?page=2", "private, max-age=300"},
+		{"timezone", "/test?timezone=Africa/Johannesburg", "private, no-store"},
+		{"pan_format", "/test?pan_format=pan_id_only", "private, no-store"},
+		{"empty timezone still varies", "/test?timezone=", "private, no-store"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", tt.url, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.cacheControl, w.Header().Get("Cache-Control"))
+			assert.Equal(t, "Accept, Authorization", w.Header().Get("Vary"))
+		})
+	}
+}
+
+func TestCacheMiddleware_SkipsMerchantSummary(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("REDIS_ENABLED", "true")
+	cache := &fakeIdempotencyCache{values: map[string][]byte{}}
+
+	calls := 0
+	router := gin.New()
+	router.Use(CacheMiddleware(cache))
+	handler := func(c *gin.Context) {
+		calls++
+		c.Header("ETag", `"v1"`)
+		c.JSON(http.StatusOK, gin.H{"status": "success"})
+	}
+	router.GET("/api/v2/merchants/:merchant_id/summary", handler)
+	router.GET("/api/v2/merchants/:merchant_id/devices", handler)
+
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest("GET", "/api/v2/merchants/m1/summary", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, `"v1"`, w.Header().Get("ETag"))
+	}
+	assert.Equal(t, 2, calls, "the summary handler runs on every poll")
+	assert.Empty(t, cache.values)
+
+	req, _ := http.NewRequest("GET", "/api/v2/merchants/m1/devices", nil)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Len(t, cache.values, 1, "other merchant routes are still cached")
+}
+
+func TestShouldInvalidateCache_MatchesCORSWriteMethods(t *testing.T) {
+	for _, method := range config.CORSAllowedMethods {
+		readOnly := method == http.MethodGet || method == http.MethodOptions
+		assert.Equal(t, !readOnly, shouldInvalidateCache(method), method)
+	}
+	assert.True(t, shouldInvalidateCache(http.MethodPatch))
+	assert.False(t, shouldInvalidateCache(http.MethodHead))
+}
+
+func TestCacheControlMiddleware_HandlersAddToVary(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(CacheControlMiddleware())
+	router.GET("/test", func(c *gin.Context) {
+		utils.AddVary(c, "accept")
+		utils.AddVary(c, "Accept-Language")
+		utils.AddVary(c, "Accept-Language")
+		c.JSON(http.StatusOK, gin.H{"status": "success"})
+	})
+
+	req, _ := http.NewRequest("GET", "/test", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Headers already listed are not repeated, and the middleware's list is kept
+	assert.Equal(t, []string{"Accept, Authorization", "Accept-Language"}, w.Header().Values("Vary"))
+}
+
+func TestCacheControlMiddleware_NoStoreRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(CacheControlMiddleware())
+	handler := func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "success"})
+	}
+	router.GET("/api/v2/transactions/:id", handler)
+	router.GET("/api/v2/transactions", handler)
+
+	t.Run("single-get is no-store by default", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/api/v2/transactions/tx-1", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+	})
+
+	t.Run("list keeps private caching", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/api/v2/transactions", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, "private, max-age=300", w.Header().Get("Cache-Control"))
+	})
+
+	t.Run("configurable", func(t *testing.T) {
+		t.Setenv("NO_STORE_ROUTES", "/api/v2/transactions")
+
+		req, _ := http.NewRequest("GET", "/api/v2/transactions", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+
+		req, _ = http.NewRequest("GET", "/api/v2/transactions/tx-1", nil)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, "private, max-age=300", w.Header().Get("Cache-Control"))
+	})
+}
@@ -0,0 +1,192 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"aken_reporting_service/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// gzipContentTypes are the content type prefixes of responses worth compressing. Anything
+// else, such as xlsx exports, is already compressed or binary and is sent as is.
+var gzipContentTypes = []string{
+	"application/json",
+	"application/x-ndjson",
+	"application/xml",
+	"text/",
+}
+
+// GzipMiddleware compresses JSON and text responses for clients that send
+// Accept-Encoding: gzip. Responses below GZIP_MIN_SIZE_BYTES are sent uncompressed;
+// GZIP_LEVEL sets the level, and 0 turns compression off.
+func GzipMiddleware() gin.HandlerFunc {
+	level := config.GetGzipLevel()
+	minSize := config.GetGzipMinSize()
+
+	return func(c *gin.Context) {
+		if level == 0 || c.Request.Method == http.MethodHead || !acceptsGzip(c.GetHeader("Accept-Encoding")) {
+			c.Next()
+			return
+		}
+
+		writer := &gzipResponseWriter{ResponseWriter: c.Writer, level: level, minSize: minSize}
+		c.Writer = writer
+		defer func() {
+			writer.close()
+			c.Writer = writer.ResponseWriter
+		}()
+
+		c.Next()
+	}
+}
+
+// acceptsGzip reports whether an Accept-Encoding header allows gzip, either by name or
+// through *, without a zero q value
+func acceptsGzip(header string) bool {
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding != "gzip" && coding != "*" {
+			continue
+		}
+
+		params = strings.ReplaceAll(strings.TrimSpace(params), " ", "")
+		if q, ok := strings.CutPrefix(params, "q="); ok {
+			if value, err := strconv.ParseFloat(q, 64); err == nil && value == 0 {
+				continue
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// gzipResponseWriter buffers a response until it reaches the minimum size, then decides
+// whether to compress it. A flush decides straight away, so streams are compressed as
+// they are written.
+type gzipResponseWriter struct {
+	gin.ResponseWriter
+	level   int
+	minSize int
+
+	buf     bytes.Buffer
+	decided bool
+	gz      *gzip.Writer
+}
+
+func (w *gzipResponseWriter) Write(data []byte) (int, error) {
+	if !w.decided {
+		w.buf.Write(data)
+		if w.buf.Len() < w.minSize {
+			return len(data), nil
+		}
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+		return len(data), nil
+	}
+
+	if w.gz != nil {
+		return w.gz.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *gzipResponseWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// WriteHeaderNow sends the headers of a response without a body
+func (w *gzipResponseWriter) WriteHeaderNow() {
+	if !w.decided {
+		w.decide(false)
+	}
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *gzipResponseWriter) Flush() {
+	if !w.decided {
+		w.decide(true)
+	}
+	if w.gz != nil {
+		w.gz.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+func (w *gzipResponseWriter) Written() bool {
+	return w.buf.Len() > 0 || w.ResponseWriter.Written()
+}
+
+func (w *gzipResponseWriter) Size() int {
+	if !w.decided {
+		return w.buf.Len()
+	}
+	return w.ResponseWriter.Size()
+}
+
+// decide starts the response compressed when compress is set and its content type is
+// compressible, and writes out what was buffered
+func (w *gzipResponseWriter) decide(compress bool) error {
+	w.decided = true
+
+	header := w.Header()
+	compressible := header.Get("Content-Encoding") == "" && isGzipContentType(header.Get("Content-Type"))
+	if compressible {
+		header.Add("Vary", "Accept-Encoding")
+	}
+
+	if compress && compressible && bodyAllowed(w.Status()) {
+		header.Set("Content-Encoding", "gzip")
+		header.Del("Content-Length")
+
+		gz, err := gzip.NewWriterLevel(w.ResponseWriter, w.level)
+		if err != nil {
+			return err
+		}
+		w.gz = gz
+	}
+
+	if w.buf.Len() == 0 {
+		return nil
+	}
+	var err error
+	if w.gz != nil {
+		_, err = w.gz.Write(w.buf.Bytes())
+	} else {
+		_, err = w.ResponseWriter.Write(w.buf.Bytes())
+	}
+	w.buf.Reset()
+	return err
+}
+
+// close sends a response that stayed below the minimum size, or finishes the gzip stream
+func (w *gzipResponseWriter) close() {
+	if !w.decided {
+		w.decide(false)
+	}
+	if w.gz != nil {
+		w.gz.Close()
+	}
+}
+
+// isGzipContentType reports whether a response of contentType is worth compressing
+func isGzipContentType(contentType string) bool {
+	contentType = strings.ToLower(contentType)
+	for _, prefix := range gzipContentTypes {
+		if strings.HasPrefix(contentType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// bodyAllowed reports whether a response with status may have a body
+func bodyAllowed(status int) bool {
+	return status >= http.StatusOK && status != http.StatusNoContent && status != http.StatusNotModified
+}
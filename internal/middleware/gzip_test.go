@@ -0,0 +1,108 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAcceptsGzip(t *testing.T) {
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{"", false},
+		{"gzip", true},
+		{"deflate, gzip;q=0.8", true},
+		{"GZIP", true},
+		{"*", true},
+		{"gzip;q=0", false},
+		{"br, deflate", false},
+		{"identity, *;q=0", false},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, acceptsGzip(tt.header), tt.header)
+	}
+}
+
+func TestGzipMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("GZIP_LEVEL", "")
+	t.Setenv("GZIP_MIN_SIZE_BYTES", "1024")
+
+	large := strings.Repeat("transaction ", 500)
+	router := gin.New()
+	router.Use(GzipMiddleware())
+	router.GET("/large", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"data": large}) })
+	router.GET("/small", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"data": "ok"}) })
+	router.GET("/xlsx", func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", []byte(large))
+	})
+
+	request := func(path, acceptEncoding string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		if acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("compresses large JSON", func(t *testing.T) {
+		w := request("/large", "gzip")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+		assert.Contains(t, w.Header().Values("Vary"), "Accept-Encoding")
+
+		reader, err := gzip.NewReader(w.Body)
+		require.NoError(t, err)
+		body, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		assert.Contains(t, string(body), large)
+	})
+
+	t.Run("sends small responses uncompressed", func(t *testing.T) {
+		w := request("/small", "gzip")
+		assert.Empty(t, w.Header().Get("Content-Encoding"))
+		assert.JSONEq(t, `{"data":"ok"}`, w.Body.String())
+	})
+
+	t.Run("needs Accept-Encoding", func(t *testing.T) {
+		w := request("/large", "")
+		assert.Empty(t, w.Header().Get("Content-Encoding"))
+		assert.Contains(t, w.Body.String(), large)
+	})
+
+	t.Run("skips binary responses", func(t *testing.T) {
+		w := request("/xlsx", "gzip")
+		assert.Empty(t, w.Header().Get("Content-Encoding"))
+		assert.Equal(t, large, w.Body.String())
+	})
+}
+
+func TestGzipMiddleware_Disabled(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("GZIP_LEVEL", "0")
+	t.Setenv("GZIP_MIN_SIZE_BYTES", "0")
+
+	router := gin.New()
+	router.Use(GzipMiddleware())
+	router.GET("/large", func(c *gin.Context) { c.String(http.StatusOK, strings.Repeat("x", 4096)) })
+
+	req := httptest.NewRequest("GET", "/large", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.Len(t, w.Body.String(), 4096)
+}
@@ -0,0 +1,190 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"aken_reporting_service/internal/config"
+	"aken_reporting_service/internal/services"
+	"aken_reporting_service/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// IdempotencyKeyHeader is the request header identifying retries of the same POST request
+	IdempotencyKeyHeader = "Idempotency-Key"
+
+	// idempotentReplayedHeader marks a response replayed from an earlier request
+	idempotentReplayedHeader = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLength = 255
+)
+
+// idempotencyMaxBodyBytes is the largest response body stored for replay. Larger responses,
+// such as big export downloads, are sent normally but not stored.
+var idempotencyMaxBodyBytes = 1 << 20
+
+// idempotencyMaxRequestBytes is the largest request body read for fingerprinting; larger
+// requests are rejected
+var idempotencyMaxRequestBytes int64 = 1 << 20
+
+// idempotencyReplayHeaders are the response headers stored and replayed with the body
+var idempotencyReplayHeaders = []string{"Content-Type", "Content-Disposition", "Location"}
+
+// idempotentResponse is the stored response to the first request with an Idempotency-Key
+type idempotentResponse struct {
+	Fingerprint string            `json:"fingerprint"`
+	Status      int               `json:"status"`
+	Headers     map[string]string `json:"headers"`
+	Body        []byte            `json:"body"`
+}
+
+// IdempotencyMiddleware replays the stored response of a POST request with an Idempotency-Key
+// header when the same merchant sends the key to the same path again within
+// IDEMPOTENCY_TTL_SECONDS. The status code and body are replayed verbatim. Reusing a key with a
+// different query or body is rejected with 422. Server errors (5xx) are not stored so the
+// request can be retried, and concurrent duplicates are not serialized. Requests pass through
+// unchanged when Redis is disabled or no merchant is authenticated, so the middleware must
+// run after authentication and never stores responses to credential requests.
+func IdempotencyMiddleware(cacheService services.CacheService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		merchantID := getMerchantID(c)
+		if cacheService == nil || c.Request.Method != http.MethodPost || key == "" || merchantID == "" || !config.IsRedisEnabled() {
+			c.Next()
+			return
+		}
+
+		if len(key) > maxIdempotencyKeyLength {
+			sendIdempotencyError(c, http.StatusBadRequest, config.ErrorCodeBadRequest,
+				fmt.Sprintf("%s must be at most %d characters", IdempotencyKeyHeader, maxIdempotencyKeyLength))
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, idempotencyMaxRequestBytes))
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				sendIdempotencyError(c, http.StatusBadRequest, config.ErrorCodeBadRequest,
+					fmt.Sprintf("Request body exceeds %d bytes", idempotencyMaxRequestBytes))
+				return
+			}
+			sendIdempotencyError(c, http.StatusBadRequest, config.ErrorCodeBadRequest, "Failed to read request body")
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		cacheKey := idempotencyCacheKey(merchantID, c.Request.URL.Path, key)
+		fingerprint := requestFingerprint(c.Request.URL.RawQuery, body)
+
+		var stored idempotentResponse
+		if err := cacheService.Get(cacheKey, &stored); err != nil {
+			utils.LogWarn("Idempotency lookup failed, processing request", map[string]interface{}{
+				"path":  c.Request.URL.Path,
+				"error": err.Error(),
+			})
+			c.Next()
+			return
+		}
+
+		if stored.Status != 0 {
+			if stored.Fingerprint != fingerprint {
+				sendIdempotencyError(c, http.StatusUnprocessableEntity, config.ErrorCodeInvalidRequest,
+					fmt.Sprintf("%s was already used for a different request", IdempotencyKeyHeader))
+				return
+			}
+
+			for name, value := range stored.Headers {
+				c.Header(name, value)
+			}
+			c.Header(idempotentReplayedHeader, "true")
+			c.Status(stored.Status)
+			c.Writer.Write(stored.Body)
+			c.Abort()
+			return
+		}
+
+		capture := &idempotencyCapture{ResponseWriter: c.Writer}
+		c.Writer = capture
+
+		c.Next()
+
+		status := c.Writer.Status()
+		if status >= http.StatusInternalServerError || capture.overflow {
+			return
+		}
+
+		headers := make(map[string]string)
+		for _, name := range idempotencyReplayHeaders {
+			if value := capture.Header().Get(name); value != "" {
+				headers[name] = value
+			}
+		}
+
+		response := idempotentResponse{
+			Fingerprint: fingerprint,
+			Status:      status,
+			Headers:     headers,
+			Body:        capture.body.Bytes(),
+		}
+		if err := cacheService.Set(cacheKey, response, config.GetIdempotencyTTL()); err != nil {
+			utils.LogWarn("Failed to store idempotent response", map[string]interface{}{
+				"path":  c.Request.URL.Path,
+				"error": err.Error(),
+			})
+		}
+	}
+}
+
+// idempotencyCapture copies the response body for storage until it exceeds idempotencyMaxBodyBytes
+type idempotencyCapture struct {
+	gin.ResponseWriter
+	body     bytes.Buffer
+	overflow bool
+}
+
+func (w *idempotencyCapture) Write(b []byte) (int, error) {
+	if !w.overflow {
+		if w.body.Len()+len(b) > idempotencyMaxBodyBytes {
+			w.overflow = true
+			w.body.Reset()
+		} else {
+			w.body.Write(b)
+		}
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *idempotencyCapture) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// idempotencyCacheKey scopes an idempotency key to the merchant and path
+func idempotencyCacheKey(merchantID, path, key string) string {
+	hash := sha256.Sum256([]byte(strings.Join([]string{merchantID, path, key}, "|")))
+	return fmt.Sprintf("%sidempotency:%s", config.GetRedisKeyPrefix(), hex.EncodeToString(hash[:]))
+}
+
+// requestFingerprint identifies the query and body of a request. It is keyed, so a stored
+// fingerprint cannot be used to confirm a guess at the body.
+func requestFingerprint(rawQuery string, body []byte) string {
+	hash := hmac.New(sha256.New, config.GetIdempotencyFingerprintKey())
+	hash.Write([]byte(rawQuery))
+	hash.Write([]byte{0})
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// sendIdempotencyError aborts the request with an error in the shape used by the other middleware
+func sendIdempotencyError(c *gin.Context, status int, code, message string) {
+	utils.SendError(c, status, code, message, nil)
+	c.Abort()
+}
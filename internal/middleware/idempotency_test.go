@@ -0,0 +1,232 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"aken_reporting_service/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeIdempotencyCache stores JSON values in memory; methods other than Get and Set panic
+type fakeIdempotencyCache struct {
+	services.CacheService
+
+	values map[string][]byte
+	ttl    time.Duration
+}
+
+func (f *fakeIdempotencyCache) Get(key string, dest interface{}) error {
+	if data, exists := f.values[key]; exists {
+		return json.Unmarshal(data, dest)
+	}
+	return nil
+}
+
+func (f *fakeIdempotencyCache) Set(key string, value interface{}, ttl time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	f.values[key] = data
+	f.ttl = ttl
+	return nil
+}
+
+func newIdempotencyRouter(cache services.CacheService, calls *int, status int) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		if merchantID := c.GetHeader("X-Test-Merchant"); merchantID != "" {
+			c.Set("merchantID", merchantID)
+		}
+	})
+	router.POST("/export", IdempotencyMiddleware(cache), func(c *gin.Context) {
+		*calls++
+		var body map[string]interface{}
+		c.ShouldBindJSON(&body)
+		c.Header("Content-Disposition", "attachment; filename=export.csv")
+		c.JSON(status, gin.H{"call": *calls, "body": body})
+	})
+	return router
+}
+
+func postWithKey(router *gin.Engine, merchantID, key, body string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(http.MethodPost, "/export?format=csv", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Set(IdempotencyKeyHeader, key)
+	}
+	if merchantID != "" {
+		req.Header.Set("X-Test-Merchant", merchantID)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotencyMiddleware_ReplaysFirstResponse(t *testing.T) {
+	t.Setenv("REDIS_ENABLED", "true")
+	t.Setenv("IDEMPOTENCY_TTL_SECONDS", "60")
+	cache := &fakeIdempotencyCache{values: map[string][]byte{}}
+	calls := 0
+	router := newIdempotencyRouter(cache, &calls, http.StatusCreated)
+
+	first := postWithKey(router, "merchant-1", "key-1", `{"a":1}`)
+	second := postWithKey(router, "merchant-1", "key-1", `{"a":1}`)
+
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, http.StatusCreated, second.Code)
+	assert.Equal(t, first.Body.String(), second.Body.String())
+	assert.Equal(t, "true", second.Header().Get(idempotentReplayedHeader))
+	assert.Empty(t, first.Header().Get(idempotentReplayedHeader))
+	assert.Equal(t, first.Header().Get("Content-Type"), second.Header().Get("Content-Type"))
+	assert.Equal(t, "attachment; filename=export.csv", second.Header().Get("Content-Disposition"))
+	assert.Equal(t, 60*time.Second, cache.ttl)
+}
+
+func TestIdempotencyMiddleware_KeyScopedToMerchant(t *testing.T) {
+	t.Setenv("REDIS_ENABLED", "true")
+	cache := &fakeIdempotencyCache{values: map[string][]byte{}}
+	calls := 0
+	router := newIdempotencyRouter(cache, &calls, http.StatusOK)
+
+	postWithKey(router, "merchant-1", "key-1", `{}`)
+	w := postWithKey(router, "merchant-2", "key-1", `{}`)
+
+	assert.Equal(t, 2, calls)
+	assert.Empty(t, w.Header().Get(idempotentReplayedHeader))
+}
+
+func TestIdempotencyMiddleware_RejectsKeyReusedWithDifferentRequest(t *testing.T) {
+	t.Setenv("REDIS_ENABLED", "true")
+	cache := &fakeIdempotencyCache{values: map[string][]byte{}}
+	calls := 0
+	router := newIdempotencyRouter(cache, &calls, http.StatusOK)
+
+	postWithKey(router, "merchant-1", "key-1", `{"format":"csv"}`)
+	w := postWithKey(router, "merchant-1", "key-1", `{"format":"xlsx"}`)
+
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+}
+
+func TestIdempotencyMiddleware_UnauthenticatedRequestsAreNotStored(t *testing.T) {
+	t.Setenv("REDIS_ENABLED", "true")
+	cache := &fakeIdempotencyCache{values: map[string][]byte{}}
+	calls := 0
+	router := newIdempotencyRouter(cache, &calls, http.StatusOK)
+
+	// Credential requests carry secrets both ways, so nothing may be kept for them
+	postWithKey(router, "", "key-1", `{"merchant_id":"m1","password":"secret"}`)
+	w := postWithKey(router, "", "key-1", `{"merchant_id":"m1","password":"secret"}`)
+
+	assert.Equal(t, 2, calls)
+	assert.Empty(t, w.Header().Get(idempotentReplayedHeader))
+	assert.Empty(t, cache.values)
+}
+
+func TestIdempotencyMiddleware_FingerprintIsKeyed(t *testing.T) {
+	t.Setenv("REDIS_ENABLED", "true")
+	cache := &fakeIdempotencyCache{values: map[string][]byte{}}
+	calls := 0
+	router := newIdempotencyRouter(cache, &calls, http.StatusOK)
+
+	postWithKey(router, "merchant-1", "key-1", `{}`)
+
+	require.Len(t, cache.values, 1)
+	for _, data := range cache.values {
+		var stored idempotentResponse
+		require.NoError(t, json.Unmarshal(data, &stored))
+		plain := sha256.Sum256([]byte("format=csv\x00{}"))
+		assert.NotEqual(t, hex.EncodeToString(plain[:]), stored.Fingerprint)
+		assert.Equal(t, requestFingerprint("format=csv", []byte(`{}`)), stored.Fingerprint)
+	}
+}
+
+func TestIdempotencyMiddleware_RejectsOversizedRequests(t *testing.T) {
+	t.Setenv("REDIS_ENABLED", "true")
+	originalMax := idempotencyMaxRequestBytes
+	idempotencyMaxRequestBytes = 10
+	defer func() { idempotencyMaxRequestBytes = originalMax }()
+
+	cache := &fakeIdempotencyCache{values: map[string][]byte{}}
+	calls := 0
+	router := newIdempotencyRouter(cache, &calls, http.StatusOK)
+
+	w := postWithKey(router, "merchant-1", "key-1", `{"a":"0123456789"}`)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Zero(t, calls)
+}
+
+func TestIdempotencyMiddleware_DoesNotStoreServerErrors(t *testing.T) {
+	t.Setenv("REDIS_ENABLED", "true")
+	cache := &fakeIdempotencyCache{values: map[string][]byte{}}
+	calls := 0
+	router := newIdempotencyRouter(cache, &calls, http.StatusServiceUnavailable)
+
+	postWithKey(router, "merchant-1", "key-1", `{}`)
+	postWithKey(router, "merchant-1", "key-1", `{}`)
+
+	assert.Equal(t, 2, calls)
+	assert.Empty(t, cache.values)
+}
+
+func TestIdempotencyMiddleware_DoesNotStoreOversizedBodies(t *testing.T) {
+	t.Setenv("REDIS_ENABLED", "true")
+	originalMax := idempotencyMaxBodyBytes
+	idempotencyMaxBodyBytes = 10
+	defer func() { idempotencyMaxBodyBytes = originalMax }()
+
+	cache := &fakeIdempotencyCache{values: map[string][]byte{}}
+	calls := 0
+	router := newIdempotencyRouter(cache, &calls, http.StatusOK)
+
+	w := postWithKey(router, "merchant-1", "key-1", `{"a":"0123456789"}`)
+
+	assert.Contains(t, w.Body.String(), "0123456789")
+	assert.Empty(t, cache.values)
+}
+
+func TestIdempotencyMiddleware_PassThrough(t *testing.T) {
+	t.Setenv("REDIS_ENABLED", "true")
+	cache := &fakeIdempotencyCache{values: map[string][]byte{}}
+	calls := 0
+	router := newIdempotencyRouter(cache, &calls, http.StatusOK)
+
+	postWithKey(router, "merchant-1", "", `{}`)
+	postWithKey(router, "merchant-1", "", `{}`)
+	assert.Equal(t, 2, calls)
+
+	w := postWithKey(router, "merchant-1", strings.Repeat("k", maxIdempotencyKeyLength+1), `{}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	t.Setenv("REDIS_ENABLED", "false")
+	postWithKey(router, "merchant-1", "key-1", `{}`)
+	postWithKey(router, "merchant-1", "key-1", `{}`)
+	assert.Equal(t, 4, calls)
+	assert.Empty(t, cache.values)
+}
+
+func TestIdempotencyMiddleware_BodyAvailableToHandler(t *testing.T) {
+	t.Setenv("REDIS_ENABLED", "true")
+	cache := &fakeIdempotencyCache{values: map[string][]byte{}}
+	calls := 0
+	router := newIdempotencyRouter(cache, &calls, http.StatusOK)
+
+	w := postWithKey(router, "merchant-1", "key-1", `{"a":1}`)
+
+	var response map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, map[string]interface{}{"a": float64(1)}, response["body"])
+}
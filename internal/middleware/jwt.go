@@ -1,11 +1,14 @@
 package middleware
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"strings"
 	"time"
 
 	"aken_reporting_service/internal/config"
+	"aken_reporting_service/internal/utils"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
@@ -18,6 +21,84 @@ type TokenClaims struct {
 	jwt.RegisteredClaims
 }
 
+// jwtClockSkew is how far past the configured expiry a token's exp may be, allowing for
+// clock differences between instances
+const jwtClockSkew = time.Minute
+
+// errTokenExpiryTooFar is returned for a token whose exp exceeds the configured token lifetime
+var errTokenExpiryTooFar = errors.New("token expiry is too far in the future")
+
+// jwtSigner holds the signing method and key lookups of one JWT algorithm. HMAC algorithms
+// use the same key both ways; asymmetric algorithms such as RS256 can be added to jwtSigners
+// with separate private and public key lookups without changing SignToken or ParseToken.
+type jwtSigner struct {
+	method     jwt.SigningMethod
+	signingKey func() (interface{}, error)
+	verifyKey  func() (interface{}, error)
+}
+
+// jwtSigners are the signers of config.JWTAlgorithms
+var jwtSigners = map[string]jwtSigner{
+	"HS256": {method: jwt.SigningMethodHS256, signingKey: hmacKey("HS256"), verifyKey: hmacKey("HS256")},
+}
+
+// hmacKey returns a key lookup for the shared secret of an HMAC algorithm
+func hmacKey(algorithm string) func() (interface{}, error) {
+	return func() (interface{}, error) {
+		return []byte(config.GetJWTSecretForAlgorithm(algorithm)), nil
+	}
+}
+
+// currentSigner returns the signer of the configured JWT_ALGORITHM
+func currentSigner() (jwtSigner, error) {
+	algorithm := config.GetJWTAlgorithm()
+	signer, exists := jwtSigners[algorithm]
+	if !exists {
+		return jwtSigner{}, fmt.Errorf("unsupported JWT algorithm %q", algorithm)
+	}
+	return signer, nil
+}
+
+// SignToken signs claims with the configured JWT algorithm
+func SignToken(claims jwt.Claims) (string, error) {
+	signer, err := currentSigner()
+	if err != nil {
+		return "", err
+	}
+	key, err := signer.signingKey()
+	if err != nil {
+		return "", err
+	}
+	return jwt.NewWithClaims(signer.method, claims).SignedString(key)
+}
+
+// ParseToken verifies tokenString with the configured JWT algorithm and decodes it into claims.
+// Tokens signed with another algorithm, without an exp claim, or with an exp further ahead than
+// JWT_EXPIRY_HOURS allows are rejected.
+func ParseToken(tokenString string, claims jwt.Claims) (*jwt.Token, error) {
+	signer, err := currentSigner()
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return signer.verifyKey()
+	}, jwt.WithValidMethods([]string{signer.method.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, err
+	}
+
+	expiresAt, err := claims.GetExpirationTime()
+	if err != nil {
+		return nil, err
+	}
+	if expiresAt.After(time.Now().Add(config.GetJWTExpiry() + jwtClockSkew)) {
+		return nil, errTokenExpiryTooFar
+	}
+
+	return token, nil
+}
+
 // JWTAuthMiddleware provides JWT authentication middleware
 func JWTAuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -52,14 +133,7 @@ func JWTAuthMiddleware() gin.HandlerFunc {
 		}
 
 		// Parse and validate token
-		token, err := jwt.ParseWithClaims(tokenString, &TokenClaims{}, func(token *jwt.Token) (interface{}, error) {
-			// Verify the signing method
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, jwt.ErrSignatureInvalid
-			}
-			return []byte(config.GetJWTSecret()), nil
-		})
-
+		token, err := ParseToken(tokenString, &TokenClaims{})
 		if err != nil {
 			sendJWTAuthError(c, "Invalid token: "+err.Error())
 			return
@@ -90,13 +164,6 @@ func JWTAuthMiddleware() gin.HandlerFunc {
 
 // sendJWTAuthError sends a JWT authentication error response
 func sendJWTAuthError(c *gin.Context, message string) {
-	c.JSON(http.StatusUnauthorized, gin.H{
-		"error": gin.H{
-			"code":       config.ErrorCodeAuthFailed,
-			"message":    message,
-			"timestamp":  time.Now().UTC().Format(time.RFC3339),
-			"request_id": c.GetHeader("X-Request-ID"),
-		},
-	})
+	utils.SendError(c, http.StatusUnauthorized, config.ErrorCodeAuthFailed, message, nil)
 	c.Abort()
 }
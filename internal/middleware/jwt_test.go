@@ -0,0 +1,129 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"aken_reporting_service/internal/config"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testClaims(ttl time.Duration) *TokenClaims {
+	now := time.Now()
+	return &TokenClaims{
+		MerchantID:   "merchant-1",
+		MerchantName: "Coffee Co",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+}
+
+func TestJWTSigners_CoverSupportedAlgorithms(t *testing.T) {
+	for algorithm := range config.JWTAlgorithms {
+		signer, exists := jwtSigners[algorithm]
+		require.True(t, exists, algorithm)
+		assert.Equal(t, algorithm, signer.method.Alg())
+	}
+}
+
+func TestSignToken_RoundTrip(t *testing.T) {
+	t.Setenv("JWT_ALGORITHM", "")
+	t.Setenv("JWT_SECRET_HS256", "hs256-secret")
+
+	tokenString, err := SignToken(testClaims(time.Hour))
+	require.NoError(t, err)
+
+	claims := &TokenClaims{}
+	token, err := ParseToken(tokenString, claims)
+	require.NoError(t, err)
+	assert.Equal(t, "HS256", token.Method.Alg())
+	assert.Equal(t, "merchant-1", claims.MerchantID)
+
+	// Signed with the per-algorithm key, not JWT_SECRET
+	_, err = jwt.ParseWithClaims(tokenString, &TokenClaims{}, func(*jwt.Token) (interface{}, error) {
+		return []byte(config.GetJWTSecret()), nil
+	})
+	assert.Error(t, err)
+}
+
+func TestParseToken_RejectsUnsafeTokens(t *testing.T) {
+	t.Setenv("JWT_ALGORITHM", "")
+	t.Setenv("JWT_EXPIRY_HOURS", "24")
+	secret := []byte(config.GetJWTSecretForAlgorithm("HS256"))
+
+	sign := func(method jwt.SigningMethod, claims jwt.Claims, key interface{}) string {
+		tokenString, err := jwt.NewWithClaims(method, claims).SignedString(key)
+		require.NoError(t, err)
+		return tokenString
+	}
+
+	noExpiry := testClaims(time.Hour)
+	noExpiry.ExpiresAt = nil
+
+	tests := map[string]string{
+		"expiry a year ahead":  sign(jwt.SigningMethodHS256, testClaims(365*24*time.Hour), secret),
+		"expiry past lifetime": sign(jwt.SigningMethodHS256, testClaims(25*time.Hour), secret),
+		"no expiry":            sign(jwt.SigningMethodHS256, noExpiry, secret),
+		"other hmac algorithm": sign(jwt.SigningMethodHS512, testClaims(time.Hour), secret),
+		"unsigned":             sign(jwt.SigningMethodNone, testClaims(time.Hour), jwt.UnsafeAllowNoneSignatureType),
+	}
+	for name, tokenString := range tests {
+		_, err := ParseToken(tokenString, &TokenClaims{})
+		assert.Error(t, err, name)
+	}
+
+	_, err := ParseToken(sign(jwt.SigningMethodHS256, testClaims(24*time.Hour), secret), &TokenClaims{})
+	assert.NoError(t, err, "a token at the configured lifetime is accepted")
+
+	_, err = ParseToken(sign(jwt.SigningMethodHS256, testClaims(365*24*time.Hour), secret), &TokenClaims{})
+	assert.ErrorIs(t, err, errTokenExpiryTooFar)
+}
+
+func TestParseToken_UnsupportedAlgorithm(t *testing.T) {
+	t.Setenv("JWT_ALGORITHM", "RS256")
+
+	_, err := SignToken(testClaims(time.Hour))
+	assert.Error(t, err)
+	_, err = ParseToken("a.b.c", &TokenClaims{})
+	assert.Error(t, err)
+}
+
+func TestJWTAuthMiddleware_RejectsFarFutureExpiry(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("ENV", "production")
+	t.Setenv("DISABLE_AUTH", "")
+	t.Setenv("JWT_ALGORITHM", "")
+	t.Setenv("JWT_EXPIRY_HOURS", "1")
+
+	router := gin.New()
+	router.Use(JWTAuthMiddleware())
+	router.GET("/test", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"merchant_id": c.GetString("merchantID")})
+	})
+
+	send := func(claims *TokenClaims) *httptest.ResponseRecorder {
+		tokenString, err := SignToken(claims)
+		require.NoError(t, err)
+		req, _ := http.NewRequest(http.MethodGet, "/test", nil)
+		req.Header.Set("Authorization", "Bearer "+tokenString)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := send(testClaims(30 * time.Minute))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "merchant-1")
+
+	w = send(testClaims(48 * time.Hour))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "too far in the future")
+}
@@ -1,17 +1,50 @@
 package middleware
 
 import (
+	"aken_reporting_service/internal/config"
 	"aken_reporting_service/internal/utils"
 	"fmt"
+	"net/http"
 	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
-// LoggingMiddleware provides structured JSON logging for HTTP requests
+// requestLogSampler decides which requests are logged: every error response, and one in
+// rate of the others
+type requestLogSampler struct {
+	rate  uint64
+	count uint64
+}
+
+func newRequestLogSampler(rate int) *requestLogSampler {
+	if rate < 1 {
+		rate = 1
+	}
+	return &requestLogSampler{rate: uint64(rate)}
+}
+
+// shouldLog reports whether a request that completed with status is logged. Of the
+// successful requests the first is logged, then every rate-th one.
+func (s *requestLogSampler) shouldLog(status int) bool {
+	if status >= http.StatusBadRequest {
+		return true
+	}
+	return (atomic.AddUint64(&s.count, 1)-1)%s.rate == 0
+}
+
+// LoggingMiddleware provides structured JSON logging for HTTP requests. Successful requests
+// are sampled at REQUEST_LOG_SAMPLE_RATE; error responses are always logged.
 func LoggingMiddleware() gin.HandlerFunc {
+	sampler := newRequestLogSampler(config.GetRequestLogSampleRate())
+
 	return gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
+		if !sampler.shouldLog(param.StatusCode) {
+			return ""
+		}
+
 		// Use our structured logger instead of default Gin logging
 		utils.LogHTTPRequest(
 			param.Method,
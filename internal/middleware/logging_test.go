@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"aken_reporting_service/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestLogSampler(t *testing.T) {
+	tests := []struct {
+		name     string
+		rate     int
+		statuses []int
+		want     []bool
+	}{
+		{"every request at rate 1", 1, []int{200, 200, 200}, []bool{true, true, true}},
+		{"invalid rate logs every request", 0, []int{200, 200}, []bool{true, true}},
+		{"one in three", 3, []int{200, 200, 200, 200, 200, 200, 200}, []bool{true, false, false, true, false, false, true}},
+		{"errors are always logged", 3, []int{200, 404, 500, 200, 200, 200}, []bool{true, true, true, false, false, true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sampler := newRequestLogSampler(tt.rate)
+			var got []bool
+			for _, status := range tt.statuses {
+				got = append(got, sampler.shouldLog(status))
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestLoggingMiddleware_Sampling(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("REQUEST_LOG_SAMPLE_RATE", "10")
+
+	var buf bytes.Buffer
+	utils.Logger.SetOutput(&buf)
+	defer utils.Logger.SetOutput(os.Stderr)
+
+	router := gin.New()
+	router.Use(LoggingMiddleware())
+	router.GET("/ok", func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.GET("/fail", func(c *gin.Context) { c.Status(http.StatusInternalServerError) })
+
+	for i := 0; i < 20; i++ {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/ok", nil))
+	}
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/fail", nil))
+
+	assert.Equal(t, 2, strings.Count(buf.String(), `"url":"/ok"`))
+	assert.Equal(t, 1, strings.Count(buf.String(), `"url":"/fail"`))
+}
+
+func TestLoggingMiddleware_QueryStringOnlyAtTrace(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("REQUEST_LOG_SAMPLE_RATE", "1")
+
+	var buf bytes.Buffer
+	utils.Logger.SetOutput(&buf)
+	defer utils.Logger.SetOutput(os.Stderr)
+	defer utils.SetLogLevel("info")
+
+	router := gin.New()
+	router.Use(LoggingMiddleware())
+	router.GET("/transactions", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	utils.SetLogLevel("info")
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/transactions?filter=rrn:eq:123456", nil))
+	assert.Contains(t, buf.String(), `"url":"/transactions"`)
+	assert.NotContains(t, buf.String(), "123456", "filter values stay out of info logs")
+
+	buf.Reset()
+	utils.SetLogLevel("trace")
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/transactions?filter=rrn:eq:123456", nil))
+	assert.Contains(t, buf.String(), `"url":"/transactions?filter=rrn:eq:123456"`)
+
+	buf.Reset()
+	utils.SetLogLevel("warn")
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/transactions", nil))
+	assert.Empty(t, buf.String())
+}
@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"aken_reporting_service/internal/config"
+	"aken_reporting_service/internal/services"
+	"aken_reporting_service/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimitMiddleware enforces the hourly request limit of the authenticated merchant's tier
+// using a Redis sliding window. It must run after authentication. When Redis is disabled or
+// unreachable the request is allowed through without rate limit headers.
+func RateLimitMiddleware(cacheService services.CacheService) gin.HandlerFunc {
+	window := time.Duration(config.RateLimitWindow) * time.Second
+
+	return func(c *gin.Context) {
+		merchantID := getMerchantID(c)
+		if cacheService == nil || merchantID == "" {
+			c.Next()
+			return
+		}
+
+		result, err := cacheService.AllowRequest(merchantID, config.GetMerchantRateLimit(merchantID), window)
+		if err != nil {
+			if !errors.Is(err, services.ErrCacheDisabled) {
+				utils.LogWarn("Rate limit check failed, allowing request", map[string]interface{}{
+					"merchant_id": merchantID,
+					"error":       err.Error(),
+				})
+			}
+			c.Next()
+			return
+		}
+
+		c.Header("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("X-RateLimit-Reset", strconv.FormatInt(result.ResetAt.Unix(), 10))
+		c.Header("X-RateLimit-Window", strconv.Itoa(config.RateLimitWindow))
+
+		if !result.Allowed {
+			retryAfter := int(math.Ceil(time.Until(result.ResetAt).Seconds()))
+			if retryAfter < 1 {
+				retryAfter = 1
+			}
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+
+			utils.SendError(c, http.StatusTooManyRequests, config.ErrorCodeRateLimited, "", nil)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,137 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"aken_reporting_service/internal/config"
+	"aken_reporting_service/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeRateLimitCache counts requests in memory; methods other than AllowRequest panic
+type fakeRateLimitCache struct {
+	services.CacheService
+
+	counts    map[string]int
+	lastLimit int
+	resetAt   time.Time
+	err       error
+}
+
+func (f *fakeRateLimitCache) AllowRequest(key string, limit int, window time.Duration) (*services.RateLimitResult, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	f.lastLimit = limit
+	allowed := f.counts[key] < limit
+	if allowed {
+		f.counts[key]++
+	}
+
+	return &services.RateLimitResult{Allowed: allowed, Limit: limit, Remaining: limit - f.counts[key], ResetAt: f.resetAt}, nil
+}
+
+func newRateLimitRouter(cache services.CacheService, merchantID string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		if merchantID != "" {
+			c.Set("merchantID", merchantID)
+		}
+	})
+	router.Use(RateLimitMiddleware(cache))
+	router.GET("/test", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "success"})
+	})
+	return router
+}
+
+func TestRateLimitMiddleware_ExceedReturns429(t *testing.T) {
+	t.Setenv("RATE_LIMIT_TIERS", "")
+	resetAt := time.Now().Add(90 * time.Second)
+	cache := &fakeRateLimitCache{counts: map[string]int{"merchant-1": config.RateLimitStandard - 1}, resetAt: resetAt}
+	router := newRateLimitRouter(cache, "merchant-1")
+
+	req, _ := http.NewRequest("GET", "/test", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, strconv.Itoa(config.RateLimitStandard), w.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "0", w.Header().Get("X-RateLimit-Remaining"))
+	assert.Equal(t, strconv.FormatInt(resetAt.Unix(), 10), w.Header().Get("X-RateLimit-Reset"))
+	assert.Empty(t, w.Header().Get("Retry-After"))
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "0", w.Header().Get("X-RateLimit-Remaining"))
+	retryAfter, err := strconv.Atoi(w.Header().Get("Retry-After"))
+	assert.NoError(t, err)
+	assert.InDelta(t, 90, retryAfter, 2)
+	assert.Contains(t, w.Body.String(), config.ErrorCodeRateLimited)
+}
+
+func TestRateLimitMiddleware_TierPerMerchant(t *testing.T) {
+	t.Setenv("RATE_LIMIT_TIERS", "merchant-1:premium, merchant-2:enterprise,merchant-3:unknown")
+
+	tests := map[string]int{
+		"merchant-1": config.RateLimitPremium,
+		"merchant-2": config.RateLimitEnterprise,
+		"merchant-3": config.RateLimitStandard,
+		"merchant-4": config.RateLimitStandard,
+	}
+
+	for merchantID, expected := range tests {
+		cache := &fakeRateLimitCache{counts: map[string]int{}, resetAt: time.Now().Add(time.Hour)}
+		router := newRateLimitRouter(cache, merchantID)
+
+		req, _ := http.NewRequest("GET", "/test", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code, merchantID)
+		assert.Equal(t, expected, cache.lastLimit, merchantID)
+		assert.Equal(t, strconv.Itoa(expected-1), w.Header().Get("X-RateLimit-Remaining"), merchantID)
+	}
+}
+
+func TestRateLimitMiddleware_FailsOpen(t *testing.T) {
+	tests := map[string]services.CacheService{
+		"no-op cache": &fakeRateLimitCache{err: services.ErrCacheDisabled},
+		"redis error": &fakeRateLimitCache{err: errors.New("connection refused")},
+		"nil cache":   nil,
+	}
+
+	for name, cache := range tests {
+		router := newRateLimitRouter(cache, "merchant-1")
+
+		req, _ := http.NewRequest("GET", "/test", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code, name)
+		assert.Empty(t, w.Header().Get("X-RateLimit-Limit"), name)
+	}
+}
+
+func TestRateLimitMiddleware_SkipsUnauthenticated(t *testing.T) {
+	cache := &fakeRateLimitCache{counts: map[string]int{}}
+	router := newRateLimitRouter(cache, "")
+
+	req, _ := http.NewRequest("GET", "/test", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, cache.counts)
+}
@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// Export job statuses
+const (
+	ExportStatusRunning   = "running"
+	ExportStatusCompleted = "completed"
+	ExportStatusFailed    = "failed"
+)
+
+// Export delivery targets
+const (
+	ExportDeliveryDownload = "download" // File is streamed in the export response
+	ExportDeliveryS3       = "s3"       // File is uploaded to object storage
+	ExportDeliveryWebhook  = "webhook"  // File is POSTed to a report schedule's webhook
+)
+
+// ExportJob records one transaction export requested by a merchant
+type ExportJob struct {
+	ID          string     `json:"id"`
+	MerchantID  string     `json:"-"`
+	Status      string     `json:"status"`
+	Format      string     `json:"format"`
+	Delivery    string     `json:"delivery,omitempty"`
+	ObjectKey   string     `json:"-"` // Object storage key for s3 delivery
+	RowCount    int        `json:"row_count"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+// ExportJobStatus is an export job as returned by the export status endpoint
+type ExportJobStatus struct {
+	ExportJob
+	DownloadURL string `json:"download_url,omitempty"` // Pre-signed URL for completed s3 exports
+}
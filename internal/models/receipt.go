@@ -0,0 +1,86 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TransactionReceipt is the printable summary of a single transaction
+type TransactionReceipt struct {
+	TransactionID   string  `json:"payment_tx_log_id"`
+	MerchantName    string  `json:"merchant_name"`
+	Type            string  `json:"tx_log_type"`
+	Amount          int64   `json:"amount"`
+	FormattedAmount string  `json:"formatted_amount"`
+	CurrencyCode    string  `json:"currency_code"`
+	CurrencySymbol  string  `json:"currency_symbol"`
+	PAN             *string `json:"pan"`
+	RRN             string  `json:"rrn"`
+	STAN            string  `json:"stan"`
+	AuthCode        *string `json:"auth_code"`
+	ResponseCode    *string `json:"response_code"`
+	DateTime        string  `json:"date_time"` // RFC 3339 in Timezone
+	Timezone        string  `json:"timezone"`
+}
+
+// NewTransactionReceipt builds the receipt of tx with its date in loc. The amount is
+// formatted with the transaction's currency, or left unformatted when it has none.
+func NewTransactionReceipt(tx *Transaction, loc *time.Location) *TransactionReceipt {
+	receipt := &TransactionReceipt{
+		TransactionID:   tx.ID,
+		MerchantName:    tx.MerchantName,
+		Type:            tx.Type,
+		Amount:          tx.Amount,
+		FormattedAmount: strconv.FormatInt(tx.Amount, 10),
+		CurrencyCode:    tx.CurrencyCode,
+		PAN:             tx.PAN,
+		RRN:             tx.RRN,
+		STAN:            tx.STAN,
+		AuthCode:        tx.AuthCode,
+		ResponseCode:    tx.ResponseCode,
+		DateTime:        tx.UpdatedAt.In(loc).Format(time.RFC3339),
+		Timezone:        loc.String(),
+	}
+
+	if tx.CurrencyInfo != nil {
+		receipt.FormattedAmount = tx.CurrencyInfo.FormatAmount(tx.Amount)
+		receipt.CurrencySymbol = tx.CurrencyInfo.Symbol
+	}
+	if receipt.Type == "" {
+		receipt.Type = tx.GetTypeString()
+	}
+
+	return receipt
+}
+
+// Text renders the receipt as a fixed-width block for printing
+func (r *TransactionReceipt) Text() string {
+	lines := [][2]string{
+		{"Merchant", r.MerchantName},
+		{"Date", r.DateTime},
+		{"Type", strings.ToUpper(r.Type)},
+		{"Amount", r.FormattedAmount},
+		{"Card", stringOrEmpty(r.PAN)},
+		{"RRN", r.RRN},
+		{"STAN", r.STAN},
+		{"Auth code", stringOrEmpty(r.AuthCode)},
+		{"Response", stringOrEmpty(r.ResponseCode)},
+		{"Transaction", r.TransactionID},
+	}
+
+	var b strings.Builder
+	b.WriteString("TRANSACTION RECEIPT\n")
+	for _, line := range lines {
+		fmt.Fprintf(&b, "%-12s %s\n", line[0]+":", line[1])
+	}
+	return b.String()
+}
+
+func stringOrEmpty(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewTransactionReceipt_WithoutCurrencyInfo(t *testing.T) {
+	tx := &Transaction{
+		ID:              "tx-1",
+		PaymentTxTypeID: 3,
+		Amount:          500,
+		UpdatedAt:       time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC),
+	}
+
+	receipt := NewTransactionReceipt(tx, time.UTC)
+
+	assert.Equal(t, "500", receipt.FormattedAmount)
+	assert.Empty(t, receipt.CurrencySymbol)
+	assert.Equal(t, "refund", receipt.Type)
+	assert.Equal(t, "2025-01-15T10:00:00Z", receipt.DateTime)
+	assert.Contains(t, receipt.Text(), "Type:        REFUND\n")
+	assert.Contains(t, receipt.Text(), "Card:        \n")
+}
@@ -0,0 +1,111 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// Report schedule frequencies
+const (
+	ReportFrequencyHourly = "hourly"
+	ReportFrequencyDaily  = "daily"
+	ReportFrequencyWeekly = "weekly"
+)
+
+// Report delivery types
+const (
+	ReportDeliveryStore   = "store"   // Uploaded to object storage, like an s3 export
+	ReportDeliveryWebhook = "webhook" // POSTed to a merchant-supplied HTTPS URL
+)
+
+// ReportSchedule is a recurring export of a merchant's transactions
+type ReportSchedule struct {
+	ID           string             `json:"id"`
+	MerchantID   string             `json:"-"`
+	Name         string             `json:"name"`
+	Filter       string             `json:"filter,omitempty"` // Filter expression, as accepted by the list endpoint
+	Fields       []string           `json:"fields,omitempty"`
+	Format       string             `json:"format"` // csv or xlsx
+	Timezone     string             `json:"timezone"`
+	Schedule     ReportScheduleSpec `json:"schedule"`
+	Delivery     ReportDelivery     `json:"delivery"`
+	CreatedAt    time.Time          `json:"created_at"`
+	NextRunAt    time.Time          `json:"next_run_at"`
+	LastRunAt    *time.Time         `json:"last_run_at,omitempty"`
+	LastStatus   string             `json:"last_status,omitempty"`    // An export job status
+	LastExportID string             `json:"last_export_id,omitempty"` // Export job of the last run
+}
+
+// ReportScheduleSpec describes when a report runs, in the schedule's timezone
+type ReportScheduleSpec struct {
+	Frequency string       `json:"frequency"`         // hourly, daily or weekly
+	Minute    int          `json:"minute"`            // Minute of the hour, 0-59
+	Hour      int          `json:"hour"`              // Hour of the day, 0-23 (daily and weekly)
+	Weekday   time.Weekday `json:"weekday,omitempty"` // 0 (Sunday) to 6 (weekly only)
+}
+
+// ReportDelivery describes where a report's output is sent
+type ReportDelivery struct {
+	Type string `json:"type"`          // store or webhook
+	URL  string `json:"url,omitempty"` // Webhook target
+}
+
+// CreateReportScheduleRequest is the request body for creating a report schedule
+type CreateReportScheduleRequest struct {
+	Name     string             `json:"name" binding:"required"`
+	Filter   string             `json:"filter"`
+	Fields   []string           `json:"fields"`
+	Format   string             `json:"format"`
+	Timezone string             `json:"timezone"`
+	Schedule ReportScheduleSpec `json:"schedule"`
+	Delivery ReportDelivery     `json:"delivery"`
+}
+
+// Validate checks the frequency and time fields of the spec
+func (s ReportScheduleSpec) Validate() error {
+	switch s.Frequency {
+	case ReportFrequencyHourly, ReportFrequencyDaily, ReportFrequencyWeekly:
+	default:
+		return fmt.Errorf("invalid frequency '%s' (must be hourly, daily or weekly)", s.Frequency)
+	}
+
+	if s.Minute < 0 || s.Minute > 59 {
+		return fmt.Errorf("invalid minute %d (must be 0-59)", s.Minute)
+	}
+	if s.Hour < 0 || s.Hour > 23 {
+		return fmt.Errorf("invalid hour %d (must be 0-23)", s.Hour)
+	}
+	if s.Weekday < time.Sunday || s.Weekday > time.Saturday {
+		return fmt.Errorf("invalid weekday %d (must be 0-6)", s.Weekday)
+	}
+
+	return nil
+}
+
+// Next returns the first run time strictly after the given time, evaluated in loc
+func (s ReportScheduleSpec) Next(after time.Time, loc *time.Location) time.Time {
+	t := after.In(loc)
+	year, month, day := t.Date()
+
+	var next time.Time
+	switch s.Frequency {
+	case ReportFrequencyHourly:
+		next = time.Date(year, month, day, t.Hour(), s.Minute, 0, 0, loc)
+		if !next.After(t) {
+			next = time.Date(year, month, day, t.Hour()+1, s.Minute, 0, 0, loc)
+		}
+	case ReportFrequencyWeekly:
+		days := (int(s.Weekday) - int(t.Weekday()) + 7) % 7
+		next = time.Date(year, month, day+days, s.Hour, s.Minute, 0, 0, loc)
+		if !next.After(t) {
+			next = time.Date(year, month, day+days+7, s.Hour, s.Minute, 0, 0, loc)
+		}
+	default:
+		next = time.Date(year, month, day, s.Hour, s.Minute, 0, 0, loc)
+		if !next.After(t) {
+			next = time.Date(year, month, day+1, s.Hour, s.Minute, 0, 0, loc)
+		}
+	}
+
+	return next.UTC()
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReportScheduleSpec_Next(t *testing.T) {
+	johannesburg, err := time.LoadLocation("Africa/Johannesburg")
+	assert.NoError(t, err)
+
+	// Wednesday 2025-01-15 10:30 UTC, 12:30 in Johannesburg
+	now := time.Date(2025, 1, 15, 10, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		spec     ReportScheduleSpec
+		loc      *time.Location
+		expected time.Time
+	}{
+		{"hourly later this hour", ReportScheduleSpec{Frequency: ReportFrequencyHourly, Minute: 45}, time.UTC, time.Date(2025, 1, 15, 10, 45, 0, 0, time.UTC)},
+		{"hourly next hour", ReportScheduleSpec{Frequency: ReportFrequencyHourly, Minute: 30}, time.UTC, time.Date(2025, 1, 15, 11, 30, 0, 0, time.UTC)},
+		{"daily later today", ReportScheduleSpec{Frequency: ReportFrequencyDaily, Hour: 18}, time.UTC, time.Date(2025, 1, 15, 18, 0, 0, 0, time.UTC)},
+		{"daily tomorrow", ReportScheduleSpec{Frequency: ReportFrequencyDaily, Hour: 6}, time.UTC, time.Date(2025, 1, 16, 6, 0, 0, 0, time.UTC)},
+		{"daily in local time", ReportScheduleSpec{Frequency: ReportFrequencyDaily, Hour: 6}, johannesburg, time.Date(2025, 1, 16, 4, 0, 0, 0, time.UTC)},
+		{"weekly later this week", ReportScheduleSpec{Frequency: ReportFrequencyWeekly, Weekday: time.Friday, Hour: 8}, time.UTC, time.Date(2025, 1, 17, 8, 0, 0, 0, time.UTC)},
+		{"weekly same day passed", ReportScheduleSpec{Frequency: ReportFrequencyWeekly, Weekday: time.Wednesday, Hour: 8}, time.UTC, time.Date(2025, 1, 22, 8, 0, 0, 0, time.UTC)},
+		{"weekly next monday", ReportScheduleSpec{Frequency: ReportFrequencyWeekly, Weekday: time.Monday}, time.UTC, time.Date(2025, 1, 20, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.spec.Next(now, tt.loc))
+		})
+	}
+}
+
+func TestReportScheduleSpec_NextRollsOverMonthEnd(t *testing.T) {
+	spec := ReportScheduleSpec{Frequency: ReportFrequencyDaily, Hour: 1}
+	now := time.Date(2025, 1, 31, 23, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, time.Date(2025, 2, 1, 1, 0, 0, 0, time.UTC), spec.Next(now, time.UTC))
+}
+
+func TestReportScheduleSpec_Validate(t *testing.T) {
+	assert.NoError(t, ReportScheduleSpec{Frequency: ReportFrequencyWeekly, Weekday: time.Saturday, Hour: 23, Minute: 59}.Validate())
+	assert.Error(t, ReportScheduleSpec{Frequency: "monthly"}.Validate())
+	assert.Error(t, ReportScheduleSpec{Frequency: ReportFrequencyDaily, Hour: 24}.Validate())
+	assert.Error(t, ReportScheduleSpec{Frequency: ReportFrequencyHourly, Minute: -1}.Validate())
+	assert.Error(t, ReportScheduleSpec{Frequency: ReportFrequencyWeekly, Weekday: 7}.Validate())
+}
@@ -1,9 +1,13 @@
 package models
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"math"
+	"strings"
 	"time"
 )
 
@@ -78,6 +82,27 @@ func (t *Transaction) GetTypeString() string {
 	}
 }
 
+// PaymentTxTypeID converts a tx_log_type string to its payment_tx_type_id.
+// The second return value is false for unknown types.
+func PaymentTxTypeID(txLogType string) (int, bool) {
+	switch txLogType {
+	case "payment":
+		return 0, true
+	case "reversal":
+		return 1, true
+	case "void":
+		return 2, true
+	case "refund":
+		return 3, true
+	case "mm purchase":
+		return 9, true
+	case "mm refund":
+		return 10, true
+	default:
+		return 0, false
+	}
+}
+
 // IsReversed checks if transaction was reversed
 func (t *Transaction) IsReversed() bool {
 	return t.ReversedTxLogID != nil
@@ -186,6 +211,9 @@ type TransactionFilter struct {
 	MerchantCode      *string    `json:"merchant_code"`
 	DeviceID          *string    `json:"device_id"`
 	ProfileID         *string    `json:"profile_id"`
+	PaymentTxRef      *string    `json:"payment_tx_ref"`
+	STAN              *string    `json:"stan"`
+	RRN               *string    `json:"rrn"`
 	ResponseCode      *string    `json:"response_code"`
 	ResultCode        *string    `json:"result_code"`
 	DateTimeFrom      *time.Time `json:"datetime_from"`
@@ -199,13 +227,129 @@ type TransactionFilter struct {
 	Completed         *bool      `json:"completed"`
 	Active            *bool      `json:"active"`
 	Reversed          *bool      `json:"reversed"`
+
+	// Not-equal ("ne" operator) filters
+	MerchantIDNot   *string `json:"merchant_id_not,omitempty"`
+	ResponseCodeNot *string `json:"response_code_not,omitempty"`
+	CurrencyCodeNot *string `json:"currency_code_not,omitempty"`
+
+	// Set membership ("in" operator) filters
+	ResponseCodeIn []string `json:"response_code_in,omitempty"`
+	CurrencyCodeIn []string `json:"currency_code_in,omitempty"`
+	TxLogTypeIn    []string `json:"tx_log_type_in,omitempty"`
+
+	// Partial match ("like"/"ilike" operator) filters, matched case-insensitively as substrings
+	MerchantNameLike *string `json:"merchant_name_like,omitempty"`
+	DescriptionLike  *string `json:"description_like,omitempty"`
+	PaymentTxRefLike *string `json:"payment_tx_ref_like,omitempty"`
+
+	// Null checks ("isnull"/"isnotnull" operators), keyed by field name: true for IS NULL,
+	// false for IS NOT NULL. Only config.NullableFilterFields are accepted.
+	NullFields map[string]bool `json:"null_fields,omitempty"`
+
+	// Settlement filters, only applied when SETTLEMENT_COLUMNS_ENABLED is set
+	SettlementStatus   *string    `json:"settlement_status,omitempty"`
+	SettlementDateFrom *time.Time `json:"settlement_date_from,omitempty"`
+	SettlementDateTo   *time.Time `json:"settlement_date_to,omitempty"`
+
+	// Bool query from the search endpoint, ANDed with the filters above
+	Search *SearchBoolQuery `json:"-"`
+
+	// Include inactive (soft-deleted) transactions, which are excluded by default
+	IncludeInactive bool `json:"-"`
+
+	// Include incomplete (in-flight) transactions, which are excluded by default unless
+	// Completed is set
+	IncludeIncomplete bool `json:"-"`
+
+	// Column the DateTimeFrom/DateTimeTo range and the tx_date_time sort apply to:
+	// DateFieldCreatedAt or DateFieldUpdatedAt (the default when empty)
+	DateField string `json:"-"`
+}
+
+// Transaction date columns selectable with ?date_field=
+const (
+	DateFieldCreatedAt = "created_at"
+	DateFieldUpdatedAt = "updated_at"
+)
+
+// SearchBoolQuery is a parsed Elasticsearch-style bool query. Must and MustNot clauses are
+// ANDed together and, when Should clauses are given, at least one of them has to match.
+type SearchBoolQuery struct {
+	Must    []SearchClause
+	Should  []SearchClause
+	MustNot []SearchClause
+}
+
+// SearchClause is a single comparison on a mapped field, or a nested bool query when Bool is set
+type SearchClause struct {
+	Field    string      // Key in config.FieldMappings
+	Operator string      // SQL comparison: =, >=, <=, >, < or ILIKE
+	Value    interface{} // Comparison value; ILIKE values are the raw substring to match
+	Bool     *SearchBoolQuery
 }
 
 // PaginationParams represents pagination parameters
 type PaginationParams struct {
-	Page     int `json:"page"`
-	Limit    int `json:"limit"`
-	PageSize int `json:"page_size"` // For v1 compatibility
+	Page          int                `json:"page"`
+	Limit         int                `json:"limit"`
+	PageSize      int                `json:"page_size"` // For v1 compatibility
+	SkipCount     bool               `json:"-"`         // Skip the total count query (streaming/cursor mode)
+	CursorMode    bool               `json:"-"`         // Keyset pagination on (updated_at, payment_tx_log_id)
+	Cursor        *TransactionCursor `json:"-"`         // Last row of the previous page; nil for the first page
+	IncludeTotals bool               `json:"-"`         // Also sum amounts over all matching rows with the count
+	TotalsOnly    bool               `json:"-"`         // Run only the count/totals query, returning no rows
+	DebugSQL      bool               `json:"-"`         // Return the data query SQL (admin debugging)
+}
+
+// TransactionCursor identifies the last row of a keyset-paginated page
+type TransactionCursor struct {
+	UpdatedAt  time.Time `json:"u"`
+	ID         string    `json:"id"`
+	MerchantID string    `json:"m"` // Merchant the cursor was issued to
+}
+
+// Encode returns the cursor as an opaque URL-safe token: the payload and its HMAC-SHA256
+// under key, so a client can't alter the position or the merchant it was issued to
+func (c TransactionCursor) Encode(key []byte) string {
+	data, _ := json.Marshal(c)
+	payload := base64.RawURLEncoding.EncodeToString(data)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(signCursor(payload, key))
+}
+
+// DecodeTransactionCursor parses a token produced by TransactionCursor.Encode, rejecting
+// tokens whose signature doesn't match key or that were issued to another merchant
+func DecodeTransactionCursor(token string, key []byte, merchantID string) (*TransactionCursor, error) {
+	payload, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, signCursor(payload, key)) {
+		return nil, fmt.Errorf("invalid cursor signature")
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+
+	var cursor TransactionCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.ID == "" || cursor.UpdatedAt.IsZero() {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	if cursor.MerchantID != merchantID {
+		return nil, fmt.Errorf("cursor was issued to another merchant")
+	}
+
+	return &cursor, nil
+}
+
+func signCursor(payload string, key []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
 }
 
 // SortParams represents sorting parameters
@@ -221,6 +365,15 @@ type TransactionSearchRequest struct {
 	Sort         []SortParams           `json:"sort"`
 	Pagination   PaginationParams       `json:"pagination"`
 	Aggregations map[string]interface{} `json:"aggregations"`
+
+	Filter *TransactionFilter `json:"-"` // Parsed form of Query, set by the service
+}
+
+// TransactionBatchRequest represents the body of a batch lookup by transaction id or by
+// RRN; exactly one of the lists is set
+type TransactionBatchRequest struct {
+	IDs  []string `json:"ids"`
+	RRNs []string `json:"rrns"`
 }
 
 // UnmarshalJSON implements custom JSON unmarshaling for TransactionSearchRequest
@@ -345,18 +498,70 @@ func (c *CurrencyInfo) FormatAmount(amount int64) string {
 	return fmt.Sprintf(formatStr, c.Symbol, major, minor)
 }
 
+// DayFacet is the number of matching transactions on one calendar day
+type DayFacet struct {
+	Date  string `json:"date" gorm:"column:date"` // YYYY-MM-DD in the requested timezone
+	Count int64  `json:"count" gorm:"column:count"`
+}
+
+// TimeseriesBucket is the transaction volume in one day or hour interval
+type TimeseriesBucket struct {
+	Bucket      string `json:"bucket" gorm:"column:bucket"` // Interval start (YYYY-MM-DDTHH:MM:SS) in the requested timezone
+	Count       int64  `json:"count" gorm:"column:count"`
+	TotalAmount int64  `json:"total_amount" gorm:"column:total_amount"`
+}
+
+// MerchantDevice is a device and terminal pair a merchant has transacted on
+type MerchantDevice struct {
+	DeviceID          *string   `json:"device_id" gorm:"column:device_id"`
+	TerminalID        *string   `json:"terminal_id" gorm:"column:terminal_id"`
+	TransactionCount  int64     `json:"transaction_count" gorm:"column:transaction_count"`
+	LastTransactionAt time.Time `json:"last_transaction_at" gorm:"column:last_transaction_at"`
+}
+
+// Merchant summary metrics selectable with ?metrics=
+const (
+	SummaryMetricCount       = "count"        // Transaction counts and response code breakdown
+	SummaryMetricSum         = "sum"          // Total amount
+	SummaryMetricSuccessRate = "success_rate" // Successful/failed counts and success rate
+	SummaryMetricDateRange   = "date_range"   // Earliest and latest transaction time
+)
+
+// SummaryMetrics is the set of metrics a merchant summary computes; empty means all of them
+type SummaryMetrics []string
+
+// Includes reports whether metric is computed
+func (m SummaryMetrics) Includes(metric string) bool {
+	if len(m) == 0 {
+		return true
+	}
+	for _, requested := range m {
+		if requested == metric {
+			return true
+		}
+	}
+	return false
+}
+
 // MerchantSummary represents merchant transaction summary
 type MerchantSummary struct {
-	MerchantID             string    `json:"merchant_id"`
-	MerchantName           string    `json:"merchant_name"`
-	TotalTransactions      int       `json:"total_transactions"`
-	SuccessfulTransactions int       `json:"successful_transactions"`
-	FailedTransactions     int       `json:"failed_transactions"`
-	TotalAmount            int64     `json:"total_amount"`
-	AverageAmount          float64   `json:"average_amount"`
-	SuccessRate            float64   `json:"success_rate"`
-	DateFrom               time.Time `json:"date_from"`
-	DateTo                 time.Time `json:"date_to"`
+	MerchantID             string         `json:"merchant_id"`
+	MerchantName           string         `json:"merchant_name"`
+	TotalTransactions      int            `json:"total_transactions"`
+	SuccessfulTransactions int            `json:"successful_transactions"`
+	FailedTransactions     int            `json:"failed_transactions"`
+	TotalAmount            int64          `json:"total_amount"`
+	AverageAmount          float64        `json:"average_amount"`
+	SuccessRate            float64        `json:"success_rate"`
+	DateFrom               time.Time      `json:"date_from"`
+	DateTo                 time.Time      `json:"date_to"`
+	ResponseCodeBreakdown  map[string]int `json:"response_code_breakdown"`   // Transaction count per result code
+	Warnings               []string       `json:"warnings,omitempty"`        // Set when a roll-up source was skipped
+	DataUpdatedAt          *time.Time     `json:"data_updated_at,omitempty"` // Latest transaction updated_at when computed, for cache staleness checks
+	Currency               *CurrencyInfo  `json:"currency,omitempty"`        // Merchant's configured currency; formatted_amount is total_amount
+	ETag                   string         `json:"-"`                         // Hash of the summary and requested metrics, sent as the ETag header
+	ComputedAt             time.Time      `json:"computed_at"`               // When the summary was computed, cached with it so clients see its age
+	Cached                 bool           `json:"-"`                         // Served from the summary cache rather than computed
 }
 
 // IsoTransaction represents a transaction from the iso_trx table
@@ -384,7 +589,9 @@ func (IsoTransaction) TableName() string {
 
 // TransactionLookupRequest represents the request for transaction lookup
 type TransactionLookupRequest struct {
-	Date     string `json:"date" binding:"required"`
+	Date     string `json:"date"`                // Either date or date_from and date_to is required
+	DateFrom string `json:"date_from,omitempty"` // First day of a range, inclusive
+	DateTo   string `json:"date_to,omitempty"`   // Last day of a range, inclusive
 	DeviceID string `json:"device_id,omitempty"` // Optional - if not provided, searches all devices
 }
 
@@ -397,6 +604,8 @@ type TransactionLookupTotal struct {
 // TransactionLookupResponse represents the response for transaction lookup
 type TransactionLookupResponse struct {
 	Date     string                   `json:"date"`
+	DateFrom string                   `json:"date_from,omitempty"`
+	DateTo   string                   `json:"date_to,omitempty"`
 	DeviceID string                   `json:"device_id"`
 	Totals   []TransactionLookupTotal `json:"totals"`
 }
@@ -411,6 +620,8 @@ type IsoTransactionSearchRequest struct {
 	Amount       int    `json:"amount,omitempty"`        // Optional filter (0 means not specified)
 	TrxDescr     string `json:"trx_descr,omitempty"`     // Optional filter
 	Date         string `json:"date,omitempty"`          // Optional filter
+	DateFrom     string `json:"date_from,omitempty"`     // Optional range start, inclusive; defaults to a recent range without date
+	DateTo       string `json:"date_to,omitempty"`       // Optional range end, inclusive
 	TxID         string `json:"tx_id,omitempty"`         // Optional filter (from request_meta.trx_id)
 	ResponseCode string `json:"response_code,omitempty"` // Optional filter (RC/response code)
 }
@@ -484,6 +695,8 @@ func (t *Transaction) FilterFields(requestedFields []string) map[string]interfac
 			result["auth_code"] = t.AuthCode
 		case "pan":
 			result["pan"] = t.PAN
+		case "bin_id":
+			result["bin_id"] = t.BinID
 		case "device_id":
 			result["device_id"] = t.DeviceID
 		case "terminal_id":
@@ -514,6 +727,10 @@ func (t *Transaction) FilterFields(requestedFields []string) map[string]interfac
 			result["settlement_status"] = t.SettlementStatus
 		case "card_type":
 			result["card_type"] = t.CardType
+		case "profile_id":
+			result["profile_id"] = t.ProfileID
+		case "payment_tx_ref":
+			result["payment_tx_ref"] = t.PaymentTxRef
 		}
 	}
 
@@ -1,7 +1,9 @@
 package models
 
 import (
+	"encoding/base64"
 	"encoding/json"
+	"strings"
 	"testing"
 	"time"
 
@@ -37,6 +39,18 @@ func TestTransaction_GetTypeString(t *testing.T) {
 	}
 }
 
+func TestPaymentTxTypeID_RoundTrip(t *testing.T) {
+	for _, typeID := range []int{0, 1, 2, 3, 9, 10} {
+		tx := Transaction{PaymentTxTypeID: typeID}
+		got, ok := PaymentTxTypeID(tx.GetTypeString())
+		assert.True(t, ok, tx.GetTypeString())
+		assert.Equal(t, typeID, got)
+	}
+
+	_, ok := PaymentTxTypeID("unknown")
+	assert.False(t, ok)
+}
+
 func TestTransaction_IsReversed(t *testing.T) {
 	tests := []struct {
 		name            string
@@ -285,3 +299,56 @@ func timePtr(t time.Time) *time.Time {
 func int64Ptr(i int64) *int64 {
 	return &i
 }
+
+var testCursorKey = []byte("cursor-test-key")
+
+func TestTransactionCursor_RoundTrip(t *testing.T) {
+	cursor := TransactionCursor{
+		UpdatedAt:  time.Date(2025, 1, 15, 10, 30, 0, 123456000, time.UTC),
+		ID:         "9cda37a0-4813-11ef-95d7-c5ac867bb9fc",
+		MerchantID: "merchant-1",
+	}
+
+	decoded, err := DecodeTransactionCursor(cursor.Encode(testCursorKey), testCursorKey, "merchant-1")
+
+	assert.NoError(t, err)
+	assert.True(t, cursor.UpdatedAt.Equal(decoded.UpdatedAt))
+	assert.Equal(t, cursor.ID, decoded.ID)
+}
+
+func TestDecodeTransactionCursor_Invalid(t *testing.T) {
+	tokens := []string{
+		"not base64!",
+		"bm90IGpzb24",
+		TransactionCursor{ID: "x", MerchantID: "merchant-1"}.Encode(testCursorKey),
+	}
+	for _, token := range tokens {
+		_, err := DecodeTransactionCursor(token, testCursorKey, "merchant-1")
+		assert.Error(t, err, token)
+	}
+}
+
+func TestDecodeTransactionCursor_RejectsTamperedCursor(t *testing.T) {
+	cursor := TransactionCursor{UpdatedAt: time.Date(2025, 1, 15, 10, 30, 0, 0, time.UTC), ID: "tx-1", MerchantID: "merchant-1"}
+	token := cursor.Encode(testCursorKey)
+	_, signature, _ := strings.Cut(token, ".")
+
+	// Re-encode the payload for another merchant but keep the original signature
+	forged := cursor
+	forged.MerchantID = "merchant-2"
+	data, _ := json.Marshal(forged)
+	tampered := base64.RawURLEncoding.EncodeToString(data) + "." + signature
+
+	_, err := DecodeTransactionCursor(tampered, testCursorKey, "merchant-2")
+	assert.Error(t, err)
+
+	_, err = DecodeTransactionCursor(token, []byte("another-key"), "merchant-1")
+	assert.Error(t, err, "cursors signed with another key must be rejected")
+}
+
+func TestDecodeTransactionCursor_RejectsOtherMerchant(t *testing.T) {
+	token := TransactionCursor{UpdatedAt: time.Date(2025, 1, 15, 10, 30, 0, 0, time.UTC), ID: "tx-1", MerchantID: "merchant-1"}.Encode(testCursorKey)
+
+	_, err := DecodeTransactionCursor(token, testCursorKey, "merchant-2")
+	assert.Error(t, err)
+}
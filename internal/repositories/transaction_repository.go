@@ -1,8 +1,10 @@
 package repositories
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
@@ -14,13 +16,26 @@ import (
 
 type TransactionRepository interface {
 	GetTransactions(merchantID string, filter *models.TransactionFilter, fields []string, sort []models.SortParams, pagination models.PaginationParams, timezone string, panFormat string) (*TransactionListResult, error)
+	StreamTransactions(ctx context.Context, merchantID string, filter *models.TransactionFilter, fields []string, sort []models.SortParams, timezone string, panFormat string, fn func(*models.Transaction) error) error
+	PollTransactions(ctx context.Context, merchantID string, filter *models.TransactionFilter, fields []string, after *models.TransactionCursor, limit int, timezone string, panFormat string) ([]models.Transaction, error)
 	GetTransactionByID(merchantID, transactionID string, fields []string, timezone string, panFormat string) (*models.Transaction, error)
+	GetTransactionsByIDs(merchantID string, ids []string, fields []string, timezone string, panFormat string) ([]models.Transaction, error)
+	GetTransactionsByRRNs(merchantID string, rrns []string, fields []string, timezone string, panFormat string, limit int) ([]models.Transaction, error)
 	GetTransactionCount(merchantID string, filter *models.TransactionFilter) (int64, error)
-	GetMerchantSummary(merchantID string, filter *models.TransactionFilter) (*models.MerchantSummary, error)
+	EstimateTransactionCount(merchantID string, filter *models.TransactionFilter) (int64, error)
+	GetDailyCounts(merchantID string, filter *models.TransactionFilter, timezone string) ([]models.DayFacet, error)
+	GetTimeseries(merchantID string, filter *models.TransactionFilter, interval, timezone string, limit int) ([]models.TimeseriesBucket, error)
+	GetMerchantDevices(merchantID string, filter *models.TransactionFilter) ([]models.MerchantDevice, error)
+	GetMerchantSummary(merchantID string, filter *models.TransactionFilter, metrics models.SummaryMetrics) (*models.MerchantSummary, error)
+	GetLatestUpdatedAt(merchantID string) (*time.Time, error)
+	GetDateBounds(merchantID string, filter *models.TransactionFilter) (earliest, latest *time.Time, err error)
+	IsMerchantUnderProvisioner(provisionerID, merchantID string) (bool, error)
+	GetMerchantSummaries(provisionerID string, filter *models.TransactionFilter, pagination models.PaginationParams) ([]models.MerchantSummary, int64, error)
 	SearchTransactions(merchantID string, searchReq *models.TransactionSearchRequest, timezone string, panFormat string) (*TransactionListResult, error)
 	GetTransactionTotals(merchantID string, request models.TransactionTotalsRequest) (*models.TransactionTotalsResponse, error)
 	GetTransactionLookup(request models.TransactionLookupRequest) (*models.TransactionLookupResponse, error)
 	SearchTransactionDetails(request models.IsoTransactionSearchRequest) (*models.IsoTransactionSearchResponse, error)
+	GetCurrencies() ([]models.Currency, error)
 	SetUseMysql(useMysql bool) // Add method to set MySQL flag
 }
 
@@ -36,7 +51,11 @@ type TransactionListResult struct {
 	Page            int                  `json:"page"`
 	Limit           int                  `json:"limit"`
 	TotalPages      int                  `json:"total_pages"`
-	RequestedFields []string             `json:"-"` // Internal field, not serialized
+	CountSkipped    bool                 `json:"count_skipped"`          // TotalCount/TotalPages were not computed
+	TotalAmount     *int64               `json:"total_amount,omitempty"` // Sum of amounts over all matching rows, when requested
+	NextCursor      string               `json:"next_cursor,omitempty"`  // Keyset cursor for the next page, if any
+	DebugSQL        string               `json:"-"`                      // Data query with placeholders, when requested
+	RequestedFields []string             `json:"-"`                      // Internal field, not serialized
 }
 
 func NewTransactionRepository(postgresDB *gorm.DB, mysqlDB *gorm.DB) TransactionRepository {
@@ -70,7 +89,12 @@ func (r *transactionRepository) GetTransactions(merchantID string, filter *model
 	var transactions []models.Transaction
 
 	// Build the query
-	query := r.buildBaseQuery(fields, timezone, panFormat)
+	var query *gorm.DB
+	if pagination.CursorMode {
+		query = r.buildKeysetQuery(fields, timezone, panFormat)
+	} else {
+		query = r.buildBaseQuery(fields, timezone, panFormat)
+	}
 
 	// Apply merchant filter
 	query = query.Where("m.merchant_id = ? OR m.provisioner_id = ?", merchantID, merchantID)
@@ -78,28 +102,71 @@ func (r *transactionRepository) GetTransactions(merchantID string, filter *model
 	// Apply additional filters
 	query = r.applyFilters(query, filter)
 
-	// Apply sorting - for DISTINCT ON queries, we need special handling
-	query = r.applySortingWithDistinct(query, sort)
+	if pagination.CursorMode {
+		// Keyset pagination: continue after the cursor in a fixed newest-first order
+		if pagination.Cursor != nil {
+			query = query.Where("(p.updated_at, p.payment_tx_log_id) < (?, ?)", pagination.Cursor.UpdatedAt, pagination.Cursor.ID)
+		}
+		query = query.Order("p.updated_at DESC, p.payment_tx_log_id DESC")
+	} else {
+		// Apply sorting - for DISTINCT ON queries, we need special handling
+		query = r.applySortingWithDistinct(query, sort, dateColumn(filter))
+	}
 
-	// Get total count for pagination
+	// Get total count for pagination (skipped in streaming/cursor mode).
+	// When totals are requested the amount is summed in the same query, over one row per
+	// transaction, and with TotalsOnly it is the only query run. Transactions are counted
+	// by id because the merchant join can match more than one row per transaction.
 	var totalCount int64
-	countQuery := r.buildCountQuery()
-	countQuery = countQuery.Where("m.merchant_id = ? OR m.provisioner_id = ?", merchantID, merchantID)
-	countQuery = r.applyFilters(countQuery, filter)
+	var totalAmount *int64
+	countSkipped := pagination.SkipCount && !pagination.IncludeTotals
+	if !countSkipped {
+		countQuery := r.buildCountQuery()
+		countQuery = countQuery.Where("m.merchant_id = ? OR m.provisioner_id = ?", merchantID, merchantID)
+		countQuery = r.applyFilters(countQuery, filter)
+
+		if pagination.IncludeTotals {
+			var totals struct {
+				TotalCount  int64 `gorm:"column:total_count"`
+				TotalAmount int64 `gorm:"column:total_amount"`
+			}
+			totalsQuery := distinctTransactions(countQuery, "p.payment_tx_log_id, p.amount").
+				Select("COUNT(*) AS total_count, COALESCE(SUM(p.amount), 0) AS total_amount")
+			if err := totalsQuery.Take(&totals).Error; err != nil {
+				return nil, err
+			}
+			totalCount = totals.TotalCount
+			totalAmount = &totals.TotalAmount
+		} else if err := countQuery.Distinct("p.payment_tx_log_id").Count(&totalCount).Error; err != nil {
+			return nil, err
+		}
+	}
 
-	if err := countQuery.Count(&totalCount).Error; err != nil {
-		return nil, err
+	if pagination.TotalsOnly {
+		return &TransactionListResult{
+			Transactions:    []models.Transaction{},
+			TotalCount:      totalCount,
+			Page:            pagination.Page,
+			Limit:           pagination.Limit,
+			CountSkipped:    countSkipped,
+			TotalAmount:     totalAmount,
+			RequestedFields: fields,
+		}, nil
 	}
 
-	// Apply pagination
-	offset := (pagination.Page - 1) * pagination.Limit
-	query = query.Limit(pagination.Limit).Offset(offset)
+	// Apply pagination (keyset mode never uses OFFSET)
+	query = query.Limit(pagination.Limit)
+	if !pagination.CursorMode {
+		offset := (pagination.Page - 1) * pagination.Limit
+		query = query.Offset(offset)
+	}
 
-	// Debug: Log the SQL query
-	// sql := r.getDB().ToSQL(func(tx *gorm.DB) *gorm.DB {
-	// 	return query.Find(&transactions)
-	// })
-	// fmt.Printf("DEBUG SQL: %s\n", sql)
+	// Capture the SQL for admin debugging. A dry-run session is used rather than ToSQL
+	// so the statement keeps its placeholders instead of interpolated values.
+	var debugSQL string
+	if pagination.DebugSQL {
+		debugSQL = query.Session(&gorm.Session{DryRun: true}).Find(&[]models.Transaction{}).Statement.SQL.String()
+	}
 
 	// Execute query
 	if err := query.Find(&transactions).Error; err != nil {
@@ -109,7 +176,17 @@ func (r *transactionRepository) GetTransactions(merchantID string, filter *model
 	// Post-process results
 	r.postProcessTransactions(transactions)
 
-	totalPages := int((totalCount + int64(pagination.Limit) - 1) / int64(pagination.Limit))
+	totalPages := 0
+	if !countSkipped {
+		totalPages = int((totalCount + int64(pagination.Limit) - 1) / int64(pagination.Limit))
+	}
+
+	// A full keyset page may have more rows after it
+	var nextCursor string
+	if pagination.CursorMode && pagination.Limit > 0 && len(transactions) >= pagination.Limit {
+		last := transactions[len(transactions)-1]
+		nextCursor = models.TransactionCursor{UpdatedAt: last.UpdatedAt, ID: last.ID, MerchantID: merchantID}.Encode(config.GetCursorSigningKey())
+	}
 
 	return &TransactionListResult{
 		Transactions:    transactions,
@@ -117,10 +194,68 @@ func (r *transactionRepository) GetTransactions(merchantID string, filter *model
 		Page:            pagination.Page,
 		Limit:           pagination.Limit,
 		TotalPages:      totalPages,
+		CountSkipped:    countSkipped,
+		TotalAmount:     totalAmount,
+		NextCursor:      nextCursor,
+		DebugSQL:        debugSQL,
 		RequestedFields: fields,
 	}, nil
 }
 
+// StreamTransactions calls fn for every matching transaction, in sort order, reading them one
+// row at a time from a database cursor so the result set is never held in memory. Iteration
+// stops at the first error from fn; cancelling ctx aborts the query.
+func (r *transactionRepository) StreamTransactions(ctx context.Context, merchantID string, filter *models.TransactionFilter, fields []string, sort []models.SortParams, timezone string, panFormat string, fn func(*models.Transaction) error) error {
+	query := r.buildBaseQuery(fields, timezone, panFormat).WithContext(ctx)
+	query = query.Where("m.merchant_id = ? OR m.provisioner_id = ?", merchantID, merchantID)
+	query = r.applyFilters(query, filter)
+	query = r.applySortingWithDistinct(query, sort, dateColumn(filter))
+
+	rows, err := query.Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		transactions := make([]models.Transaction, 1)
+		if err := query.ScanRows(rows, &transactions[0]); err != nil {
+			return err
+		}
+		r.postProcessTransactions(transactions)
+
+		if err := fn(&transactions[0]); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// PollTransactions returns at most limit transactions that come after the cursor in
+// (updated_at, payment_tx_log_id) order, oldest first, or the first ones when after is nil.
+// The keyset predicate lets an index on those columns serve each poll without a scan of
+// the table, and the id breaks ties so rows sharing an updated_at are neither skipped nor
+// repeated when a poll ends between them.
+func (r *transactionRepository) PollTransactions(ctx context.Context, merchantID string, filter *models.TransactionFilter, fields []string, after *models.TransactionCursor, limit int, timezone string, panFormat string) ([]models.Transaction, error) {
+	var transactions []models.Transaction
+
+	query := r.buildKeysetQuery(fields, timezone, panFormat).WithContext(ctx)
+	query = query.Where("m.merchant_id = ? OR m.provisioner_id = ?", merchantID, merchantID)
+	query = r.applyFilters(query, filter)
+	if after != nil {
+		query = query.Where("(p.updated_at, p.payment_tx_log_id) > (?, ?)", after.UpdatedAt, after.ID)
+	}
+	query = query.Order("p.updated_at ASC, p.payment_tx_log_id ASC").Limit(limit)
+
+	if err := query.Find(&transactions).Error; err != nil {
+		return nil, err
+	}
+
+	r.postProcessTransactions(transactions)
+	return transactions, nil
+}
+
 // GetTransactionByID retrieves a single transaction by ID
 func (r *transactionRepository) GetTransactionByID(merchantID, transactionID string, fields []string, timezone string, panFormat string) (*models.Transaction, error) {
 	var transaction models.Transaction
@@ -136,28 +271,271 @@ func (r *transactionRepository) GetTransactionByID(merchantID, transactionID str
 		return nil, err
 	}
 
-	// Post-process single transaction
-	r.postProcessTransactions([]models.Transaction{transaction})
+	// Post-process single transaction (in place, so computed fields are kept)
+	transactions := []models.Transaction{transaction}
+	r.postProcessTransactions(transactions)
+
+	return &transactions[0], nil
+}
+
+// GetTransactionsByIDs retrieves the transactions matching a list of ids for batch lookups.
+// Small lists use a plain IN (...) clause; lists above the configured threshold are joined
+// against a single uuid[] parameter so the statement never exceeds the bind parameter limit.
+func (r *transactionRepository) GetTransactionsByIDs(merchantID string, ids []string, fields []string, timezone string, panFormat string) ([]models.Transaction, error) {
+	var transactions []models.Transaction
+	if len(ids) == 0 {
+		return transactions, nil
+	}
+
+	query := r.buildBaseQuery(fields, timezone, panFormat)
+	query = r.applyIDFilter(query, ids)
+	query = query.Where("m.merchant_id = ? OR m.provisioner_id = ?", merchantID, merchantID)
+	query = query.Order("p.payment_tx_log_id")
+
+	if err := query.Find(&transactions).Error; err != nil {
+		return nil, err
+	}
+
+	r.postProcessTransactions(transactions)
+
+	return transactions, nil
+}
+
+// applyIDFilter restricts the query to the given transaction ids
+func (r *transactionRepository) applyIDFilter(query *gorm.DB, ids []string) *gorm.DB {
+	if len(ids) <= config.GetBatchInListThreshold() {
+		return query.Where("p.payment_tx_log_id IN ?", ids)
+	}
 
-	return &transaction, nil
+	// Join against an unnested array (an inline VALUES list) bound as one parameter
+	return query.Joins("JOIN unnest(CAST(? AS uuid[])) AS batch_ids(id) ON p.payment_tx_log_id = batch_ids.id",
+		buildArrayLiteral(ids))
 }
 
-// GetTransactionCount returns the total count of transactions matching the filter
+// GetTransactionsByRRNs retrieves the transactions matching a list of RRNs for batch
+// lookups from reconciliation files. An RRN is not unique, so one may match several rows;
+// at most limit rows are returned.
+func (r *transactionRepository) GetTransactionsByRRNs(merchantID string, rrns []string, fields []string, timezone string, panFormat string, limit int) ([]models.Transaction, error) {
+	var transactions []models.Transaction
+	if len(rrns) == 0 {
+		return transactions, nil
+	}
+
+	query := r.buildBaseQuery(fields, timezone, panFormat)
+	query = r.applyRRNFilter(query, rrns)
+	query = query.Where("m.merchant_id = ? OR m.provisioner_id = ?", merchantID, merchantID)
+	// The order must start with the DISTINCT ON key of the base query
+	query = query.Order("p.payment_tx_log_id").Limit(limit)
+
+	if err := query.Find(&transactions).Error; err != nil {
+		return nil, err
+	}
+
+	r.postProcessTransactions(transactions)
+
+	return transactions, nil
+}
+
+// applyRRNFilter restricts the query to the given RRNs, the same way applyIDFilter does ids
+func (r *transactionRepository) applyRRNFilter(query *gorm.DB, rrns []string) *gorm.DB {
+	if len(rrns) <= config.GetBatchInListThreshold() {
+		return query.Where("p.rrn IN ?", rrns)
+	}
+
+	return query.Joins("JOIN unnest(CAST(? AS text[])) AS batch_rrns(rrn) ON p.rrn = batch_rrns.rrn",
+		buildArrayLiteral(rrns))
+}
+
+// buildArrayLiteral renders values as a PostgreSQL array literal, e.g. {"a","b"}
+func buildArrayLiteral(values []string) string {
+	escaper := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+	var b strings.Builder
+	b.WriteString("{")
+	for i, value := range values {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		b.WriteString(`"`)
+		b.WriteString(escaper.Replace(value))
+		b.WriteString(`"`)
+	}
+	b.WriteString("}")
+
+	return b.String()
+}
+
+// GetTransactionCount returns the number of distinct transactions matching the filter
 func (r *transactionRepository) GetTransactionCount(merchantID string, filter *models.TransactionFilter) (int64, error) {
 	query := r.buildCountQuery()
 	query = query.Where("m.merchant_id = ? OR m.provisioner_id = ?", merchantID, merchantID)
 	query = r.applyFilters(query, filter)
 
 	var count int64
-	if err := query.Count(&count).Error; err != nil {
+	if err := query.Distinct("p.payment_tx_log_id").Count(&count).Error; err != nil {
 		return 0, err
 	}
 
 	return count, nil
 }
 
-// GetMerchantSummary calculates summary statistics for a merchant
-func (r *transactionRepository) GetMerchantSummary(merchantID string, filter *models.TransactionFilter) (*models.MerchantSummary, error) {
+// EstimateTransactionCount returns the query planner's row estimate for the filtered
+// transactions. It runs EXPLAIN instead of COUNT(*), so it is cheap on any result size but
+// only as accurate as the table statistics.
+func (r *transactionRepository) EstimateTransactionCount(merchantID string, filter *models.TransactionFilter) (int64, error) {
+	query := r.buildCountQuery().Select("p.payment_tx_log_id")
+	query = query.Where("m.merchant_id = ? OR m.provisioner_id = ?", merchantID, merchantID)
+	query = r.applyFilters(query, filter)
+
+	// Render the statement with its placeholders and run it through EXPLAIN on the pool
+	stmt := query.Session(&gorm.Session{DryRun: true}).Find(&[]models.Transaction{}).Statement
+	if stmt.Error != nil {
+		return 0, stmt.Error
+	}
+
+	sqlDB, err := r.getDB().DB()
+	if err != nil {
+		return 0, err
+	}
+
+	var plan string
+	if err := sqlDB.QueryRow("EXPLAIN (FORMAT JSON) "+stmt.SQL.String(), stmt.Vars...).Scan(&plan); err != nil {
+		return 0, err
+	}
+
+	return parseExplainRows(plan)
+}
+
+// parseExplainRows returns the top-level "Plan Rows" of EXPLAIN (FORMAT JSON) output
+func parseExplainRows(plan string) (int64, error) {
+	var explained []struct {
+		Plan struct {
+			PlanRows float64 `json:"Plan Rows"`
+		} `json:"Plan"`
+	}
+	if err := json.Unmarshal([]byte(plan), &explained); err != nil {
+		return 0, fmt.Errorf("failed to parse query plan: %w", err)
+	}
+	if len(explained) == 0 {
+		return 0, fmt.Errorf("query plan is empty")
+	}
+
+	return int64(explained[0].Plan.PlanRows), nil
+}
+
+// GetDailyCounts returns the number of matching transactions per day in the given timezone
+func (r *transactionRepository) GetDailyCounts(merchantID string, filter *models.TransactionFilter, timezone string) ([]models.DayFacet, error) {
+	query := r.buildCountQuery().
+		Select("TO_CHAR(TIMEZONE(?, p.updated_at), 'YYYY-MM-DD') AS date, COUNT(DISTINCT p.payment_tx_log_id) AS count", timezone).
+		Where("m.merchant_id = ? OR m.provisioner_id = ?", merchantID, merchantID)
+	query = r.applyFilters(query, filter)
+
+	facets := []models.DayFacet{}
+	if err := query.Group("date").Order("date").Find(&facets).Error; err != nil {
+		return nil, err
+	}
+
+	return facets, nil
+}
+
+// GetTimeseries returns the number and total amount of matching transactions per interval
+// ("day" or "hour"), truncated in the given timezone so buckets align with local time.
+// At most limit buckets, the earliest, are returned.
+func (r *transactionRepository) GetTimeseries(merchantID string, filter *models.TransactionFilter, interval, timezone string, limit int) ([]models.TimeseriesBucket, error) {
+	matches := r.buildCountQuery().
+		Where("m.merchant_id = ? OR m.provisioner_id = ?", merchantID, merchantID)
+	matches = r.applyFilters(matches, filter)
+
+	query := distinctTransactions(matches, "p.payment_tx_log_id, p.amount, p.updated_at").
+		Select(`TO_CHAR(DATE_TRUNC(?, TIMEZONE(?, p.updated_at)), 'YYYY-MM-DD"T"HH24:MI:SS') AS bucket,
+			COUNT(*) AS count,
+			COALESCE(SUM(p.amount), 0) AS total_amount`, interval, timezone)
+
+	buckets := []models.TimeseriesBucket{}
+	if err := query.Group("bucket").Order("bucket").Limit(limit).Find(&buckets).Error; err != nil {
+		return nil, err
+	}
+
+	return buckets, nil
+}
+
+// GetMerchantDevices returns the distinct device and terminal pairs of the merchant's matching
+// transactions with their transaction count and latest updated_at, most recently used first
+func (r *transactionRepository) GetMerchantDevices(merchantID string, filter *models.TransactionFilter) ([]models.MerchantDevice, error) {
+	query := r.buildCountQuery().
+		Select(`p.device_id, p.terminal_id,
+			COUNT(DISTINCT p.payment_tx_log_id) AS transaction_count,
+			MAX(p.updated_at) AS last_transaction_at`).
+		Where("m.merchant_id = ? OR m.provisioner_id = ?", merchantID, merchantID)
+	query = r.applyFilters(query, filter)
+
+	devices := []models.MerchantDevice{}
+	err := query.Group("p.device_id, p.terminal_id").
+		Order("last_transaction_at DESC, p.device_id, p.terminal_id").
+		Find(&devices).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return devices, nil
+}
+
+// GetLatestUpdatedAt returns the most recent updated_at of the merchant's transactions, or nil
+// when there are none. It is a cheap check for new data behind cached aggregates.
+func (r *transactionRepository) GetLatestUpdatedAt(merchantID string) (*time.Time, error) {
+	var latest struct {
+		UpdatedAt *time.Time `gorm:"column:latest_updated_at"`
+	}
+
+	err := r.buildCountQuery().
+		Select("MAX(p.updated_at) AS latest_updated_at").
+		Where("m.merchant_id = ? OR m.provisioner_id = ?", merchantID, merchantID).
+		Take(&latest).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return latest.UpdatedAt, nil
+}
+
+// GetDateBounds returns the earliest and latest date of the merchant's transactions, or nils
+// when it has none. Only filter.DateField is used, to pick the date column.
+func (r *transactionRepository) GetDateBounds(merchantID string, filter *models.TransactionFilter) (*time.Time, *time.Time, error) {
+	var bounds struct {
+		Earliest *time.Time `gorm:"column:earliest"`
+		Latest   *time.Time `gorm:"column:latest"`
+	}
+
+	column := dateColumn(filter)
+	err := r.buildCountQuery().
+		Select(fmt.Sprintf("MIN(%s) AS earliest, MAX(%s) AS latest", column, column)).
+		Where("m.merchant_id = ? OR m.provisioner_id = ?", merchantID, merchantID).
+		Take(&bounds).Error
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return bounds.Earliest, bounds.Latest, nil
+}
+
+// IsMerchantUnderProvisioner reports whether merchantID belongs to provisionerID and
+// provisionerID is flagged as a provisioner
+func (r *transactionRepository) IsMerchantUnderProvisioner(provisionerID, merchantID string) (bool, error) {
+	var count int64
+	err := r.getDB().Table("merchants m").
+		Joins("JOIN merchants pr ON pr.merchant_id = m.provisioner_id").
+		Where("m.merchant_id = ? AND m.provisioner_id = ? AND pr.is_provisioner = true", merchantID, provisionerID).
+		Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+// GetMerchantSummary calculates summary statistics for a merchant. Only the aggregates for
+// the requested metrics are selected; an empty metrics set computes everything.
+func (r *transactionRepository) GetMerchantSummary(merchantID string, filter *models.TransactionFilter, metrics models.SummaryMetrics) (*models.MerchantSummary, error) {
 	type summaryResult struct {
 		MerchantID     string     `gorm:"column:merchant_id"`
 		MerchantName   string     `gorm:"column:merchant_name"`
@@ -171,15 +549,7 @@ func (r *transactionRepository) GetMerchantSummary(merchantID string, filter *mo
 	var result summaryResult
 
 	query := r.getDB().Table("payment_tx_log p").
-		Select(`
-			m.merchant_id,
-			m.name as merchant_name,
-			COUNT(*) as total_transactions,
-			SUM(CASE WHEN p.result_code IN ('00', '10') THEN 1 ELSE 0 END) as successful_transactions,
-			SUM(COALESCE(p.amount, 0)) as total_amount,
-			MIN(p.updated_at) as min_date,
-			MAX(p.updated_at) as max_date
-		`).
+		Select(buildSummarySelection(metrics)).
 		Joins("LEFT JOIN merchants m ON p.merchant_id = m.merchant_id").
 		Where("m.merchant_id = ? OR m.provisioner_id = ?", merchantID, merchantID).
 		Group("m.merchant_id, m.name")
@@ -188,26 +558,47 @@ func (r *transactionRepository) GetMerchantSummary(merchantID string, filter *mo
 
 	if err := query.Take(&result).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
+			currency, err := r.getMerchantCurrency(merchantID)
+			if err != nil {
+				return nil, err
+			}
 			return &models.MerchantSummary{
-				MerchantID:   merchantID,
-				MerchantName: "Unknown",
+				MerchantID:            merchantID,
+				MerchantName:          "Unknown",
+				ResponseCodeBreakdown: map[string]int{},
+				Currency:              currency,
 			}, nil
 		}
 		return nil, err
 	}
 
+	var breakdown map[string]int
+	if metrics.Includes(models.SummaryMetricCount) {
+		var err error
+		if breakdown, err = r.getResponseCodeBreakdown(merchantID, filter); err != nil {
+			return nil, err
+		}
+	}
+
 	summary := &models.MerchantSummary{
 		MerchantID:             result.MerchantID,
 		MerchantName:           result.MerchantName,
 		TotalTransactions:      result.TotalTxns,
 		SuccessfulTransactions: result.SuccessfulTxns,
-		FailedTransactions:     result.TotalTxns - result.SuccessfulTxns,
 		TotalAmount:            result.TotalAmount,
+		ResponseCodeBreakdown:  breakdown,
 	}
 
+	if metrics.Includes(models.SummaryMetricSuccessRate) {
+		summary.FailedTransactions = result.TotalTxns - result.SuccessfulTxns
+	}
 	if result.TotalTxns > 0 {
-		summary.AverageAmount = float64(result.TotalAmount) / float64(result.TotalTxns)
-		summary.SuccessRate = (float64(result.SuccessfulTxns) / float64(result.TotalTxns)) * 100
+		if metrics.Includes(models.SummaryMetricSum) {
+			summary.AverageAmount = config.RoundAmount(float64(result.TotalAmount)/float64(result.TotalTxns), 0, config.GetAmountRoundingMode())
+		}
+		if metrics.Includes(models.SummaryMetricSuccessRate) {
+			summary.SuccessRate = (float64(result.SuccessfulTxns) / float64(result.TotalTxns)) * 100
+		}
 	}
 
 	if result.MinDate != nil {
@@ -217,48 +608,275 @@ func (r *transactionRepository) GetMerchantSummary(merchantID string, filter *mo
 		summary.DateTo = *result.MaxDate
 	}
 
+	currency, err := r.getMerchantCurrency(merchantID)
+	if err != nil {
+		return nil, err
+	}
+	if currency != nil {
+		currency.FormattedAmount = currency.FormatAmount(summary.TotalAmount)
+	}
+	summary.Currency = currency
+
 	return summary, nil
 }
 
+// getMerchantCurrency returns the currency configured on the merchant, or nil when the
+// merchant does not exist or has no currency. FormattedAmount is left for the caller.
+func (r *transactionRepository) getMerchantCurrency(merchantID string) (*models.CurrencyInfo, error) {
+	var row struct {
+		CurrencyCode string `gorm:"column:currency_code"`
+		CurrencyName string `gorm:"column:curr_short"`
+		CurrDelim    int    `gorm:"column:curr_delim"`
+	}
+
+	err := r.getDB().Table("merchants m").
+		Select("m.currency_code, c.curr_short, c.curr_delim").
+		Joins(currencyJoin("m.currency_code")).
+		Where("m.merchant_id = ?", merchantID).
+		Take(&row).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if row.CurrencyCode == "" {
+		return nil, nil
+	}
+
+	return &models.CurrencyInfo{
+		Code:     row.CurrencyCode,
+		Name:     row.CurrencyName,
+		Symbol:   config.CurrencySymbol(row.CurrencyCode, row.CurrencyName),
+		Exponent: row.CurrDelim,
+	}, nil
+}
+
+// GetCurrencies returns every row of the currency table, ordered by code. curr_code is not
+// unique in the source schema, so a code may appear more than once.
+func (r *transactionRepository) GetCurrencies() ([]models.Currency, error) {
+	currencies := []models.Currency{}
+	if err := r.getDB().Order("curr_code, curr_short, curr_delim").Find(&currencies).Error; err != nil {
+		return nil, err
+	}
+	return currencies, nil
+}
+
+// buildSummarySelection returns the SELECT list of the merchant summary query for the
+// requested metrics. The transaction count is needed by every metric but date_range, to
+// derive averages and rates.
+func buildSummarySelection(metrics models.SummaryMetrics) string {
+	selected := []string{"m.merchant_id", "m.name as merchant_name"}
+
+	if metrics.Includes(models.SummaryMetricCount) || metrics.Includes(models.SummaryMetricSum) || metrics.Includes(models.SummaryMetricSuccessRate) {
+		selected = append(selected, "COUNT(*) as total_transactions")
+	}
+	if metrics.Includes(models.SummaryMetricSuccessRate) {
+		selected = append(selected, "SUM(CASE WHEN p.result_code IN ('00', '10') THEN 1 ELSE 0 END) as successful_transactions")
+	}
+	if metrics.Includes(models.SummaryMetricSum) {
+		selected = append(selected, "SUM(COALESCE(p.amount, 0)) as total_amount")
+	}
+	if metrics.Includes(models.SummaryMetricDateRange) {
+		selected = append(selected, "MIN(p.updated_at) as min_date", "MAX(p.updated_at) as max_date")
+	}
+
+	return strings.Join(selected, ", ")
+}
+
+// getResponseCodeBreakdown counts a merchant's transactions per result code, applying the same
+// scope and filters as GetMerchantSummary. Transactions without a result code count as "unknown".
+func (r *transactionRepository) getResponseCodeBreakdown(merchantID string, filter *models.TransactionFilter) (map[string]int, error) {
+	var rows []struct {
+		ResultCode string `gorm:"column:result_code"`
+		Count      int    `gorm:"column:count"`
+	}
+
+	query := r.getDB().Table("payment_tx_log p").
+		Select("COALESCE(p.result_code, 'unknown') AS result_code, COUNT(*) AS count").
+		Joins("LEFT JOIN merchants m ON p.merchant_id = m.merchant_id").
+		Where("m.merchant_id = ? OR m.provisioner_id = ?", merchantID, merchantID).
+		Group("p.result_code")
+
+	query = r.applyFilters(query, filter)
+
+	if err := query.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	breakdown := make(map[string]int, len(rows))
+	for _, row := range rows {
+		breakdown[row.ResultCode] += row.Count
+	}
+
+	return breakdown, nil
+}
+
+// GetMerchantSummaries calculates a summary for every merchant owned by a provisioner (including
+// the provisioner itself) in one query grouped by merchant, ordered by merchant ID and paginated.
+// It also returns the number of merchants with matching transactions.
+func (r *transactionRepository) GetMerchantSummaries(provisionerID string, filter *models.TransactionFilter, pagination models.PaginationParams) ([]models.MerchantSummary, int64, error) {
+	type summaryResult struct {
+		MerchantID     string     `gorm:"column:merchant_id"`
+		MerchantName   string     `gorm:"column:merchant_name"`
+		TotalTxns      int        `gorm:"column:total_transactions"`
+		SuccessfulTxns int        `gorm:"column:successful_transactions"`
+		TotalAmount    int64      `gorm:"column:total_amount"`
+		MinDate        *time.Time `gorm:"column:min_date"`
+		MaxDate        *time.Time `gorm:"column:max_date"`
+	}
+
+	scopedQuery := func() *gorm.DB {
+		query := r.getDB().Table("payment_tx_log p").
+			Joins("LEFT JOIN merchants m ON p.merchant_id = m.merchant_id").
+			Where("m.merchant_id = ? OR m.provisioner_id = ?", provisionerID, provisionerID)
+		return r.applyFilters(query, filter)
+	}
+
+	var totalCount int64
+	if err := scopedQuery().Distinct("p.merchant_id").Count(&totalCount).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var results []summaryResult
+	offset := (pagination.Page - 1) * pagination.Limit
+	err := scopedQuery().
+		Select(`
+			p.merchant_id,
+			MAX(m.name) as merchant_name,
+			COUNT(*) as total_transactions,
+			SUM(CASE WHEN p.result_code IN ('00', '10') THEN 1 ELSE 0 END) as successful_transactions,
+			SUM(COALESCE(p.amount, 0)) as total_amount,
+			MIN(p.updated_at) as min_date,
+			MAX(p.updated_at) as max_date
+		`).
+		Group("p.merchant_id").
+		Order("p.merchant_id").
+		Offset(offset).
+		Limit(pagination.Limit).
+		Find(&results).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	summaries := make([]models.MerchantSummary, 0, len(results))
+	for _, result := range results {
+		summary := models.MerchantSummary{
+			MerchantID:             result.MerchantID,
+			MerchantName:           result.MerchantName,
+			TotalTransactions:      result.TotalTxns,
+			SuccessfulTransactions: result.SuccessfulTxns,
+			FailedTransactions:     result.TotalTxns - result.SuccessfulTxns,
+			TotalAmount:            result.TotalAmount,
+		}
+
+		if result.TotalTxns > 0 {
+			summary.AverageAmount = config.RoundAmount(float64(result.TotalAmount)/float64(result.TotalTxns), 0, config.GetAmountRoundingMode())
+			summary.SuccessRate = (float64(result.SuccessfulTxns) / float64(result.TotalTxns)) * 100
+		}
+		if result.MinDate != nil {
+			summary.DateFrom = *result.MinDate
+		}
+		if result.MaxDate != nil {
+			summary.DateTo = *result.MaxDate
+		}
+
+		summaries = append(summaries, summary)
+	}
+
+	return summaries, totalCount, nil
+}
+
 // SearchTransactions performs advanced search with complex query body
 func (r *transactionRepository) SearchTransactions(merchantID string, searchReq *models.TransactionSearchRequest, timezone string, panFormat string) (*TransactionListResult, error) {
-	// For now, convert the search request to basic filters
-	// In a full implementation, this would parse Elasticsearch-style queries
-	filter := r.convertSearchToFilter(searchReq.Query)
+	// The query body is parsed into searchReq.Filter by the service
+	filter := searchReq.Filter
+	if filter == nil {
+		filter = &models.TransactionFilter{}
+	}
 
 	return r.GetTransactions(merchantID, filter, searchReq.Fields, searchReq.Sort, searchReq.Pagination, timezone, panFormat)
 }
 
 // buildBaseQuery constructs the base query with joins and field selection
 func (r *transactionRepository) buildBaseQuery(fields []string, timezone string, panFormat string) *gorm.DB {
-	var query *gorm.DB
+	return r.buildDistinctQuery("p.payment_tx_log_id", fields, timezone, panFormat)
+}
+
+// buildKeysetQuery constructs the base query for keyset pagination. Rows are deduplicated on
+// (updated_at, payment_tx_log_id) so DISTINCT ON matches the keyset ORDER BY, and both columns
+// are always selected so the next cursor can be built from the last row.
+func (r *transactionRepository) buildKeysetQuery(fields []string, timezone string, panFormat string) *gorm.DB {
+	if len(fields) > 0 {
+		keysetFields := append([]string{}, fields...)
+		for _, field := range []string{"payment_tx_log_id", "updated_at"} {
+			if !containsString(keysetFields, field) {
+				keysetFields = append(keysetFields, field)
+			}
+		}
+		fields = keysetFields
+	}
+
+	return r.buildDistinctQuery("p.updated_at, p.payment_tx_log_id", fields, timezone, panFormat)
+}
+
+// buildDistinctQuery constructs a query with joins and field selection, deduplicated on distinctOn
+func (r *transactionRepository) buildDistinctQuery(distinctOn string, fields []string, timezone string, panFormat string) *gorm.DB {
+	var selectedFields string
 
 	if len(fields) == 0 {
 		// No field filtering - select all fields plus computed fields from joins
-		query = r.getDB().Table("payment_tx_log p").
-			Select("DISTINCT ON (p.payment_tx_log_id) p.*, m.name as merchant_name, c.curr_short as currency_name, c.curr_delim").
-			Joins("LEFT JOIN merchants m ON p.merchant_id = m.merchant_id").
-			Joins("LEFT JOIN currency c ON p.currency_code = c.curr_code")
+		selectedFields = "p.*, m.name as merchant_name, c.curr_short as currency_name, c.curr_delim"
 	} else {
 		// Field filtering requested - select only specific fields
-		selectedFields := r.buildFieldSelection(fields, timezone, panFormat)
-		query = r.getDB().Table("payment_tx_log p").
-			Select("DISTINCT ON (p.payment_tx_log_id) " + selectedFields).
-			Joins("LEFT JOIN merchants m ON p.merchant_id = m.merchant_id").
-			Joins("LEFT JOIN currency c ON p.currency_code = c.curr_code")
+		selectedFields = r.buildFieldSelection(fields, timezone, panFormat)
 	}
 
-	return query
+	return r.getDB().Table("payment_tx_log p").
+		Select("DISTINCT ON (" + distinctOn + ") " + selectedFields).
+		Joins("LEFT JOIN merchants m ON p.merchant_id = m.merchant_id").
+		Joins(currencyJoin("p.currency_code"))
+}
+
+// currencyJoin returns the join as c of the currency row for the code in codeColumn.
+// curr_code is not unique in the source schema, so by default a lateral subquery picks a
+// single row per code; with the "join" strategy a duplicated code multiplies the rows.
+func currencyJoin(codeColumn string) string {
+	if config.GetCurrencyJoinStrategy() == config.CurrencyJoinDirect {
+		return "LEFT JOIN currency c ON " + codeColumn + " = c.curr_code"
+	}
+	return "LEFT JOIN LATERAL (SELECT curr_short, curr_delim FROM currency " +
+		"WHERE curr_code = " + codeColumn + " ORDER BY curr_short, curr_delim LIMIT 1) c ON true"
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// distinctTransactions selects columns of one row per transaction matched by query, a
+// count query, as a subquery aliased p. The merchant join can match more than one row per
+// transaction, which an aggregate such as SUM(p.amount) would otherwise count repeatedly.
+func distinctTransactions(query *gorm.DB, columns string) *gorm.DB {
+	inner := query.Select("DISTINCT ON (p.payment_tx_log_id) " + columns)
+	return query.Session(&gorm.Session{NewDB: true}).Table("(?) AS p", inner)
 }
 
-// buildCountQuery constructs a query for counting records
+// buildCountQuery constructs a query for counting records. No filter reads the currency
+// table, so it is not joined. The merchant join can still multiply rows, so counts over it
+// must be of DISTINCT p.payment_tx_log_id.
 func (r *transactionRepository) buildCountQuery() *gorm.DB {
 	return r.getDB().Table("payment_tx_log p").
-		Joins("LEFT JOIN merchants m ON p.merchant_id = m.merchant_id").
-		Joins("LEFT JOIN currency c ON p.currency_code = c.curr_code")
+		Joins("LEFT JOIN merchants m ON p.merchant_id = m.merchant_id")
 }
 
-// buildFieldSelection creates the SELECT clause based on requested fields
+// buildFieldSelection creates the SELECT clause based on requested fields. Only fields in
+// config.FieldMappings or config.ColumnFields are selected; anything else is dropped so a
+// field name never reaches the SQL unless it is allowlisted, whatever the caller validated.
 func (r *transactionRepository) buildFieldSelection(fields []string, timezone string, panFormat string) string {
 	var selectedFields []string
 
@@ -278,7 +896,17 @@ func (r *transactionRepository) buildFieldSelection(fields []string, timezone st
 		case "response_code":
 			selectedFields = append(selectedFields, "p.result_code as response_code")
 		case "pan":
-			selectedFields = append(selectedFields, fmt.Sprintf("%s as pan", panFormatSQL))
+			if config.IsPANDisabled() {
+				selectedFields = append(selectedFields, fmt.Sprintf("'%s' as pan", config.PANPlaceholder))
+			} else {
+				selectedFields = append(selectedFields, fmt.Sprintf("%s as pan", panFormatSQL))
+			}
+		case "bin_id":
+			if config.IsPANDisabled() {
+				selectedFields = append(selectedFields, "NULL as bin_id")
+			} else {
+				selectedFields = append(selectedFields, "p.bin_id as bin_id")
+			}
 		case "tx_date_time":
 			selectedFields = append(selectedFields, fmt.Sprintf("TO_CHAR(TIMEZONE('%s', p.updated_at), 'YYYY-MM-DD\"T\"HH24:MI:SS.MS\"Z\"') as tx_date_time", timezone))
 		case "tx_log_type":
@@ -290,8 +918,8 @@ func (r *transactionRepository) buildFieldSelection(fields []string, timezone st
 		default:
 			if sqlField, exists := config.FieldMappings[field]; exists {
 				selectedFields = append(selectedFields, fmt.Sprintf("%s as %s", sqlField, field))
-			} else {
-				selectedFields = append(selectedFields, fmt.Sprintf("p.%s", field))
+			} else if column, exists := config.ColumnFields[field]; exists {
+				selectedFields = append(selectedFields, column)
 			}
 		}
 	}
@@ -302,7 +930,7 @@ func (r *transactionRepository) buildFieldSelection(fields []string, timezone st
 // applyFilters adds WHERE conditions based on the filter
 func (r *transactionRepository) applyFilters(query *gorm.DB, filter *models.TransactionFilter) *gorm.DB {
 	if filter == nil {
-		return query
+		filter = &models.TransactionFilter{}
 	}
 
 	// Note: DeviceID filtering is disabled since we're not joining with devices table
@@ -310,83 +938,282 @@ func (r *transactionRepository) applyFilters(query *gorm.DB, filter *models.Tran
 	// 	query = query.Where("d.deviceid = ?", *filter.DeviceID)
 	// }
 
+	if filter.ProfileID != nil {
+		query = query.Where("p.profile_id = ?", *filter.ProfileID)
+	}
+
+	if filter.PaymentTxRef != nil {
+		query = query.Where("p.payment_tx_ref = ?", *filter.PaymentTxRef)
+	}
+
+	if filter.STAN != nil {
+		query = query.Where("p.stan = ?", *filter.STAN)
+	}
+
+	if filter.RRN != nil {
+		query = query.Where("p.rrn = ?", *filter.RRN)
+	}
+
 	if filter.ResponseCode != nil {
 		query = query.Where("p.result_code = ?", *filter.ResponseCode)
 	}
 
+	if len(filter.ResponseCodeIn) > 0 {
+		query = query.Where("p.result_code IN (?)", filter.ResponseCodeIn)
+	}
+
+	if filter.ResponseCodeNot != nil {
+		query = query.Where("p.result_code != ?", *filter.ResponseCodeNot)
+	}
+
+	if filter.MerchantIDNot != nil {
+		query = query.Where("p.merchant_id != ?", *filter.MerchantIDNot)
+	}
+
 	if filter.DateTimeFrom != nil {
-		query = query.Where("p.updated_at >= ?", *filter.DateTimeFrom)
+		query = query.Where(dateColumn(filter)+" >= ?", *filter.DateTimeFrom)
 	}
 
 	if filter.DateTimeTo != nil {
-		query = query.Where("p.updated_at <= ?", *filter.DateTimeTo)
+		query = query.Where(dateColumn(filter)+" <= ?", *filter.DateTimeTo)
 	}
 
 	if filter.CurrencyCode != nil {
 		query = query.Where("p.currency_code = ?", *filter.CurrencyCode)
 	}
 
-	if filter.AmountMin != nil {
-		query = query.Where("p.amount >= ?", *filter.AmountMin)
+	if len(filter.CurrencyCodeIn) > 0 {
+		query = query.Where("p.currency_code IN (?)", filter.CurrencyCodeIn)
+	}
+
+	if filter.CurrencyCodeNot != nil {
+		query = query.Where("p.currency_code != ?", *filter.CurrencyCodeNot)
+	}
+
+	if filter.MerchantNameLike != nil {
+		query = query.Where(`m.name ILIKE ? ESCAPE '\'`, "%"+escapeLikePattern(*filter.MerchantNameLike)+"%")
 	}
 
-	if filter.AmountMax != nil {
+	if filter.DescriptionLike != nil {
+		query = query.Where(`p.description ILIKE ? ESCAPE '\'`, "%"+escapeLikePattern(*filter.DescriptionLike)+"%")
+	}
+
+	if filter.PaymentTxRefLike != nil {
+		query = query.Where(`p.payment_tx_ref ILIKE ? ESCAPE '\'`, "%"+escapeLikePattern(*filter.PaymentTxRefLike)+"%")
+	}
+
+	// Null checks bind no value; fields are sorted so the statement is the same every time
+	nullFields := make([]string, 0, len(filter.NullFields))
+	for field := range filter.NullFields {
+		nullFields = append(nullFields, field)
+	}
+	sort.Strings(nullFields)
+	for _, field := range nullFields {
+		if filter.NullFields[field] {
+			query = query.Where(config.FieldMappings[field] + " IS NULL")
+		} else {
+			query = query.Where(config.FieldMappings[field] + " IS NOT NULL")
+		}
+	}
+
+	if filter.Search != nil {
+		if condition, args := buildSearchCondition(filter.Search); condition != "" {
+			query = query.Where(condition, args...)
+		}
+	}
+
+	// Settlement columns don't exist in every schema, so they are only filtered on when enabled
+	if config.IsSettlementColumnsEnabled() {
+		if filter.SettlementStatus != nil {
+			query = query.Where(config.FieldMappings["settlement_status"]+" = ?", *filter.SettlementStatus)
+		}
+
+		if filter.SettlementDateFrom != nil {
+			query = query.Where("p.settlement_date >= ?", *filter.SettlementDateFrom)
+		}
+
+		if filter.SettlementDateTo != nil {
+			query = query.Where("p.settlement_date <= ?", *filter.SettlementDateTo)
+		}
+	}
+
+	if filter.AmountMin != nil && filter.AmountMax != nil {
+		query = query.Where("p.amount BETWEEN ? AND ?", *filter.AmountMin, *filter.AmountMax)
+	} else if filter.AmountMin != nil {
+		query = query.Where("p.amount >= ?", *filter.AmountMin)
+	} else if filter.AmountMax != nil {
 		query = query.Where("p.amount <= ?", *filter.AmountMax)
 	}
 
 	if filter.TxLogType != nil {
 		// Convert string type to numeric type
-		var typeID string
-		switch *filter.TxLogType {
-		case "payment":
-			typeID = "0"
-		case "reversal":
-			typeID = "1"
-		case "void":
-			typeID = "2"
-		case "refund":
-			typeID = "3"
-		case "mm purchase":
-			typeID = "9"
-		case "mm refund":
-			typeID = "10"
-		}
-		if typeID != "" {
+		if typeID, ok := models.PaymentTxTypeID(*filter.TxLogType); ok {
 			query = query.Where("p.payment_tx_type_id = ?", typeID)
 		}
 	}
 
+	if len(filter.TxLogTypeIn) > 0 {
+		// Types are validated by the filter parser, so unknown names are not expected here
+		var typeIDs []int
+		for _, txLogType := range filter.TxLogTypeIn {
+			if typeID, ok := models.PaymentTxTypeID(txLogType); ok {
+				typeIDs = append(typeIDs, typeID)
+			}
+		}
+		if len(typeIDs) > 0 {
+			query = query.Where("p.payment_tx_type_id IN (?)", typeIDs)
+		}
+	}
+
+	if !filter.IncludeInactive {
+		query = query.Where("p.active = true")
+	}
+
+	// An explicit completed condition wins over the default exclusion of incomplete transactions
+	if filter.Completed != nil {
+		query = query.Where("p.completed = ?", *filter.Completed)
+	} else if !filter.IncludeIncomplete {
+		query = query.Where("p.completed = true")
+	}
+
 	return query
 }
 
-// applySortingWithDistinct adds ORDER BY clauses for DISTINCT ON queries
-func (r *transactionRepository) applySortingWithDistinct(query *gorm.DB, sort []models.SortParams) *gorm.DB {
-	// For DISTINCT ON (p.payment_tx_log_id), we must order by p.payment_tx_log_id first
-	orderBy := []string{"p.payment_tx_log_id"}
+// escapeLikePattern escapes LIKE wildcards so user input is matched literally
+func escapeLikePattern(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
 
-	if len(sort) == 0 {
-		// Default sort - add updated_at after the required DISTINCT column
-		orderBy = append(orderBy, "p.updated_at DESC")
-	} else {
-		// Add user-specified sorts after the required DISTINCT column
-		for _, s := range sort {
-			direction := "ASC"
-			if strings.ToLower(s.Direction) == "desc" {
-				direction = "DESC"
-			}
+// searchOperators are the SQL comparisons a search clause may use
+var searchOperators = map[string]bool{"=": true, ">=": true, "<=": true, ">": true, "<": true, "ILIKE": true}
 
-			var sqlField string
-			if mappedField, exists := config.FieldMappings[s.Field]; exists {
-				sqlField = mappedField
-			} else {
-				sqlField = fmt.Sprintf("p.%s", s.Field)
-			}
+// buildSearchCondition renders a bool query from the search endpoint as a single WHERE condition.
+// must_not clauses treat NULL columns as not matching, so rows without a value are kept.
+func buildSearchCondition(boolQuery *models.SearchBoolQuery) (string, []interface{}) {
+	var parts []string
+	var args []interface{}
+
+	for _, clause := range boolQuery.Must {
+		condition, clauseArgs := buildSearchClause(clause)
+		parts = append(parts, condition)
+		args = append(args, clauseArgs...)
+	}
+
+	if len(boolQuery.Should) > 0 {
+		var should []string
+		for _, clause := range boolQuery.Should {
+			condition, clauseArgs := buildSearchClause(clause)
+			should = append(should, condition)
+			args = append(args, clauseArgs...)
+		}
+		parts = append(parts, "("+strings.Join(should, " OR ")+")")
+	}
+
+	for _, clause := range boolQuery.MustNot {
+		condition, clauseArgs := buildSearchClause(clause)
+		parts = append(parts, "NOT COALESCE("+condition+", false)")
+		args = append(args, clauseArgs...)
+	}
+
+	if len(parts) == 0 {
+		return "", nil
+	}
+
+	return "(" + strings.Join(parts, " AND ") + ")", args
+}
 
-			orderBy = append(orderBy, fmt.Sprintf("%s %s", sqlField, direction))
+// buildSearchClause renders a single search clause; unknown fields or operators match nothing
+func buildSearchClause(clause models.SearchClause) (string, []interface{}) {
+	if clause.Bool != nil {
+		condition, args := buildSearchCondition(clause.Bool)
+		if condition == "" {
+			return "TRUE", nil
 		}
+		return condition, args
+	}
+
+	column, exists := config.FieldMappings[clause.Field]
+	if clause.Field == "description" {
+		column, exists = "p.description", true
+	}
+	if !exists || !searchOperators[clause.Operator] {
+		return "FALSE", nil
+	}
+
+	if clause.Operator == "ILIKE" {
+		text, _ := clause.Value.(string)
+		return "(" + column + ` ILIKE ? ESCAPE '\')`, []interface{}{"%" + escapeLikePattern(text) + "%"}
 	}
 
-	return query.Order(strings.Join(orderBy, ", "))
+	return "(" + column + " " + clause.Operator + " ?)", []interface{}{clause.Value}
+}
+
+// dateColumn returns the column the filter's date range and the tx_date_time sort apply to
+func dateColumn(filter *models.TransactionFilter) string {
+	if filter != nil && filter.DateField == models.DateFieldCreatedAt {
+		return "p.created_at"
+	}
+	return "p.updated_at"
+}
+
+// applySortingWithDistinct orders a DISTINCT ON (p.payment_tx_log_id) query. DISTINCT ON
+// requires its key to lead the ORDER BY, so the deduplicated rows are selected in a
+// subquery ordered by id, carrying each sort expression as a sort_N column, and the
+// requested order is applied by the outer query with the id as the last key so pages stay
+// stable. The tx_date_time sort and the default sort order by dateSortColumn.
+func (r *transactionRepository) applySortingWithDistinct(query *gorm.DB, sort []models.SortParams, dateSortColumn string) *gorm.DB {
+	var sortColumns, orderBy []string
+	addSort := func(expression, direction string) {
+		alias := fmt.Sprintf("sort_%d", len(sortColumns))
+		sortColumns = append(sortColumns, expression+" AS "+alias)
+		orderBy = append(orderBy, "t."+alias+" "+direction)
+	}
+
+	if len(sort) == 0 {
+		addSort(dateSortColumn, "DESC")
+	}
+	for _, s := range sort {
+		direction := "ASC"
+		if strings.ToLower(s.Direction) == "desc" {
+			direction = "DESC"
+		}
+
+		expression, ok := sortExpression(s.Field)
+		if !ok {
+			continue
+		}
+		if s.Field == "tx_date_time" {
+			expression = dateSortColumn
+		}
+		addSort(expression, direction)
+	}
+	addSort("p.payment_tx_log_id", "ASC")
+
+	selects := append([]string{}, query.Statement.Selects...)
+	if len(selects) == 0 {
+		selects = append(selects, "p.*")
+	}
+	inner := query.Select(strings.Join(append(selects, sortColumns...), ", ")).Order("p.payment_tx_log_id")
+
+	return query.Session(&gorm.Session{NewDB: true}).
+		Table("(?) AS t", inner).
+		Select("t.*").
+		Order(strings.Join(orderBy, ", "))
+}
+
+// sortExpression returns the SQL expression to order by for a sort field. Fields that are
+// not allowlisted report false and must not be sorted on.
+func sortExpression(field string) (string, bool) {
+	if mappedField, exists := config.FieldMappings[field]; exists {
+		return mappedField, true
+	}
+	if computedField, exists := config.SortableComputedFields[field]; exists {
+		return computedField, true
+	}
+	if column, exists := config.ColumnFields[field]; exists {
+		return column, true
+	}
+	return "", false
 }
 
 // applySorting adds ORDER BY clauses
@@ -402,11 +1229,11 @@ func (r *transactionRepository) applySorting(query *gorm.DB, sort []models.SortP
 			direction = "DESC"
 		}
 
-		if sqlField, exists := config.FieldMappings[s.Field]; exists {
-			query = query.Order(fmt.Sprintf("%s %s", sqlField, direction))
-		} else {
-			query = query.Order(fmt.Sprintf("p.%s %s", s.Field, direction))
+		expression, ok := sortExpression(s.Field)
+		if !ok {
+			continue
 		}
+		query = query.Order(fmt.Sprintf("%s %s", expression, direction))
 	}
 
 	return query
@@ -444,6 +1271,15 @@ func (r *transactionRepository) postProcessTransactions(transactions []models.Tr
 		// Create currency info from joined currency data
 		r.populateCurrencyInfo(tx)
 
+		// The default selection loads bin_id and pan_id with p.*, so drop them here
+		if config.IsPANDisabled() {
+			placeholder := config.PANPlaceholder
+			tx.PAN = &placeholder
+			tx.BinID = nil
+			tx.PanID = nil
+			continue
+		}
+
 		// Generate PAN from bin_id and pan_id if available
 		if tx.BinID != nil && tx.PanID != nil && *tx.BinID != "" && *tx.PanID != "" {
 			binID := *tx.BinID
@@ -463,7 +1299,6 @@ func (r *transactionRepository) populateCurrencyInfo(tx *models.Transaction) {
 		currInfo := &models.CurrencyInfo{
 			Code:     tx.CurrencyCode,
 			Name:     tx.CurrencyName, // From joined currency table
-			Symbol:   "R",             // Default to R for South African Rand
 			Exponent: tx.CurrDelim,    // From joined currency table
 		}
 
@@ -475,6 +1310,7 @@ func (r *transactionRepository) populateCurrencyInfo(tx *models.Transaction) {
 				currInfo.Exponent = currency.CurrDelim
 			}
 		}
+		currInfo.Symbol = config.CurrencySymbol(tx.CurrencyCode, currInfo.Name)
 
 		// Format the amount using the currency info
 		currInfo.FormattedAmount = currInfo.FormatAmount(tx.Amount)
@@ -482,76 +1318,6 @@ func (r *transactionRepository) populateCurrencyInfo(tx *models.Transaction) {
 	}
 }
 
-// convertSearchToFilter converts search query to basic filter (simplified implementation)
-func (r *transactionRepository) convertSearchToFilter(query interface{}) *models.TransactionFilter {
-	// This is a simplified implementation
-	// In a real implementation, you'd parse Elasticsearch-style queries
-	filter := &models.TransactionFilter{}
-
-	if queryMap, ok := query.(map[string]interface{}); ok {
-		if boolQuery, exists := queryMap["bool"]; exists {
-			if boolMap, ok := boolQuery.(map[string]interface{}); ok {
-				if mustClauses, exists := boolMap["must"]; exists {
-					if clauses, ok := mustClauses.([]interface{}); ok {
-						for _, clause := range clauses {
-							r.parseClause(clause, filter)
-						}
-					}
-				}
-			}
-		}
-	}
-
-	return filter
-}
-
-// parseClause parses individual query clauses (simplified)
-func (r *transactionRepository) parseClause(clause interface{}, filter *models.TransactionFilter) {
-	if clauseMap, ok := clause.(map[string]interface{}); ok {
-		// Parse term queries
-		if term, exists := clauseMap["term"]; exists {
-			if termMap, ok := term.(map[string]interface{}); ok {
-				for field, value := range termMap {
-					switch field {
-					case "merchant_id":
-						if strVal, ok := value.(string); ok {
-							filter.MerchantID = &strVal
-						}
-					case "response_code":
-						if strVal, ok := value.(string); ok {
-							filter.ResponseCode = &strVal
-						}
-					}
-				}
-			}
-		}
-
-		// Parse range queries
-		if rangeQuery, exists := clauseMap["range"]; exists {
-			if rangeMap, ok := rangeQuery.(map[string]interface{}); ok {
-				for field, value := range rangeMap {
-					if field == "amount" {
-						if valueMap, ok := value.(map[string]interface{}); ok {
-							if gte, exists := valueMap["gte"]; exists {
-								if intVal, ok := gte.(float64); ok {
-									val := int64(intVal)
-									filter.AmountMin = &val
-								}
-							}
-							if lte, exists := valueMap["lte"]; exists {
-								if intVal, ok := lte.(float64); ok {
-									val := int64(intVal)
-									filter.AmountMax = &val
-								}
-							}
-						}
-					}
-				}
-			}
-		}
-	}
-}
-
 // GetTransactionTotals returns transaction totals by type for a specific date and device/terminal
 func (r *transactionRepository) GetTransactionTotals(merchantID string, request models.TransactionTotalsRequest) (*models.TransactionTotalsResponse, error) {
 	type TotalResult struct {
@@ -586,7 +1352,7 @@ func (r *transactionRepository) GetTransactionTotals(merchantID string, request
 		Where("DATE(p.created_at) = ?", request.Date).
 		Where("m.merchant_id = ? OR m.provisioner_id = ?", merchantID, merchantID).
 		Group("p.payment_tx_type_id, pt.name").
-		Order("p.payment_tx_type_id")
+		Order("p.payment_tx_type_id, pt.name")
 
 	// Apply device/terminal filters
 	if request.DeviceID != "" {
@@ -606,11 +1372,12 @@ func (r *transactionRepository) GetTransactionTotals(merchantID string, request
 
 	// Convert results to response format
 	totals := make([]models.TransactionTotal, len(results))
+	roundingMode := config.GetAmountRoundingMode()
 	for i, result := range results {
 		totals[i] = models.TransactionTotal{
 			TrxType:     result.TrxType,
 			TrxDescr:    result.TrxDescr,
-			TotalAmount: result.TotalAmount,
+			TotalAmount: config.RoundAmount(result.TotalAmount, 2, roundingMode),
 		}
 	}
 
@@ -642,33 +1409,9 @@ func (r *transactionRepository) GetTransactionLookup(request models.TransactionL
 	}
 
 	var results []LookupResult
-	var query *gorm.DB
 
-	// Build query based on whether device_id is provided
-	if request.DeviceID != "" {
-		// Query with device_id filter
-		query = r.getDB().Raw(`
-			SELECT 
-				TRIM(TRIM(BOTH '"' FROM JSON_EXTRACT(trx_snd, '$."43"'))) AS trx_descr,
-				SUM(trx_amt) / 100 AS total_amount_egp
-			FROM iso_trx
-			WHERE DATE(trx_datetime) = ? 
-			AND TRIM(BOTH '"' FROM JSON_EXTRACT(trx_snd, '$."42"')) = ?
-			AND trx_rsp_code = '00'
-			GROUP BY trx_descr
-		`, request.Date, request.DeviceID)
-	} else {
-		// Query without device_id filter (all devices)
-		query = r.getDB().Raw(`
-			SELECT 
-				TRIM(TRIM(BOTH '"' FROM JSON_EXTRACT(trx_snd, '$."43"'))) AS trx_descr,
-				SUM(trx_amt) / 100 AS total_amount_egp
-			FROM iso_trx
-			WHERE DATE(trx_datetime) = ? 
-			AND trx_rsp_code = '00'
-			GROUP BY trx_descr
-		`, request.Date)
-	}
+	lookupSQL, args := buildLookupQuery(request)
+	query := r.getDB().Raw(lookupSQL, args...)
 
 	if err := query.Scan(&results).Error; err != nil {
 		return nil, fmt.Errorf("failed to get transaction lookup: %w", err)
@@ -676,22 +1419,60 @@ func (r *transactionRepository) GetTransactionLookup(request models.TransactionL
 
 	// Convert results to response format
 	totals := make([]models.TransactionLookupTotal, len(results))
+	roundingMode := config.GetAmountRoundingMode()
 	for i, result := range results {
 		totals[i] = models.TransactionLookupTotal{
 			TrxDescr:       result.TrxDescr,
-			TotalAmountEGP: result.TotalAmountEGP,
+			TotalAmountEGP: config.RoundAmount(result.TotalAmountEGP, 2, roundingMode),
 		}
 	}
 
 	// Build response
 	response := &models.TransactionLookupResponse{
 		Date:     request.Date,
+		DateFrom: request.DateFrom,
+		DateTo:   request.DateTo,
 		DeviceID: request.DeviceID,
 		Totals:   totals,
 	}
 
 	return response, nil
 }
+
+// buildLookupQuery builds the per-description lookup totals for a date, optionally for a
+// single device. Totals are ordered by description so array positions are stable.
+func buildLookupQuery(request models.TransactionLookupRequest) (string, []interface{}) {
+	dateCondition, args := isoDateCondition(request.Date, request.DateFrom, request.DateTo)
+	query := `
+		SELECT 
+			TRIM(TRIM(BOTH '"' FROM JSON_EXTRACT(trx_snd, '$."43"'))) AS trx_descr,
+			SUM(trx_amt) / 100 AS total_amount_egp
+		FROM iso_trx
+		WHERE ` + dateCondition + `
+		AND trx_rsp_code = '00'`
+
+	if request.DeviceID != "" {
+		query += `
+		AND TRIM(BOTH '"' FROM JSON_EXTRACT(trx_snd, '$."42"')) = ?`
+		args = append(args, request.DeviceID)
+	}
+
+	query += `
+		GROUP BY trx_descr
+		ORDER BY trx_descr
+	`
+	return query, args
+}
+
+// isoDateCondition restricts iso_trx to a single date, or to the inclusive date_from/date_to
+// range when no date is set
+func isoDateCondition(date, dateFrom, dateTo string) (string, []interface{}) {
+	if date != "" {
+		return "DATE(trx_datetime) = ?", []interface{}{date}
+	}
+	return "DATE(trx_datetime) BETWEEN ? AND ?", []interface{}{dateFrom, dateTo}
+}
+
 // SearchTransactionDetails returns detailed transaction information based on search criteria
 func (r *transactionRepository) SearchTransactionDetails(request models.IsoTransactionSearchRequest) (*models.IsoTransactionSearchResponse, error) {
 	type SearchResult struct {
@@ -715,13 +1496,63 @@ func (r *transactionRepository) SearchTransactionDetails(request models.IsoTrans
 	var results []SearchResult
 	
 	// Build dynamic query based on provided filters
+	searchSQL, args := buildIsoSearchQuery(request)
+
+	// Execute the dynamic query
+	query := r.getDB().Raw(searchSQL, args...)
+
+	if err := query.Scan(&results).Error; err != nil {
+		return nil, fmt.Errorf("failed to search transaction details: %w", err)
+	}
+	
+	// Convert results to response format
+	transactions := make([]models.TransactionSearchItem, len(results))
+	for i, result := range results {
+		transactions[i] = models.TransactionSearchItem{
+			Datetime:        result.Datetime,
+			STAN:            result.STAN,
+			RRN:             result.TrxRRN,
+			BIN:             result.BIN,
+			PANID:           result.PANID,
+			DeviceID:        result.DeviceID,
+			GroupID:         result.GroupID,
+			TrxDescr:        result.TrxDescr,
+			TrxType:         result.TrxType,
+			BankGroupID:     result.BankGroupID,
+			TransactionCode: result.TransactionCode,
+			TxID:            result.TxID,
+			Amount:          result.Amount,
+			RC:              result.RC,
+			TrxAuthCode:     result.TrxAuthCode,
+		}
+	}
+	
+	// Build response
+	response := &models.IsoTransactionSearchResponse{
+		Transactions: transactions,
+	}
+	
+	return response, nil
+}
+
+// buildIsoSearchQuery builds the iso_trx search for the provided filters. Rows are ordered by
+// transaction time, then by trx_guid, so the order is stable between calls. With PAN_DISABLED
+// the BIN and PANID are returned blank rather than read from the track data.
+func buildIsoSearchQuery(request models.IsoTransactionSearchRequest) (string, []interface{}) {
+	cardColumns := `
+			LEFT(TRIM(BOTH '"' FROM JSON_EXTRACT(trx_snd, '$."35"')), 6) AS BIN,
+			RIGHT(SUBSTRING_INDEX(SUBSTRING_INDEX(TRIM(BOTH '"' FROM JSON_EXTRACT(trx_snd, '$."35"')),'=',1),'D',1), 4) AS PANID,`
+	if config.IsPANDisabled() {
+		cardColumns = `
+			'' AS BIN,
+			'' AS PANID,`
+	}
+
 	baseQuery := `
 		SELECT
 			trx_datetime AS datetime,
 			trx_stan AS STAN,
-			COALESCE(trx_rrn, '') AS trx_rrn,
-			LEFT(TRIM(BOTH '"' FROM JSON_EXTRACT(trx_snd, '$."35"')), 6) AS BIN,
-			RIGHT(SUBSTRING_INDEX(SUBSTRING_INDEX(TRIM(BOTH '"' FROM JSON_EXTRACT(trx_snd, '$."35"')),'=',1),'D',1), 4) AS PANID,
+			COALESCE(trx_rrn, '') AS trx_rrn,` + cardColumns + `
 			TRIM(TRIM(BOTH '"' FROM JSON_EXTRACT(trx_snd, '$."42"'))) AS device_id,
 			TRIM(TRIM(BOTH '"' FROM JSON_EXTRACT(trx_snd, '$."41"'))) AS group_id,
 			TRIM(TRIM(BOTH '"' FROM JSON_EXTRACT(trx_snd, '$."43"'))) AS trx_descr,
@@ -735,111 +1566,79 @@ func (r *transactionRepository) SearchTransactionDetails(request models.IsoTrans
 		FROM iso_trx
 		WHERE 1=1
 	`
-	
+
 	// Build WHERE conditions dynamically
 	var conditions []string
 	var args []interface{}
-	
+
 	// Add date filter if provided
-	if request.Date != "" {
-		conditions = append(conditions, "DATE(trx_datetime) = ?")
-		args = append(args, request.Date)
+	if request.Date != "" || request.DateFrom != "" {
+		condition, dateArgs := isoDateCondition(request.Date, request.DateFrom, request.DateTo)
+		conditions = append(conditions, condition)
+		args = append(args, dateArgs...)
 	}
-	
+
 	// Add device_id filter if provided
 	if request.DeviceID != "" {
 		conditions = append(conditions, "TRIM(TRIM(BOTH '\"' FROM JSON_EXTRACT(trx_snd, '$.\"42\"'))) = ?")
 		args = append(args, request.DeviceID)
 	}
-	
+
 	// Add trx_rrn filter if provided
 	if request.TrxRRN != "" {
 		conditions = append(conditions, "trx_rrn = ?")
 		args = append(args, request.TrxRRN)
 	}
-	
+
 	// Add amount filter if provided (non-zero)
 	if request.Amount != 0 {
 		conditions = append(conditions, "trx_amt = ?")
 		args = append(args, request.Amount)
 	}
-	
+
 	// Add panid filter if provided
 	if request.PanID != "" {
 		conditions = append(conditions, "RIGHT(SUBSTRING_INDEX(SUBSTRING_INDEX(TRIM(BOTH '\"' FROM JSON_EXTRACT(trx_snd, '$.\"35\"')),'=',1),'D',1), 4) = ?")
 		args = append(args, request.PanID)
 	}
-	
+
 	// Add group_id filter if provided
 	if request.GroupID != "" {
 		conditions = append(conditions, "TRIM(TRIM(BOTH '\"' FROM JSON_EXTRACT(trx_snd, '$.\"41\"'))) = ?")
 		args = append(args, request.GroupID)
 	}
-	
+
 	// Add bank_group_id filter if provided
 	if request.BankGroupID != "" {
 		conditions = append(conditions, "TRIM(TRIM(BOTH '\"' FROM JSON_EXTRACT(JSON_EXTRACT(trx_snd, '$.\"request_meta\"'), '$.\"bank_group_id\"'))) = ?")
 		args = append(args, request.BankGroupID)
 	}
-	
+
 	// Add trx_descr filter if provided
 	if request.TrxDescr != "" {
 		conditions = append(conditions, "TRIM(TRIM(BOTH '\"' FROM JSON_EXTRACT(trx_snd, '$.\"43\"'))) = ?")
 		args = append(args, request.TrxDescr)
 	}
-	
+
 	// Add tx_id filter if provided
 	if request.TxID != "" {
 		conditions = append(conditions, "TRIM(TRIM(BOTH '\"' FROM JSON_EXTRACT(JSON_EXTRACT(trx_snd, '$.\"request_meta\"'), '$.\"trx_id\"'))) = ?")
 		args = append(args, request.TxID)
 	}
-	
+
 	// Add response_code filter if provided
 	if request.ResponseCode != "" {
 		conditions = append(conditions, "trx_rsp_code = ?")
 		args = append(args, request.ResponseCode)
 	}
-	
+
 	// Combine all conditions
 	if len(conditions) > 0 {
 		baseQuery += " AND " + strings.Join(conditions, " AND ")
 	}
-	
-	baseQuery += " ORDER BY trx_datetime"
-	
-	// Execute the dynamic query
-	query := r.getDB().Raw(baseQuery, args...)
-	
-	if err := query.Scan(&results).Error; err != nil {
-		return nil, fmt.Errorf("failed to search transaction details: %w", err)
-	}
-	
-	// Convert results to response format
-	transactions := make([]models.TransactionSearchItem, len(results))
-	for i, result := range results {
-		transactions[i] = models.TransactionSearchItem{
-			Datetime:        result.Datetime,
-			STAN:            result.STAN,
-			RRN:             result.TrxRRN,
-			BIN:             result.BIN,
-			PANID:           result.PANID,
-			DeviceID:        result.DeviceID,
-			GroupID:         result.GroupID,
-			TrxDescr:        result.TrxDescr,
-			TrxType:         result.TrxType,
-			BankGroupID:     result.BankGroupID,
-			TransactionCode: result.TransactionCode,
-			TxID:            result.TxID,
-			Amount:          result.Amount,
-			RC:              result.RC,
-			TrxAuthCode:     result.TrxAuthCode,
-		}
-	}
-	
-	// Build response
-	response := &models.IsoTransactionSearchResponse{
-		Transactions: transactions,
-	}
-	
-	return response, nil
-}
\ No newline at end of file
+
+	// trx_guid breaks ties between transactions logged in the same second
+	baseQuery += " ORDER BY trx_datetime, trx_guid"
+
+	return baseQuery, args
+}
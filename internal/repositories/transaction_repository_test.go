@@ -0,0 +1,930 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"aken_reporting_service/internal/config"
+	"aken_reporting_service/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// newDryRunRepository returns a repository whose queries are built but never executed
+func newDryRunRepository(t *testing.T) *transactionRepository {
+	t.Helper()
+
+	db, err := gorm.Open(postgres.New(postgres.Config{
+		DSN: "host=localhost user=test dbname=test sslmode=disable",
+	}), &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	require.NoError(t, err)
+
+	return &transactionRepository{postgresDB: db}
+}
+
+func generateIDs(n int) []string {
+	ids := make([]string, n)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("00000000-0000-0000-0000-%012d", i)
+	}
+	return ids
+}
+
+func TestApplyIDFilter_SmallListUsesIn(t *testing.T) {
+	t.Setenv("BATCH_IN_LIST_THRESHOLD", "10")
+	repo := newDryRunRepository(t)
+
+	stmt := repo.applyIDFilter(repo.buildCountQuery(), generateIDs(3)).Find(&[]models.Transaction{}).Statement
+
+	assert.Contains(t, stmt.SQL.String(), "p.payment_tx_log_id IN ($1,$2,$3)")
+	assert.Len(t, stmt.Vars, 3)
+}
+
+func TestApplyIDFilter_LargeListUsesArrayJoin(t *testing.T) {
+	t.Setenv("BATCH_IN_LIST_THRESHOLD", "500")
+	repo := newDryRunRepository(t)
+	ids := generateIDs(70000) // More than PostgreSQL's 65535 bind parameter limit
+
+	stmt := repo.applyIDFilter(repo.buildCountQuery(), ids).Find(&[]models.Transaction{}).Statement
+	sql := stmt.SQL.String()
+
+	assert.Contains(t, sql, "JOIN unnest(CAST($1 AS uuid[])) AS batch_ids(id) ON p.payment_tx_log_id = batch_ids.id")
+	assert.NotContains(t, sql, " IN ")
+	require.Len(t, stmt.Vars, 1)
+
+	literal, ok := stmt.Vars[0].(string)
+	require.True(t, ok)
+	assert.True(t, strings.HasPrefix(literal, `{"`+ids[0]+`",`))
+	assert.Equal(t, len(ids), strings.Count(literal, ",")+1)
+}
+
+func TestApplyRRNFilter(t *testing.T) {
+	t.Setenv("BATCH_IN_LIST_THRESHOLD", "2")
+	repo := newDryRunRepository(t)
+
+	stmt := repo.applyRRNFilter(repo.buildCountQuery(), []string{"000012345678", "12345678"}).Find(&[]models.Transaction{}).Statement
+	assert.Contains(t, stmt.SQL.String(), "p.rrn IN ($1,$2)")
+	assert.Equal(t, []interface{}{"000012345678", "12345678"}, stmt.Vars)
+
+	stmt = repo.applyRRNFilter(repo.buildCountQuery(), []string{"1", "2", "3"}).Find(&[]models.Transaction{}).Statement
+	assert.Contains(t, stmt.SQL.String(), "JOIN unnest(CAST($1 AS text[])) AS batch_rrns(rrn) ON p.rrn = batch_rrns.rrn")
+	assert.Equal(t, []interface{}{`{"1","2","3"}`}, stmt.Vars)
+}
+
+func TestGetTransactionsByRRNs_OrderMatchesDistinctOn(t *testing.T) {
+	repo := newDryRunRepository(t)
+	queries := captureQueries(t, repo)
+
+	// Postgres rejects a DISTINCT ON whose expressions do not lead the ORDER BY
+	_, err := repo.GetTransactionsByRRNs("merchant-1", []string{"000012345678"}, []string{"payment_tx_log_id", "rrn"}, "UTC", "", 11)
+	require.NoError(t, err)
+
+	require.Len(t, *queries, 1)
+	assert.Contains(t, (*queries)[0], "SELECT DISTINCT ON (p.payment_tx_log_id) ")
+	assert.Contains(t, (*queries)[0], "ORDER BY p.payment_tx_log_id LIMIT 11")
+}
+
+func TestGetTransactionsByIDs_LargeListKeepsMerchantScope(t *testing.T) {
+	repo := newDryRunRepository(t)
+
+	sql := repo.getDB().ToSQL(func(tx *gorm.DB) *gorm.DB {
+		dryRun := &transactionRepository{postgresDB: tx}
+		query := dryRun.buildBaseQuery([]string{"payment_tx_log_id"}, "UTC", "")
+		query = dryRun.applyIDFilter(query, generateIDs(1000))
+		return query.Where("m.merchant_id = ? OR m.provisioner_id = ?", "merchant-1", "merchant-1").Find(&[]models.Transaction{})
+	})
+
+	assert.Contains(t, sql, "JOIN unnest(")
+	assert.Contains(t, sql, "m.merchant_id = 'merchant-1'")
+}
+
+func TestGetTransactionsByIDs_EmptyList(t *testing.T) {
+	repo := newDryRunRepository(t)
+
+	transactions, err := repo.GetTransactionsByIDs("merchant-1", nil, nil, "UTC", "")
+
+	assert.NoError(t, err)
+	assert.Empty(t, transactions)
+}
+
+func TestParseExplainRows(t *testing.T) {
+	rows, err := parseExplainRows(`[{"Plan": {"Node Type": "Hash Join", "Plan Rows": 125000, "Plans": [{"Plan Rows": 3}]}}]`)
+	require.NoError(t, err)
+	assert.Equal(t, int64(125000), rows)
+
+	_, err = parseExplainRows(`[]`)
+	assert.Error(t, err)
+
+	_, err = parseExplainRows(`not json`)
+	assert.Error(t, err)
+}
+
+func TestBuildArrayLiteral_EscapesElements(t *testing.T) {
+	assert.Equal(t, `{"a","b\"c","d\\e"}`, buildArrayLiteral([]string{"a", `b"c`, `d\e`}))
+}
+
+func TestApplyFilters_NotEqual(t *testing.T) {
+	repo := newDryRunRepository(t)
+	responseCode := "00"
+	currencyCode := "0710"
+	filter := &models.TransactionFilter{ResponseCodeNot: &responseCode, CurrencyCodeNot: &currencyCode}
+
+	sql := repo.applyFilters(repo.buildCountQuery(), filter).Find(&[]models.Transaction{}).Statement.SQL.String()
+
+	assert.Contains(t, sql, "p.result_code != $1")
+	assert.Contains(t, sql, "p.currency_code != $2")
+}
+
+func TestApplyFilters_In(t *testing.T) {
+	repo := newDryRunRepository(t)
+	filter := &models.TransactionFilter{ResponseCodeIn: []string{"00", "10", "11"}, CurrencyCodeIn: []string{"0710"}}
+
+	sql := repo.applyFilters(repo.buildCountQuery(), filter).Find(&[]models.Transaction{}).Statement.SQL.String()
+
+	assert.Contains(t, sql, "p.result_code IN ($1,$2,$3)")
+	assert.Contains(t, sql, "p.currency_code IN ($4)")
+}
+
+func TestApplyFilters_NullChecks(t *testing.T) {
+	repo := newDryRunRepository(t)
+	filter := &models.TransactionFilter{NullFields: map[string]bool{"auth_code": true, "device_id": false}}
+
+	stmt := repo.applyFilters(repo.buildCountQuery(), filter).Find(&[]models.Transaction{}).Statement
+	sql := stmt.SQL.String()
+
+	assert.Contains(t, sql, "p.auth_code IS NULL AND p.device_id IS NOT NULL")
+	assert.Empty(t, stmt.Vars, "null checks must not bind a value")
+}
+
+func TestApplyFilters_TxLogType(t *testing.T) {
+	repo := newDryRunRepository(t)
+	txLogType := "reversal"
+	filter := &models.TransactionFilter{TxLogType: &txLogType, TxLogTypeIn: []string{"payment", "refund", "mm refund"}}
+
+	sql := repo.applyFilters(repo.buildCountQuery(), filter).Find(&[]models.Transaction{}).Statement.SQL.String()
+
+	assert.Contains(t, sql, "p.payment_tx_type_id = $1")
+	assert.Contains(t, sql, "p.payment_tx_type_id IN ($2,$3,$4)")
+}
+
+func TestApplyFilters_ProfileID(t *testing.T) {
+	repo := newDryRunRepository(t)
+	profileID := "profile-1"
+	filter := &models.TransactionFilter{ProfileID: &profileID}
+
+	sql := repo.applyFilters(repo.buildCountQuery(), filter).Find(&[]models.Transaction{}).Statement.SQL.String()
+
+	assert.Contains(t, sql, "p.profile_id = $1")
+}
+
+func TestGetCurrencies_ReadsEveryRow(t *testing.T) {
+	repo := newDryRunRepository(t)
+	queries := captureQueries(t, repo)
+
+	currencies, err := repo.GetCurrencies()
+
+	require.NoError(t, err)
+	assert.NotNil(t, currencies)
+	require.Len(t, *queries, 1)
+	assert.Equal(t, `SELECT * FROM "currency" ORDER BY curr_code, curr_short, curr_delim`, (*queries)[0])
+}
+
+func TestApplyFilters_STAN(t *testing.T) {
+	repo := newDryRunRepository(t)
+	stan := "123456"
+	filter := &models.TransactionFilter{STAN: &stan}
+
+	sql := repo.applyFilters(repo.buildCountQuery(), filter).Find(&[]models.Transaction{}).Statement.SQL.String()
+
+	assert.Contains(t, sql, "p.stan = $1")
+}
+
+func TestApplyFilters_RRNAndSTANMatchExactly(t *testing.T) {
+	repo := newDryRunRepository(t)
+	stan, rrn := "000123", "000012345678"
+	filter := &models.TransactionFilter{STAN: &stan, RRN: &rrn}
+
+	stmt := repo.applyFilters(repo.buildCountQuery(), filter).Find(&[]models.Transaction{}).Statement
+
+	assert.Contains(t, stmt.SQL.String(), "p.stan = $1")
+	assert.Contains(t, stmt.SQL.String(), "p.rrn = $2")
+	assert.Equal(t, []interface{}{"000123", "000012345678"}, stmt.Vars, "values are bound as strings with their leading zeros")
+}
+
+func TestApplyFilters_AmountRange(t *testing.T) {
+	repo := newDryRunRepository(t)
+	min, max := int64(950), int64(1050)
+
+	sql := repo.applyFilters(repo.buildCountQuery(), &models.TransactionFilter{AmountMin: &min, AmountMax: &max}).Find(&[]models.Transaction{}).Statement.SQL.String()
+	assert.Contains(t, sql, "p.amount BETWEEN $1 AND $2")
+
+	sql = repo.applyFilters(repo.buildCountQuery(), &models.TransactionFilter{AmountMin: &min}).Find(&[]models.Transaction{}).Statement.SQL.String()
+	assert.Contains(t, sql, "p.amount >= $1")
+	assert.NotContains(t, sql, "BETWEEN")
+
+	sql = repo.applyFilters(repo.buildCountQuery(), &models.TransactionFilter{AmountMax: &max}).Find(&[]models.Transaction{}).Statement.SQL.String()
+	assert.Contains(t, sql, "p.amount <= $1")
+}
+
+func TestApplyFilters_InactiveExcludedByDefault(t *testing.T) {
+	repo := newDryRunRepository(t)
+
+	for _, filter := range []*models.TransactionFilter{nil, {}} {
+		sql := repo.applyFilters(repo.buildCountQuery(), filter).Find(&[]models.Transaction{}).Statement.SQL.String()
+		assert.Contains(t, sql, "p.active = true")
+	}
+
+	sql := repo.applyFilters(repo.buildCountQuery(), &models.TransactionFilter{IncludeInactive: true}).
+		Find(&[]models.Transaction{}).Statement.SQL.String()
+	assert.NotContains(t, sql, "p.active")
+}
+
+func TestApplyFilters_IncompleteExcludedByDefault(t *testing.T) {
+	repo := newDryRunRepository(t)
+	render := func(filter *models.TransactionFilter) string {
+		return repo.applyFilters(repo.buildCountQuery(), filter).Find(&[]models.Transaction{}).Statement.SQL.String()
+	}
+
+	assert.Contains(t, render(nil), "p.completed = true")
+	assert.NotContains(t, render(&models.TransactionFilter{IncludeIncomplete: true}), "p.completed")
+
+	completed := false
+	sql := render(&models.TransactionFilter{Completed: &completed})
+	assert.Contains(t, sql, "p.completed = $")
+	assert.NotContains(t, sql, "p.completed = true")
+}
+
+func TestEscapeLikePattern(t *testing.T) {
+	tests := map[string]string{
+		"COFFEE":      "COFFEE",
+		"100%":        `100\%`,
+		"bean_shop":   `bean\_shop`,
+		`back\slash`:  `back\\slash`,
+		`50%_off\now`: `50\%\_off\\now`,
+	}
+
+	for input, expected := range tests {
+		assert.Equal(t, expected, escapeLikePattern(input), input)
+	}
+}
+
+func TestApplyFilters_Like(t *testing.T) {
+	repo := newDryRunRepository(t)
+	merchantName := "COFFEE_100%"
+	description := "refund"
+	filter := &models.TransactionFilter{MerchantNameLike: &merchantName, DescriptionLike: &description}
+
+	stmt := repo.applyFilters(repo.buildCountQuery(), filter).Find(&[]models.Transaction{}).Statement
+	sql := stmt.SQL.String()
+
+	assert.Contains(t, sql, `m.name ILIKE $1 ESCAPE '\'`)
+	assert.Contains(t, sql, `p.description ILIKE $2 ESCAPE '\'`)
+	assert.Equal(t, []interface{}{`%COFFEE\_100\%%`, "%refund%"}, stmt.Vars)
+}
+
+func TestApplyFilters_PaymentTxRef(t *testing.T) {
+	repo := newDryRunRepository(t)
+	exact := "INV-1001"
+	partial := "INV_10"
+
+	stmt := repo.applyFilters(repo.buildCountQuery(), &models.TransactionFilter{PaymentTxRef: &exact}).
+		Find(&[]models.Transaction{}).Statement
+	assert.Contains(t, stmt.SQL.String(), "p.payment_tx_ref = $1")
+	assert.Equal(t, "INV-1001", stmt.Vars[0])
+
+	stmt = repo.applyFilters(repo.buildCountQuery(), &models.TransactionFilter{PaymentTxRefLike: &partial}).
+		Find(&[]models.Transaction{}).Statement
+	assert.Contains(t, stmt.SQL.String(), `p.payment_tx_ref ILIKE $1 ESCAPE '\'`)
+	assert.Equal(t, `%INV\_10%`, stmt.Vars[0])
+}
+
+func TestApplyFilters_SettlementGuardedByConfig(t *testing.T) {
+	repo := newDryRunRepository(t)
+	status := "settled"
+	filter := &models.TransactionFilter{SettlementStatus: &status}
+
+	t.Setenv("SETTLEMENT_COLUMNS_ENABLED", "false")
+	sql := repo.applyFilters(repo.buildCountQuery(), filter).Find(&[]models.Transaction{}).Statement.SQL.String()
+	assert.NotContains(t, sql, "settlement_status")
+
+	t.Setenv("SETTLEMENT_COLUMNS_ENABLED", "true")
+	sql = repo.applyFilters(repo.buildCountQuery(), filter).Find(&[]models.Transaction{}).Statement.SQL.String()
+	assert.Contains(t, sql, "COALESCE(p.settlement_status, 'pending') = $1")
+}
+
+func TestApplyFilters_SearchBoolQuery(t *testing.T) {
+	repo := newDryRunRepository(t)
+	filter := &models.TransactionFilter{Search: &models.SearchBoolQuery{
+		Must: []models.SearchClause{
+			{Field: "device_id", Operator: "=", Value: "DEV1"},
+			{Bool: &models.SearchBoolQuery{Must: []models.SearchClause{
+				{Field: "amount", Operator: ">=", Value: int64(100)},
+				{Field: "amount", Operator: "<", Value: int64(500)},
+			}}},
+		},
+		Should: []models.SearchClause{
+			{Field: "response_code", Operator: "=", Value: "00"},
+			{Field: "merchant_name", Operator: "ILIKE", Value: "coffee_"},
+		},
+		MustNot: []models.SearchClause{
+			{Field: "description", Operator: "ILIKE", Value: "test"},
+		},
+	}}
+
+	stmt := repo.applyFilters(repo.buildCountQuery(), filter).Find(&[]models.Transaction{}).Statement
+	sql := stmt.SQL.String()
+
+	assert.Contains(t, sql, `((p.device_id = $1) AND ((p.amount >= $2) AND (p.amount < $3)) AND ((p.result_code = $4) OR (m.name ILIKE $5 ESCAPE '\')) AND NOT COALESCE((p.description ILIKE $6 ESCAPE '\'), false))`)
+	assert.Equal(t, []interface{}{"DEV1", int64(100), int64(500), "00", `%coffee\_%`, "%test%"}, stmt.Vars)
+}
+
+func TestBuildSearchClause_UnknownFieldMatchesNothing(t *testing.T) {
+	condition, args := buildSearchClause(models.SearchClause{Field: "nope", Operator: "=", Value: "x"})
+	assert.Equal(t, "FALSE", condition)
+	assert.Nil(t, args)
+
+	condition, _ = buildSearchClause(models.SearchClause{Field: "amount", Operator: "; DROP", Value: 1})
+	assert.Equal(t, "FALSE", condition)
+}
+
+func TestApplySortingWithDistinct_ComputedField(t *testing.T) {
+	repo := newDryRunRepository(t)
+
+	sql := repo.applySortingWithDistinct(repo.buildCountQuery(), []models.SortParams{{Field: "success", Direction: "desc"}}, "p.updated_at").
+		Find(&[]models.Transaction{}).Statement.SQL.String()
+
+	assert.Contains(t, sql, "CASE WHEN p.result_code IN ('00', '10') THEN 1 ELSE 0 END AS sort_0")
+	assert.Contains(t, sql, "ORDER BY t.sort_0 DESC, t.sort_1 ASC")
+}
+
+func TestApplySortingWithDistinct_PaymentTxRef(t *testing.T) {
+	repo := newDryRunRepository(t)
+
+	sql := repo.applySortingWithDistinct(repo.buildCountQuery(), []models.SortParams{{Field: "payment_tx_ref", Direction: "asc"}}, "p.updated_at").
+		Find(&[]models.Transaction{}).Statement.SQL.String()
+
+	assert.Contains(t, sql, "p.payment_tx_ref AS sort_0")
+	assert.Contains(t, sql, "ORDER BY t.sort_0 ASC, t.sort_1 ASC")
+}
+
+func TestGetTransactions_SortAppliedOutsideDistinctOn(t *testing.T) {
+	repo := newDryRunRepository(t)
+	queries := captureQueries(t, repo)
+
+	_, err := repo.GetTransactions("merchant-1", nil, []string{"payment_tx_log_id", "amount"}, []models.SortParams{{Field: "amount", Direction: "desc"}},
+		models.PaginationParams{Page: 2, Limit: 10, SkipCount: true}, "UTC", "")
+	require.NoError(t, err)
+	require.Len(t, *queries, 1)
+
+	// The DISTINCT ON subquery is ordered by its key only; amount leads the outer ORDER BY
+	// and the id breaks ties, with the page taken from the ordered result
+	sql := (*queries)[0]
+	assert.True(t, strings.HasPrefix(sql, "SELECT t.* FROM (SELECT DISTINCT ON (p.payment_tx_log_id) "), sql)
+	assert.Contains(t, sql, "p.amount AS sort_0, p.payment_tx_log_id AS sort_1 FROM payment_tx_log p")
+	assert.Contains(t, sql, "ORDER BY p.payment_tx_log_id) AS t ORDER BY t.sort_0 DESC, t.sort_1 ASC LIMIT 10 OFFSET 10")
+}
+
+func TestBuildFieldSelection_PANDisabled(t *testing.T) {
+	repo := newDryRunRepository(t)
+	fields := []string{"payment_tx_log_id", "pan", "bin_id"}
+
+	for _, panFormat := range []string{"", "bin_id_and_pan_id", "pan_id_only"} {
+		t.Setenv("PAN_DISABLED", "false")
+		selection := repo.buildFieldSelection(fields, "UTC", panFormat)
+		assert.Contains(t, selection, "p.pan_id")
+		assert.Contains(t, selection, "p.bin_id as bin_id")
+
+		t.Setenv("PAN_DISABLED", "true")
+		selection = repo.buildFieldSelection(fields, "UTC", panFormat)
+		assert.Equal(t, "p.payment_tx_log_id, '**** **** **** ****' as pan, NULL as bin_id", selection)
+	}
+}
+
+func TestPopulateCurrencyInfo_SymbolFromCurrencyCode(t *testing.T) {
+	repo := newDryRunRepository(t)
+
+	tests := []struct {
+		code, name string
+		symbol     string
+		formatted  string
+	}{
+		{"818", "EGP", "E£", "E£ 12.50"},
+		{"0710", "ZAR", "R", "R 12.50"},
+		{"784", "AED", "AED", "AED 12.50"},
+	}
+
+	for _, tt := range tests {
+		tx := &models.Transaction{Amount: 1250, CurrencyCode: tt.code, CurrencyName: tt.name, CurrDelim: 2}
+		repo.populateCurrencyInfo(tx)
+
+		require.NotNil(t, tx.CurrencyInfo)
+		assert.Equal(t, tt.symbol, tx.CurrencyInfo.Symbol, tt.code)
+		assert.Equal(t, tt.formatted, tx.CurrencyInfo.FormattedAmount, tt.code)
+	}
+}
+
+func TestPostProcessTransactions_PANDisabled(t *testing.T) {
+	repo := newDryRunRepository(t)
+	binID, panID := "41111122", "1111"
+	newRows := func() []models.Transaction {
+		// Rows as loaded by the default selection (p.*), which includes bin_id and pan_id
+		return []models.Transaction{{ID: "tx-1", BinID: &binID, PanID: &panID}}
+	}
+
+	rows := newRows()
+	repo.postProcessTransactions(rows)
+	require.NotNil(t, rows[0].PAN)
+	assert.Equal(t, "4111 11** **** 1111", *rows[0].PAN)
+
+	t.Setenv("PAN_DISABLED", "true")
+	rows = newRows()
+	repo.postProcessTransactions(rows)
+	require.NotNil(t, rows[0].PAN)
+	assert.Equal(t, config.PANPlaceholder, *rows[0].PAN)
+	assert.Nil(t, rows[0].BinID)
+	assert.Nil(t, rows[0].PanID)
+
+	body, err := json.Marshal(rows[0])
+	require.NoError(t, err)
+	assert.NotContains(t, string(body), binID)
+	assert.NotContains(t, string(body), panID)
+}
+
+func TestBuildFieldSelection_DropsFieldsOutsideAllowlist(t *testing.T) {
+	repo := newDryRunRepository(t)
+
+	selection := repo.buildFieldSelection([]string{
+		"amount",
+		"created_at",
+		"1; DROP TABLE payment_tx_log; --",
+		"payment_tx_log_id FROM merchants --",
+	}, "UTC", "")
+
+	assert.Equal(t, "p.amount, p.created_at", selection)
+
+	sql := repo.getDB().ToSQL(func(tx *gorm.DB) *gorm.DB {
+		dryRun := &transactionRepository{postgresDB: tx}
+		return dryRun.buildBaseQuery([]string{"rrn", "rrn, (SELECT password FROM users) as x"}, "UTC", "").Find(&[]models.Transaction{})
+	})
+	assert.Contains(t, sql, "p.rrn as rrn")
+	assert.NotContains(t, sql, "users")
+}
+
+func TestApplySorting_DropsFieldsOutsideAllowlist(t *testing.T) {
+	repo := newDryRunRepository(t)
+	sort := []models.SortParams{
+		{Field: "amount", Direction: "desc"},
+		{Field: "(SELECT 1); DROP TABLE payment_tx_log", Direction: "asc"},
+	}
+
+	sql := repo.applySortingWithDistinct(repo.buildCountQuery(), sort, "p.updated_at").Find(&[]models.Transaction{}).Statement.SQL.String()
+	assert.Contains(t, sql, "p.amount AS sort_0, p.payment_tx_log_id AS sort_1")
+	assert.Contains(t, sql, "ORDER BY t.sort_0 DESC, t.sort_1 ASC")
+	assert.NotContains(t, sql, "DROP")
+
+	sql = repo.applySorting(repo.buildCountQuery(), sort).Find(&[]models.Transaction{}).Statement.SQL.String()
+	assert.Contains(t, sql, "ORDER BY p.amount DESC")
+	assert.NotContains(t, sql, "DROP")
+}
+
+// captureQueries records the SQL of every query run through the repository's database
+func captureQueries(t *testing.T, repo *transactionRepository) *[]string {
+	t.Helper()
+
+	var queries []string
+	err := repo.postgresDB.Callback().Query().After("gorm:query").Register("test:capture", func(tx *gorm.DB) {
+		// A subquery is built through the query callbacks too, but has no destination
+		if tx.Statement.Dest == nil {
+			return
+		}
+		queries = append(queries, tx.Statement.SQL.String())
+	})
+	require.NoError(t, err)
+
+	return &queries
+}
+
+func TestGetTransactions_CursorMode(t *testing.T) {
+	repo := newDryRunRepository(t)
+	queries := captureQueries(t, repo)
+
+	cursor := &models.TransactionCursor{UpdatedAt: time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC), ID: "tx-9"}
+	result, err := repo.GetTransactions("merchant-1", nil, []string{"amount"}, nil, models.PaginationParams{
+		Page: 1, Limit: 50, SkipCount: true, CursorMode: true, Cursor: cursor,
+	}, "UTC", "")
+
+	require.NoError(t, err)
+	require.Len(t, *queries, 1, "the count query must be skipped")
+	sql := (*queries)[0]
+
+	assert.Contains(t, sql, "DISTINCT ON (p.updated_at, p.payment_tx_log_id)")
+	assert.Contains(t, sql, "p.payment_tx_log_id, p.updated_at FROM")
+	assert.Contains(t, sql, "(p.updated_at, p.payment_tx_log_id) < ($3, $4)")
+	assert.Contains(t, sql, "ORDER BY p.updated_at DESC, p.payment_tx_log_id DESC LIMIT 50")
+	assert.NotContains(t, sql, "OFFSET")
+	assert.Equal(t, []string{"amount"}, result.RequestedFields)
+	assert.Empty(t, result.NextCursor)
+}
+
+func TestPollTransactions_KeysetQuery(t *testing.T) {
+	repo := newDryRunRepository(t)
+	queries := captureQueries(t, repo)
+
+	lastSeen := &models.TransactionCursor{UpdatedAt: time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC), ID: "tx-9"}
+	_, err := repo.PollTransactions(context.Background(), "merchant-1", nil, []string{"amount"}, lastSeen, 100, "UTC", "")
+	require.NoError(t, err)
+
+	_, err = repo.PollTransactions(context.Background(), "merchant-1", nil, []string{"amount"}, nil, 100, "UTC", "")
+	require.NoError(t, err)
+
+	require.Len(t, *queries, 2, "a poll must not run a count query")
+	sql := (*queries)[0]
+	assert.Contains(t, sql, "DISTINCT ON (p.updated_at, p.payment_tx_log_id)")
+	assert.Contains(t, sql, "p.payment_tx_log_id, p.updated_at FROM")
+	assert.Contains(t, sql, "m.merchant_id = $1 OR m.provisioner_id = $2")
+	// Rows at lastSeen's updated_at with a greater id are still returned
+	assert.Contains(t, sql, "(p.updated_at, p.payment_tx_log_id) > ($3, $4)")
+	assert.Contains(t, sql, "ORDER BY p.updated_at ASC, p.payment_tx_log_id ASC LIMIT 100")
+	assert.NotContains(t, sql, "OFFSET")
+
+	assert.NotContains(t, (*queries)[1], "(p.updated_at, p.payment_tx_log_id) >")
+	assert.Contains(t, (*queries)[1], "LIMIT 100")
+}
+
+func TestGetTransactions_PageModeUsesOffset(t *testing.T) {
+	repo := newDryRunRepository(t)
+	queries := captureQueries(t, repo)
+
+	_, err := repo.GetTransactions("merchant-1", nil, nil, nil, models.PaginationParams{
+		Page: 3, Limit: 50, SkipCount: true,
+	}, "UTC", "")
+
+	require.NoError(t, err)
+	require.Len(t, *queries, 1)
+	assert.Contains(t, (*queries)[0], "DISTINCT ON (p.payment_tx_log_id)")
+	assert.Contains(t, (*queries)[0], "LIMIT 50 OFFSET 100")
+}
+
+func TestGetDailyCounts_Query(t *testing.T) {
+	repo := newDryRunRepository(t)
+	queries := captureQueries(t, repo)
+	responseCode := "00"
+
+	facets, err := repo.GetDailyCounts("merchant-1", &models.TransactionFilter{ResponseCode: &responseCode}, "Africa/Johannesburg")
+
+	require.NoError(t, err)
+	assert.NotNil(t, facets)
+	require.Len(t, *queries, 1)
+	sql := (*queries)[0]
+	assert.Contains(t, sql, "TO_CHAR(TIMEZONE($1, p.updated_at), 'YYYY-MM-DD') AS date, COUNT(DISTINCT p.payment_tx_log_id) AS count")
+	assert.Contains(t, sql, "p.result_code = $4")
+	assert.Contains(t, sql, "GROUP BY \"date\" ORDER BY date")
+}
+
+func TestGetDateBounds_Query(t *testing.T) {
+	repo := newDryRunRepository(t)
+	queries := captureQueries(t, repo)
+
+	_, _, err := repo.GetDateBounds("merchant-1", &models.TransactionFilter{DateField: models.DateFieldCreatedAt})
+
+	require.NoError(t, err)
+	require.Len(t, *queries, 1)
+	sql := (*queries)[0]
+	assert.Contains(t, sql, "MIN(p.created_at) AS earliest, MAX(p.created_at) AS latest")
+	assert.Contains(t, sql, "m.merchant_id = $1 OR m.provisioner_id = $2")
+	assert.NotContains(t, sql, "p.active", "the bounds ignore the request's filters")
+}
+
+func TestGetTransactionTotals_OrderedByType(t *testing.T) {
+	repo := newDryRunRepository(t)
+	queries := captureQueries(t, repo)
+
+	_, err := repo.GetTransactionTotals("merchant-1", models.TransactionTotalsRequest{Date: "2025-01-15"})
+
+	require.NoError(t, err)
+	require.Len(t, *queries, 1)
+	assert.Contains(t, (*queries)[0], "ORDER BY p.payment_tx_type_id, pt.name")
+}
+
+func TestBuildLookupQuery_OrderedByDescription(t *testing.T) {
+	for _, deviceID := range []string{"", "DEV1"} {
+		query, args := buildLookupQuery(models.TransactionLookupRequest{Date: "2025-01-15", DeviceID: deviceID})
+
+		assert.Regexp(t, `GROUP BY trx_descr\s+ORDER BY trx_descr\s*$`, query, deviceID)
+		if deviceID == "" {
+			assert.Equal(t, []interface{}{"2025-01-15"}, args)
+		} else {
+			assert.Equal(t, []interface{}{"2025-01-15", "DEV1"}, args)
+		}
+	}
+}
+
+func TestBuildIsoSearchQuery_StableOrder(t *testing.T) {
+	query, args := buildIsoSearchQuery(models.IsoTransactionSearchRequest{Date: "2025-01-15", TrxRRN: "123456"})
+
+	assert.True(t, strings.HasSuffix(query, "AND DATE(trx_datetime) = ? AND trx_rrn = ? ORDER BY trx_datetime, trx_guid"), query)
+	assert.Equal(t, []interface{}{"2025-01-15", "123456"}, args)
+}
+
+func TestBuildIsoSearchQuery_PANDisabled(t *testing.T) {
+	query, _ := buildIsoSearchQuery(models.IsoTransactionSearchRequest{Date: "2025-01-15"})
+	assert.Contains(t, query, `LEFT(TRIM(BOTH '"' FROM JSON_EXTRACT(trx_snd, '$."35"')), 6) AS BIN`)
+
+	t.Setenv("PAN_DISABLED", "true")
+	query, _ = buildIsoSearchQuery(models.IsoTransactionSearchRequest{Date: "2025-01-15"})
+	assert.Contains(t, query, "'' AS BIN,")
+	assert.Contains(t, query, "'' AS PANID,")
+	assert.NotContains(t, query, `'$."35"'`)
+}
+
+func TestBuildIsoQueries_DateRange(t *testing.T) {
+	query, args := buildLookupQuery(models.TransactionLookupRequest{DateFrom: "2025-01-01", DateTo: "2025-01-31"})
+	assert.Contains(t, query, "WHERE DATE(trx_datetime) BETWEEN ? AND ?")
+	assert.Equal(t, []interface{}{"2025-01-01", "2025-01-31"}, args)
+
+	query, args = buildIsoSearchQuery(models.IsoTransactionSearchRequest{DateFrom: "2025-01-01", DateTo: "2025-01-31", TrxRRN: "123456"})
+	assert.Contains(t, query, "AND DATE(trx_datetime) BETWEEN ? AND ? AND trx_rrn = ?")
+	assert.Equal(t, []interface{}{"2025-01-01", "2025-01-31", "123456"}, args)
+}
+
+func TestIsMerchantUnderProvisioner_Query(t *testing.T) {
+	repo := newDryRunRepository(t)
+	queries := captureQueries(t, repo)
+
+	_, err := repo.IsMerchantUnderProvisioner("provisioner-1", "sub-1")
+
+	require.NoError(t, err)
+	require.Len(t, *queries, 1)
+	sql := (*queries)[0]
+	assert.Contains(t, sql, "JOIN merchants pr ON pr.merchant_id = m.provisioner_id")
+	assert.Contains(t, sql, "m.merchant_id = $1 AND m.provisioner_id = $2 AND pr.is_provisioner = true")
+}
+
+func TestGetTimeseries_Query(t *testing.T) {
+	repo := newDryRunRepository(t)
+	queries := captureQueries(t, repo)
+	responseCode := "00"
+
+	buckets, err := repo.GetTimeseries("merchant-1", &models.TransactionFilter{ResponseCode: &responseCode}, "hour", "Africa/Johannesburg", 101)
+
+	require.NoError(t, err)
+	assert.NotNil(t, buckets)
+	require.Len(t, *queries, 1)
+	sql := (*queries)[0]
+	assert.Contains(t, sql, `TO_CHAR(DATE_TRUNC($1, TIMEZONE($2, p.updated_at)), 'YYYY-MM-DD"T"HH24:MI:SS') AS bucket`)
+	assert.Contains(t, sql, "COUNT(*) AS count")
+	assert.Contains(t, sql, "COALESCE(SUM(p.amount), 0) AS total_amount FROM (SELECT DISTINCT ON (p.payment_tx_log_id) p.payment_tx_log_id, p.amount, p.updated_at FROM payment_tx_log p")
+	assert.Contains(t, sql, "(m.merchant_id = $3 OR m.provisioner_id = $4) AND p.result_code = $5")
+	assert.Contains(t, sql, `) AS p GROUP BY "bucket" ORDER BY bucket LIMIT 101`)
+}
+
+func TestGetMerchantDevices_Query(t *testing.T) {
+	repo := newDryRunRepository(t)
+	queries := captureQueries(t, repo)
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	devices, err := repo.GetMerchantDevices("merchant-1", &models.TransactionFilter{DateTimeFrom: &from})
+
+	require.NoError(t, err)
+	assert.NotNil(t, devices)
+	require.Len(t, *queries, 1)
+	sql := (*queries)[0]
+	assert.Contains(t, sql, "SELECT p.device_id, p.terminal_id")
+	assert.Contains(t, sql, "COUNT(DISTINCT p.payment_tx_log_id) AS transaction_count")
+	assert.Contains(t, sql, "MAX(p.updated_at) AS last_transaction_at")
+	assert.Contains(t, sql, "(m.merchant_id = $1 OR m.provisioner_id = $2) AND p.updated_at >= $3")
+	assert.Contains(t, sql, "GROUP BY p.device_id, p.terminal_id ORDER BY last_transaction_at DESC, p.device_id, p.terminal_id")
+}
+
+func TestGetTransactions_IncludeTotals(t *testing.T) {
+	repo := newDryRunRepository(t)
+	queries := captureQueries(t, repo)
+	responseCode := "00"
+
+	result, err := repo.GetTransactions("merchant-1", &models.TransactionFilter{ResponseCode: &responseCode}, nil, nil,
+		models.PaginationParams{Page: 1, Limit: 10, IncludeTotals: true}, "UTC", "")
+
+	require.NoError(t, err)
+	require.Len(t, *queries, 2)
+	totalsSQL := (*queries)[0]
+	assert.Contains(t, totalsSQL, "SELECT COUNT(*) AS total_count, COALESCE(SUM(p.amount), 0) AS total_amount FROM (SELECT DISTINCT ON (p.payment_tx_log_id) p.payment_tx_log_id, p.amount FROM payment_tx_log p")
+	assert.Contains(t, totalsSQL, ") AS p LIMIT 1")
+	assert.Contains(t, totalsSQL, "(m.merchant_id = $1 OR m.provisioner_id = $2) AND p.result_code = $3")
+	assert.NotNil(t, result.TotalAmount)
+	assert.False(t, result.CountSkipped)
+}
+
+func TestTransactionCounts_DistinctAcrossMultiplyingJoins(t *testing.T) {
+	repo := newDryRunRepository(t)
+	queries := captureQueries(t, repo)
+
+	// The merchant join can return several rows for one transaction, so a plain COUNT(*)
+	// over-counts. The count must match the rows the data query returns, which are
+	// deduplicated by DISTINCT ON (p.payment_tx_log_id); otherwise clients page past the end
+	_, err := repo.GetTransactions("merchant-1", &models.TransactionFilter{}, nil, nil,
+		models.PaginationParams{Page: 1, Limit: 10}, "UTC", "")
+	require.NoError(t, err)
+	require.Len(t, *queries, 2)
+	assert.Contains(t, (*queries)[1], "SELECT DISTINCT ON (p.payment_tx_log_id) ")
+
+	_, err = repo.GetTransactionCount("merchant-1", &models.TransactionFilter{})
+	require.NoError(t, err)
+
+	var countQueries []string
+	for _, sql := range *queries {
+		if strings.HasPrefix(sql, "SELECT count(") || strings.HasPrefix(sql, "SELECT COUNT(") {
+			countQueries = append(countQueries, sql)
+		}
+	}
+	require.Len(t, countQueries, 2)
+	for _, sql := range countQueries {
+		assert.Contains(t, sql, `COUNT(DISTINCT("p"."payment_tx_log_id"))`)
+		assert.Contains(t, sql, "LEFT JOIN merchants m ON p.merchant_id = m.merchant_id")
+		assert.NotContains(t, sql, "currency")
+		assert.NotContains(t, sql, "count(*)")
+		assert.NotContains(t, sql, "DISTINCT ON")
+	}
+}
+
+func TestCurrencyJoin_DuplicatedCurrencyCode(t *testing.T) {
+	// currency.curr_code has no unique constraint, so a code can appear twice, e.g.
+	// ('710', 'ZAR', 2) and ('710', 'R', 2). The lateral join returns one of them per
+	// transaction; the plain join returns both and multiplies the transaction's rows.
+	tests := []struct {
+		strategy string
+		join     string
+	}{
+		{"", "LEFT JOIN LATERAL (SELECT curr_short, curr_delim FROM currency WHERE curr_code = p.currency_code ORDER BY curr_short, curr_delim LIMIT 1) c ON true"},
+		{config.CurrencyJoinLateral, "LEFT JOIN LATERAL (SELECT curr_short, curr_delim FROM currency WHERE curr_code = p.currency_code ORDER BY curr_short, curr_delim LIMIT 1) c ON true"},
+		{config.CurrencyJoinDirect, "LEFT JOIN currency c ON p.currency_code = c.curr_code"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.strategy, func(t *testing.T) {
+			t.Setenv("CURRENCY_JOIN_STRATEGY", tt.strategy)
+			repo := newDryRunRepository(t)
+			queries := captureQueries(t, repo)
+
+			_, err := repo.GetTransactions("merchant-1", &models.TransactionFilter{}, []string{"payment_tx_log_id", "currency_info"}, nil,
+				models.PaginationParams{Page: 1, Limit: 10}, "UTC", "")
+			require.NoError(t, err)
+
+			require.Len(t, *queries, 2)
+			assert.NotContains(t, (*queries)[0], "currency")
+			assert.Contains(t, (*queries)[1], tt.join)
+			assert.Contains(t, (*queries)[1], "c.curr_short as currency_name, c.curr_delim")
+		})
+	}
+}
+
+func TestGetTransactions_TotalsOnlySkipsRowQuery(t *testing.T) {
+	repo := newDryRunRepository(t)
+	queries := captureQueries(t, repo)
+
+	result, err := repo.GetTransactions("merchant-1", &models.TransactionFilter{}, nil, nil,
+		models.PaginationParams{Page: 1, Limit: 10, IncludeTotals: true, TotalsOnly: true}, "UTC", "")
+
+	require.NoError(t, err)
+	require.Len(t, *queries, 1)
+	assert.Contains(t, (*queries)[0], "SELECT COUNT(*) AS total_count, COALESCE(SUM(p.amount), 0) AS total_amount FROM (SELECT DISTINCT ON (p.payment_tx_log_id) p.payment_tx_log_id, p.amount FROM payment_tx_log p")
+	assert.Empty(t, result.Transactions)
+	assert.NotNil(t, result.TotalAmount)
+}
+
+func TestGetTransactions_DateField(t *testing.T) {
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		dateField string
+		column    string
+	}{
+		{"", "p.updated_at"},
+		{models.DateFieldUpdatedAt, "p.updated_at"},
+		{models.DateFieldCreatedAt, "p.created_at"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.column+"/"+tt.dateField, func(t *testing.T) {
+			repo := newDryRunRepository(t)
+			queries := captureQueries(t, repo)
+			filter := &models.TransactionFilter{DateTimeFrom: &from, DateField: tt.dateField}
+
+			_, err := repo.GetTransactions("merchant-1", filter, nil, []models.SortParams{{Field: "tx_date_time", Direction: "asc"}},
+				models.PaginationParams{Page: 1, Limit: 10, SkipCount: true}, "UTC", "")
+			require.NoError(t, err)
+			require.Len(t, *queries, 1)
+			assert.Contains(t, (*queries)[0], tt.column+" >= $3")
+			assert.Contains(t, (*queries)[0], tt.column+" AS sort_0")
+			assert.Contains(t, (*queries)[0], "ORDER BY t.sort_0 ASC")
+
+			_, err = repo.GetTransactions("merchant-1", filter, nil, nil,
+				models.PaginationParams{Page: 1, Limit: 10, SkipCount: true}, "UTC", "")
+			require.NoError(t, err)
+			assert.Contains(t, (*queries)[1], tt.column+" AS sort_0")
+			assert.Contains(t, (*queries)[1], "ORDER BY t.sort_0 DESC")
+		})
+	}
+}
+
+func TestGetMerchantSummaries_GroupedQuery(t *testing.T) {
+	repo := newDryRunRepository(t)
+	queries := captureQueries(t, repo)
+	responseCode := "00"
+
+	summaries, total, err := repo.GetMerchantSummaries("provisioner-1", &models.TransactionFilter{ResponseCode: &responseCode},
+		models.PaginationParams{Page: 2, Limit: 25})
+
+	require.NoError(t, err)
+	assert.NotNil(t, summaries)
+	assert.Zero(t, total)
+	require.Len(t, *queries, 2)
+
+	countSQL, summarySQL := (*queries)[0], (*queries)[1]
+	assert.Contains(t, countSQL, `COUNT(DISTINCT("p"."merchant_id"))`)
+	assert.Contains(t, countSQL, "(m.merchant_id = $1 OR m.provisioner_id = $2)")
+
+	assert.Contains(t, summarySQL, "(m.merchant_id = $1 OR m.provisioner_id = $2)")
+	assert.Contains(t, summarySQL, "p.result_code = $3")
+	assert.Contains(t, summarySQL, `GROUP BY "p"."merchant_id" ORDER BY p.merchant_id LIMIT 25 OFFSET 25`)
+}
+
+func TestGetMerchantSummary_ResponseCodeBreakdownQuery(t *testing.T) {
+	repo := newDryRunRepository(t)
+	queries := captureQueries(t, repo)
+	currency := "ZAR"
+
+	summary, err := repo.GetMerchantSummary("merchant-1", &models.TransactionFilter{CurrencyCode: &currency}, nil)
+
+	require.NoError(t, err)
+	assert.NotNil(t, summary.ResponseCodeBreakdown)
+	require.Len(t, *queries, 3)
+
+	breakdownSQL := (*queries)[1]
+	assert.Contains(t, breakdownSQL, "COALESCE(p.result_code, 'unknown') AS result_code, COUNT(*) AS count")
+	assert.Contains(t, breakdownSQL, "(m.merchant_id = $1 OR m.provisioner_id = $2) AND p.currency_code = $3")
+	assert.Contains(t, breakdownSQL, `GROUP BY "p"."result_code"`)
+}
+
+func TestGetMerchantSummary_SelectsOnlyRequestedMetrics(t *testing.T) {
+	repo := newDryRunRepository(t)
+	queries := captureQueries(t, repo)
+
+	_, err := repo.GetMerchantSummary("merchant-1", nil, models.SummaryMetrics{models.SummaryMetricSum})
+
+	require.NoError(t, err)
+	require.Len(t, *queries, 2, "the response code breakdown is only queried for the count metric")
+
+	summarySQL := (*queries)[0]
+	assert.Contains(t, summarySQL, "SUM(COALESCE(p.amount, 0)) as total_amount")
+	assert.NotContains(t, summarySQL, "successful_transactions")
+	assert.NotContains(t, summarySQL, "MIN(p.updated_at)")
+}
+
+func TestGetMerchantSummary_MerchantCurrencyQuery(t *testing.T) {
+	repo := newDryRunRepository(t)
+	queries := captureQueries(t, repo)
+
+	_, err := repo.GetMerchantSummary("merchant-1", nil, models.SummaryMetrics{models.SummaryMetricSum})
+
+	require.NoError(t, err)
+	require.Len(t, *queries, 2)
+	currencySQL := (*queries)[1]
+	assert.Contains(t, currencySQL, "SELECT m.currency_code, c.curr_short, c.curr_delim FROM merchants m")
+	assert.Contains(t, currencySQL, "WHERE curr_code = m.currency_code ORDER BY curr_short, curr_delim LIMIT 1) c ON true")
+	assert.Contains(t, currencySQL, "WHERE m.merchant_id = $1")
+}
+
+func TestBuildSummarySelection_AllMetricsWhenOmitted(t *testing.T) {
+	selection := buildSummarySelection(nil)
+
+	assert.Contains(t, selection, "COUNT(*) as total_transactions")
+	assert.Contains(t, selection, "as successful_transactions")
+	assert.Contains(t, selection, "as total_amount")
+	assert.Contains(t, selection, "MIN(p.updated_at) as min_date, MAX(p.updated_at) as max_date")
+
+	selection = buildSummarySelection(models.SummaryMetrics{models.SummaryMetricDateRange})
+	assert.Equal(t, "m.merchant_id, m.name as merchant_name, MIN(p.updated_at) as min_date, MAX(p.updated_at) as max_date", selection)
+}
+
+func TestGetTransactions_DebugSQLUsesPlaceholders(t *testing.T) {
+	repo := newDryRunRepository(t)
+	responseCode := "91"
+
+	result, err := repo.GetTransactions("merchant-1", &models.TransactionFilter{ResponseCode: &responseCode}, nil, nil,
+		models.PaginationParams{Page: 1, Limit: 10, SkipCount: true, DebugSQL: true}, "UTC", "")
+
+	require.NoError(t, err)
+	assert.Contains(t, result.DebugSQL, "(m.merchant_id = $1 OR m.provisioner_id = $2) AND p.result_code = $3")
+	assert.NotContains(t, result.DebugSQL, "merchant-1")
+	assert.NotContains(t, result.DebugSQL, "91")
+
+	result, err = repo.GetTransactions("merchant-1", nil, nil, nil, models.PaginationParams{Page: 1, Limit: 10, SkipCount: true}, "UTC", "")
+	require.NoError(t, err)
+	assert.Empty(t, result.DebugSQL)
+}
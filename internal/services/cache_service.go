@@ -3,11 +3,17 @@ package services
 import (
 	"context"
 	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
+	"sync/atomic"
 	"time"
 
 	"aken_reporting_service/internal/config"
+	"aken_reporting_service/internal/database"
 	"aken_reporting_service/internal/models"
 	"aken_reporting_service/internal/repositories"
 
@@ -36,11 +42,25 @@ type CacheService interface {
 	Delete(key string) error
 	DeletePattern(pattern string) error
 
+	// Rate limiting
+	AllowRequest(key string, limit int, window time.Duration) (*RateLimitResult, error)
+
 	// Health check
 	Ping() error
 	Close() error
 }
 
+// ErrCacheDisabled is returned by operations that need Redis when caching is disabled
+var ErrCacheDisabled = errors.New("cache is disabled")
+
+// RateLimitResult is the outcome of counting a request against a sliding window
+type RateLimitResult struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	ResetAt   time.Time // When the oldest counted request leaves the window
+}
+
 type cacheService struct {
 	client *redis.Client
 	prefix string
@@ -252,11 +272,116 @@ func (c *cacheService) DeletePattern(pattern string) error {
 	return iter.Err()
 }
 
+// slidingWindowScript counts a request in a sorted-set sliding window. Requests over the
+// limit are not recorded, so rejected retries don't extend the wait.
+var slidingWindowScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+redis.call('ZREMRANGEBYSCORE', KEYS[1], 0, now - window)
+local count = redis.call('ZCARD', KEYS[1])
+local allowed = 0
+if count < limit then
+	redis.call('ZADD', KEYS[1], now, ARGV[4])
+	count = count + 1
+	allowed = 1
+end
+redis.call('PEXPIRE', KEYS[1], window)
+local reset = now + window
+local oldest = redis.call('ZRANGE', KEYS[1], 0, 0, 'WITHSCORES')
+if oldest[2] then
+	reset = tonumber(oldest[2]) + window
+end
+return {allowed, count, reset}
+`)
+
+// rateLimitSequence makes sliding window members unique within the same millisecond
+var rateLimitSequence uint64
+
+// rateLimitInstance makes sliding window members unique across the instances sharing Redis,
+// whose sequences can reach the same value in the same millisecond
+var rateLimitInstance = newRateLimitInstance()
+
+// newRateLimitInstance returns a random identifier for this instance, falling back to the
+// process id and start time if no random bytes are available
+func newRateLimitInstance() string {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return fmt.Sprintf("%d.%d", os.Getpid(), time.Now().UnixNano())
+	}
+	return hex.EncodeToString(id)
+}
+
+// rateLimitMember returns a sliding window member for a request counted at now
+func rateLimitMember(now int64) string {
+	return fmt.Sprintf("%d-%s-%d", now, rateLimitInstance, atomic.AddUint64(&rateLimitSequence, 1))
+}
+
+// AllowRequest counts a request for key against limit over a sliding window
+func (c *cacheService) AllowRequest(key string, limit int, window time.Duration) (*RateLimitResult, error) {
+	cacheKey := c.prefix + ":ratelimit:" + key
+	now := time.Now().UnixMilli()
+	member := rateLimitMember(now)
+
+	values, err := slidingWindowScript.Run(c.ctx, c.client, []string{cacheKey}, now, window.Milliseconds(), limit, member).Int64Slice()
+	if err != nil {
+		return nil, fmt.Errorf("failed to check rate limit: %v", err)
+	}
+	if len(values) != 3 {
+		return nil, fmt.Errorf("unexpected rate limit response: %v", values)
+	}
+
+	remaining := limit - int(values[1])
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	return &RateLimitResult{
+		Allowed:   values[0] == 1,
+		Limit:     limit,
+		Remaining: remaining,
+		ResetAt:   time.UnixMilli(values[2]),
+	}, nil
+}
+
 // Ping tests Redis connection
 func (c *cacheService) Ping() error {
 	return c.client.Ping(c.ctx).Err()
 }
 
+// CheckCacheHealth pings the cache and reports it in the database health shape. Status is
+// "disabled" when Redis caching is turned off, and "unhealthy" when Redis is configured but
+// could not be reached, including when the cache service failed to start (cacheService is nil).
+func CheckCacheHealth(cacheService CacheService) database.HealthStatus {
+	start := time.Now()
+
+	var err error
+	if cacheService == nil {
+		err = fmt.Errorf("cache service was not initialized")
+		if !config.IsRedisEnabled() {
+			err = ErrCacheDisabled
+		}
+	} else {
+		err = cacheService.Ping()
+	}
+
+	status := database.HealthStatus{
+		Status:    "healthy",
+		Message:   "Redis is responding normally",
+		Timestamp: time.Now(),
+		Latency:   time.Since(start).Milliseconds(),
+	}
+	switch {
+	case errors.Is(err, ErrCacheDisabled):
+		status.Status = "disabled"
+		status.Message = "Redis caching is disabled"
+	case err != nil:
+		status.Status = "unhealthy"
+		status.Message = "Redis is unavailable; serving without cache"
+	}
+	return status
+}
+
 // Close closes Redis connection
 func (c *cacheService) Close() error {
 	return c.client.Close()
@@ -289,5 +414,8 @@ func (n *noOpCacheService) Get(key string, dest interface{}) error
 func (n *noOpCacheService) Set(key string, value interface{}, ttl time.Duration) error { return nil }
 func (n *noOpCacheService) Delete(key string) error                                    { return nil }
 func (n *noOpCacheService) DeletePattern(pattern string) error                         { return nil }
-func (n *noOpCacheService) Ping() error                                                { return nil }
-func (n *noOpCacheService) Close() error                                               { return nil }
+func (n *noOpCacheService) AllowRequest(key string, limit int, window time.Duration) (*RateLimitResult, error) {
+	return nil, ErrCacheDisabled
+}
+func (n *noOpCacheService) Ping() error  { return ErrCacheDisabled }
+func (n *noOpCacheService) Close() error { return nil }
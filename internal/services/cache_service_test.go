@@ -1,6 +1,7 @@
 package services
 
 import (
+	"strings"
 	"testing"
 	"time"
 
@@ -233,3 +234,53 @@ func TestCacheService_DeletePattern(t *testing.T) {
 // Helper function to set Redis enabled for testing
 // This would need to be implemented in the config package
 // For now, we'll skip this test
+
+func TestCacheService_AllowRequest(t *testing.T) {
+	// Skip if Redis is not available
+	if !config.IsRedisEnabled() {
+		t.Skip("Redis not available for testing")
+	}
+
+	cacheService, err := NewCacheService()
+	assert.NoError(t, err)
+	defer cacheService.Close()
+
+	key := "test-merchant-" + time.Now().Format("150405.000000")
+	for i := 0; i < 3; i++ {
+		result, err := cacheService.AllowRequest(key, 3, time.Minute)
+		assert.NoError(t, err)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, 2-i, result.Remaining)
+	}
+
+	result, err := cacheService.AllowRequest(key, 3, time.Minute)
+	assert.NoError(t, err)
+	assert.False(t, result.Allowed)
+	assert.Equal(t, 0, result.Remaining)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), result.ResetAt, 2*time.Second)
+}
+
+func TestRateLimitMember(t *testing.T) {
+	first, second := rateLimitMember(1700000000000), rateLimitMember(1700000000000)
+
+	assert.NotEqual(t, first, second)
+	assert.True(t, strings.HasPrefix(first, "1700000000000-"+rateLimitInstance+"-"), first)
+	assert.NotEqual(t, rateLimitInstance, newRateLimitInstance(), "instances must not share an identifier")
+}
+
+func TestCheckCacheHealth(t *testing.T) {
+	assert.Equal(t, "disabled", CheckCacheHealth(&noOpCacheService{}).Status)
+
+	t.Setenv("REDIS_ENABLED", "false")
+	assert.Equal(t, "disabled", CheckCacheHealth(nil).Status)
+
+	t.Setenv("REDIS_ENABLED", "true")
+	health := CheckCacheHealth(nil)
+	assert.Equal(t, "unhealthy", health.Status)
+	assert.Contains(t, health.Message, "unavailable")
+}
+
+func TestNoOpCacheService_AllowRequest(t *testing.T) {
+	_, err := (&noOpCacheService{}).AllowRequest("merchant-1", 10, time.Minute)
+	assert.ErrorIs(t, err, ErrCacheDisabled)
+}
@@ -0,0 +1,352 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"aken_reporting_service/internal/models"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// maxExportJobsPerMerchant bounds the history kept for each merchant
+const maxExportJobsPerMerchant = 100
+
+// ExportJobStore keeps the history of transaction exports per merchant
+type ExportJobStore interface {
+	Create(merchantID, format string) (*models.ExportJob, error)
+	SetDelivery(id, delivery, objectKey string) error
+	Finish(id string, status string, rowCount int) error
+	Get(merchantID, id string) (*models.ExportJob, bool, error)
+	List(merchantID string, page, limit int) ([]models.ExportJob, int, error)
+}
+
+type memoryExportJobStore struct {
+	mu         sync.Mutex
+	byMerchant map[string][]*models.ExportJob // Oldest first
+	byID       map[string]*models.ExportJob
+}
+
+// NewExportJobStore creates the export job store; only the most recent jobs of each merchant
+// are kept. When cache is connected to Redis the jobs are kept there, so any instance can
+// report on an export and history survives restarts; otherwise, as with a nil cache, they
+// are kept in memory per process and lost on restart.
+func NewExportJobStore(cache CacheService) ExportJobStore {
+	if redisCache, ok := cache.(*cacheService); ok {
+		return &redisExportJobStore{
+			client: redisCache.client,
+			prefix: redisCache.prefix + ":export_jobs",
+			ctx:    redisCache.ctx,
+		}
+	}
+	return &memoryExportJobStore{
+		byMerchant: make(map[string][]*models.ExportJob),
+		byID:       make(map[string]*models.ExportJob),
+	}
+}
+
+// Create records a new running export for the merchant
+func (s *memoryExportJobStore) Create(merchantID, format string) (*models.ExportJob, error) {
+	id, err := newJobID()
+	if err != nil {
+		return nil, err
+	}
+
+	job := &models.ExportJob{
+		ID:         id,
+		MerchantID: merchantID,
+		Status:     models.ExportStatusRunning,
+		Format:     format,
+		CreatedAt:  time.Now().UTC(),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	jobs := append(s.byMerchant[merchantID], job)
+	if len(jobs) > maxExportJobsPerMerchant {
+		delete(s.byID, jobs[0].ID)
+		jobs = jobs[1:]
+	}
+	s.byMerchant[merchantID] = jobs
+	s.byID[job.ID] = job
+
+	copied := *job
+	return &copied, nil
+}
+
+// SetDelivery records where the export file is delivered and, for object storage, its key
+func (s *memoryExportJobStore) SetDelivery(id, delivery, objectKey string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.byID[id]
+	if !ok {
+		return nil
+	}
+
+	job.Delivery = delivery
+	job.ObjectKey = objectKey
+	return nil
+}
+
+// Finish records the final status and row count of an export
+func (s *memoryExportJobStore) Finish(id string, status string, rowCount int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.byID[id]
+	if !ok {
+		return nil
+	}
+
+	finishJob(job, status, rowCount)
+	return nil
+}
+
+// Get returns one of the merchant's exports, reporting whether it exists
+func (s *memoryExportJobStore) Get(merchantID, id string) (*models.ExportJob, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.byID[id]
+	if !ok || job.MerchantID != merchantID {
+		return nil, false, nil
+	}
+
+	copied := *job
+	return &copied, true, nil
+}
+
+// List returns a page of the merchant's exports, newest first, and the merchant's total
+func (s *memoryExportJobStore) List(merchantID string, page, limit int) ([]models.ExportJob, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	jobs := s.byMerchant[merchantID]
+	total := len(jobs)
+
+	result := []models.ExportJob{}
+	offset, ok := pageOffset(page, limit, total)
+	if !ok {
+		return result, total, nil
+	}
+	for i := total - 1 - offset; i >= 0 && len(result) < limit; i-- {
+		result = append(result, *jobs[i])
+	}
+
+	return result, total, nil
+}
+
+// redisExportJobStore keeps export jobs in Redis under prefix: a hash of jobs by id and a
+// list of ids per merchant, newest first
+type redisExportJobStore struct {
+	client *redis.Client
+	prefix string
+	ctx    context.Context
+}
+
+// storedExportJob is the Redis encoding of an export job, which keeps the merchant id and
+// object key that the API leaves out
+type storedExportJob struct {
+	models.ExportJob
+	MerchantID string `json:"merchant_id"`
+	ObjectKey  string `json:"object_key,omitempty"`
+}
+
+// createExportJobScript adds a job to the merchant's history and drops the jobs that fall
+// off its end
+var createExportJobScript = redis.NewScript(`
+redis.call('HSET', KEYS[2], ARGV[1], ARGV[2])
+redis.call('LPUSH', KEYS[1], ARGV[1])
+local keep = tonumber(ARGV[3])
+local evicted = redis.call('LRANGE', KEYS[1], keep, -1)
+for _, id in ipairs(evicted) do
+	redis.call('HDEL', KEYS[2], id)
+end
+redis.call('LTRIM', KEYS[1], 0, keep - 1)
+return 1
+`)
+
+// updateExportJobScript stores a job only if it was not evicted meanwhile
+var updateExportJobScript = redis.NewScript(`
+if redis.call('HEXISTS', KEYS[1], ARGV[1]) == 0 then
+	return 0
+end
+redis.call('HSET', KEYS[1], ARGV[1], ARGV[2])
+return 1
+`)
+
+func (s *redisExportJobStore) jobsKey() string { return s.prefix }
+func (s *redisExportJobStore) merchantKey(merchantID string) string {
+	return s.prefix + ":merchant:" + merchantID
+}
+
+// Create records a new running export for the merchant
+func (s *redisExportJobStore) Create(merchantID, format string) (*models.ExportJob, error) {
+	id, err := newJobID()
+	if err != nil {
+		return nil, err
+	}
+
+	job := models.ExportJob{
+		ID:         id,
+		MerchantID: merchantID,
+		Status:     models.ExportStatusRunning,
+		Format:     format,
+		CreatedAt:  time.Now().UTC(),
+	}
+
+	data, err := encodeExportJob(job)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := []string{s.merchantKey(merchantID), s.jobsKey()}
+	if err := createExportJobScript.Run(s.ctx, s.client, keys, id, data, maxExportJobsPerMerchant).Err(); err != nil {
+		return nil, fmt.Errorf("failed to store export job: %v", err)
+	}
+
+	return &job, nil
+}
+
+// SetDelivery records where the export file is delivered and, for object storage, its key
+func (s *redisExportJobStore) SetDelivery(id, delivery, objectKey string) error {
+	return s.update(id, func(job *models.ExportJob) {
+		job.Delivery = delivery
+		job.ObjectKey = objectKey
+	})
+}
+
+// Finish records the final status and row count of an export
+func (s *redisExportJobStore) Finish(id string, status string, rowCount int) error {
+	return s.update(id, func(job *models.ExportJob) {
+		finishJob(job, status, rowCount)
+	})
+}
+
+// Get returns one of the merchant's exports, reporting whether it exists
+func (s *redisExportJobStore) Get(merchantID, id string) (*models.ExportJob, bool, error) {
+	jobs, err := s.load([]string{id})
+	if err != nil {
+		return nil, false, err
+	}
+	if len(jobs) == 0 || jobs[0].MerchantID != merchantID {
+		return nil, false, nil
+	}
+	return &jobs[0], true, nil
+}
+
+// List returns a page of the merchant's exports, newest first, and the merchant's total
+func (s *redisExportJobStore) List(merchantID string, page, limit int) ([]models.ExportJob, int, error) {
+	total, err := s.client.LLen(s.ctx, s.merchantKey(merchantID)).Result()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count export jobs: %v", err)
+	}
+
+	start, ok := pageOffset(page, limit, int(total))
+	if !ok {
+		return []models.ExportJob{}, int(total), nil
+	}
+
+	ids, err := s.client.LRange(s.ctx, s.merchantKey(merchantID), int64(start), int64(start+limit-1)).Result()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list export jobs: %v", err)
+	}
+
+	jobs, err := s.load(ids)
+	if err != nil {
+		return nil, 0, err
+	}
+	return jobs, int(total), nil
+}
+
+// update applies change to a stored job; jobs that no longer exist are left alone
+func (s *redisExportJobStore) update(id string, change func(job *models.ExportJob)) error {
+	jobs, err := s.load([]string{id})
+	if err != nil || len(jobs) == 0 {
+		return err
+	}
+
+	job := jobs[0]
+	change(&job)
+
+	data, err := encodeExportJob(job)
+	if err != nil {
+		return err
+	}
+
+	if err := updateExportJobScript.Run(s.ctx, s.client, []string{s.jobsKey()}, id, data).Err(); err != nil {
+		return fmt.Errorf("failed to update export job: %v", err)
+	}
+	return nil
+}
+
+// load reads the jobs with ids in order, skipping any that no longer exist
+func (s *redisExportJobStore) load(ids []string) ([]models.ExportJob, error) {
+	result := []models.ExportJob{}
+	if len(ids) == 0 {
+		return result, nil
+	}
+
+	values, err := s.client.HMGet(s.ctx, s.jobsKey(), ids...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read export jobs: %v", err)
+	}
+
+	for _, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			continue
+		}
+		var stored storedExportJob
+		if err := json.Unmarshal([]byte(data), &stored); err != nil {
+			return nil, fmt.Errorf("failed to decode export job: %v", err)
+		}
+		stored.ExportJob.MerchantID = stored.MerchantID
+		stored.ExportJob.ObjectKey = stored.ObjectKey
+		result = append(result, stored.ExportJob)
+	}
+
+	return result, nil
+}
+
+// pageOffset returns the offset of the first item of page in a list of total items. It
+// reports false for pages past the end, without computing (page-1)*limit, which overflows
+// for huge pages.
+func pageOffset(page, limit, total int) (int, bool) {
+	if page < 1 || limit < 1 || page-1 >= (total+limit-1)/limit {
+		return 0, false
+	}
+	return (page - 1) * limit, true
+}
+
+func encodeExportJob(job models.ExportJob) (string, error) {
+	data, err := json.Marshal(storedExportJob{ExportJob: job, MerchantID: job.MerchantID, ObjectKey: job.ObjectKey})
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// finishJob sets the final status and row count of job, completed now
+func finishJob(job *models.ExportJob, status string, rowCount int) {
+	now := time.Now().UTC()
+	job.Status = status
+	job.RowCount = rowCount
+	job.CompletedAt = &now
+}
+
+// newJobID returns a random identifier for export jobs and report schedules
+func newJobID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
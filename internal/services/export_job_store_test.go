@@ -0,0 +1,149 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"aken_reporting_service/internal/config"
+	"aken_reporting_service/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// listJobs returns a page of the merchant's export jobs, failing the test on a store error
+func listJobs(t *testing.T, store ExportJobStore, merchantID string, page, limit int) ([]models.ExportJob, int) {
+	t.Helper()
+	jobs, total, err := store.List(merchantID, page, limit)
+	require.NoError(t, err)
+	return jobs, total
+}
+
+func TestExportJobStore_ListIsScopedPerMerchant(t *testing.T) {
+	store := NewExportJobStore(nil)
+
+	job, err := store.Create("merchant-1", "csv")
+	require.NoError(t, err)
+	_, err = store.Create("merchant-2", "xlsx")
+	require.NoError(t, err)
+
+	require.NoError(t, store.Finish(job.ID, models.ExportStatusCompleted, 42))
+
+	jobs, total := listJobs(t, store, "merchant-1", 1, 10)
+	assert.Equal(t, 1, total)
+	require.Len(t, jobs, 1)
+	assert.Equal(t, job.ID, jobs[0].ID)
+	assert.Equal(t, models.ExportStatusCompleted, jobs[0].Status)
+	assert.Equal(t, 42, jobs[0].RowCount)
+	assert.NotNil(t, jobs[0].CompletedAt)
+
+	jobs, total = listJobs(t, store, "merchant-3", 1, 10)
+	assert.Zero(t, total)
+	assert.Empty(t, jobs)
+}
+
+func TestExportJobStore_ListPaginatesNewestFirst(t *testing.T) {
+	store := NewExportJobStore(nil)
+
+	var ids []string
+	for i := 0; i < 5; i++ {
+		job, err := store.Create("merchant-1", "csv")
+		require.NoError(t, err)
+		ids = append(ids, job.ID)
+	}
+
+	jobs, total := listJobs(t, store, "merchant-1", 1, 2)
+	assert.Equal(t, 5, total)
+	require.Len(t, jobs, 2)
+	assert.Equal(t, ids[4], jobs[0].ID)
+	assert.Equal(t, ids[3], jobs[1].ID)
+
+	jobs, _ = listJobs(t, store, "merchant-1", 3, 2)
+	require.Len(t, jobs, 1)
+	assert.Equal(t, ids[0], jobs[0].ID)
+
+	jobs, _ = listJobs(t, store, "merchant-1", 4, 2)
+	assert.Empty(t, jobs)
+
+	// (page-1)*limit overflows for a page this large
+	jobs, total = listJobs(t, store, "merchant-1", 4611686018427387904, 4)
+	assert.Equal(t, 5, total)
+	assert.Empty(t, jobs)
+}
+
+func TestExportJobStore_KeepsMostRecentJobs(t *testing.T) {
+	store := NewExportJobStore(nil)
+
+	first, err := store.Create("merchant-1", "csv")
+	require.NoError(t, err)
+	for i := 0; i < maxExportJobsPerMerchant; i++ {
+		_, err := store.Create("merchant-1", "csv")
+		require.NoError(t, err)
+	}
+
+	jobs, total := listJobs(t, store, "merchant-1", 1, maxExportJobsPerMerchant)
+	assert.Equal(t, maxExportJobsPerMerchant, total)
+	for _, job := range jobs {
+		assert.NotEqual(t, first.ID, job.ID)
+	}
+}
+
+func TestExportJobStore_GetIsScopedPerMerchant(t *testing.T) {
+	store := NewExportJobStore(nil)
+
+	job, err := store.Create("merchant-1", "csv")
+	require.NoError(t, err)
+	require.NoError(t, store.SetDelivery(job.ID, models.ExportDeliveryS3, "merchant-1/"+job.ID+".csv"))
+
+	got, ok, err := store.Get("merchant-1", job.ID)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, models.ExportDeliveryS3, got.Delivery)
+	assert.Equal(t, "merchant-1/"+job.ID+".csv", got.ObjectKey)
+
+	_, ok, err = store.Get("merchant-2", job.ID)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	_, ok, err = store.Get("merchant-1", "missing")
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestExportJobStore_RedisSharedBetweenInstances(t *testing.T) {
+	if !config.IsRedisEnabled() {
+		t.Skip("Redis not available for testing")
+	}
+	cacheService, err := NewCacheService()
+	if err != nil {
+		t.Skipf("Redis not reachable: %v", err)
+	}
+	defer cacheService.Close()
+
+	// Two stores on one Redis stand in for two instances of the service
+	first := NewExportJobStore(cacheService)
+	second := NewExportJobStore(cacheService)
+	merchantID := fmt.Sprintf("merchant-%d", time.Now().UnixNano())
+
+	job, err := first.Create(merchantID, "csv")
+	require.NoError(t, err)
+	objectKey := merchantID + "/" + job.ID + ".csv"
+	require.NoError(t, first.SetDelivery(job.ID, models.ExportDeliveryS3, objectKey))
+	require.NoError(t, first.Finish(job.ID, models.ExportStatusCompleted, 7))
+
+	got, ok, err := second.Get(merchantID, job.ID)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, models.ExportStatusCompleted, got.Status)
+	assert.Equal(t, 7, got.RowCount)
+	assert.Equal(t, objectKey, got.ObjectKey)
+
+	_, ok, err = second.Get("other-"+merchantID, job.ID)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	jobs, total := listJobs(t, second, merchantID, 1, 10)
+	assert.Equal(t, 1, total)
+	require.Len(t, jobs, 1)
+	assert.Equal(t, job.ID, jobs[0].ID)
+}
@@ -0,0 +1,327 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"aken_reporting_service/internal/config"
+)
+
+// ExportUploader stores finished export files in object storage. Keys are relative to the
+// uploader's configured prefix.
+type ExportUploader interface {
+	Upload(ctx context.Context, key, contentType string, body io.Reader) error
+	PresignGetURL(key string) (string, error)
+}
+
+// ExportObjectKey returns the key an export job's file is uploaded under
+func ExportObjectKey(merchantID, exportID, format string) string {
+	return fmt.Sprintf("%s/%s.%s", merchantID, exportID, format)
+}
+
+// s3PartSize is the size of the parts a large upload is streamed in. S3 requires every part
+// but the last to be at least 5 MiB; only one part is held in memory at a time.
+const s3PartSize = 8 << 20
+
+// s3Uploader uploads to an S3-compatible bucket using path-style URLs and AWS Signature V4
+type s3Uploader struct {
+	cfg      *config.S3Config
+	client   *http.Client
+	now      func() time.Time
+	partSize int
+}
+
+// NewS3Uploader creates an uploader for the configured S3-compatible bucket
+func NewS3Uploader(cfg *config.S3Config) ExportUploader {
+	return &s3Uploader{
+		cfg:      cfg,
+		client:   &http.Client{Timeout: 5 * time.Minute},
+		now:      time.Now,
+		partSize: s3PartSize,
+	}
+}
+
+// Upload stores body under key. A body that fits in one part is sent with a single PUT;
+// a larger one is streamed as a multipart upload, which is aborted if any part fails.
+func (u *s3Uploader) Upload(ctx context.Context, key, contentType string, body io.Reader) error {
+	part := make([]byte, u.partSize)
+	n, err := io.ReadFull(body, part)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		_, _, err = u.send(ctx, http.MethodPut, key, nil, contentType, part[:n])
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	uploadID, err := u.createMultipartUpload(ctx, key, contentType)
+	if err != nil {
+		return err
+	}
+	if err := u.uploadParts(ctx, key, uploadID, body, part); err != nil {
+		// The request context may be what failed, so the abort gets its own
+		abortCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if _, _, abortErr := u.send(abortCtx, http.MethodDelete, key, url.Values{"uploadId": {uploadID}}, "application/octet-stream", nil); abortErr != nil {
+			return fmt.Errorf("%v (aborting the upload also failed: %v)", err, abortErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// createMultipartUpload starts a multipart upload of key and returns its upload id
+func (u *s3Uploader) createMultipartUpload(ctx context.Context, key, contentType string) (string, error) {
+	resp, _, err := u.send(ctx, http.MethodPost, key, url.Values{"uploads": {""}}, contentType, nil)
+	if err != nil {
+		return "", err
+	}
+
+	var result struct {
+		UploadID string `xml:"UploadId"`
+	}
+	if err := xml.Unmarshal(resp, &result); err != nil || result.UploadID == "" {
+		return "", fmt.Errorf("object storage returned no upload id")
+	}
+	return result.UploadID, nil
+}
+
+// uploadParts sends first, which is already full, and the rest of body as the parts of
+// the upload, then completes it
+func (u *s3Uploader) uploadParts(ctx context.Context, key, uploadID string, body io.Reader, first []byte) error {
+	type completedPart struct {
+		PartNumber int    `xml:"PartNumber"`
+		ETag       string `xml:"ETag"`
+	}
+	var parts []completedPart
+
+	part, n := first, len(first)
+	for n > 0 {
+		query := url.Values{"partNumber": {strconv.Itoa(len(parts) + 1)}, "uploadId": {uploadID}}
+		_, header, err := u.send(ctx, http.MethodPut, key, query, "application/octet-stream", part[:n])
+		if err != nil {
+			return err
+		}
+		parts = append(parts, completedPart{PartNumber: len(parts) + 1, ETag: header.Get("ETag")})
+
+		n, err = io.ReadFull(body, part)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+	}
+
+	complete, err := xml.Marshal(struct {
+		XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+		Parts   []completedPart `xml:"Part"`
+	}{Parts: parts})
+	if err != nil {
+		return err
+	}
+
+	resp, _, err := u.send(ctx, http.MethodPost, key, url.Values{"uploadId": {uploadID}}, "application/xml", complete)
+	if err != nil {
+		return err
+	}
+	// S3 can report a failed completion in the body of a 200 response
+	var failure struct {
+		XMLName xml.Name
+		Code    string `xml:"Code"`
+	}
+	if xml.Unmarshal(resp, &failure) == nil && failure.XMLName.Local == "Error" {
+		return fmt.Errorf("object storage failed to complete the upload: %s", failure.Code)
+	}
+	return nil
+}
+
+// send makes a signed request for key and returns the response body and headers, failing
+// on a non-2xx response
+func (u *s3Uploader) send(ctx context.Context, method, key string, query url.Values, contentType string, payload []byte) ([]byte, http.Header, error) {
+	target := u.objectURL(key)
+	target.RawQuery = canonicalQueryString(query)
+
+	req, err := http.NewRequestWithContext(ctx, method, target.String(), bytes.NewReader(payload))
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	payloadHash := sha256.Sum256(payload)
+	u.sign(req, hex.EncodeToString(payloadHash[:]))
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, nil, fmt.Errorf("object storage responded with status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	return body, resp.Header, err
+}
+
+// PresignGetURL returns a download URL for key that is valid for the configured TTL
+func (u *s3Uploader) PresignGetURL(key string) (string, error) {
+	if u.cfg.AccessKeyID == "" || u.cfg.SecretAccessKey == "" {
+		return "", fmt.Errorf("object storage credentials are not configured")
+	}
+
+	return u.presign(u.objectURL(key)), nil
+}
+
+// presign adds the Signature V4 query parameters for a GET of target and returns the URL
+func (u *s3Uploader) presign(target *url.URL) string {
+	now := u.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	scope := u.credentialScope(now)
+
+	query := url.Values{}
+	query.Set("X-Amz-Algorithm", "AWS4-HMAC-SHA256")
+	query.Set("X-Amz-Credential", u.cfg.AccessKeyID+"/"+scope)
+	query.Set("X-Amz-Date", amzDate)
+	query.Set("X-Amz-Expires", strconv.Itoa(int(u.cfg.PresignTTL/time.Second)))
+	query.Set("X-Amz-SignedHeaders", "host")
+
+	canonicalQuery := canonicalQueryString(query)
+	canonicalRequest := strings.Join([]string{
+		http.MethodGet,
+		target.EscapedPath(),
+		canonicalQuery,
+		"host:" + target.Host + "\n",
+		"host",
+		"UNSIGNED-PAYLOAD",
+	}, "\n")
+
+	signature := u.signature(now, amzDate, scope, canonicalRequest)
+	target.RawQuery = canonicalQuery + "&X-Amz-Signature=" + signature
+
+	return target.String()
+}
+
+// objectURL returns the path-style URL of key, below the configured prefix, in the bucket
+func (u *s3Uploader) objectURL(key string) *url.URL {
+	scheme := "https"
+	if !u.cfg.UseSSL {
+		scheme = "http"
+	}
+
+	path := "/" + u.cfg.Bucket + "/" + u.cfg.KeyPrefix + key
+	return &url.URL{
+		Scheme:  scheme,
+		Host:    u.cfg.Endpoint,
+		Path:    path,
+		RawPath: s3EscapePath(path),
+	}
+}
+
+// sign adds the Signature V4 Authorization header to req
+func (u *s3Uploader) sign(req *http.Request, payloadHash string) {
+	now := u.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	scope := u.credentialScope(now)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := "content-type;host;x-amz-content-sha256;x-amz-date"
+	canonicalHeaders := "content-type:" + req.Header.Get("Content-Type") + "\n" +
+		"host:" + req.URL.Host + "\n" +
+		"x-amz-content-sha256:" + payloadHash + "\n" +
+		"x-amz-date:" + amzDate + "\n"
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		u.cfg.AccessKeyID, scope, signedHeaders, u.signature(now, amzDate, scope, canonicalRequest)))
+}
+
+// credentialScope returns <date>/<region>/s3/aws4_request for the signing time
+func (u *s3Uploader) credentialScope(now time.Time) string {
+	return now.Format("20060102") + "/" + u.region() + "/s3/aws4_request"
+}
+
+// signature derives the signing key for the day and signs the canonical request
+func (u *s3Uploader) signature(now time.Time, amzDate, scope, canonicalRequest string) string {
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+u.cfg.SecretAccessKey), now.Format("20060102"))
+	key = hmacSHA256(key, u.region())
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func (u *s3Uploader) region() string {
+	if u.cfg.Region == "" {
+		return "us-east-1"
+	}
+	return u.cfg.Region
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// canonicalQueryString encodes query sorted by key as Signature V4 requires
+func canonicalQueryString(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		parts = append(parts, s3Escape(key, true)+"="+s3Escape(query.Get(key), true))
+	}
+	return strings.Join(parts, "&")
+}
+
+// s3EscapePath percent-encodes an object path, keeping the slashes between segments
+func s3EscapePath(path string) string {
+	return s3Escape(path, false)
+}
+
+// s3Escape percent-encodes everything except unreserved characters (and optionally '/')
+func s3Escape(s string, escapeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !escapeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
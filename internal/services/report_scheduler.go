@@ -0,0 +1,573 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"aken_reporting_service/internal/models"
+	"aken_reporting_service/internal/utils"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// maxReportSchedulesPerMerchant bounds how many schedules a merchant can keep
+const maxReportSchedulesPerMerchant = 20
+
+// ErrTooManySchedules is returned when a merchant already has the maximum number of schedules
+var ErrTooManySchedules = fmt.Errorf("a merchant can have at most %d report schedules", maxReportSchedulesPerMerchant)
+
+// reportContentTypes maps report formats to the content type used for webhook delivery
+var reportContentTypes = map[string]string{
+	"csv":  "text/csv",
+	"xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+// ReportScheduleStore keeps merchants' scheduled reports. Due claims the schedules it
+// returns, so when instances share a store each run happens on one of them.
+type ReportScheduleStore interface {
+	Create(schedule models.ReportSchedule) (*models.ReportSchedule, error)
+	List(merchantID string) ([]models.ReportSchedule, error)
+	Delete(merchantID, id string) (bool, error)
+	Due(now time.Time) ([]models.ReportSchedule, error)
+	RecordRun(id string, ranAt time.Time, status, exportID string, nextRunAt time.Time) error
+}
+
+type memoryReportScheduleStore struct {
+	mu        sync.Mutex
+	schedules map[string]*models.ReportSchedule
+}
+
+// NewReportScheduleStore creates the report schedule store. When cache is connected to
+// Redis the schedules are kept there, shared by every instance and kept across restarts;
+// otherwise, as with a nil cache, they are kept in memory per process and lost on restart.
+func NewReportScheduleStore(cache CacheService) ReportScheduleStore {
+	if redisCache, ok := cache.(*cacheService); ok {
+		return &redisReportScheduleStore{
+			client: redisCache.client,
+			prefix: redisCache.prefix + ":report_schedules",
+			ctx:    redisCache.ctx,
+		}
+	}
+	return &memoryReportScheduleStore{schedules: make(map[string]*models.ReportSchedule)}
+}
+
+// Create assigns an id to the schedule and stores it
+func (s *memoryReportScheduleStore) Create(schedule models.ReportSchedule) (*models.ReportSchedule, error) {
+	id, err := newJobID()
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	count := 0
+	for _, existing := range s.schedules {
+		if existing.MerchantID == schedule.MerchantID {
+			count++
+		}
+	}
+	if count >= maxReportSchedulesPerMerchant {
+		return nil, ErrTooManySchedules
+	}
+
+	schedule.ID = id
+	s.schedules[id] = &schedule
+
+	return &schedule, nil
+}
+
+// List returns the merchant's schedules, oldest first
+func (s *memoryReportScheduleStore) List(merchantID string) ([]models.ReportSchedule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := []models.ReportSchedule{}
+	for _, schedule := range s.schedules {
+		if schedule.MerchantID == merchantID {
+			result = append(result, *schedule)
+		}
+	}
+	sortSchedules(result)
+
+	return result, nil
+}
+
+// Delete removes one of the merchant's schedules, reporting whether it existed
+func (s *memoryReportScheduleStore) Delete(merchantID, id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	schedule, ok := s.schedules[id]
+	if !ok || schedule.MerchantID != merchantID {
+		return false, nil
+	}
+	delete(s.schedules, id)
+
+	return true, nil
+}
+
+// Due returns every schedule whose next run is at or before now
+func (s *memoryReportScheduleStore) Due(now time.Time) ([]models.ReportSchedule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := []models.ReportSchedule{}
+	for _, schedule := range s.schedules {
+		if !schedule.NextRunAt.After(now) {
+			result = append(result, *schedule)
+		}
+	}
+	sortSchedules(result)
+
+	return result, nil
+}
+
+// RecordRun stores the outcome of a run and when the schedule runs next
+func (s *memoryReportScheduleStore) RecordRun(id string, ranAt time.Time, status, exportID string, nextRunAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	schedule, ok := s.schedules[id]
+	if !ok {
+		return nil
+	}
+
+	schedule.LastRunAt = &ranAt
+	schedule.LastStatus = status
+	schedule.LastExportID = exportID
+	schedule.NextRunAt = nextRunAt
+
+	return nil
+}
+
+// redisReportScheduleStore keeps schedules in Redis under prefix: a hash of schedules by
+// id, a set of ids per merchant and a sorted set of ids by next run time
+type redisReportScheduleStore struct {
+	client *redis.Client
+	prefix string
+	ctx    context.Context
+}
+
+// storedReportSchedule is the Redis encoding of a schedule, which keeps the merchant id
+// that the API leaves out
+type storedReportSchedule struct {
+	models.ReportSchedule
+	MerchantID string `json:"merchant_id"`
+}
+
+// reportScheduleClaimTTL is how long a claimed run is remembered; it only has to outlast
+// the window in which other instances could see the same slot as due
+const reportScheduleClaimTTL = 24 * time.Hour
+
+// createScheduleScript adds a schedule unless the merchant already has the maximum
+var createScheduleScript = redis.NewScript(`
+if redis.call('SCARD', KEYS[1]) >= tonumber(ARGV[4]) then
+	return 0
+end
+redis.call('SADD', KEYS[1], ARGV[1])
+redis.call('HSET', KEYS[2], ARGV[1], ARGV[2])
+redis.call('ZADD', KEYS[3], ARGV[3], ARGV[1])
+return 1
+`)
+
+// deleteScheduleScript removes a schedule if it belongs to the merchant
+var deleteScheduleScript = redis.NewScript(`
+if redis.call('SREM', KEYS[1], ARGV[1]) == 0 then
+	return 0
+end
+redis.call('HDEL', KEYS[2], ARGV[1])
+redis.call('ZREM', KEYS[3], ARGV[1])
+return 1
+`)
+
+// updateScheduleScript stores a schedule only if it was not deleted meanwhile
+var updateScheduleScript = redis.NewScript(`
+if redis.call('HEXISTS', KEYS[1], ARGV[1]) == 0 then
+	return 0
+end
+redis.call('HSET', KEYS[1], ARGV[1], ARGV[2])
+redis.call('ZADD', KEYS[2], ARGV[3], ARGV[1])
+return 1
+`)
+
+func (s *redisReportScheduleStore) schedulesKey() string { return s.prefix }
+func (s *redisReportScheduleStore) dueKey() string       { return s.prefix + ":due" }
+func (s *redisReportScheduleStore) merchantKey(merchantID string) string {
+	return s.prefix + ":merchant:" + merchantID
+}
+
+// Create assigns an id to the schedule and stores it
+func (s *redisReportScheduleStore) Create(schedule models.ReportSchedule) (*models.ReportSchedule, error) {
+	id, err := newJobID()
+	if err != nil {
+		return nil, err
+	}
+	schedule.ID = id
+
+	data, err := json.Marshal(storedReportSchedule{ReportSchedule: schedule, MerchantID: schedule.MerchantID})
+	if err != nil {
+		return nil, err
+	}
+
+	keys := []string{s.merchantKey(schedule.MerchantID), s.schedulesKey(), s.dueKey()}
+	created, err := createScheduleScript.Run(s.ctx, s.client, keys, id, data, schedule.NextRunAt.UnixMilli(), maxReportSchedulesPerMerchant).Int()
+	if err != nil {
+		return nil, fmt.Errorf("failed to store report schedule: %v", err)
+	}
+	if created == 0 {
+		return nil, ErrTooManySchedules
+	}
+
+	return &schedule, nil
+}
+
+// List returns the merchant's schedules, oldest first
+func (s *redisReportScheduleStore) List(merchantID string) ([]models.ReportSchedule, error) {
+	ids, err := s.client.SMembers(s.ctx, s.merchantKey(merchantID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list report schedules: %v", err)
+	}
+
+	result, err := s.load(ids)
+	if err != nil {
+		return nil, err
+	}
+	sortSchedules(result)
+
+	return result, nil
+}
+
+// Delete removes one of the merchant's schedules, reporting whether it existed
+func (s *redisReportScheduleStore) Delete(merchantID, id string) (bool, error) {
+	keys := []string{s.merchantKey(merchantID), s.schedulesKey(), s.dueKey()}
+	deleted, err := deleteScheduleScript.Run(s.ctx, s.client, keys, id).Int()
+	if err != nil {
+		return false, fmt.Errorf("failed to delete report schedule: %v", err)
+	}
+	return deleted == 1, nil
+}
+
+// Due returns the schedules whose next run is at or before now that this call claimed.
+// A run is claimed once per schedule and next run time, so instances polling the same
+// store do not run it twice.
+func (s *redisReportScheduleStore) Due(now time.Time) ([]models.ReportSchedule, error) {
+	ids, err := s.client.ZRangeByScore(s.ctx, s.dueKey(), &redis.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatInt(now.UnixMilli(), 10),
+	}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find due report schedules: %v", err)
+	}
+
+	due, err := s.load(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []models.ReportSchedule{}
+	for _, schedule := range due {
+		claimKey := fmt.Sprintf("%s:claim:%s:%d", s.prefix, schedule.ID, schedule.NextRunAt.UnixMilli())
+		claimed, err := s.client.SetNX(s.ctx, claimKey, 1, reportScheduleClaimTTL).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to claim report schedule: %v", err)
+		}
+		if claimed {
+			result = append(result, schedule)
+		}
+	}
+	sortSchedules(result)
+
+	return result, nil
+}
+
+// RecordRun stores the outcome of a run and when the schedule runs next
+func (s *redisReportScheduleStore) RecordRun(id string, ranAt time.Time, status, exportID string, nextRunAt time.Time) error {
+	schedules, err := s.load([]string{id})
+	if err != nil || len(schedules) == 0 {
+		return err
+	}
+
+	schedule := schedules[0]
+	schedule.LastRunAt = &ranAt
+	schedule.LastStatus = status
+	schedule.LastExportID = exportID
+	schedule.NextRunAt = nextRunAt
+
+	data, err := json.Marshal(storedReportSchedule{ReportSchedule: schedule, MerchantID: schedule.MerchantID})
+	if err != nil {
+		return err
+	}
+
+	keys := []string{s.schedulesKey(), s.dueKey()}
+	if err := updateScheduleScript.Run(s.ctx, s.client, keys, id, data, nextRunAt.UnixMilli()).Err(); err != nil {
+		return fmt.Errorf("failed to record report schedule run: %v", err)
+	}
+	return nil
+}
+
+// load reads the schedules with ids, skipping any that no longer exist
+func (s *redisReportScheduleStore) load(ids []string) ([]models.ReportSchedule, error) {
+	result := []models.ReportSchedule{}
+	if len(ids) == 0 {
+		return result, nil
+	}
+
+	values, err := s.client.HMGet(s.ctx, s.schedulesKey(), ids...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read report schedules: %v", err)
+	}
+
+	for _, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			continue
+		}
+		var stored storedReportSchedule
+		if err := json.Unmarshal([]byte(data), &stored); err != nil {
+			return nil, fmt.Errorf("failed to decode report schedule: %v", err)
+		}
+		stored.ReportSchedule.MerchantID = stored.MerchantID
+		result = append(result, stored.ReportSchedule)
+	}
+
+	return result, nil
+}
+
+func sortSchedules(schedules []models.ReportSchedule) {
+	sort.Slice(schedules, func(i, j int) bool {
+		if !schedules[i].CreatedAt.Equal(schedules[j].CreatedAt) {
+			return schedules[i].CreatedAt.Before(schedules[j].CreatedAt)
+		}
+		return schedules[i].ID < schedules[j].ID
+	})
+}
+
+// ReportDeliverer sends a report of the export job exportID to its destination, reading
+// the file from body as it is rendered
+type ReportDeliverer func(exportID string, body io.Reader) error
+
+// ReportRunner renders a scheduled report with the export machinery, streaming it to
+// deliver, and returns the id of the export job it recorded
+type ReportRunner func(schedule models.ReportSchedule, deliver ReportDeliverer) (string, error)
+
+// ReportScheduler runs due report schedules in the background and delivers their output
+type ReportScheduler struct {
+	store    ReportScheduleStore
+	run      ReportRunner
+	uploader ExportUploader
+	client   *http.Client
+	done     chan struct{} // Closed when the goroutine started by Start returns
+}
+
+// NewReportScheduler creates a scheduler that renders reports with run. Reports with the
+// store delivery type are uploaded with uploader, which is nil when object storage is not
+// configured.
+func NewReportScheduler(store ReportScheduleStore, run ReportRunner, uploader ExportUploader) *ReportScheduler {
+	return &ReportScheduler{
+		store:    store,
+		run:      run,
+		uploader: uploader,
+		client:   newWebhookClient(),
+	}
+}
+
+// ErrWebhookAddressNotAllowed is returned when a webhook URL names, or resolves to, an
+// address that is not public
+var ErrWebhookAddressNotAllowed = errors.New("webhook url must resolve to a public address")
+
+// ValidateWebhookURL checks that a webhook URL is absolute https and does not name localhost
+// or a loopback, private, link-local or otherwise non-public IP. Host names are checked again
+// when delivery dials them, since they can resolve to anything.
+func ValidateWebhookURL(rawURL string) error {
+	target, err := url.Parse(rawURL)
+	if err != nil || target.Scheme != "https" || target.Hostname() == "" {
+		return errors.New("webhook delivery requires an absolute https url")
+	}
+
+	host := strings.ToLower(strings.TrimSuffix(target.Hostname(), "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ErrWebhookAddressNotAllowed
+	}
+	if addr, err := netip.ParseAddr(host); err == nil && !isPublicAddr(addr) {
+		return ErrWebhookAddressNotAllowed
+	}
+	return nil
+}
+
+// cgnatPrefix is the shared address space of carrier-grade NAT, which is not routable
+var cgnatPrefix = netip.MustParsePrefix("100.64.0.0/10")
+
+// isPublicAddr reports whether addr is a globally routable unicast address
+func isPublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsGlobalUnicast() && !addr.IsPrivate() && !cgnatPrefix.Contains(addr)
+}
+
+// newWebhookClient returns the client webhooks are delivered with. It refuses to connect
+// to non-public addresses, checked on the resolved IP so a host name cannot point it at
+// internal services, and does not follow redirects, which count as a failed delivery. The
+// timeout covers rendering as well, since reports are sent while they are written.
+func newWebhookClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil || !isPublicAddr(addrPort.Addr()) {
+				return fmt.Errorf("%w: %s", ErrWebhookAddressNotAllowed, address)
+			}
+			return nil
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:   10 * time.Minute,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// Start checks for due schedules every interval until ctx is cancelled. Cancelling ctx
+// also aborts the delivery of a report in progress, which is recorded as failed.
+func (s *ReportScheduler) Start(ctx context.Context, interval time.Duration) {
+	s.done = make(chan struct{})
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				s.runDue(ctx, now)
+			}
+		}
+	}()
+
+	utils.LogInfo("Report scheduler started", map[string]interface{}{
+		"interval": interval.String(),
+	})
+}
+
+// Wait blocks until the scheduler started by Start has stopped after its context was
+// cancelled, so the stores and database it uses can be closed
+func (s *ReportScheduler) Wait() {
+	if s.done != nil {
+		<-s.done
+	}
+}
+
+// RunDue runs every schedule that is due at now, one at a time, and returns how many ran
+func (s *ReportScheduler) RunDue(now time.Time) int {
+	return s.runDue(context.Background(), now)
+}
+
+// runDue is RunDue under ctx. Once ctx is cancelled the remaining runs fail without
+// rendering, so each claimed schedule still moves on to its next slot.
+func (s *ReportScheduler) runDue(ctx context.Context, now time.Time) int {
+	due, err := s.store.Due(now)
+	if err != nil {
+		utils.LogError("Failed to find due report schedules", err, nil)
+		return 0
+	}
+
+	for _, schedule := range due {
+		status := models.ExportStatusCompleted
+		exportID, err := s.runSchedule(ctx, schedule)
+		if err != nil {
+			status = models.ExportStatusFailed
+			utils.LogError("Scheduled report failed", err, map[string]interface{}{
+				"merchant_id": schedule.MerchantID,
+				"schedule_id": schedule.ID,
+				"export_id":   exportID,
+			})
+		}
+
+		loc, err := time.LoadLocation(schedule.Timezone)
+		if err != nil {
+			loc = time.UTC
+		}
+		if err := s.store.RecordRun(schedule.ID, now, status, exportID, schedule.Schedule.Next(now, loc)); err != nil {
+			utils.LogError("Failed to record scheduled report run", err, map[string]interface{}{
+				"merchant_id": schedule.MerchantID,
+				"schedule_id": schedule.ID,
+			})
+		}
+	}
+
+	return len(due)
+}
+
+// runSchedule renders one report and delivers it under ctx, returning the export job id
+func (s *ReportScheduler) runSchedule(ctx context.Context, schedule models.ReportSchedule) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	return s.run(schedule, func(exportID string, body io.Reader) error {
+		if schedule.Delivery.Type == models.ReportDeliveryWebhook {
+			return s.deliverWebhook(ctx, schedule, exportID, body)
+		}
+		return s.deliverStore(ctx, schedule, exportID, body)
+	})
+}
+
+// deliverStore uploads the report to object storage under its export's key, where the
+// export status endpoint links to it
+func (s *ReportScheduler) deliverStore(ctx context.Context, schedule models.ReportSchedule, exportID string, body io.Reader) error {
+	if s.uploader == nil {
+		return errors.New("store delivery requires object storage to be configured")
+	}
+
+	key := ExportObjectKey(schedule.MerchantID, exportID, schedule.Format)
+	return s.uploader.Upload(ctx, key, reportContentTypes[schedule.Format], body)
+}
+
+// deliverWebhook POSTs the report to the schedule's URL, failing on a non-2xx response,
+// a redirect included
+func (s *ReportScheduler) deliverWebhook(ctx context.Context, schedule models.ReportSchedule, exportID string, body io.Reader) error {
+	if schedule.Delivery.URL == "" {
+		return errors.New("webhook delivery requires a url")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, schedule.Delivery.URL, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", reportContentTypes[schedule.Format])
+	req.Header.Set("X-Report-Schedule-ID", schedule.ID)
+	req.Header.Set("X-Export-ID", exportID)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,358 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"aken_reporting_service/internal/config"
+	"aken_reporting_service/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestSchedule(merchantID string, nextRunAt time.Time) models.ReportSchedule {
+	return models.ReportSchedule{
+		MerchantID: merchantID,
+		Name:       "Daily settlement",
+		Format:     "csv",
+		Timezone:   "UTC",
+		Schedule:   models.ReportScheduleSpec{Frequency: models.ReportFrequencyDaily, Hour: 6},
+		Delivery:   models.ReportDelivery{Type: models.ReportDeliveryStore},
+		CreatedAt:  nextRunAt.Add(-time.Hour),
+		NextRunAt:  nextRunAt,
+	}
+}
+
+// listSchedules returns the merchant's schedules, failing the test on a store error
+func listSchedules(t *testing.T, store ReportScheduleStore, merchantID string) []models.ReportSchedule {
+	t.Helper()
+	schedules, err := store.List(merchantID)
+	require.NoError(t, err)
+	return schedules
+}
+
+func TestReportScheduleStore_ScopedPerMerchant(t *testing.T) {
+	store := NewReportScheduleStore(nil)
+	now := time.Date(2025, 1, 15, 6, 0, 0, 0, time.UTC)
+
+	created, err := store.Create(newTestSchedule("merchant-1", now))
+	require.NoError(t, err)
+	assert.NotEmpty(t, created.ID)
+	_, err = store.Create(newTestSchedule("merchant-2", now))
+	require.NoError(t, err)
+
+	schedules := listSchedules(t, store, "merchant-1")
+	require.Len(t, schedules, 1)
+	assert.Equal(t, created.ID, schedules[0].ID)
+
+	deleted, err := store.Delete("merchant-2", created.ID)
+	require.NoError(t, err)
+	assert.False(t, deleted, "another merchant must not delete the schedule")
+	deleted, err = store.Delete("merchant-1", created.ID)
+	require.NoError(t, err)
+	assert.True(t, deleted)
+	assert.Empty(t, listSchedules(t, store, "merchant-1"))
+	assert.Len(t, listSchedules(t, store, "merchant-2"), 1)
+}
+
+func TestReportScheduleStore_LimitPerMerchant(t *testing.T) {
+	store := NewReportScheduleStore(nil)
+	now := time.Now()
+
+	for i := 0; i < maxReportSchedulesPerMerchant; i++ {
+		_, err := store.Create(newTestSchedule("merchant-1", now))
+		require.NoError(t, err)
+	}
+
+	_, err := store.Create(newTestSchedule("merchant-1", now))
+	assert.ErrorIs(t, err, ErrTooManySchedules)
+	_, err = store.Create(newTestSchedule("merchant-2", now))
+	assert.NoError(t, err)
+}
+
+func TestReportScheduleStore_RedisSharedBetweenInstances(t *testing.T) {
+	if !config.IsRedisEnabled() {
+		t.Skip("Redis not available for testing")
+	}
+	cacheService, err := NewCacheService()
+	if err != nil {
+		t.Skipf("Redis not reachable: %v", err)
+	}
+	defer cacheService.Close()
+
+	// Two stores on one Redis stand in for two instances of the service
+	first := NewReportScheduleStore(cacheService)
+	second := NewReportScheduleStore(cacheService)
+	merchantID := fmt.Sprintf("merchant-%d", time.Now().UnixNano())
+	now := time.Date(2025, 1, 15, 6, 0, 0, 0, time.UTC)
+
+	created, err := first.Create(newTestSchedule(merchantID, now))
+	require.NoError(t, err)
+	defer first.Delete(merchantID, created.ID)
+
+	schedules := listSchedules(t, second, merchantID)
+	require.Len(t, schedules, 1)
+	assert.Equal(t, created.ID, schedules[0].ID)
+	assert.Equal(t, merchantID, schedules[0].MerchantID)
+
+	claimed := 0
+	for _, store := range []ReportScheduleStore{first, second} {
+		due, err := store.Due(now)
+		require.NoError(t, err)
+		for _, schedule := range due {
+			if schedule.ID == created.ID {
+				claimed++
+			}
+		}
+	}
+	assert.Equal(t, 1, claimed, "a due run must be claimed by one instance")
+
+	next := now.AddDate(0, 0, 1)
+	require.NoError(t, second.RecordRun(created.ID, now, models.ExportStatusCompleted, "export-1", next))
+	schedules = listSchedules(t, first, merchantID)
+	require.Len(t, schedules, 1)
+	assert.Equal(t, "export-1", schedules[0].LastExportID)
+	assert.Equal(t, next, schedules[0].NextRunAt.UTC())
+
+	deleted, err := second.Delete(merchantID, created.ID)
+	require.NoError(t, err)
+	assert.True(t, deleted)
+	assert.Empty(t, listSchedules(t, first, merchantID))
+}
+
+// recordingUploader keeps uploaded objects in memory
+type recordingUploader struct {
+	objects      map[string]string
+	contentTypes map[string]string
+}
+
+func (u *recordingUploader) Upload(ctx context.Context, key, contentType string, body io.Reader) error {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	if u.objects == nil {
+		u.objects, u.contentTypes = map[string]string{}, map[string]string{}
+	}
+	u.objects[key] = string(data)
+	u.contentTypes[key] = contentType
+	return nil
+}
+
+func (u *recordingUploader) PresignGetURL(key string) (string, error) {
+	return "https://storage.example.com/" + key, nil
+}
+
+func TestReportScheduler_RunDueStoresOutputAndReschedules(t *testing.T) {
+	store := NewReportScheduleStore(nil)
+	now := time.Date(2025, 1, 15, 6, 0, 0, 0, time.UTC)
+
+	due, err := store.Create(newTestSchedule("merchant-1", now))
+	require.NoError(t, err)
+	_, err = store.Create(newTestSchedule("merchant-1", now.Add(time.Minute)))
+	require.NoError(t, err)
+
+	var ran []string
+	runner := func(schedule models.ReportSchedule, deliver ReportDeliverer) (string, error) {
+		ran = append(ran, schedule.ID)
+		return "export-1", deliver("export-1", strings.NewReader("payment_tx_log_id\ntx-1\n"))
+	}
+
+	uploader := &recordingUploader{}
+	scheduler := NewReportScheduler(store, runner, uploader)
+
+	assert.Equal(t, 1, scheduler.RunDue(now))
+	assert.Equal(t, []string{due.ID}, ran, "only the due schedule should run")
+
+	assert.Equal(t, "payment_tx_log_id\ntx-1\n", uploader.objects["merchant-1/export-1.csv"])
+	assert.Equal(t, "text/csv", uploader.contentTypes["merchant-1/export-1.csv"])
+
+	schedules := listSchedules(t, store, "merchant-1")
+	require.Len(t, schedules, 2)
+	ranSchedule := schedules[0]
+	if ranSchedule.ID != due.ID {
+		ranSchedule = schedules[1]
+	}
+	assert.Equal(t, models.ExportStatusCompleted, ranSchedule.LastStatus)
+	assert.Equal(t, "export-1", ranSchedule.LastExportID)
+	assert.Equal(t, now, *ranSchedule.LastRunAt)
+	assert.Equal(t, time.Date(2025, 1, 16, 6, 0, 0, 0, time.UTC), ranSchedule.NextRunAt)
+
+	assert.Zero(t, scheduler.RunDue(now), "a schedule must not run twice for the same slot")
+}
+
+func TestReportScheduler_StoreDeliveryFailsWithoutObjectStorage(t *testing.T) {
+	store := NewReportScheduleStore(nil)
+	now := time.Date(2025, 1, 15, 6, 0, 0, 0, time.UTC)
+	_, err := store.Create(newTestSchedule("merchant-1", now))
+	require.NoError(t, err)
+
+	runner := func(schedule models.ReportSchedule, deliver ReportDeliverer) (string, error) {
+		return "export-1", deliver("export-1", strings.NewReader("rrn\n"))
+	}
+	NewReportScheduler(store, runner, nil).RunDue(now)
+
+	assert.Equal(t, models.ExportStatusFailed, listSchedules(t, store, "merchant-1")[0].LastStatus)
+}
+
+func TestReportScheduler_WebhookDelivery(t *testing.T) {
+	var received []byte
+	var headers http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received, _ = io.ReadAll(r.Body)
+		headers = r.Header
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	store := NewReportScheduleStore(nil)
+	now := time.Date(2025, 1, 15, 6, 0, 0, 0, time.UTC)
+	schedule := newTestSchedule("merchant-1", now)
+	schedule.Delivery = models.ReportDelivery{Type: models.ReportDeliveryWebhook, URL: server.URL}
+	created, err := store.Create(schedule)
+	require.NoError(t, err)
+
+	runner := func(schedule models.ReportSchedule, deliver ReportDeliverer) (string, error) {
+		return "export-7", deliver("export-7", strings.NewReader("rrn\n001\n"))
+	}
+
+	// The test server listens on loopback, which the delivery client refuses
+	scheduler := NewReportScheduler(store, runner, nil)
+	scheduler.client = server.Client()
+	scheduler.RunDue(now)
+
+	assert.Equal(t, "rrn\n001\n", string(received))
+	assert.Equal(t, "text/csv", headers.Get("Content-Type"))
+	assert.Equal(t, created.ID, headers.Get("X-Report-Schedule-ID"))
+	assert.Equal(t, "export-7", headers.Get("X-Export-ID"))
+	assert.Equal(t, models.ExportStatusCompleted, listSchedules(t, store, "merchant-1")[0].LastStatus)
+}
+
+func TestReportScheduler_FailedRunIsRecordedAndRescheduled(t *testing.T) {
+	store := NewReportScheduleStore(nil)
+	now := time.Date(2025, 1, 15, 6, 0, 0, 0, time.UTC)
+	_, err := store.Create(newTestSchedule("merchant-1", now))
+	require.NoError(t, err)
+
+	runner := func(schedule models.ReportSchedule, deliver ReportDeliverer) (string, error) {
+		return "export-9", errors.New("connection refused")
+	}
+
+	NewReportScheduler(store, runner, &recordingUploader{}).RunDue(now)
+
+	schedule := listSchedules(t, store, "merchant-1")[0]
+	assert.Equal(t, models.ExportStatusFailed, schedule.LastStatus)
+	assert.Equal(t, "export-9", schedule.LastExportID)
+	assert.True(t, schedule.NextRunAt.After(now))
+}
+
+func TestReportScheduler_StopsOnCancelAndWaits(t *testing.T) {
+	store := NewReportScheduleStore(nil)
+	now := time.Date(2025, 1, 15, 6, 0, 0, 0, time.UTC)
+	_, err := store.Create(newTestSchedule("merchant-1", now))
+	require.NoError(t, err)
+
+	ran := false
+	runner := func(schedule models.ReportSchedule, deliver ReportDeliverer) (string, error) {
+		ran = true
+		return "export-1", nil
+	}
+	scheduler := NewReportScheduler(store, runner, &recordingUploader{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	scheduler.Start(ctx, time.Hour)
+	cancel()
+	scheduler.Wait()
+
+	// A due schedule reached after cancellation is not rendered but still moves on
+	assert.Equal(t, 1, scheduler.runDue(ctx, now))
+	assert.False(t, ran)
+	schedule := listSchedules(t, store, "merchant-1")[0]
+	assert.Equal(t, models.ExportStatusFailed, schedule.LastStatus)
+	assert.True(t, schedule.NextRunAt.After(now))
+}
+
+func TestReportScheduler_WebhookRefusesNonPublicAddresses(t *testing.T) {
+	called := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer server.Close()
+
+	store := NewReportScheduleStore(nil)
+	now := time.Date(2025, 1, 15, 6, 0, 0, 0, time.UTC)
+	schedule := newTestSchedule("merchant-1", now)
+	schedule.Delivery = models.ReportDelivery{Type: models.ReportDeliveryWebhook, URL: server.URL}
+	_, err := store.Create(schedule)
+	require.NoError(t, err)
+
+	runner := func(schedule models.ReportSchedule, deliver ReportDeliverer) (string, error) {
+		return "export-1", deliver("export-1", strings.NewReader(""))
+	}
+	NewReportScheduler(store, runner, nil).RunDue(now)
+
+	assert.False(t, called)
+	assert.Equal(t, models.ExportStatusFailed, listSchedules(t, store, "merchant-1")[0].LastStatus)
+}
+
+func TestReportScheduler_WebhookDoesNotFollowRedirects(t *testing.T) {
+	followed := false
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		followed = true
+	}))
+	defer target.Close()
+	redirect := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusTemporaryRedirect))
+	defer redirect.Close()
+
+	store := NewReportScheduleStore(nil)
+	now := time.Date(2025, 1, 15, 6, 0, 0, 0, time.UTC)
+	schedule := newTestSchedule("merchant-1", now)
+	schedule.Delivery = models.ReportDelivery{Type: models.ReportDeliveryWebhook, URL: redirect.URL}
+	_, err := store.Create(schedule)
+	require.NoError(t, err)
+
+	runner := func(schedule models.ReportSchedule, deliver ReportDeliverer) (string, error) {
+		return "export-1", deliver("export-1", strings.NewReader(""))
+	}
+	scheduler := NewReportScheduler(store, runner, nil)
+	scheduler.client.Transport = http.DefaultTransport
+	scheduler.RunDue(now)
+
+	assert.False(t, followed)
+	assert.Equal(t, models.ExportStatusFailed, listSchedules(t, store, "merchant-1")[0].LastStatus)
+}
+
+func TestValidateWebhookURL(t *testing.T) {
+	for _, rawURL := range []string{
+		"https://hooks.example.com/reports",
+		"https://203.0.113.10:8443/hook",
+	} {
+		assert.NoError(t, ValidateWebhookURL(rawURL), rawURL)
+	}
+
+	for _, rawURL := range []string{
+		"http://hooks.example.com/reports",
+		"/reports",
+		"https://localhost/hook",
+		"https://api.localhost/hook",
+		"https://127.0.0.1/hook",
+		"https://10.1.2.3/hook",
+		"https://192.168.0.10/hook",
+		"https://169.254.169.254/latest/meta-data",
+		"https://100.64.0.1/hook",
+		"https://[::1]/hook",
+		"https://[fd00::1]/hook",
+		"https://[::ffff:127.0.0.1]/hook",
+		"https://0.0.0.0/hook",
+	} {
+		assert.Error(t, ValidateWebhookURL(rawURL), rawURL)
+	}
+}
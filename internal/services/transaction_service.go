@@ -1,7 +1,13 @@
 package services
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -9,26 +15,52 @@ import (
 	"aken_reporting_service/internal/database"
 	"aken_reporting_service/internal/models"
 	"aken_reporting_service/internal/repositories"
+	"aken_reporting_service/internal/utils"
 	"crypto/md5"
+
+	"golang.org/x/sync/errgroup"
 )
 
 type TransactionService interface {
 	GetTransactions(merchantID string, params *GetTransactionsParams) (*TransactionServiceResult, error)
+	CountTransactions(merchantID string, filter *models.TransactionFilter) (int64, error)
+	EstimateDuration(merchantID string, filter *models.TransactionFilter) (time.Duration, error)
+	StreamTransactions(ctx context.Context, merchantID string, params *GetTransactionsParams, fn func(*models.Transaction) error) error
+	FollowTransactions(ctx context.Context, merchantID string, params *GetTransactionsParams, pollInterval time.Duration, batchSize int, fn func(*models.Transaction) error) error
 	GetTransactionByID(merchantID, transactionID string, fields []string, timezone string, panFormat string) (*models.Transaction, error)
+	GetTransactionsByIDs(merchantID string, ids []string, fields []string, timezone string, panFormat string) ([]models.Transaction, error)
+	GetTransactionsByRRNs(merchantID string, rrns []string, fields []string, timezone string, panFormat string) ([]models.Transaction, bool, error)
+	GetTransactionsBySTAN(merchantID, stan, date string, fields []string, timezone string, panFormat string) ([]models.Transaction, bool, error)
 	SearchTransactions(merchantID string, searchReq *models.TransactionSearchRequest, timezone string, panFormat string) (*TransactionServiceResult, error)
-	GetMerchantSummary(merchantID string, filter *models.TransactionFilter) (*models.MerchantSummary, error)
+	GetMerchantSummary(merchantID string, filter *models.TransactionFilter, metrics models.SummaryMetrics) (*models.MerchantSummary, error)
+	GetMerchantSummaries(provisionerID string, filter *models.TransactionFilter, page, limit int) (*MerchantSummariesResult, error)
+	CanAccessMerchant(merchantID, requestedMerchantID string) (bool, error)
+	GetTimeseries(merchantID string, filter *models.TransactionFilter, interval, timezone string) ([]models.TimeseriesBucket, error)
+	GetMerchantDevices(merchantID string, filter *models.TransactionFilter) ([]models.MerchantDevice, error)
+	GetEmptyResultSuggestions(merchantID string, filter *models.TransactionFilter, timezone string) ([]string, error)
 	GetTransactionTotals(merchantID string, request models.TransactionTotalsRequest) (*models.TransactionTotalsResponse, error)
 	GetTransactionLookup(request models.TransactionLookupRequest) (*models.TransactionLookupResponse, error)
 	SearchTransactionDetails(request models.IsoTransactionSearchRequest) (*models.IsoTransactionSearchResponse, error)
+	GetCurrencies() (*CurrenciesResult, error)
 	ParseAdvancedFilter(filterString, timezone string) (*models.TransactionFilter, error)
+	ParseSearchQuery(query interface{}) (*models.TransactionFilter, error)
 	ParseSort(sortString string) ([]models.SortParams, error)
 	ValidateFields(fields []string) error
+	ValidateTimezone(timezone string) error
 	SetUseMysql(useMysql bool) // Add method to set database preference
 }
 
 type transactionService struct {
 	transactionRepo repositories.TransactionRepository
 	cacheService    CacheService
+	rollupSources   []RollupSource // Additional shards/databases merged into provisioner roll-ups
+}
+
+// RollupSource is an additional data source queried for provisioner roll-ups. If it fails,
+// merchant summaries are returned without its data and with a warning naming it.
+type RollupSource struct {
+	Name string
+	Repo repositories.TransactionRepository
 }
 
 type GetTransactionsParams struct {
@@ -39,6 +71,14 @@ type GetTransactionsParams struct {
 	Limit     int
 	Timezone  string
 	PANFormat string
+	SkipCount bool // Don't compute the total count (streaming/cursor mode)
+
+	CursorMode bool                      // Keyset pagination instead of page numbers
+	Cursor     *models.TransactionCursor // Position after which to continue; nil for the first page
+
+	Facets        []string // Opt-in facets computed over the whole filtered set (e.g. "day")
+	IncludeTotals bool     // Sum amounts over the whole filtered set alongside the count
+	DebugSQL      bool     // Return the generated data query SQL; callers must restrict this to admins
 }
 
 type TransactionServiceResult struct {
@@ -50,13 +90,33 @@ type TransactionServiceResult struct {
 	CurrentPageCount int                  `json:"current_page_count"`
 	HasNext          bool                 `json:"has_next"`
 	HasPrev          bool                 `json:"has_prev"`
-	RequestedFields  []string             `json:"-"` // Internal field, not serialized
+	HasMore          bool                 `json:"has_more"`               // A full page was returned when the total is unknown
+	CountSkipped     bool                 `json:"count_skipped"`          // TotalCount/TotalPages were not computed
+	CursorMode       bool                 `json:"cursor_mode"`            // Keyset pagination was used
+	NextCursor       string               `json:"next_cursor,omitempty"`  // Opaque cursor for the next keyset page
+	DayFacets        []models.DayFacet    `json:"day_facets,omitempty"`   // Counts per day, when the "day" facet was requested
+	TotalAmount      *int64               `json:"total_amount,omitempty"` // Sum of amounts over all matching rows, when requested
+	Cached           bool                 `json:"cached"`                 // Served from cache; transaction lists are never cached
+	DebugSQL         string               `json:"-"`                      // Generated data query SQL, when requested
+	RequestedFields  []string             `json:"-"`                      // Internal field, not serialized
 }
 
-func NewTransactionService(transactionRepo repositories.TransactionRepository, cacheService CacheService) TransactionService {
+// MerchantSummariesResult is a page of per-merchant summaries for a provisioner
+type MerchantSummariesResult struct {
+	Summaries  []models.MerchantSummary `json:"data"`
+	TotalCount int64                    `json:"total_count"` // Merchants with matching transactions
+	Page       int                      `json:"page"`
+	Limit      int                      `json:"limit"`
+	TotalPages int                      `json:"total_pages"`
+}
+
+// NewTransactionService creates the transaction service. Merchant summaries also merge in
+// the results of any rollupSources.
+func NewTransactionService(transactionRepo repositories.TransactionRepository, cacheService CacheService, rollupSources ...RollupSource) TransactionService {
 	return &transactionService{
 		transactionRepo: transactionRepo,
 		cacheService:    cacheService,
+		rollupSources:   rollupSources,
 	}
 }
 
@@ -72,7 +132,7 @@ func (s *transactionService) GetTransactions(merchantID string, params *GetTrans
 		params.Page = 1
 	}
 	if params.Limit < config.MinPageSize {
-		params.Limit = config.DefaultPageSize
+		params.Limit = config.GetDefaultPageSize(config.PageSizeEndpointList)
 	}
 	if params.Limit > config.MaxPageSize {
 		params.Limit = config.MaxPageSize
@@ -95,50 +155,158 @@ func (s *transactionService) GetTransactions(merchantID string, params *GetTrans
 	// Skip caching for transaction data to ensure fresh data
 	// Transaction data changes frequently and users need latest information
 
+	// Keyset pagination never counts; the count would scan the whole filtered set
+	if params.CursorMode {
+		params.Page = 1
+		params.SkipCount = true
+	}
+
 	pagination := models.PaginationParams{
-		Page:  params.Page,
-		Limit: params.Limit,
+		Page:          params.Page,
+		Limit:         params.Limit,
+		SkipCount:     params.SkipCount,
+		CursorMode:    params.CursorMode,
+		Cursor:        params.Cursor,
+		IncludeTotals: params.IncludeTotals,
+		DebugSQL:      params.DebugSQL,
 	}
 
 	// Use retry logic for database operations
 	retryConfig := database.DefaultRetryConfig()
 
+	// The data page and any facet queries run concurrently, bounded per request
+	group := newQueryGroup()
+
 	var result *repositories.TransactionListResult
-	err := database.RetryWithBackoff(func() error {
-		var dbErr error
-		result, dbErr = s.transactionRepo.GetTransactions(
-			merchantID,
-			params.Filter,
-			params.Fields,
-			params.Sort,
-			pagination,
-			params.Timezone,
-			params.PANFormat,
-		)
-		return dbErr
-	}, retryConfig)
+	group.Go(func() error {
+		return database.RetryWithBackoff(func() error {
+			var dbErr error
+			result, dbErr = s.transactionRepo.GetTransactions(
+				merchantID,
+				params.Filter,
+				params.Fields,
+				params.Sort,
+				pagination,
+				params.Timezone,
+				params.PANFormat,
+			)
+			return dbErr
+		}, retryConfig)
+	})
+
+	var dayFacets []models.DayFacet
+	for _, facet := range params.Facets {
+		if facet == "day" {
+			group.Go(func() error {
+				var err error
+				dayFacets, err = s.transactionRepo.GetDailyCounts(merchantID, params.Filter, params.Timezone)
+				return err
+			})
+		}
+	}
 
-	if err != nil {
+	if err := group.Wait(); err != nil {
 		// Don't wrap the error to avoid exposing internal details
 		return nil, err
 	}
 
 	// Return fresh transaction data without caching
-	serviceResult := &TransactionServiceResult{
-		Transactions:     result.Transactions,
-		TotalCount:       result.TotalCount,
-		Page:             result.Page,
-		Limit:            result.Limit,
-		TotalPages:       result.TotalPages,
-		CurrentPageCount: len(result.Transactions),
-		HasNext:          result.Page < result.TotalPages,
-		HasPrev:          result.Page > 1,
-		RequestedFields:  result.RequestedFields,
+	serviceResult := newTransactionServiceResult(result)
+	serviceResult.CursorMode = params.CursorMode
+	if params.CursorMode {
+		serviceResult.HasPrev = params.Cursor != nil
 	}
+	serviceResult.DayFacets = dayFacets
 
 	return serviceResult, nil
 }
 
+// newQueryGroup returns an errgroup that runs at most config.GetMaxConcurrentQueries()
+// of a request's database queries at a time
+func newQueryGroup() *errgroup.Group {
+	group := &errgroup.Group{}
+	group.SetLimit(config.GetMaxConcurrentQueries())
+	return group
+}
+
+// EstimateDuration estimates how long exporting every transaction matching filter takes, from
+// the planner's row estimate and the configured rows per second
+func (s *transactionService) EstimateDuration(merchantID string, filter *models.TransactionFilter) (time.Duration, error) {
+	rows, err := s.transactionRepo.EstimateTransactionCount(merchantID, filter)
+	if err != nil {
+		return 0, err
+	}
+
+	return time.Duration(rows) * time.Second / time.Duration(config.GetEstimatedRowsPerSecond()), nil
+}
+
+// StreamTransactions calls fn for every transaction matching params, ignoring pagination.
+// Rows are read from a database cursor; the query is not retried, since rows may already
+// have been handed to fn when it fails.
+func (s *transactionService) StreamTransactions(ctx context.Context, merchantID string, params *GetTransactionsParams, fn func(*models.Transaction) error) error {
+	if err := s.prepareStreamParams(params); err != nil {
+		return err
+	}
+
+	return s.transactionRepo.StreamTransactions(ctx, merchantID, params.Filter, params.Fields, params.Sort, params.Timezone, params.PANFormat, fn)
+}
+
+// FollowTransactions calls fn for every transaction matching params in updated_at order,
+// then keeps polling for rows updated after the last one seen until ctx is done. Each poll
+// reads at most batchSize rows; a full batch is followed by the next one straight away,
+// otherwise the next poll waits pollInterval. params.Sort is ignored.
+func (s *transactionService) FollowTransactions(ctx context.Context, merchantID string, params *GetTransactionsParams, pollInterval time.Duration, batchSize int, fn func(*models.Transaction) error) error {
+	if err := s.prepareStreamParams(params); err != nil {
+		return err
+	}
+
+	var lastSeen *models.TransactionCursor
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		batch, err := s.transactionRepo.PollTransactions(ctx, merchantID, params.Filter, params.Fields, lastSeen, batchSize, params.Timezone, params.PANFormat)
+		if err != nil {
+			return err
+		}
+
+		for i := range batch {
+			if err := fn(&batch[i]); err != nil {
+				return err
+			}
+			lastSeen = &models.TransactionCursor{UpdatedAt: batch[i].UpdatedAt, ID: batch[i].ID}
+		}
+
+		if len(batch) >= batchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// prepareStreamParams applies the defaults of a streamed read and validates its fields
+func (s *transactionService) prepareStreamParams(params *GetTransactionsParams) error {
+	if params.Timezone == "" {
+		params.Timezone = "UTC"
+	}
+	if params.PANFormat == "" {
+		params.PANFormat = "bin_id_and_pan_id"
+	}
+
+	if len(params.Fields) > 0 {
+		if err := s.ValidateFields(params.Fields); err != nil {
+			return fmt.Errorf("invalid fields: %v", err)
+		}
+	}
+	return nil
+}
+
 // GetTransactionByID retrieves a single transaction by ID
 func (s *transactionService) GetTransactionByID(merchantID, transactionID string, fields []string, timezone string, panFormat string) (*models.Transaction, error) {
 	if len(fields) == 0 {
@@ -164,6 +332,213 @@ func (s *transactionService) GetTransactionByID(merchantID, transactionID string
 	return transaction, nil
 }
 
+// GetTransactionsByIDs retrieves a batch of transactions by id.
+// Blank and duplicate ids are dropped; large lists are handled by the repository with an array join.
+func (s *transactionService) GetTransactionsByIDs(merchantID string, ids []string, fields []string, timezone string, panFormat string) ([]models.Transaction, error) {
+	if len(fields) == 0 {
+		fields = config.DefaultFields
+	}
+	if timezone == "" {
+		timezone = "UTC"
+	}
+	if panFormat == "" {
+		panFormat = "bin_id_and_pan_id"
+	}
+
+	if err := s.ValidateFields(fields); err != nil {
+		return nil, fmt.Errorf("invalid fields: %v", err)
+	}
+
+	uniqueIDs := uniqueBatchValues(ids)
+	if len(uniqueIDs) == 0 {
+		return []models.Transaction{}, nil
+	}
+
+	return s.transactionRepo.GetTransactionsByIDs(merchantID, uniqueIDs, fields, timezone, panFormat)
+}
+
+// GetTransactionsByRRNs retrieves the transactions matching a batch of RRNs, every match
+// of an RRN that is not unique. Blank and duplicate RRNs are dropped. At most
+// MaxBatchRRNMatches transactions are returned; the flag reports that more matched.
+func (s *transactionService) GetTransactionsByRRNs(merchantID string, rrns []string, fields []string, timezone string, panFormat string) ([]models.Transaction, bool, error) {
+	if len(fields) == 0 {
+		fields = config.DefaultFields
+	}
+	if timezone == "" {
+		timezone = "UTC"
+	}
+	if panFormat == "" {
+		panFormat = "bin_id_and_pan_id"
+	}
+
+	if err := s.ValidateFields(fields); err != nil {
+		return nil, false, fmt.Errorf("invalid fields: %v", err)
+	}
+
+	uniqueRRNs := uniqueBatchValues(rrns)
+	if len(uniqueRRNs) == 0 {
+		return []models.Transaction{}, false, nil
+	}
+
+	// One row past the cap tells whether the list was cut off
+	transactions, err := s.transactionRepo.GetTransactionsByRRNs(merchantID, uniqueRRNs, fields, timezone, panFormat, config.MaxBatchRRNMatches+1)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(transactions) > config.MaxBatchRRNMatches {
+		return transactions[:config.MaxBatchRRNMatches], true, nil
+	}
+	return transactions, false, nil
+}
+
+// uniqueBatchValues trims the values of a batch lookup, dropping blank and duplicate ones
+func uniqueBatchValues(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	unique := make([]string, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" || seen[value] {
+			continue
+		}
+		seen[value] = true
+		unique = append(unique, value)
+	}
+	return unique
+}
+
+// currenciesCacheKey is the cache key of the currency reference data
+const currenciesCacheKey = "currencies"
+
+// CurrenciesResult is the currency reference data and when it was read from the database
+type CurrenciesResult struct {
+	Currencies []models.Currency `json:"currencies"`
+	DataAsOf   time.Time         `json:"data_as_of"` // When the currencies were read, cached with them
+	Cached     bool              `json:"-"`          // Served from the cache rather than read
+}
+
+// GetCurrencies returns the currency reference data, cached for config.GetCurrencyCacheTTL
+// since the currency table rarely changes
+func (s *transactionService) GetCurrencies() (*CurrenciesResult, error) {
+	cacheKey := config.GetRedisKeyPrefix() + currenciesCacheKey
+	if s.cacheService != nil {
+		var cached CurrenciesResult
+		if err := s.cacheService.Get(cacheKey, &cached); err == nil && cached.Currencies != nil {
+			cached.Cached = true
+			return &cached, nil
+		}
+	}
+
+	currencies, err := s.transactionRepo.GetCurrencies()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &CurrenciesResult{Currencies: currencies, DataAsOf: time.Now().UTC()}
+	if s.cacheService != nil {
+		s.cacheService.Set(cacheKey, result, config.GetCurrencyCacheTTL())
+	}
+	return result, nil
+}
+
+// ErrInvalidLookupDate is returned when a lookup date is not in YYYY-MM-DD format
+var ErrInvalidLookupDate = errors.New("invalid date format, expected YYYY-MM-DD")
+
+// ErrInvalidDateRange is returned when a v1 efinance date range is missing, incomplete or reversed
+var ErrInvalidDateRange = errors.New("invalid date range")
+
+// resolveIsoDateRange validates the date, or the inclusive date_from/date_to range, of a v1
+// efinance request on the MySQL iso_trx table and returns the range to query, which is
+// empty for a single date. A range longer than config.GetEfinanceMaxRangeDays returns
+// ErrDateRangeTooLarge. Without either, a required date returns ErrInvalidDateRange and
+// an optional one gives the config.GetEfinanceDefaultRangeDays ending on now.
+func resolveIsoDateRange(date, dateFrom, dateTo string, required bool, now time.Time) (string, string, error) {
+	if date != "" {
+		if dateFrom != "" || dateTo != "" {
+			return "", "", fmt.Errorf("%w: send either date or date_from and date_to, not both", ErrInvalidDateRange)
+		}
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			return "", "", fmt.Errorf("%w: %v", ErrInvalidLookupDate, err)
+		}
+		return "", "", nil
+	}
+
+	if dateFrom == "" && dateTo == "" {
+		if required {
+			return "", "", fmt.Errorf("%w: date or date_from and date_to are required", ErrInvalidDateRange)
+		}
+		days := config.GetEfinanceDefaultRangeDays()
+		return now.AddDate(0, 0, 1-days).Format("2006-01-02"), now.Format("2006-01-02"), nil
+	}
+	if dateFrom == "" || dateTo == "" {
+		return "", "", fmt.Errorf("%w: date_from and date_to must be sent together", ErrInvalidDateRange)
+	}
+
+	from, err := time.Parse("2006-01-02", dateFrom)
+	if err != nil {
+		return "", "", fmt.Errorf("%w: %v", ErrInvalidLookupDate, err)
+	}
+	to, err := time.Parse("2006-01-02", dateTo)
+	if err != nil {
+		return "", "", fmt.Errorf("%w: %v", ErrInvalidLookupDate, err)
+	}
+	if to.Before(from) {
+		return "", "", fmt.Errorf("%w: date_from %s is after date_to %s", ErrInvalidDateRange, dateFrom, dateTo)
+	}
+
+	maxDays := config.GetEfinanceMaxRangeDays()
+	if days := int(to.Sub(from).Hours()/24) + 1; days > maxDays {
+		return "", "", fmt.Errorf("%w: date_from to date_to spans %d days, more than the maximum of %d; narrow the range or split the query", ErrDateRangeTooLarge, days, maxDays)
+	}
+	return dateFrom, dateTo, nil
+}
+
+// GetTransactionsBySTAN retrieves the transactions with a STAN on a date, which runs from
+// midnight to midnight in timezone. A STAN is not unique, so every match is returned, in the
+// default order, up to config.DefaultPageSize rows. The flag reports whether more matched
+// than were returned.
+func (s *transactionService) GetTransactionsBySTAN(merchantID, stan, date string, fields []string, timezone string, panFormat string) ([]models.Transaction, bool, error) {
+	if len(fields) == 0 {
+		fields = config.DefaultFields
+	}
+	if timezone == "" {
+		timezone = "UTC"
+	}
+	if panFormat == "" {
+		panFormat = "bin_id_and_pan_id"
+	}
+
+	if err := s.ValidateFields(fields); err != nil {
+		return nil, false, fmt.Errorf("invalid fields: %v", err)
+	}
+
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid timezone '%s'", timezone)
+	}
+	dayStart, err := time.ParseInLocation("2006-01-02", date, location)
+	if err != nil {
+		return nil, false, ErrInvalidLookupDate
+	}
+	dayEnd := dayStart.AddDate(0, 0, 1).Add(-time.Nanosecond)
+
+	filter := &models.TransactionFilter{
+		STAN:         &stan,
+		DateTimeFrom: &dayStart,
+		DateTimeTo:   &dayEnd,
+	}
+	// One row past the cap tells whether the list was cut off
+	pagination := models.PaginationParams{Page: 1, Limit: config.DefaultPageSize + 1, SkipCount: true}
+
+	result, err := s.transactionRepo.GetTransactions(merchantID, filter, fields, nil, pagination, timezone, panFormat)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(result.Transactions) > config.DefaultPageSize {
+		return result.Transactions[:config.DefaultPageSize], true, nil
+	}
+	return result.Transactions, false, nil
+}
+
 // SearchTransactions performs advanced search
 func (s *transactionService) SearchTransactions(merchantID string, searchReq *models.TransactionSearchRequest, timezone string, panFormat string) (*TransactionServiceResult, error) {
 	// Set defaults
@@ -171,7 +546,7 @@ func (s *transactionService) SearchTransactions(merchantID string, searchReq *mo
 		searchReq.Pagination.Page = 1
 	}
 	if searchReq.Pagination.Limit < config.MinPageSize {
-		searchReq.Pagination.Limit = config.DefaultPageSize
+		searchReq.Pagination.Limit = config.GetDefaultPageSize(config.PageSizeEndpointSearch)
 	}
 	if searchReq.Pagination.Limit > config.MaxPageSize {
 		searchReq.Pagination.Limit = config.MaxPageSize
@@ -190,12 +565,26 @@ func (s *transactionService) SearchTransactions(merchantID string, searchReq *mo
 		return nil, fmt.Errorf("invalid fields: %v", err)
 	}
 
+	if searchReq.Filter == nil {
+		filter, err := s.ParseSearchQuery(searchReq.Query)
+		if err != nil {
+			return nil, fmt.Errorf("invalid query: %v", err)
+		}
+		searchReq.Filter = filter
+	}
+
 	result, err := s.transactionRepo.SearchTransactions(merchantID, searchReq, timezone, panFormat)
 	if err != nil {
 		return nil, err
 	}
 
-	return &TransactionServiceResult{
+	return newTransactionServiceResult(result), nil
+}
+
+// newTransactionServiceResult converts a repository list result into a service result.
+// When the total count was skipped, has_more is derived from whether a full page was returned.
+func newTransactionServiceResult(result *repositories.TransactionListResult) *TransactionServiceResult {
+	serviceResult := &TransactionServiceResult{
 		Transactions:     result.Transactions,
 		TotalCount:       result.TotalCount,
 		Page:             result.Page,
@@ -204,49 +593,366 @@ func (s *transactionService) SearchTransactions(merchantID string, searchReq *mo
 		CurrentPageCount: len(result.Transactions),
 		HasNext:          result.Page < result.TotalPages,
 		HasPrev:          result.Page > 1,
+		CountSkipped:     result.CountSkipped,
+		NextCursor:       result.NextCursor,
+		TotalAmount:      result.TotalAmount,
+		DebugSQL:         result.DebugSQL,
 		RequestedFields:  result.RequestedFields,
+	}
+
+	if result.CountSkipped {
+		serviceResult.HasMore = result.Limit > 0 && len(result.Transactions) >= result.Limit
+		serviceResult.HasNext = serviceResult.HasMore
+	}
+
+	return serviceResult
+}
+
+// GetMerchantSummary calculates merchant summary statistics, limited to metrics when set.
+// A range longer than config.GetMaxQueryRangeDays returns ErrDateRangeTooLarge.
+func (s *transactionService) GetMerchantSummary(merchantID string, filter *models.TransactionFilter, metrics models.SummaryMetrics) (*models.MerchantSummary, error) {
+	if err := boundDateRange(filter); err != nil {
+		return nil, err
+	}
+
+	// Generate cache key for merchant summary
+	cacheKey := s.generateMerchantSummaryCacheKey(merchantID, filter, metrics)
+
+	// Try to get from cache first (summaries can be cached). The cheap latest updated_at check
+	// catches writes made since the summary was cached, which nothing else invalidates.
+	var latestUpdatedAt *time.Time
+	if s.cacheService != nil {
+		var latestErr error
+		latestUpdatedAt, latestErr = s.transactionRepo.GetLatestUpdatedAt(merchantID)
+		if latestErr != nil {
+			utils.LogWarn("Failed to check merchant summary staleness", map[string]interface{}{
+				"merchant_id": merchantID,
+				"error":       latestErr.Error(),
+			})
+		}
+
+		if cachedSummary, err := s.cacheService.GetCachedMerchantSummary(cacheKey); err == nil && cachedSummary != nil {
+			if latestErr != nil || !isSummaryStale(cachedSummary, latestUpdatedAt) {
+				cachedSummary.Cached = true
+				cachedSummary.ETag = merchantSummaryETag(cachedSummary, metrics)
+				return cachedSummary, nil
+			}
+		}
+	}
+
+	// Cache miss or stale entry - calculate summary from database
+	summary, err := s.getRollupMerchantSummary(merchantID, filter, metrics)
+	if err != nil {
+		// Don't wrap the error to avoid exposing internal details
+		return nil, err
+	}
+	summary.DataUpdatedAt = latestUpdatedAt
+	summary.ComputedAt = time.Now().UTC()
+	summary.ETag = merchantSummaryETag(summary, metrics)
+
+	// Cache the summary for 30 minutes (aggregated data is safe to cache).
+	// Partial summaries are not cached so the next request retries the degraded source.
+	if s.cacheService != nil && len(summary.Warnings) == 0 {
+		ttl := config.GetRedisTTL()
+		s.cacheService.SetCachedMerchantSummary(cacheKey, summary, ttl)
+	}
+
+	return summary, nil
+}
+
+// isSummaryStale reports whether transactions were updated after a cached summary was computed.
+// Only the primary repository is checked; roll-up sources rely on the cache TTL.
+func isSummaryStale(cached *models.MerchantSummary, latestUpdatedAt *time.Time) bool {
+	if latestUpdatedAt == nil {
+		return false
+	}
+	return cached.DataUpdatedAt == nil || latestUpdatedAt.After(*cached.DataUpdatedAt)
+}
+
+// GetEmptyResultSuggestions explains why filter matched no transactions, using the date range
+// of the merchant's transactions. Dates are formatted in timezone.
+func (s *transactionService) GetEmptyResultSuggestions(merchantID string, filter *models.TransactionFilter, timezone string) ([]string, error) {
+	earliest, latest, err := s.transactionRepo.GetDateBounds(merchantID, filter)
+	if err != nil {
+		return nil, err
+	}
+	if earliest == nil || latest == nil {
+		return []string{"no transactions exist for this merchant"}, nil
+	}
+
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	earliestDate := earliest.In(loc).Format(time.RFC3339)
+	latestDate := latest.In(loc).Format(time.RFC3339)
+
+	switch {
+	case filter != nil && filter.DateTimeFrom != nil && filter.DateTimeFrom.After(*latest):
+		return []string{fmt.Sprintf("date range matched no transactions; latest transaction for this merchant is %s", latestDate)}, nil
+	case filter != nil && filter.DateTimeTo != nil && filter.DateTimeTo.Before(*earliest):
+		return []string{fmt.Sprintf("date range matched no transactions; earliest transaction for this merchant is %s", earliestDate)}, nil
+	default:
+		return []string{fmt.Sprintf("filter matched no transactions; this merchant has transactions from %s to %s", earliestDate, latestDate)}, nil
+	}
+}
+
+// CanAccessMerchant reports whether the authenticated merchantID may read requestedMerchantID's
+// data: its own, or that of a sub-merchant when merchantID is its provisioner
+func (s *transactionService) CanAccessMerchant(merchantID, requestedMerchantID string) (bool, error) {
+	if requestedMerchantID == merchantID {
+		return true, nil
+	}
+	return s.transactionRepo.IsMerchantUnderProvisioner(merchantID, requestedMerchantID)
+}
+
+// GetMerchantSummaries returns a page of summaries, one per merchant owned by the provisioner.
+// Only the primary repository is queried; roll-up sources are not merged per merchant.
+func (s *transactionService) GetMerchantSummaries(provisionerID string, filter *models.TransactionFilter, page, limit int) (*MerchantSummariesResult, error) {
+	if page < 1 {
+		page = 1
+	}
+	if limit < config.MinPageSize {
+		limit = config.DefaultPageSize
+	}
+	if limit > config.MaxPageSize {
+		limit = config.MaxPageSize
+	}
+
+	summaries, totalCount, err := s.transactionRepo.GetMerchantSummaries(provisionerID, filter, models.PaginationParams{Page: page, Limit: limit})
+	if err != nil {
+		// Don't wrap the error to avoid exposing internal details
+		return nil, err
+	}
+
+	return &MerchantSummariesResult{
+		Summaries:  summaries,
+		TotalCount: totalCount,
+		Page:       page,
+		Limit:      limit,
+		TotalPages: int((totalCount + int64(limit) - 1) / int64(limit)),
 	}, nil
 }
 
-// GetMerchantSummary calculates merchant summary statistics
-func (s *transactionService) GetMerchantSummary(merchantID string, filter *models.TransactionFilter) (*models.MerchantSummary, error) {
-	// Generate cache key for merchant summary
-	cacheKey := s.generateMerchantSummaryCacheKey(merchantID, filter)
+// ErrDateRangeTooLarge is returned when a tx_date_time range spans more days than
+// config.GetMaxQueryRangeDays allows
+var ErrDateRangeTooLarge = errors.New("date range too large")
+
+// boundDateRange gives the filter a bounded tx_date_time range and returns
+// ErrDateRangeTooLarge, with the span and the cap, when the range is longer than the
+// configured maximum. A filter without dates gets the last config.GetDefaultQueryRangeDays
+// days, from the start of a UTC day so repeated requests build the same filter; one with
+// only an upper bound starts the maximum range before it; one with only a lower bound runs
+// to now.
+func boundDateRange(filter *models.TransactionFilter) error {
+	if filter == nil {
+		return nil
+	}
+
+	maxDays := config.GetMaxQueryRangeDays()
+	maxRange := time.Duration(maxDays) * 24 * time.Hour
+	switch {
+	case filter.DateTimeFrom == nil && filter.DateTimeTo == nil:
+		from := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -config.GetDefaultQueryRangeDays())
+		filter.DateTimeFrom = &from
+		return nil
+	case filter.DateTimeFrom == nil:
+		from := filter.DateTimeTo.Add(-maxRange)
+		filter.DateTimeFrom = &from
+		return nil
+	}
+
+	to := time.Now()
+	if filter.DateTimeTo != nil {
+		to = *filter.DateTimeTo
+	}
+	span := to.Sub(*filter.DateTimeFrom)
+	if span <= maxRange {
+		return nil
+	}
+
+	days := int(math.Ceil(span.Hours() / 24))
+	return fmt.Errorf("%w: tx_date_time spans %d days, more than the maximum of %d; narrow the range or split the query", ErrDateRangeTooLarge, days, maxDays)
+}
+
+// ErrTooManyBuckets is returned when a timeseries would have more buckets than
+// config.GetMaxAggregationBuckets allows
+var ErrTooManyBuckets = errors.New("too many buckets for the requested interval and date range")
+
+// timeseriesIntervalDurations is the length of a bucket per interval, used to estimate bucket counts
+var timeseriesIntervalDurations = map[string]time.Duration{
+	"day":  24 * time.Hour,
+	"hour": time.Hour,
+}
+
+// GetTimeseries returns transaction counts and amounts bucketed by day or hour in the given timezone.
+// It returns ErrTooManyBuckets without querying when a bounded date range spans more buckets than
+// allowed, and when the query itself yields more. A range longer than config.GetMaxQueryRangeDays
+// returns ErrDateRangeTooLarge.
+func (s *transactionService) GetTimeseries(merchantID string, filter *models.TransactionFilter, interval, timezone string) ([]models.TimeseriesBucket, error) {
+	if err := boundDateRange(filter); err != nil {
+		return nil, err
+	}
+
+	maxBuckets := config.GetMaxAggregationBuckets()
+
+	if filter != nil && filter.DateTimeFrom != nil && filter.DateTimeTo != nil {
+		span := filter.DateTimeTo.Sub(*filter.DateTimeFrom)
+		if duration, ok := timeseriesIntervalDurations[interval]; ok && span/duration+1 > time.Duration(maxBuckets) {
+			return nil, ErrTooManyBuckets
+		}
+	}
+
+	// Fetch one bucket more than allowed to detect open-ended ranges that exceed the cap
+	buckets, err := s.transactionRepo.GetTimeseries(merchantID, filter, interval, timezone, maxBuckets+1)
+	if err != nil {
+		// Don't wrap the error to avoid exposing internal details
+		return nil, err
+	}
+	if len(buckets) > maxBuckets {
+		return nil, ErrTooManyBuckets
+	}
+
+	return buckets, nil
+}
+
+// CountTransactions returns the number of transactions matching the filter without loading them
+func (s *transactionService) CountTransactions(merchantID string, filter *models.TransactionFilter) (int64, error) {
+	count, err := s.transactionRepo.GetTransactionCount(merchantID, filter)
+	if err != nil {
+		// Don't wrap the error to avoid exposing internal details
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// GetMerchantDevices returns the devices and terminals the merchant has transacted on
+func (s *transactionService) GetMerchantDevices(merchantID string, filter *models.TransactionFilter) ([]models.MerchantDevice, error) {
+	devices, err := s.transactionRepo.GetMerchantDevices(merchantID, filter)
+	if err != nil {
+		// Don't wrap the error to avoid exposing internal details
+		return nil, err
+	}
+
+	return devices, nil
+}
+
+// getRollupMerchantSummary queries the primary repository and any roll-up sources, merging
+// the results. Failed sources are skipped with a warning; an error is only returned when
+// every source fails.
+func (s *transactionService) getRollupMerchantSummary(merchantID string, filter *models.TransactionFilter, metrics models.SummaryMetrics) (*models.MerchantSummary, error) {
+	sources := append([]RollupSource{{Name: "primary", Repo: s.transactionRepo}}, s.rollupSources...)
+
+	// Query the sources concurrently, bounded per request, then merge in source order
+	summaries := make([]*models.MerchantSummary, len(sources))
+	errs := make([]error, len(sources))
+	group := newQueryGroup()
+	for i, source := range sources {
+		group.Go(func() error {
+			summaries[i], errs[i] = source.Repo.GetMerchantSummary(merchantID, filter, metrics)
+			return nil // Failed sources degrade the summary rather than failing it
+		})
+	}
+	group.Wait()
+
+	var merged *models.MerchantSummary
+	var warnings []string
+	var firstErr error
+
+	for i, source := range sources {
+		summary, err := summaries[i], errs[i]
+		if err != nil {
+			utils.LogWarn("Skipping degraded roll-up source", map[string]interface{}{
+				"merchant_id": merchantID,
+				"source":      source.Name,
+				"error":       err.Error(),
+			})
+			if firstErr == nil {
+				firstErr = err
+			}
+			warnings = append(warnings, fmt.Sprintf("data source '%s' is unavailable; results are partial", source.Name))
+			continue
+		}
+
+		if merged == nil {
+			merged = summary
+		} else {
+			mergeMerchantSummaries(merged, summary)
+		}
+	}
+
+	if merged == nil {
+		return nil, firstErr
+	}
+
+	merged.Warnings = warnings
+	return merged, nil
+}
+
+// mergeMerchantSummaries adds the counts and amounts of from into into, widening the
+// date range and recomputing the derived averages
+func mergeMerchantSummaries(into, from *models.MerchantSummary) {
+	if (into.MerchantName == "" || into.MerchantName == "Unknown") && from.MerchantName != "" {
+		into.MerchantName = from.MerchantName
+	}
+
+	into.TotalTransactions += from.TotalTransactions
+	into.SuccessfulTransactions += from.SuccessfulTransactions
+	into.FailedTransactions += from.FailedTransactions
+	into.TotalAmount += from.TotalAmount
 
-	// Try to get from cache first (summaries can be cached)
-	if s.cacheService != nil {
-		if cachedSummary, err := s.cacheService.GetCachedMerchantSummary(cacheKey); err == nil && cachedSummary != nil {
-			return cachedSummary, nil
-		}
+	if len(from.ResponseCodeBreakdown) > 0 && into.ResponseCodeBreakdown == nil {
+		into.ResponseCodeBreakdown = make(map[string]int, len(from.ResponseCodeBreakdown))
+	}
+	for code, count := range from.ResponseCodeBreakdown {
+		into.ResponseCodeBreakdown[code] += count
 	}
 
-	// Cache miss - calculate summary from database
-	summary, err := s.transactionRepo.GetMerchantSummary(merchantID, filter)
-	if err != nil {
-		// Don't wrap the error to avoid exposing internal details
-		return nil, err
+	if !from.DateFrom.IsZero() && (into.DateFrom.IsZero() || from.DateFrom.Before(into.DateFrom)) {
+		into.DateFrom = from.DateFrom
+	}
+	if from.DateTo.After(into.DateTo) {
+		into.DateTo = from.DateTo
 	}
 
-	// Cache the summary for 30 minutes (aggregated data is safe to cache)
-	if s.cacheService != nil {
-		ttl := config.GetRedisTTL()
-		s.cacheService.SetCachedMerchantSummary(cacheKey, summary, ttl)
+	into.AverageAmount = 0
+	into.SuccessRate = 0
+	if into.TotalTransactions > 0 {
+		into.AverageAmount = config.RoundAmount(float64(into.TotalAmount)/float64(into.TotalTransactions), 0, config.GetAmountRoundingMode())
+		into.SuccessRate = (float64(into.SuccessfulTransactions) / float64(into.TotalTransactions)) * 100
 	}
 
-	return summary, nil
+	if into.Currency == nil {
+		into.Currency = from.Currency
+	}
+	if into.Currency != nil {
+		into.Currency.FormattedAmount = into.Currency.FormatAmount(into.TotalAmount)
+	}
 }
 
 // ParseAdvancedFilter parses filter string into TransactionFilter struct
 func (s *transactionService) ParseAdvancedFilter(filterString, timezone string) (*models.TransactionFilter, error) {
+	filter := &models.TransactionFilter{}
 	if filterString == "" {
-		return &models.TransactionFilter{}, nil
+		return filter, boundDateRange(filter)
 	}
 
-	filter := &models.TransactionFilter{}
-
 	// Split by AND, but preserve parenthesized groups
 	conditions := s.splitPreservingParentheses(filterString, " AND ")
 
+	// Cap the number of OR branches to avoid pathological queries
+	maxOrClauses := config.GetMaxFilterOrClauses()
+	orClauseCount := 0
+	for _, condition := range conditions {
+		if branches := strings.Count(condition, " OR "); branches > 0 {
+			orClauseCount += branches + 1
+		}
+	}
+	if orClauseCount > maxOrClauses {
+		return nil, fmt.Errorf("filter contains %d OR clauses, exceeding the maximum of %d; use the 'in' operator to match multiple values (e.g. response_code:in:00,05,51)", orClauseCount, maxOrClauses)
+	}
+
 	for _, condition := range conditions {
 		condition = strings.TrimSpace(condition)
 		if condition == "" {
@@ -275,9 +981,315 @@ func (s *transactionService) ParseAdvancedFilter(filterString, timezone string)
 		}
 	}
 
+	if err := boundDateRange(filter); err != nil {
+		return nil, err
+	}
+
+	return filter, nil
+}
+
+// searchRangeOperators maps the bounds of a range clause to SQL comparisons
+var searchRangeOperators = map[string]string{
+	"gte": ">=",
+	"lte": "<=",
+	"gt":  ">",
+	"lt":  "<",
+}
+
+// searchRangeFields are the fields a range clause can be applied to
+var searchRangeFields = map[string]bool{
+	"tx_date_time": true,
+	"amount":       true,
+}
+
+// searchMatchFields are the text fields a match clause can search
+var searchMatchFields = map[string]bool{
+	"merchant_name": true,
+	"description":   true,
+}
+
+// searchTermUnsupported lists mapped fields that cannot be compared with a term clause
+var searchTermUnsupported = map[string]string{
+	"pan":       "the PAN is only returned masked",
+	"meta":      "use user_ref to match on the meta reference",
+	"card_type": "card_type is not stored",
+}
+
+// ErrSearchQueryTooDeep is returned when a search query nests bool clauses deeper than
+// config.GetMaxSearchQueryDepth allows
+var ErrSearchQueryTooDeep = errors.New("search query is nested too deeply")
+
+// ParseSearchQuery parses an Elasticsearch-style query from the search endpoint into a
+// TransactionFilter. Supported clauses are bool (must/filter/should/must_not), term, range,
+// match and match_all; anything else is rejected rather than silently ignored.
+func (s *transactionService) ParseSearchQuery(query interface{}) (*models.TransactionFilter, error) {
+	filter := &models.TransactionFilter{}
+	if query == nil {
+		return filter, nil
+	}
+
+	clause, err := s.parseClause(query, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if clause != nil {
+		if clause.Bool != nil {
+			filter.Search = clause.Bool
+		} else {
+			filter.Search = &models.SearchBoolQuery{Must: []models.SearchClause{*clause}}
+		}
+	}
+
 	return filter, nil
 }
 
+// parseClause parses a single query clause nested in depth bool clauses. It returns nil for
+// match_all.
+func (s *transactionService) parseClause(clause interface{}, depth int) (*models.SearchClause, error) {
+	clauseMap, ok := clause.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("query clause must be an object")
+	}
+	if len(clauseMap) != 1 {
+		return nil, fmt.Errorf("query clause must have exactly one key, got %d", len(clauseMap))
+	}
+
+	for clauseType, body := range clauseMap {
+		switch clauseType {
+		case "bool":
+			if depth >= config.GetMaxSearchQueryDepth() {
+				return nil, fmt.Errorf("%w (at most %d levels of bool clauses)", ErrSearchQueryTooDeep, config.GetMaxSearchQueryDepth())
+			}
+			boolQuery, err := s.parseBoolQuery(body, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			return &models.SearchClause{Bool: boolQuery}, nil
+		case "term":
+			return s.parseTermClause(body)
+		case "range":
+			return s.parseRangeClause(body)
+		case "match":
+			return s.parseMatchClause(body)
+		case "match_all":
+			return nil, nil
+		default:
+			return nil, fmt.Errorf("unsupported query clause %q (supported: bool, term, range, match, match_all)", clauseType)
+		}
+	}
+
+	return nil, nil
+}
+
+// parseBoolQuery parses the body of a bool clause. Each occurrence type accepts a single
+// clause or an array of clauses; filter is treated the same as must.
+func (s *transactionService) parseBoolQuery(body interface{}, depth int) (*models.SearchBoolQuery, error) {
+	boolMap, ok := body.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("bool clause must be an object")
+	}
+
+	boolQuery := &models.SearchBoolQuery{}
+	for occurrence, value := range boolMap {
+		var target *[]models.SearchClause
+		switch occurrence {
+		case "must", "filter":
+			target = &boolQuery.Must
+		case "should":
+			target = &boolQuery.Should
+		case "must_not":
+			target = &boolQuery.MustNot
+		default:
+			return nil, fmt.Errorf("unsupported bool option %q (supported: must, filter, should, must_not)", occurrence)
+		}
+
+		clauses, ok := value.([]interface{})
+		if !ok {
+			clauses = []interface{}{value}
+		}
+
+		for _, raw := range clauses {
+			clause, err := s.parseClause(raw, depth)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", occurrence, err)
+			}
+			if clause == nil {
+				if occurrence == "must_not" {
+					return nil, fmt.Errorf("must_not: match_all would exclude every transaction")
+				}
+				continue
+			}
+			*target = append(*target, *clause)
+		}
+	}
+
+	return boolQuery, nil
+}
+
+// parseTermClause parses {"term": {"field": value}} for any field in config.FieldMappings
+func (s *transactionService) parseTermClause(body interface{}) (*models.SearchClause, error) {
+	field, value, err := singleFieldClause("term", body)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, exists := config.FieldMappings[field]; !exists {
+		return nil, fmt.Errorf("term: unknown field %q", field)
+	}
+	if reason, unsupported := searchTermUnsupported[field]; unsupported {
+		return nil, fmt.Errorf("term: field %q is not supported (%s)", field, reason)
+	}
+	if (field == "settlement_status" || field == "settlement_date") && !config.IsSettlementColumnsEnabled() {
+		return nil, fmt.Errorf("term: field %q is not available", field)
+	}
+
+	if valueMap, ok := value.(map[string]interface{}); ok {
+		value, ok = valueMap["value"]
+		if !ok || len(valueMap) != 1 {
+			return nil, fmt.Errorf("term: field %q must be a value or {\"value\": ...}", field)
+		}
+	}
+
+	switch v := value.(type) {
+	case string, bool:
+	case float64:
+		if field == "amount" {
+			value = int64(v)
+		}
+	default:
+		return nil, fmt.Errorf("term: field %q must be a string, number or boolean", field)
+	}
+
+	return &models.SearchClause{Field: field, Operator: "=", Value: value}, nil
+}
+
+// parseRangeClause parses {"range": {"field": {"gte": ..., "lt": ...}}} on tx_date_time or amount.
+// Bounds are ANDed, so the clause becomes a nested bool query when more than one is given.
+func (s *transactionService) parseRangeClause(body interface{}) (*models.SearchClause, error) {
+	field, value, err := singleFieldClause("range", body)
+	if err != nil {
+		return nil, err
+	}
+
+	if !searchRangeFields[field] {
+		return nil, fmt.Errorf("range: field %q is not supported (supported: tx_date_time, amount)", field)
+	}
+
+	bounds, ok := value.(map[string]interface{})
+	if !ok || len(bounds) == 0 {
+		return nil, fmt.Errorf("range: field %q needs at least one of gte, lte, gt, lt", field)
+	}
+
+	for bound := range bounds {
+		if _, known := searchRangeOperators[bound]; !known {
+			return nil, fmt.Errorf("range: unsupported option %q (supported: gte, lte, gt, lt)", bound)
+		}
+	}
+
+	var clauses []models.SearchClause
+	for _, bound := range []string{"gte", "gt", "lte", "lt"} {
+		raw, exists := bounds[bound]
+		if !exists {
+			continue
+		}
+
+		var boundValue interface{}
+		if field == "amount" {
+			amount, err := parseSearchAmount(raw)
+			if err != nil {
+				return nil, fmt.Errorf("range: invalid amount for %s: %v", bound, raw)
+			}
+			boundValue = amount
+		} else {
+			date, err := parseSearchDate(bound, raw)
+			if err != nil {
+				return nil, fmt.Errorf("range: invalid date for %s: %v", bound, raw)
+			}
+			boundValue = date
+		}
+
+		clauses = append(clauses, models.SearchClause{Field: field, Operator: searchRangeOperators[bound], Value: boundValue})
+	}
+
+	if len(clauses) == 1 {
+		return &clauses[0], nil
+	}
+	return &models.SearchClause{Bool: &models.SearchBoolQuery{Must: clauses}}, nil
+}
+
+// parseMatchClause parses {"match": {"field": "text"}} as a case-insensitive substring match
+func (s *transactionService) parseMatchClause(body interface{}) (*models.SearchClause, error) {
+	field, value, err := singleFieldClause("match", body)
+	if err != nil {
+		return nil, err
+	}
+
+	if !searchMatchFields[field] {
+		return nil, fmt.Errorf("match: field %q is not supported (supported: merchant_name, description)", field)
+	}
+
+	if valueMap, ok := value.(map[string]interface{}); ok {
+		value, ok = valueMap["query"]
+		if !ok || len(valueMap) != 1 {
+			return nil, fmt.Errorf("match: field %q must be a string or {\"query\": ...}", field)
+		}
+	}
+
+	text, ok := value.(string)
+	if !ok || strings.TrimSpace(text) == "" {
+		return nil, fmt.Errorf("match: field %q requires a non-empty string", field)
+	}
+
+	return &models.SearchClause{Field: field, Operator: "ILIKE", Value: text}, nil
+}
+
+// singleFieldClause unpacks a leaf clause body of the form {"field": value}
+func singleFieldClause(clauseType string, body interface{}) (string, interface{}, error) {
+	bodyMap, ok := body.(map[string]interface{})
+	if !ok || len(bodyMap) != 1 {
+		return "", nil, fmt.Errorf("%s clause must name exactly one field", clauseType)
+	}
+
+	for field, value := range bodyMap {
+		return field, value, nil
+	}
+
+	return "", nil, nil
+}
+
+// parseSearchAmount accepts a JSON number in minor units or an amount string as used in filters
+func parseSearchAmount(value interface{}) (int64, error) {
+	switch v := value.(type) {
+	case float64:
+		return int64(v), nil
+	case string:
+		return parseAmount(v)
+	default:
+		return 0, fmt.Errorf("unsupported amount type")
+	}
+}
+
+// parseSearchDate parses a range bound on tx_date_time. Date-only upper bounds (lte) and
+// exclusive lower bounds (gt) cover the whole day, matching the list filter behaviour.
+func parseSearchDate(bound string, value interface{}) (time.Time, error) {
+	text, ok := value.(string)
+	if !ok {
+		return time.Time{}, fmt.Errorf("date must be a string")
+	}
+
+	date, err := parseDateTime(text)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if isDateOnly(text) && (bound == "lte" || bound == "gt") {
+		date = date.Add(24*time.Hour - time.Nanosecond)
+	}
+
+	return date, nil
+}
+
 // splitPreservingParentheses splits a string by delimiter while preserving parenthesized groups
 func (s *transactionService) splitPreservingParentheses(input, delimiter string) []string {
 	var result []string
@@ -330,31 +1342,128 @@ func (s *transactionService) parseCondition(condition string, filter *models.Tra
 		return fmt.Errorf("invalid operator '%s' for field '%s'", operator, field)
 	}
 
+	if operator == "isnull" || operator == "isnotnull" {
+		return parseNullCondition(field, operator, value, filter)
+	}
+
 	switch field {
 	case "merchant_id":
-		if operator == "eq" {
+		switch operator {
+		case "eq":
 			filter.MerchantID = &value
+		case "ne":
+			filter.MerchantIDNot = &value
 		}
 	case "device_id":
 		if operator == "eq" {
 			filter.DeviceID = &value
 		}
+	case "profile_id":
+		if operator != "eq" {
+			return fmt.Errorf("operator '%s' is not supported for field '%s' (use eq)", operator, field)
+		}
+		filter.ProfileID = &value
+	case "stan", "rrn":
+		if operator != "eq" {
+			return fmt.Errorf("operator '%s' is not supported for field '%s' (use eq)", operator, field)
+		}
+		if strings.TrimSpace(value) == "" {
+			return fmt.Errorf("eq operator for field '%s' requires a value", field)
+		}
+		if field == "stan" {
+			filter.STAN = &value
+		} else {
+			filter.RRN = &value
+		}
+	case "payment_tx_ref":
+		switch operator {
+		case "eq":
+			filter.PaymentTxRef = &value
+		case "like", "ilike":
+			if strings.TrimSpace(value) == "" {
+				return fmt.Errorf("%s operator for field '%s' requires a value", operator, field)
+			}
+			filter.PaymentTxRefLike = &value
+		default:
+			return fmt.Errorf("operator '%s' is not supported for field '%s' (use eq, like or ilike)", operator, field)
+		}
 	case "response_code":
-		if operator == "eq" {
+		switch operator {
+		case "eq":
 			filter.ResponseCode = &value
+		case "ne":
+			filter.ResponseCodeNot = &value
+		case "in":
+			values, err := parseInList(field, value)
+			if err != nil {
+				return err
+			}
+			filter.ResponseCodeIn = values
 		}
 	case "currency_code":
-		if operator == "eq" {
+		switch operator {
+		case "eq":
 			filter.CurrencyCode = &value
+		case "ne":
+			filter.CurrencyCodeNot = &value
+		case "in":
+			values, err := parseInList(field, value)
+			if err != nil {
+				return err
+			}
+			filter.CurrencyCodeIn = values
+		}
+	case "merchant_name", "description":
+		if operator != "like" && operator != "ilike" {
+			return fmt.Errorf("operator '%s' is not supported for field '%s' (use like or ilike)", operator, field)
+		}
+		if strings.TrimSpace(value) == "" {
+			return fmt.Errorf("%s operator for field '%s' requires a value", operator, field)
+		}
+		if field == "merchant_name" {
+			filter.MerchantNameLike = &value
+		} else {
+			filter.DescriptionLike = &value
 		}
 	case "tx_log_type":
-		if operator == "eq" {
+		switch operator {
+		case "eq":
 			filter.TxLogType = &value
+		case "in":
+			values, err := parseInList(field, value)
+			if err != nil {
+				return err
+			}
+			for _, txLogType := range values {
+				if _, ok := models.PaymentTxTypeID(txLogType); !ok {
+					return fmt.Errorf("unknown tx_log_type '%s'", txLogType)
+				}
+			}
+			filter.TxLogTypeIn = values
+		}
+	case "completed":
+		if operator != "eq" {
+			return fmt.Errorf("operator '%s' is not supported for field '%s' (use eq)", operator, field)
 		}
+		completed, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid value '%s' for field '%s' (must be true or false)", value, field)
+		}
+		filter.Completed = &completed
 	case "amount":
 		return s.parseAmountCondition(operator, value, filter)
 	case "tx_date_time":
-		return s.parseDateCondition(operator, value, filter, timezone)
+		return s.parseDateCondition(field, operator, value, filter, timezone)
+	case "settlement_status", "settlement_date":
+		if !config.IsSettlementColumnsEnabled() {
+			return fmt.Errorf("filtering on '%s' is not available on this deployment", field)
+		}
+		if field == "settlement_date" {
+			return s.parseDateCondition(field, operator, value, filter, timezone)
+		}
+		if operator == "eq" {
+			filter.SettlementStatus = &value
+		}
 	default:
 		return fmt.Errorf("unsupported filter field: %s", field)
 	}
@@ -362,6 +1471,50 @@ func (s *transactionService) parseCondition(condition string, filter *models.Tra
 	return nil
 }
 
+// parseNullCondition parses an isnull or isnotnull condition on a nullable field. The value
+// may be empty or true; false inverts the check, so auth_code:isnull:false is isnotnull.
+func parseNullCondition(field, operator, value string, filter *models.TransactionFilter) error {
+	if _, mapped := config.FieldMappings[field]; !mapped {
+		return fmt.Errorf("unsupported filter field: %s", field)
+	}
+	if !config.NullableFilterFields[field] {
+		return fmt.Errorf("operator '%s' is not supported for field '%s' (it is never null)", operator, field)
+	}
+
+	isNull := operator == "isnull"
+	if value != "" {
+		check, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid value '%s' for operator '%s' (must be true, false or empty)", value, operator)
+		}
+		if !check {
+			isNull = !isNull
+		}
+	}
+
+	if filter.NullFields == nil {
+		filter.NullFields = make(map[string]bool)
+	}
+	filter.NullFields[field] = isNull
+	return nil
+}
+
+// parseInList splits a comma-separated "in" operator value, dropping empty segments
+func parseInList(field, value string) ([]string, error) {
+	var values []string
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			values = append(values, part)
+		}
+	}
+
+	if len(values) == 0 {
+		return nil, fmt.Errorf("in operator for field '%s' requires at least one value", field)
+	}
+
+	return values, nil
+}
+
 // parseAmountCondition parses amount-related conditions
 func (s *transactionService) parseAmountCondition(operator, value string, filter *models.TransactionFilter) error {
 	switch operator {
@@ -382,27 +1535,66 @@ func (s *transactionService) parseAmountCondition(operator, value string, filter
 		if len(parts) != 2 {
 			return fmt.Errorf("between operator requires two comma-separated values")
 		}
-		if min, err := parseAmount(strings.TrimSpace(parts[0])); err == nil {
-			filter.AmountMin = &min
-		} else {
+		min, err := parseAmount(strings.TrimSpace(parts[0]))
+		if err != nil {
 			return fmt.Errorf("invalid min amount: %s", parts[0])
 		}
-		if max, err := parseAmount(strings.TrimSpace(parts[1])); err == nil {
-			filter.AmountMax = &max
-		} else {
+		max, err := parseAmount(strings.TrimSpace(parts[1]))
+		if err != nil {
 			return fmt.Errorf("invalid max amount: %s", parts[1])
 		}
+		if min > max {
+			return fmt.Errorf("invalid amount range: min %s is greater than max %s", strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]))
+		}
+		filter.AmountMin = &min
+		filter.AmountMax = &max
+	case "near":
+		parts := strings.Split(value, ",")
+		if len(parts) != 2 {
+			return fmt.Errorf("near operator requires an amount and a tolerance (e.g. amount:near:1000,50)")
+		}
+		center, err := parseAmount(strings.TrimSpace(parts[0]))
+		if err != nil {
+			return fmt.Errorf("invalid amount: %s", parts[0])
+		}
+		tolerance, err := parseAmount(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return fmt.Errorf("invalid amount tolerance: %s", parts[1])
+		}
+		min, max, err := amountToleranceRange(center, tolerance)
+		if err != nil {
+			return err
+		}
+		filter.AmountMin = &min
+		filter.AmountMax = &max
 	}
 
 	return nil
 }
 
-// parseDateCondition parses date-related conditions
-func (s *transactionService) parseDateCondition(operator, value string, filter *models.TransactionFilter, timezone string) error {
+// amountToleranceRange returns the inclusive range center ± tolerance. The tolerance must not
+// be negative and the range must fit in an int64.
+func amountToleranceRange(center, tolerance int64) (int64, int64, error) {
+	if tolerance < 0 {
+		return 0, 0, fmt.Errorf("invalid amount tolerance: %d must not be negative", tolerance)
+	}
+	if center > math.MaxInt64-tolerance || center < math.MinInt64+tolerance {
+		return 0, 0, fmt.Errorf("invalid amount tolerance: %d ± %d is out of range", center, tolerance)
+	}
+	return center - tolerance, center + tolerance, nil
+}
+
+// parseDateCondition parses date-related conditions for tx_date_time and settlement_date
+func (s *transactionService) parseDateCondition(field, operator, value string, filter *models.TransactionFilter, timezone string) error {
+	dateFrom, dateTo := &filter.DateTimeFrom, &filter.DateTimeTo
+	if field == "settlement_date" {
+		dateFrom, dateTo = &filter.SettlementDateFrom, &filter.SettlementDateTo
+	}
+
 	switch operator {
 	case "gte":
 		if date, err := parseDateTime(value); err == nil {
-			filter.DateTimeFrom = &date
+			*dateFrom = &date
 		} else {
 			return fmt.Errorf("invalid date format: %s", value)
 		}
@@ -412,7 +1604,7 @@ func (s *transactionService) parseDateCondition(operator, value string, filter *
 			if isDateOnly(value) {
 				date = date.Add(24*time.Hour - time.Nanosecond)
 			}
-			filter.DateTimeTo = &date
+			*dateTo = &date
 		} else {
 			return fmt.Errorf("invalid date format: %s", value)
 		}
@@ -421,25 +1613,41 @@ func (s *transactionService) parseDateCondition(operator, value string, filter *
 		if len(parts) != 2 {
 			return fmt.Errorf("between operator requires two comma-separated values")
 		}
-		if from, err := parseDateTime(strings.TrimSpace(parts[0])); err == nil {
-			filter.DateTimeFrom = &from
-		} else {
+		from, err := parseDateTime(strings.TrimSpace(parts[0]))
+		if err != nil {
 			return fmt.Errorf("invalid from date: %s", parts[0])
 		}
-		if to, err := parseDateTime(strings.TrimSpace(parts[1])); err == nil {
-			// If the "to" date is just a date (not a datetime), extend it to end of day
-			if isDateOnly(strings.TrimSpace(parts[1])) {
-				to = to.Add(24*time.Hour - time.Nanosecond)
-			}
-			filter.DateTimeTo = &to
-		} else {
+		to, err := parseDateTime(strings.TrimSpace(parts[1]))
+		if err != nil {
 			return fmt.Errorf("invalid to date: %s", parts[1])
 		}
+		// If the "to" date is just a date (not a datetime), extend it to end of day
+		if isDateOnly(strings.TrimSpace(parts[1])) {
+			to = to.Add(24*time.Hour - time.Nanosecond)
+		}
+		if from.After(to) {
+			return fmt.Errorf("invalid date range: from %s is after to %s", strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]))
+		}
+		*dateFrom = &from
+		*dateTo = &to
 	}
 
 	return nil
 }
 
+// ValidateTimezone checks that timezone is an IANA zone name before it reaches SQL, where it
+// is interpolated into TIMEZONE() calls. "Local" and empty names are rejected because
+// Postgres doesn't know them.
+func (s *transactionService) ValidateTimezone(timezone string) error {
+	if timezone == "" || timezone == "Local" {
+		return fmt.Errorf("invalid timezone '%s'", timezone)
+	}
+	if _, err := time.LoadLocation(timezone); err != nil {
+		return fmt.Errorf("invalid timezone '%s'", timezone)
+	}
+	return nil
+}
+
 // ParseSort parses sort string into SortParams slice
 func (s *transactionService) ParseSort(sortString string) ([]models.SortParams, error) {
 	if sortString == "" {
@@ -467,9 +1675,11 @@ func (s *transactionService) ParseSort(sortString string) ([]models.SortParams,
 			return nil, fmt.Errorf("invalid sort direction '%s' for field '%s'", direction, field)
 		}
 
-		// Validate field exists in mappings
-		if _, exists := config.FieldMappings[field]; !exists && field != "tx_date_time" {
-			return nil, fmt.Errorf("invalid sort field: %s", field)
+		// Validate field exists in mappings or is a sortable computed field
+		_, mapped := config.FieldMappings[field]
+		_, computed := config.SortableComputedFields[field]
+		if !mapped && !computed && field != "tx_date_time" {
+			return nil, &InvalidSortFieldError{Field: field, AllowedFields: AllowedSortFields()}
 		}
 
 		sortParams = append(sortParams, models.SortParams{
@@ -481,6 +1691,32 @@ func (s *transactionService) ParseSort(sortString string) ([]models.SortParams,
 	return sortParams, nil
 }
 
+// InvalidSortFieldError is returned by ParseSort for a field that cannot be sorted on
+type InvalidSortFieldError struct {
+	Field         string
+	AllowedFields []string
+}
+
+func (e *InvalidSortFieldError) Error() string {
+	return fmt.Sprintf("invalid sort field: %s (allowed: %s)", e.Field, strings.Join(e.AllowedFields, ", "))
+}
+
+// AllowedSortFields returns the fields ParseSort accepts, sorted by name
+func AllowedSortFields() []string {
+	fields := make([]string, 0, len(config.FieldMappings)+len(config.SortableComputedFields)+1)
+	if _, mapped := config.FieldMappings["tx_date_time"]; !mapped {
+		fields = append(fields, "tx_date_time")
+	}
+	for field := range config.FieldMappings {
+		fields = append(fields, field)
+	}
+	for field := range config.SortableComputedFields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
 // ValidateFields validates that all requested fields are valid
 func (s *transactionService) ValidateFields(fields []string) error {
 	validFields := make(map[string]bool)
@@ -488,11 +1724,9 @@ func (s *transactionService) ValidateFields(fields []string) error {
 		validFields[field] = true
 	}
 
-	// Add some additional valid fields
-	validFields["created_at"] = true
-	validFields["updated_at"] = true
-	validFields["description"] = true
-	validFields["currency_info"] = true
+	for field := range config.ColumnFields {
+		validFields[field] = true
+	}
 
 	for _, field := range fields {
 		if !validFields[field] {
@@ -591,6 +1825,15 @@ func (s *transactionService) generateTransactionCacheKey(merchantID string, para
 		if params.Filter.DeviceID != nil {
 			keyParts = append(keyParts, fmt.Sprintf("filter_device_id:%s", *params.Filter.DeviceID))
 		}
+		if params.Filter.ProfileID != nil {
+			keyParts = append(keyParts, fmt.Sprintf("filter_profile_id:%s", *params.Filter.ProfileID))
+		}
+		if params.Filter.PaymentTxRef != nil {
+			keyParts = append(keyParts, fmt.Sprintf("filter_payment_tx_ref:%s", *params.Filter.PaymentTxRef))
+		}
+		if params.Filter.PaymentTxRefLike != nil {
+			keyParts = append(keyParts, fmt.Sprintf("filter_payment_tx_ref_like:%s", *params.Filter.PaymentTxRefLike))
+		}
 		if params.Filter.ResponseCode != nil {
 			keyParts = append(keyParts, fmt.Sprintf("filter_response_code:%s", *params.Filter.ResponseCode))
 		}
@@ -609,6 +1852,18 @@ func (s *transactionService) generateTransactionCacheKey(merchantID string, para
 		if params.Filter.DateTimeTo != nil {
 			keyParts = append(keyParts, fmt.Sprintf("filter_date_to:%s", params.Filter.DateTimeTo.Format(time.RFC3339)))
 		}
+		if params.Filter.DateField != "" {
+			keyParts = append(keyParts, fmt.Sprintf("filter_date_field:%s", params.Filter.DateField))
+		}
+		if params.Filter.IncludeInactive {
+			keyParts = append(keyParts, "filter_include_inactive")
+		}
+		if params.Filter.IncludeIncomplete {
+			keyParts = append(keyParts, "filter_include_incomplete")
+		}
+		if params.Filter.Completed != nil {
+			keyParts = append(keyParts, fmt.Sprintf("filter_completed:%t", *params.Filter.Completed))
+		}
 	}
 
 	// Join all parts and create a hash
@@ -618,14 +1873,36 @@ func (s *transactionService) generateTransactionCacheKey(merchantID string, para
 	return fmt.Sprintf("%s:%s", config.GetRedisKeyPrefix(), hash[:16])
 }
 
+// merchantSummaryETag returns the entity tag of a summary: a hash of the serialized summary
+// and the metrics it is rendered with
+func merchantSummaryETag(summary *models.MerchantSummary, metrics models.SummaryMetrics) string {
+	tagged := *summary
+	tagged.ComputedAt = time.Time{} // recomputing unchanged data keeps the tag
+	data, err := json.Marshal(tagged)
+	if err != nil {
+		return ""
+	}
+
+	sortedMetrics := append([]string{}, metrics...)
+	sort.Strings(sortedMetrics)
+	data = append(data, "|metrics:"+strings.Join(sortedMetrics, ",")...)
+	return fmt.Sprintf(`"%x"`, md5.Sum(data))
+}
+
 // generateMerchantSummaryCacheKey creates a unique cache key for merchant summary queries
-func (s *transactionService) generateMerchantSummaryCacheKey(merchantID string, filter *models.TransactionFilter) string {
+func (s *transactionService) generateMerchantSummaryCacheKey(merchantID string, filter *models.TransactionFilter, metrics models.SummaryMetrics) string {
 	// Create a string representation of the parameters
 	keyParts := []string{
 		"summary",
 		merchantID,
 	}
 
+	if len(metrics) > 0 {
+		sortedMetrics := append([]string{}, metrics...)
+		sort.Strings(sortedMetrics)
+		keyParts = append(keyParts, "metrics:"+strings.Join(sortedMetrics, ","))
+	}
+
 	// Add filter parameters if present
 	if filter != nil {
 		if filter.DateTimeFrom != nil {
@@ -640,6 +1917,15 @@ func (s *transactionService) generateMerchantSummaryCacheKey(merchantID string,
 		if filter.AmountMax != nil {
 			keyParts = append(keyParts, fmt.Sprintf("max_amount:%d", *filter.AmountMax))
 		}
+		if filter.IncludeInactive {
+			keyParts = append(keyParts, "include_inactive")
+		}
+		if filter.IncludeIncomplete {
+			keyParts = append(keyParts, "include_incomplete")
+		}
+		if filter.Completed != nil {
+			keyParts = append(keyParts, fmt.Sprintf("completed:%t", *filter.Completed))
+		}
 	}
 
 	// Join all parts and create a hash
@@ -669,10 +1955,12 @@ func (s *transactionService) GetTransactionTotals(merchantID string, request mod
 
 // GetTransactionLookup retrieves transaction totals by description for a specific date and device
 func (s *transactionService) GetTransactionLookup(request models.TransactionLookupRequest) (*models.TransactionLookupResponse, error) {
-	// Validate the date format
-	if _, err := time.Parse("2006-01-02", request.Date); err != nil {
-		return nil, fmt.Errorf("invalid date format, expected YYYY-MM-DD: %w", err)
+	// Validate the date, or the range and its size
+	dateFrom, dateTo, err := resolveIsoDateRange(request.Date, request.DateFrom, request.DateTo, true, time.Now())
+	if err != nil {
+		return nil, err
 	}
+	request.DateFrom, request.DateTo = dateFrom, dateTo
 
 	// Note: device_id is optional - if not provided, totals will be returned for all devices
 
@@ -686,12 +1974,12 @@ func (s *transactionService) GetTransactionLookup(request models.TransactionLook
 }
 // SearchTransactionDetails searches for detailed transaction information based on multiple criteria
 func (s *transactionService) SearchTransactionDetails(request models.IsoTransactionSearchRequest) (*models.IsoTransactionSearchResponse, error) {
-	// Validate the date format if provided
-	if request.Date != "" {
-		if _, err := time.Parse("2006-01-02", request.Date); err != nil {
-			return nil, fmt.Errorf("invalid date format, expected YYYY-MM-DD: %w", err)
-		}
+	// Validate the date or range; a search without one covers the default range
+	dateFrom, dateTo, err := resolveIsoDateRange(request.Date, request.DateFrom, request.DateTo, false, time.Now())
+	if err != nil {
+		return nil, err
 	}
+	request.DateFrom, request.DateTo = dateFrom, dateTo
 	
 	// Note: All filter fields are optional - no fields are required
 	// The repository will build conditional WHERE clauses based on provided filters
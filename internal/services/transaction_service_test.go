@@ -1,12 +1,23 @@
 package services
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"aken_reporting_service/internal/config"
 	"aken_reporting_service/internal/models"
+	"aken_reporting_service/internal/repositories"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNewTransactionService(t *testing.T) {
@@ -391,3 +402,1447 @@ func timePtr(t time.Time) *time.Time {
 func int64Ptr(i int64) *int64 {
 	return &i
 }
+
+func TestNewTransactionServiceResult_CountSkipped(t *testing.T) {
+	tests := []struct {
+		name         string
+		rows         int
+		limit        int
+		expectedMore bool
+	}{
+		{"full page has more", 10, 10, true},
+		{"partial page is last", 4, 10, false},
+		{"empty page is last", 0, 10, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := newTransactionServiceResult(&repositories.TransactionListResult{
+				Transactions: make([]models.Transaction, tt.rows),
+				Page:         1,
+				Limit:        tt.limit,
+				CountSkipped: true,
+			})
+
+			assert.True(t, result.CountSkipped)
+			assert.Equal(t, tt.expectedMore, result.HasMore)
+			assert.Equal(t, tt.expectedMore, result.HasNext)
+			assert.Equal(t, tt.rows, result.CurrentPageCount)
+		})
+	}
+}
+
+func TestNewTransactionServiceResult_WithTotal(t *testing.T) {
+	result := newTransactionServiceResult(&repositories.TransactionListResult{
+		Transactions: make([]models.Transaction, 10),
+		TotalCount:   25,
+		Page:         3,
+		Limit:        10,
+		TotalPages:   3,
+	})
+
+	assert.False(t, result.CountSkipped)
+	assert.False(t, result.HasMore)
+	assert.False(t, result.HasNext)
+	assert.True(t, result.HasPrev)
+}
+
+func TestGetTransactions_PassesSkipCount(t *testing.T) {
+	repo := &fakeTransactionRepo{
+		listResult: &repositories.TransactionListResult{
+			Transactions: make([]models.Transaction, 5),
+			Page:         1,
+			Limit:        5,
+			CountSkipped: true,
+		},
+	}
+	service := NewTransactionService(repo, nil)
+
+	result, err := service.GetTransactions("merchant-1", &GetTransactionsParams{
+		Page:      1,
+		Limit:     5,
+		SkipCount: true,
+	})
+
+	assert.NoError(t, err)
+	assert.True(t, repo.lastPagination.SkipCount)
+	assert.True(t, result.HasMore)
+}
+
+// fakeTransactionRepo is a minimal in-memory TransactionRepository for service tests.
+// Methods not overridden here panic via the embedded nil interface.
+type fakeTransactionRepo struct {
+	repositories.TransactionRepository
+
+	listResult     *repositories.TransactionListResult
+	listErr        error
+	summaryResult  *models.MerchantSummary
+	summaryErr     error
+	lastFilter     *models.TransactionFilter
+	lastPagination models.PaginationParams
+	lastIDs        []string
+	dayFacets      []models.DayFacet
+	lastTimezone   string
+	lastMerchantID string
+	summaries      []models.MerchantSummary
+	summariesTotal int64
+	summaryCalls   int
+	latestUpdated  *time.Time
+	latestErr      error
+	earliest       *time.Time
+	pollRows       []models.Transaction        // Ordered by (updated_at, id), as PollTransactions returns them
+	pollCursors    []*models.TransactionCursor // lastSeen passed to each PollTransactions call
+	currencies     []models.Currency
+	currencyCalls  int
+}
+
+func (f *fakeTransactionRepo) GetCurrencies() ([]models.Currency, error) {
+	f.currencyCalls++
+	return f.currencies, nil
+}
+
+func (f *fakeTransactionRepo) PollTransactions(ctx context.Context, merchantID string, filter *models.TransactionFilter, fields []string, after *models.TransactionCursor, limit int, timezone string, panFormat string) ([]models.Transaction, error) {
+	f.pollCursors = append(f.pollCursors, after)
+	batch := []models.Transaction{}
+	for _, tx := range f.pollRows {
+		if after != nil && (tx.UpdatedAt.Before(after.UpdatedAt) || tx.UpdatedAt.Equal(after.UpdatedAt) && tx.ID <= after.ID) {
+			continue
+		}
+		if len(batch) == limit {
+			break
+		}
+		batch = append(batch, tx)
+	}
+	return batch, nil
+}
+
+func (f *fakeTransactionRepo) GetMerchantSummaries(provisionerID string, filter *models.TransactionFilter, pagination models.PaginationParams) ([]models.MerchantSummary, int64, error) {
+	f.lastMerchantID = provisionerID
+	f.lastFilter = filter
+	f.lastPagination = pagination
+	return f.summaries, f.summariesTotal, f.summaryErr
+}
+
+func (f *fakeTransactionRepo) GetDailyCounts(merchantID string, filter *models.TransactionFilter, timezone string) ([]models.DayFacet, error) {
+	f.lastTimezone = timezone
+	return f.dayFacets, nil
+}
+
+func (f *fakeTransactionRepo) GetTransactionsByIDs(merchantID string, ids []string, fields []string, timezone string, panFormat string) ([]models.Transaction, error) {
+	f.lastIDs = ids
+	return []models.Transaction{}, f.listErr
+}
+
+func (f *fakeTransactionRepo) GetTransactionsByRRNs(merchantID string, rrns []string, fields []string, timezone string, panFormat string, limit int) ([]models.Transaction, error) {
+	f.lastIDs = rrns
+	f.lastPagination = models.PaginationParams{Limit: limit}
+	if f.listResult != nil {
+		return f.listResult.Transactions, f.listErr
+	}
+	return []models.Transaction{}, f.listErr
+}
+
+func (f *fakeTransactionRepo) GetTransactions(merchantID string, filter *models.TransactionFilter, fields []string, sort []models.SortParams, pagination models.PaginationParams, timezone string, panFormat string) (*repositories.TransactionListResult, error) {
+	f.lastFilter = filter
+	f.lastPagination = pagination
+	return f.listResult, f.listErr
+}
+
+func (f *fakeTransactionRepo) SearchTransactions(merchantID string, searchReq *models.TransactionSearchRequest, timezone string, panFormat string) (*repositories.TransactionListResult, error) {
+	f.lastFilter = searchReq.Filter
+	f.lastPagination = searchReq.Pagination
+	return f.listResult, f.listErr
+}
+
+func TestDefaultPageSizePerEndpoint(t *testing.T) {
+	t.Setenv("DEFAULT_PAGE_SIZE_LIST", "250")
+	t.Setenv("DEFAULT_PAGE_SIZE_SEARCH", "25")
+
+	repo := &fakeTransactionRepo{listResult: &repositories.TransactionListResult{Page: 1}}
+	service := NewTransactionService(repo, nil)
+
+	_, err := service.GetTransactions("merchant-1", &GetTransactionsParams{Page: 1})
+	require.NoError(t, err)
+	assert.Equal(t, 250, repo.lastPagination.Limit)
+
+	_, err = service.SearchTransactions("merchant-1", &models.TransactionSearchRequest{}, "UTC", "")
+	require.NoError(t, err)
+	assert.Equal(t, 25, repo.lastPagination.Limit)
+}
+
+func TestGetMerchantSummary_MergesRollupSources(t *testing.T) {
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC)
+
+	primary := &fakeTransactionRepo{summaryResult: &models.MerchantSummary{
+		MerchantID: "provisioner-1", MerchantName: "Provisioner", TotalTransactions: 10,
+		SuccessfulTransactions: 8, FailedTransactions: 2, TotalAmount: 1000, DateFrom: from.AddDate(0, 0, 5), DateTo: to,
+		ResponseCodeBreakdown: map[string]int{"00": 8, "05": 2},
+	}}
+	shard := &fakeTransactionRepo{summaryResult: &models.MerchantSummary{
+		MerchantID: "provisioner-1", MerchantName: "Provisioner", TotalTransactions: 10,
+		SuccessfulTransactions: 2, FailedTransactions: 8, TotalAmount: 3000, DateFrom: from, DateTo: to.AddDate(0, 0, -5),
+		ResponseCodeBreakdown: map[string]int{"00": 2, "05": 3, "51": 5},
+	}}
+
+	service := NewTransactionService(primary, nil, RollupSource{Name: "shard-2", Repo: shard})
+
+	summary, err := service.GetMerchantSummary("provisioner-1", nil, nil)
+
+	assert.NoError(t, err)
+	assert.Empty(t, summary.Warnings)
+	assert.Equal(t, 20, summary.TotalTransactions)
+	assert.Equal(t, 10, summary.SuccessfulTransactions)
+	assert.Equal(t, int64(4000), summary.TotalAmount)
+	assert.Equal(t, 200.0, summary.AverageAmount)
+	assert.Equal(t, 50.0, summary.SuccessRate)
+	assert.Equal(t, from, summary.DateFrom)
+	assert.Equal(t, to, summary.DateTo)
+	assert.Equal(t, map[string]int{"00": 10, "05": 5, "51": 5}, summary.ResponseCodeBreakdown)
+}
+
+func TestGetMerchantSummary_RoundsMergedAverage(t *testing.T) {
+	tests := []struct {
+		mode    string
+		average float64
+	}{
+		{"", 2.5},
+		{config.RoundingHalfUp, 3},
+		{config.RoundingHalfEven, 2},
+		{config.RoundingTruncate, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			t.Setenv("AMOUNT_ROUNDING_MODE", tt.mode)
+
+			primary := &fakeTransactionRepo{summaryResult: &models.MerchantSummary{
+				MerchantID: "provisioner-1", TotalTransactions: 1, TotalAmount: 2,
+			}}
+			shard := &fakeTransactionRepo{summaryResult: &models.MerchantSummary{
+				MerchantID: "provisioner-1", TotalTransactions: 1, TotalAmount: 3,
+			}}
+			service := NewTransactionService(primary, nil, RollupSource{Name: "shard-2", Repo: shard})
+
+			summary, err := service.GetMerchantSummary("provisioner-1", nil, nil)
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.average, summary.AverageAmount)
+		})
+	}
+}
+
+func TestGetMerchantSummary_CurrencyFormatsMergedTotal(t *testing.T) {
+	primary := &fakeTransactionRepo{summaryResult: &models.MerchantSummary{
+		MerchantID: "provisioner-1", TotalTransactions: 1, TotalAmount: 1050,
+		Currency: &models.CurrencyInfo{Code: "710", Name: "ZAR", Symbol: "R", Exponent: 2, FormattedAmount: "R 10.50"},
+	}}
+	shard := &fakeTransactionRepo{summaryResult: &models.MerchantSummary{
+		MerchantID: "provisioner-1", TotalTransactions: 1, TotalAmount: 2000,
+	}}
+
+	service := NewTransactionService(primary, nil, RollupSource{Name: "shard-2", Repo: shard})
+
+	summary, err := service.GetMerchantSummary("provisioner-1", nil, nil)
+
+	require.NoError(t, err)
+	require.NotNil(t, summary.Currency)
+	assert.Equal(t, "ZAR", summary.Currency.Name)
+	assert.Equal(t, 2, summary.Currency.Exponent)
+	assert.Equal(t, "R 30.50", summary.Currency.FormattedAmount)
+}
+
+func TestGetMerchantSummary_DegradedSourceReturnsPartial(t *testing.T) {
+	primary := &fakeTransactionRepo{summaryResult: &models.MerchantSummary{
+		MerchantID: "provisioner-1", TotalTransactions: 4, SuccessfulTransactions: 4, TotalAmount: 400,
+	}}
+	shard := &fakeTransactionRepo{summaryErr: errors.New("connection refused")}
+
+	service := NewTransactionService(primary, nil, RollupSource{Name: "shard-2", Repo: shard})
+
+	summary, err := service.GetMerchantSummary("provisioner-1", nil, nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 4, summary.TotalTransactions)
+	assert.Len(t, summary.Warnings, 1)
+	assert.Contains(t, summary.Warnings[0], "shard-2")
+}
+
+func TestGetMerchantSummary_AllSourcesDown(t *testing.T) {
+	primary := &fakeTransactionRepo{summaryErr: errors.New("connection refused")}
+	shard := &fakeTransactionRepo{summaryErr: errors.New("timeout")}
+
+	service := NewTransactionService(primary, nil, RollupSource{Name: "shard-2", Repo: shard})
+
+	summary, err := service.GetMerchantSummary("provisioner-1", nil, nil)
+
+	assert.Error(t, err)
+	assert.Nil(t, summary)
+}
+
+func (f *fakeTransactionRepo) GetMerchantSummary(merchantID string, filter *models.TransactionFilter, metrics models.SummaryMetrics) (*models.MerchantSummary, error) {
+	f.summaryCalls++
+	f.lastFilter = filter
+	if f.summaryErr != nil {
+		return nil, f.summaryErr
+	}
+	summary := *f.summaryResult
+	return &summary, nil
+}
+
+func (f *fakeTransactionRepo) GetLatestUpdatedAt(merchantID string) (*time.Time, error) {
+	return f.latestUpdated, f.latestErr
+}
+
+func (f *fakeTransactionRepo) GetDateBounds(merchantID string, filter *models.TransactionFilter) (*time.Time, *time.Time, error) {
+	return f.earliest, f.latestUpdated, f.latestErr
+}
+
+// fakeSummaryCache stores merchant summaries as JSON, as Redis would
+type fakeSummaryCache struct {
+	CacheService
+
+	summaries map[string][]byte
+}
+
+func (f *fakeSummaryCache) GetCachedMerchantSummary(key string) (*models.MerchantSummary, error) {
+	data, ok := f.summaries[key]
+	if !ok {
+		return nil, nil
+	}
+	var summary models.MerchantSummary
+	err := json.Unmarshal(data, &summary)
+	return &summary, err
+}
+
+func (f *fakeSummaryCache) SetCachedMerchantSummary(key string, summary *models.MerchantSummary, ttl time.Duration) error {
+	data, err := json.Marshal(summary)
+	f.summaries[key] = data
+	return err
+}
+
+// fakeValueCache stores generic cache values as JSON, as Redis would
+type fakeValueCache struct {
+	CacheService
+
+	values map[string][]byte
+	ttls   map[string]time.Duration
+}
+
+func (f *fakeValueCache) Get(key string, dest interface{}) error {
+	data, ok := f.values[key]
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(data, dest)
+}
+
+func (f *fakeValueCache) Set(key string, value interface{}, ttl time.Duration) error {
+	data, err := json.Marshal(value)
+	f.values[key] = data
+	f.ttls[key] = ttl
+	return err
+}
+
+func TestGetCurrencies_CachedForTheCurrencyTTL(t *testing.T) {
+	t.Setenv("CURRENCY_CACHE_TTL_SECONDS", "3600")
+	repo := &fakeTransactionRepo{currencies: []models.Currency{
+		{CurrencyCode: "0710", CurrencyName: "ZAR", CurrDelim: 2},
+		{CurrencyCode: "0818", CurrencyName: "EGP", CurrDelim: 2},
+	}}
+	cache := &fakeValueCache{values: map[string][]byte{}, ttls: map[string]time.Duration{}}
+	service := NewTransactionService(repo, cache)
+
+	result, err := service.GetCurrencies()
+	require.NoError(t, err)
+	assert.Equal(t, repo.currencies, result.Currencies)
+	assert.False(t, result.Cached)
+	assert.Equal(t, 1, repo.currencyCalls)
+	assert.Equal(t, time.Hour, cache.ttls[config.GetRedisKeyPrefix()+currenciesCacheKey])
+
+	result, err = service.GetCurrencies()
+	require.NoError(t, err)
+	assert.Equal(t, repo.currencies, result.Currencies)
+	assert.True(t, result.Cached)
+	assert.Equal(t, 1, repo.currencyCalls, "the second call is served from the cache")
+}
+
+func TestGetCurrencies_CachedDataAsOfIsWhenRead(t *testing.T) {
+	repo := &fakeTransactionRepo{currencies: []models.Currency{{CurrencyCode: "0710", CurrencyName: "ZAR", CurrDelim: 2}}}
+	cache := &fakeValueCache{values: map[string][]byte{}, ttls: map[string]time.Duration{}}
+	service := NewTransactionService(repo, cache)
+
+	readAt := time.Now().UTC().Add(-time.Hour).Truncate(time.Second)
+	require.NoError(t, cache.Set(config.GetRedisKeyPrefix()+currenciesCacheKey,
+		&CurrenciesResult{Currencies: repo.currencies, DataAsOf: readAt}, time.Hour))
+
+	cached, err := service.GetCurrencies()
+	require.NoError(t, err)
+	assert.True(t, cached.Cached)
+	assert.Equal(t, readAt, cached.DataAsOf)
+
+	live, err := NewTransactionService(repo, nil).GetCurrencies()
+	require.NoError(t, err)
+	assert.False(t, live.Cached)
+	assert.True(t, cached.DataAsOf.Before(live.DataAsOf))
+}
+
+func TestGetMerchantSummary_ETagCachedWithSummary(t *testing.T) {
+	firstWrite := time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)
+	repo := &fakeTransactionRepo{
+		summaryResult: &models.MerchantSummary{MerchantID: "merchant-1", TotalTransactions: 3},
+		latestUpdated: &firstWrite,
+	}
+	cache := &fakeSummaryCache{summaries: map[string][]byte{}}
+	service := NewTransactionService(repo, cache)
+
+	computed, err := service.GetMerchantSummary("merchant-1", nil, nil)
+	require.NoError(t, err)
+	require.NotEmpty(t, computed.ETag)
+
+	cached, err := service.GetMerchantSummary("merchant-1", nil, nil)
+	require.NoError(t, err)
+	assert.True(t, cached.Cached)
+	assert.Equal(t, computed.ETag, cached.ETag, "the ETag is stored with the cached summary")
+
+	withMetrics, err := service.GetMerchantSummary("merchant-1", nil, models.SummaryMetrics{"count"})
+	require.NoError(t, err)
+	assert.NotEqual(t, computed.ETag, withMetrics.ETag, "other metrics render another body")
+
+	secondWrite := firstWrite.Add(time.Minute)
+	repo.latestUpdated = &secondWrite
+	repo.summaryResult = &models.MerchantSummary{MerchantID: "merchant-1", TotalTransactions: 4}
+	refreshed, err := service.GetMerchantSummary("merchant-1", nil, nil)
+	require.NoError(t, err)
+	assert.False(t, refreshed.Cached)
+	assert.NotEqual(t, computed.ETag, refreshed.ETag)
+}
+
+func TestGetMerchantSummary_CachedComputedAtIsWhenComputed(t *testing.T) {
+	write := time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)
+	repo := &fakeTransactionRepo{
+		summaryResult: &models.MerchantSummary{MerchantID: "merchant-1", TotalTransactions: 3},
+		latestUpdated: &write,
+	}
+	cache := &fakeSummaryCache{summaries: map[string][]byte{}}
+	service := NewTransactionService(repo, cache)
+
+	before := time.Now().UTC()
+	computed, err := service.GetMerchantSummary("merchant-1", nil, nil)
+	require.NoError(t, err)
+	assert.False(t, computed.ComputedAt.Before(before))
+
+	// Age the cached entry as if it was computed an hour ago
+	computedAt := computed.ComputedAt.Add(-time.Hour)
+	for key, data := range cache.summaries {
+		var entry models.MerchantSummary
+		require.NoError(t, json.Unmarshal(data, &entry))
+		entry.ComputedAt = computedAt
+		require.NoError(t, cache.SetCachedMerchantSummary(key, &entry, time.Hour))
+	}
+
+	cached, err := service.GetMerchantSummary("merchant-1", nil, nil)
+	require.NoError(t, err)
+	assert.True(t, cached.Cached)
+	assert.True(t, computedAt.Equal(cached.ComputedAt))
+	assert.Equal(t, computed.ETag, merchantSummaryETag(cached, nil), "the computation time is not part of the ETag")
+
+	live, err := service.GetMerchantSummary("merchant-1", nil, models.SummaryMetrics{"count"})
+	require.NoError(t, err)
+	assert.False(t, live.Cached)
+	assert.True(t, cached.ComputedAt.Before(live.ComputedAt))
+}
+
+func TestGetMerchantSummary_RefreshesWhenNewDataArrives(t *testing.T) {
+	firstWrite := time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)
+	repo := &fakeTransactionRepo{
+		summaryResult: &models.MerchantSummary{MerchantID: "merchant-1", TotalTransactions: 3},
+		latestUpdated: &firstWrite,
+	}
+	service := NewTransactionService(repo, &fakeSummaryCache{summaries: map[string][]byte{}})
+
+	summary, err := service.GetMerchantSummary("merchant-1", nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 3, summary.TotalTransactions)
+	assert.Equal(t, 1, repo.summaryCalls)
+	assert.False(t, summary.Cached)
+
+	// No new data: served from cache
+	repo.summaryResult = &models.MerchantSummary{MerchantID: "merchant-1", TotalTransactions: 4}
+	summary, err = service.GetMerchantSummary("merchant-1", nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 3, summary.TotalTransactions)
+	assert.Equal(t, 1, repo.summaryCalls)
+	assert.True(t, summary.Cached)
+
+	// A transaction was written after the summary was cached: recomputed
+	secondWrite := firstWrite.Add(time.Minute)
+	repo.latestUpdated = &secondWrite
+	summary, err = service.GetMerchantSummary("merchant-1", nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 4, summary.TotalTransactions)
+	assert.Equal(t, 2, repo.summaryCalls)
+	assert.False(t, summary.Cached)
+
+	// The refreshed entry is fresh again
+	_, err = service.GetMerchantSummary("merchant-1", nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 2, repo.summaryCalls)
+}
+
+func TestGetMerchantSummary_ServesCacheWhenStalenessCheckFails(t *testing.T) {
+	repo := &fakeTransactionRepo{summaryResult: &models.MerchantSummary{MerchantID: "merchant-1", TotalTransactions: 3}}
+	service := NewTransactionService(repo, &fakeSummaryCache{summaries: map[string][]byte{}})
+
+	_, err := service.GetMerchantSummary("merchant-1", nil, nil)
+	require.NoError(t, err)
+
+	repo.latestErr = errors.New("connection refused")
+	_, err = service.GetMerchantSummary("merchant-1", nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, repo.summaryCalls)
+}
+
+func TestGetEmptyResultSuggestions(t *testing.T) {
+	earliest := time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)
+	latest := time.Date(2024, 9, 30, 17, 0, 0, 0, time.UTC)
+	repo := &fakeTransactionRepo{earliest: &earliest, latestUpdated: &latest}
+	service := NewTransactionService(repo, nil)
+
+	before := time.Date(2023, 12, 31, 0, 0, 0, 0, time.UTC)
+	after := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	responseCode := "05"
+
+	tests := []struct {
+		name   string
+		filter *models.TransactionFilter
+		want   string
+	}{
+		{"range before first transaction", &models.TransactionFilter{DateTimeTo: &before},
+			"date range matched no transactions; earliest transaction for this merchant is 2024-03-01T10:00:00+02:00"},
+		{"range after last transaction", &models.TransactionFilter{DateTimeFrom: &after},
+			"date range matched no transactions; latest transaction for this merchant is 2024-09-30T19:00:00+02:00"},
+		{"other filters", &models.TransactionFilter{ResponseCode: &responseCode},
+			"filter matched no transactions; this merchant has transactions from 2024-03-01T10:00:00+02:00 to 2024-09-30T19:00:00+02:00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			suggestions, err := service.GetEmptyResultSuggestions("merchant-1", tt.filter, "Africa/Johannesburg")
+			require.NoError(t, err)
+			assert.Equal(t, []string{tt.want}, suggestions)
+		})
+	}
+
+	t.Run("merchant without transactions", func(t *testing.T) {
+		service := NewTransactionService(&fakeTransactionRepo{}, nil)
+		suggestions, err := service.GetEmptyResultSuggestions("merchant-1", nil, "UTC")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"no transactions exist for this merchant"}, suggestions)
+	})
+}
+
+func TestIsSummaryStale(t *testing.T) {
+	cachedAt := time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)
+	later := cachedAt.Add(time.Second)
+
+	assert.False(t, isSummaryStale(&models.MerchantSummary{DataUpdatedAt: &cachedAt}, &cachedAt))
+	assert.True(t, isSummaryStale(&models.MerchantSummary{DataUpdatedAt: &cachedAt}, &later))
+	assert.True(t, isSummaryStale(&models.MerchantSummary{}, &later), "data arrived for a merchant that had none")
+	assert.False(t, isSummaryStale(&models.MerchantSummary{}, nil))
+}
+
+func TestParseAdvancedFilter_MaxOrClauses(t *testing.T) {
+	t.Setenv("MAX_FILTER_OR_CLAUSES", "3")
+	service := NewTransactionService(nil, nil)
+
+	tests := []struct {
+		name    string
+		filter  string
+		wantErr bool
+	}{
+		{"no OR clauses", "response_code:eq:00 AND amount:gte:100", false},
+		{"at the cap", "(response_code:eq:00 OR response_code:eq:05 OR response_code:eq:51)", false},
+		{"over the cap", "(response_code:eq:00 OR response_code:eq:05 OR response_code:eq:51 OR response_code:eq:91)", true},
+		{"over the cap across groups", "(response_code:eq:00 OR response_code:eq:05) AND (tx_log_type:eq:payment OR tx_log_type:eq:refund)", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := service.ParseAdvancedFilter(tt.filter, "UTC")
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), "'in' operator")
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestParseAdvancedFilter_MaxOrClausesDefault(t *testing.T) {
+	t.Setenv("MAX_FILTER_OR_CLAUSES", "")
+	service := NewTransactionService(nil, nil)
+
+	branches := make([]string, 21)
+	for i := range branches {
+		branches[i] = "response_code:eq:05"
+	}
+
+	_, err := service.ParseAdvancedFilter(strings.Join(branches, " OR "), "UTC")
+	assert.Error(t, err)
+}
+
+func TestGetTransactionsByIDs_DedupesIDs(t *testing.T) {
+	repo := &fakeTransactionRepo{}
+	service := NewTransactionService(repo, nil)
+
+	ids := make([]string, 0, 5001)
+	for i := 0; i < 5000; i++ {
+		ids = append(ids, fmt.Sprintf("00000000-0000-0000-0000-%012d", i))
+	}
+	ids = append(ids, ids[0], " ", "")
+
+	_, err := service.GetTransactionsByIDs("merchant-1", ids, nil, "", "")
+
+	assert.NoError(t, err)
+	assert.Len(t, repo.lastIDs, 5000)
+}
+
+func TestGetTransactionsByIDs_EmptyListSkipsRepository(t *testing.T) {
+	repo := &fakeTransactionRepo{}
+	service := NewTransactionService(repo, nil)
+
+	result, err := service.GetTransactionsByIDs("merchant-1", []string{" "}, nil, "", "")
+
+	assert.NoError(t, err)
+	assert.NotNil(t, result)
+	assert.Empty(t, result)
+	assert.Nil(t, repo.lastIDs)
+}
+
+func TestGetTransactionsByRRNs_DedupesAndKeepsLeadingZeros(t *testing.T) {
+	repo := &fakeTransactionRepo{}
+	service := NewTransactionService(repo, nil)
+
+	_, _, err := service.GetTransactionsByRRNs("merchant-1", []string{"000012345678", " 000012345678 ", "12345678", ""}, nil, "", "")
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"000012345678", "12345678"}, repo.lastIDs)
+
+	repo.lastIDs = nil
+	result, _, err := service.GetTransactionsByRRNs("merchant-1", []string{" "}, nil, "", "")
+	assert.NoError(t, err)
+	assert.Empty(t, result)
+	assert.Nil(t, repo.lastIDs, "a list of blank RRNs skips the repository")
+}
+
+func TestGetTransactionsByRRNs_ReportsMoreThanTheCap(t *testing.T) {
+	rows := make([]models.Transaction, config.MaxBatchRRNMatches+1)
+	repo := &fakeTransactionRepo{listResult: &repositories.TransactionListResult{Transactions: rows}}
+	service := NewTransactionService(repo, nil)
+
+	transactions, hasMore, err := service.GetTransactionsByRRNs("merchant-1", []string{"000012345678"}, nil, "", "")
+
+	require.NoError(t, err)
+	assert.Equal(t, config.MaxBatchRRNMatches+1, repo.lastPagination.Limit, "one row past the cap is read")
+	assert.Len(t, transactions, config.MaxBatchRRNMatches)
+	assert.True(t, hasMore)
+}
+
+func TestResolveIsoDateRange(t *testing.T) {
+	t.Setenv("EFINANCE_MAX_RANGE_DAYS", "31")
+	t.Setenv("EFINANCE_DEFAULT_RANGE_DAYS", "7")
+	now := time.Date(2025, 3, 10, 15, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name             string
+		date, from, to   string
+		required         bool
+		wantFrom, wantTo string
+		wantErr          error
+	}{
+		{name: "single date", date: "2025-01-15", required: true},
+		{name: "range at the maximum", from: "2025-01-01", to: "2025-01-31", required: true, wantFrom: "2025-01-01", wantTo: "2025-01-31"},
+		{name: "single day range", from: "2025-01-15", to: "2025-01-15", wantFrom: "2025-01-15", wantTo: "2025-01-15"},
+		{name: "range over the maximum", from: "2025-01-01", to: "2025-02-01", required: true, wantErr: ErrDateRangeTooLarge},
+		{name: "reversed range", from: "2025-01-31", to: "2025-01-01", wantErr: ErrInvalidDateRange},
+		{name: "open range", from: "2025-01-01", wantErr: ErrInvalidDateRange},
+		{name: "date and range", date: "2025-01-15", from: "2025-01-01", to: "2025-01-31", wantErr: ErrInvalidDateRange},
+		{name: "bad date", date: "15/01/2025", wantErr: ErrInvalidLookupDate},
+		{name: "bad range date", from: "2025-01-01", to: "2025-13-01", wantErr: ErrInvalidLookupDate},
+		{name: "missing required date", required: true, wantErr: ErrInvalidDateRange},
+		{name: "default range for an optional date", wantFrom: "2025-03-04", wantTo: "2025-03-10"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, to, err := resolveIsoDateRange(tt.date, tt.from, tt.to, tt.required, now)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantFrom, from)
+			assert.Equal(t, tt.wantTo, to)
+		})
+	}
+}
+
+func TestResolveIsoDateRange_ConfigurableMaximum(t *testing.T) {
+	t.Setenv("EFINANCE_MAX_RANGE_DAYS", "7")
+
+	_, _, err := resolveIsoDateRange("", "2025-01-01", "2025-01-07", true, time.Now())
+	assert.NoError(t, err)
+
+	_, _, err = resolveIsoDateRange("", "2025-01-01", "2025-01-08", true, time.Now())
+	assert.ErrorIs(t, err, ErrDateRangeTooLarge)
+	assert.Contains(t, err.Error(), "spans 8 days, more than the maximum of 7")
+}
+
+func TestGetTransactionsBySTAN_FiltersTheDayInTimezone(t *testing.T) {
+	repo := &fakeTransactionRepo{listResult: &repositories.TransactionListResult{
+		Transactions: []models.Transaction{{ID: "tx-1"}, {ID: "tx-2"}},
+	}}
+	service := NewTransactionService(repo, nil)
+
+	transactions, hasMore, err := service.GetTransactionsBySTAN("merchant-1", "000123", "2025-01-15", nil, "Africa/Johannesburg", "")
+
+	require.NoError(t, err)
+	assert.Len(t, transactions, 2)
+	assert.False(t, hasMore)
+	require.NotNil(t, repo.lastFilter.STAN)
+	assert.Equal(t, "000123", *repo.lastFilter.STAN)
+	assert.Equal(t, time.Date(2025, 1, 14, 22, 0, 0, 0, time.UTC), repo.lastFilter.DateTimeFrom.UTC())
+	assert.Equal(t, time.Date(2025, 1, 15, 21, 59, 59, 999999999, time.UTC), repo.lastFilter.DateTimeTo.UTC())
+	assert.True(t, repo.lastPagination.SkipCount)
+}
+
+func TestGetTransactionsBySTAN_ReportsMoreThanTheCap(t *testing.T) {
+	rows := make([]models.Transaction, config.DefaultPageSize+1)
+	repo := &fakeTransactionRepo{listResult: &repositories.TransactionListResult{Transactions: rows}}
+	service := NewTransactionService(repo, nil)
+
+	transactions, hasMore, err := service.GetTransactionsBySTAN("merchant-1", "000123", "2025-01-15", nil, "UTC", "")
+
+	require.NoError(t, err)
+	assert.Len(t, transactions, config.DefaultPageSize)
+	assert.True(t, hasMore)
+	assert.Equal(t, config.DefaultPageSize+1, repo.lastPagination.Limit)
+}
+
+func TestGetTransactionsBySTAN_InvalidDate(t *testing.T) {
+	repo := &fakeTransactionRepo{}
+	service := NewTransactionService(repo, nil)
+
+	_, _, err := service.GetTransactionsBySTAN("merchant-1", "000123", "15/01/2025", nil, "UTC", "")
+
+	assert.ErrorIs(t, err, ErrInvalidLookupDate)
+	assert.Nil(t, repo.lastFilter)
+}
+
+func TestParseAdvancedFilter_NotEqual(t *testing.T) {
+	service := NewTransactionService(nil, nil)
+
+	filter, err := service.ParseAdvancedFilter("response_code:ne:00 AND currency_code:ne:0710 AND merchant_id:ne:abc", "UTC")
+
+	assert.NoError(t, err)
+	assert.Nil(t, filter.ResponseCode)
+	assert.Equal(t, "00", *filter.ResponseCodeNot)
+	assert.Equal(t, "0710", *filter.CurrencyCodeNot)
+	assert.Equal(t, "abc", *filter.MerchantIDNot)
+}
+
+func TestParseAdvancedFilter_InOperator(t *testing.T) {
+	service := NewTransactionService(nil, nil)
+
+	filter, err := service.ParseAdvancedFilter("response_code:in:00, 10,,11 AND currency_code:in:0710,0840", "UTC")
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"00", "10", "11"}, filter.ResponseCodeIn)
+	assert.Equal(t, []string{"0710", "0840"}, filter.CurrencyCodeIn)
+}
+
+func TestParseAdvancedFilter_Completed(t *testing.T) {
+	service := NewTransactionService(nil, nil)
+
+	filter, err := service.ParseAdvancedFilter("completed:eq:false", "UTC")
+	assert.NoError(t, err)
+	require.NotNil(t, filter.Completed)
+	assert.False(t, *filter.Completed)
+
+	_, err = service.ParseAdvancedFilter("completed:eq:maybe", "UTC")
+	assert.Error(t, err)
+
+	_, err = service.ParseAdvancedFilter("completed:ne:true", "UTC")
+	assert.Error(t, err)
+}
+
+func TestParseAdvancedFilter_ProfileID(t *testing.T) {
+	service := NewTransactionService(nil, nil)
+
+	filter, err := service.ParseAdvancedFilter("profile_id:eq:profile-1", "UTC")
+	assert.NoError(t, err)
+	require.NotNil(t, filter.ProfileID)
+	assert.Equal(t, "profile-1", *filter.ProfileID)
+
+	_, err = service.ParseAdvancedFilter("profile_id:ne:profile-1", "UTC")
+	assert.Error(t, err)
+}
+
+func TestParseAdvancedFilter_STAN(t *testing.T) {
+	service := NewTransactionService(nil, nil)
+
+	filter, err := service.ParseAdvancedFilter("stan:eq:000123", "UTC")
+	assert.NoError(t, err)
+	require.NotNil(t, filter.STAN)
+	assert.Equal(t, "000123", *filter.STAN, "leading zeros are kept")
+
+	filter, err = service.ParseAdvancedFilter("stan:eq:000123 AND response_code:eq:00", "UTC")
+	assert.NoError(t, err)
+	require.NotNil(t, filter.STAN)
+	require.NotNil(t, filter.ResponseCode)
+
+	_, err = service.ParseAdvancedFilter("stan:gt:000123", "UTC")
+	assert.Error(t, err)
+
+	_, err = service.ParseAdvancedFilter("stan:eq:", "UTC")
+	assert.Error(t, err)
+}
+
+func TestParseAdvancedFilter_RRN(t *testing.T) {
+	service := NewTransactionService(nil, nil)
+
+	filter, err := service.ParseAdvancedFilter("rrn:eq:000012345678", "UTC")
+	assert.NoError(t, err)
+	require.NotNil(t, filter.RRN)
+	assert.Equal(t, "000012345678", *filter.RRN, "leading zeros are kept")
+	assert.Nil(t, filter.STAN)
+
+	filter, err = service.ParseAdvancedFilter("rrn:eq:000012345678 AND stan:eq:000123", "UTC")
+	assert.NoError(t, err)
+	require.NotNil(t, filter.RRN)
+	require.NotNil(t, filter.STAN)
+	assert.Equal(t, "000123", *filter.STAN)
+
+	_, err = service.ParseAdvancedFilter("rrn:like:0000123", "UTC")
+	assert.Error(t, err)
+
+	_, err = service.ParseAdvancedFilter("rrn:eq: ", "UTC")
+	assert.Error(t, err)
+}
+
+func TestParseAdvancedFilter_PaymentTxRef(t *testing.T) {
+	service := NewTransactionService(nil, nil)
+
+	filter, err := service.ParseAdvancedFilter("payment_tx_ref:eq:INV-1001", "UTC")
+	assert.NoError(t, err)
+	require.NotNil(t, filter.PaymentTxRef)
+	assert.Equal(t, "INV-1001", *filter.PaymentTxRef)
+	assert.Nil(t, filter.PaymentTxRefLike)
+
+	filter, err = service.ParseAdvancedFilter("payment_tx_ref:like:INV-10", "UTC")
+	assert.NoError(t, err)
+	require.NotNil(t, filter.PaymentTxRefLike)
+	assert.Equal(t, "INV-10", *filter.PaymentTxRefLike)
+	assert.Nil(t, filter.PaymentTxRef)
+
+	_, err = service.ParseAdvancedFilter("payment_tx_ref:gt:INV", "UTC")
+	assert.Error(t, err)
+
+	sort, err := service.ParseSort("payment_tx_ref:asc")
+	assert.NoError(t, err)
+	assert.Equal(t, []models.SortParams{{Field: "payment_tx_ref", Direction: "asc"}}, sort)
+}
+
+func TestParseAdvancedFilter_BetweenRangeOrder(t *testing.T) {
+	service := NewTransactionService(nil, nil)
+
+	_, err := service.ParseAdvancedFilter("amount:between:1000,100", "UTC")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "min 1000 is greater than max 100")
+
+	_, err = service.ParseAdvancedFilter("tx_date_time:between:2025-02-01,2025-01-01", "UTC")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "from 2025-02-01 is after to 2025-01-01")
+
+	// Equal bounds are a valid single-value or single-day range
+	filter, err := service.ParseAdvancedFilter("amount:between:100,100 AND tx_date_time:between:2025-01-01,2025-01-01", "UTC")
+	require.NoError(t, err)
+	assert.Equal(t, int64(100), *filter.AmountMin)
+	assert.Equal(t, int64(100), *filter.AmountMax)
+	assert.Equal(t, time.Date(2025, 1, 1, 23, 59, 59, 999999999, time.UTC), filter.DateTimeTo.UTC())
+}
+
+func TestAmountToleranceRange(t *testing.T) {
+	tests := []struct {
+		center, tolerance int64
+		min, max          int64
+	}{
+		{1000, 50, 950, 1050},
+		{1000, 0, 1000, 1000},
+		{30, 50, -20, 80},
+		{math.MaxInt64 - 5, 5, math.MaxInt64 - 10, math.MaxInt64},
+	}
+	for _, tt := range tests {
+		min, max, err := amountToleranceRange(tt.center, tt.tolerance)
+		require.NoError(t, err, "%d±%d", tt.center, tt.tolerance)
+		assert.Equal(t, tt.min, min, "%d±%d", tt.center, tt.tolerance)
+		assert.Equal(t, tt.max, max, "%d±%d", tt.center, tt.tolerance)
+	}
+
+	_, _, err := amountToleranceRange(1000, -1)
+	assert.Error(t, err)
+	_, _, err = amountToleranceRange(math.MaxInt64, 1)
+	assert.Error(t, err)
+	_, _, err = amountToleranceRange(math.MinInt64, 1)
+	assert.Error(t, err)
+}
+
+func TestParseAdvancedFilter_AmountNear(t *testing.T) {
+	service := NewTransactionService(nil, nil)
+
+	filter, err := service.ParseAdvancedFilter("amount:near:1000,50", "UTC")
+	require.NoError(t, err)
+	assert.Equal(t, int64(950), *filter.AmountMin)
+	assert.Equal(t, int64(1050), *filter.AmountMax)
+
+	filter, err = service.ParseAdvancedFilter("amount:near:10.00,0.25 AND response_code:eq:00", "UTC")
+	require.NoError(t, err)
+	assert.Equal(t, int64(975), *filter.AmountMin)
+	assert.Equal(t, int64(1025), *filter.AmountMax)
+
+	for _, invalid := range []string{"amount:near:1000", "amount:near:1000,", "amount:near:abc,5", "amount:near:1000,-5", "amount:near:1,2,3"} {
+		_, err := service.ParseAdvancedFilter(invalid, "UTC")
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestValidateTimezone(t *testing.T) {
+	service := NewTransactionService(nil, nil)
+
+	for _, timezone := range []string{"UTC", "Africa/Johannesburg", "America/New_York"} {
+		assert.NoError(t, service.ValidateTimezone(timezone), timezone)
+	}
+	for _, timezone := range []string{"", "Local", "Mars/Base", "UTC') OR 1=1 --", "../../etc/passwd"} {
+		assert.Error(t, service.ValidateTimezone(timezone), timezone)
+	}
+}
+
+func TestParseAdvancedFilter_TxLogTypeIn(t *testing.T) {
+	service := NewTransactionService(nil, nil)
+
+	filter, err := service.ParseAdvancedFilter("tx_log_type:in:payment,refund", "UTC")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"payment", "refund"}, filter.TxLogTypeIn)
+
+	_, err = service.ParseAdvancedFilter("tx_log_type:in:payment,refnud", "UTC")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "refnud")
+}
+
+func TestParseAdvancedFilter_InOperatorEmptyList(t *testing.T) {
+	service := NewTransactionService(nil, nil)
+
+	_, err := service.ParseAdvancedFilter("response_code:in: , ,", "UTC")
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "requires at least one value")
+}
+
+func TestParseAdvancedFilter_Like(t *testing.T) {
+	service := NewTransactionService(nil, nil)
+
+	filter, err := service.ParseAdvancedFilter("merchant_name:like:COFFEE AND description:ilike:50%_off", "UTC")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "COFFEE", *filter.MerchantNameLike)
+	assert.Equal(t, "50%_off", *filter.DescriptionLike)
+}
+
+func TestParseAdvancedFilter_LikeValidation(t *testing.T) {
+	service := NewTransactionService(nil, nil)
+
+	_, err := service.ParseAdvancedFilter("merchant_name:eq:COFFEE", "UTC")
+	assert.Error(t, err)
+
+	_, err = service.ParseAdvancedFilter("description:like: ", "UTC")
+	assert.Error(t, err)
+}
+
+func TestParseAdvancedFilter_NullChecks(t *testing.T) {
+	service := NewTransactionService(nil, nil)
+
+	filter, err := service.ParseAdvancedFilter("auth_code:isnull:true AND device_id:isnotnull: AND user_ref:isnull:false", "UTC")
+
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{"auth_code": true, "device_id": false, "user_ref": false}, filter.NullFields)
+
+	filter, err = service.ParseAdvancedFilter("auth_code:isnull:", "UTC")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{"auth_code": true}, filter.NullFields)
+}
+
+func TestParseAdvancedFilter_NullChecksValidation(t *testing.T) {
+	service := NewTransactionService(nil, nil)
+
+	for _, filterString := range []string{
+		"amount:isnull:true",         // never null
+		"rrn:isnotnull:",             // never null
+		"unknown_field:isnull:true",  // not a filter field
+		"auth_code:isnull:sometimes", // not a boolean
+	} {
+		_, err := service.ParseAdvancedFilter(filterString, "UTC")
+		assert.Error(t, err, filterString)
+	}
+}
+
+func TestParseAdvancedFilter_MaxQueryRange(t *testing.T) {
+	t.Setenv("MAX_QUERY_RANGE_DAYS", "366")
+	service := NewTransactionService(nil, nil)
+
+	_, err := service.ParseAdvancedFilter("tx_date_time:between:2010-01-01,2030-01-01", "UTC")
+	assert.ErrorIs(t, err, ErrDateRangeTooLarge)
+	assert.Contains(t, err.Error(), "maximum of 366")
+
+	_, err = service.ParseAdvancedFilter("tx_date_time:gte:2024-01-01 AND tx_date_time:lte:2025-01-02", "UTC")
+	assert.ErrorIs(t, err, ErrDateRangeTooLarge)
+
+	// A range without an upper bound runs to now
+	_, err = service.ParseAdvancedFilter("tx_date_time:gte:2010-01-01", "UTC")
+	assert.ErrorIs(t, err, ErrDateRangeTooLarge)
+
+	// A whole leap year is within the cap, as is a recent range with only a lower bound
+	for _, filterString := range []string{
+		"tx_date_time:between:2024-01-01,2024-12-31",
+		"tx_date_time:gte:" + time.Now().UTC().AddDate(0, 0, -30).Format("2006-01-02"),
+	} {
+		_, err = service.ParseAdvancedFilter(filterString, "UTC")
+		assert.NoError(t, err, filterString)
+	}
+}
+
+func TestParseAdvancedFilter_DefaultDateRange(t *testing.T) {
+	t.Setenv("MAX_QUERY_RANGE_DAYS", "366")
+	t.Setenv("DEFAULT_QUERY_RANGE_DAYS", "30")
+	service := NewTransactionService(nil, nil)
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+
+	// Without dates the last 30 days are read, from the start of a UTC day
+	for _, filterString := range []string{"", "response_code:eq:00"} {
+		filter, err := service.ParseAdvancedFilter(filterString, "UTC")
+		require.NoError(t, err, filterString)
+		require.NotNil(t, filter.DateTimeFrom, filterString)
+		assert.Equal(t, today.AddDate(0, 0, -30), filter.DateTimeFrom.UTC(), filterString)
+		assert.Nil(t, filter.DateTimeTo, filterString)
+	}
+
+	// Only an upper bound: the maximum range before it
+	filter, err := service.ParseAdvancedFilter("tx_date_time:lte:2025-01-01", "UTC")
+	require.NoError(t, err)
+	require.NotNil(t, filter.DateTimeFrom)
+	assert.Equal(t, 366*24*time.Hour, filter.DateTimeTo.Sub(*filter.DateTimeFrom))
+
+	// The default never exceeds the maximum
+	t.Setenv("MAX_QUERY_RANGE_DAYS", "7")
+	filter, err = service.ParseAdvancedFilter("", "UTC")
+	require.NoError(t, err)
+	assert.Equal(t, today.AddDate(0, 0, -7), filter.DateTimeFrom.UTC())
+}
+
+func TestMaxQueryRange_SummaryAndTimeseries(t *testing.T) {
+	t.Setenv("MAX_QUERY_RANGE_DAYS", "30")
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 2, 0)
+	filter := &models.TransactionFilter{DateTimeFrom: &from, DateTimeTo: &to}
+
+	// The repository is never reached: any call would panic on the embedded nil interface
+	service := NewTransactionService(&fakeTransactionRepo{}, nil)
+
+	_, err := service.GetMerchantSummary("merchant-1", filter, nil)
+	assert.ErrorIs(t, err, ErrDateRangeTooLarge)
+
+	_, err = service.GetTimeseries("merchant-1", filter, "day", "UTC")
+	assert.ErrorIs(t, err, ErrDateRangeTooLarge)
+}
+
+func TestParseAdvancedFilter_Settlement(t *testing.T) {
+	service := NewTransactionService(nil, nil)
+
+	t.Run("disabled", func(t *testing.T) {
+		t.Setenv("SETTLEMENT_COLUMNS_ENABLED", "false")
+
+		_, err := service.ParseAdvancedFilter("settlement_status:eq:settled", "UTC")
+		assert.Error(t, err)
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		t.Setenv("SETTLEMENT_COLUMNS_ENABLED", "true")
+
+		filter, err := service.ParseAdvancedFilter("settlement_status:eq:settled AND settlement_date:between:2025-01-01,2025-01-31", "UTC")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "settled", *filter.SettlementStatus)
+		assert.Equal(t, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), filter.SettlementDateFrom.UTC())
+		assert.Equal(t, time.Date(2025, 1, 31, 23, 59, 59, 999999999, time.UTC), filter.SettlementDateTo.UTC())
+		assert.NotNil(t, filter.DateTimeFrom, "the default window applies to tx_date_time")
+		assert.Nil(t, filter.DateTimeTo)
+	})
+}
+
+func TestParseSort_ComputedFields(t *testing.T) {
+	service := NewTransactionService(nil, nil)
+
+	sort, err := service.ParseSort("success:desc,tx_date_time:desc")
+
+	assert.NoError(t, err)
+	assert.Equal(t, []models.SortParams{{Field: "success", Direction: "desc"}, {Field: "tx_date_time", Direction: "desc"}}, sort)
+
+	_, err = service.ParseSort("response_description:asc")
+	assert.Error(t, err)
+}
+
+func TestParseSort_InvalidFieldListsAllowedFields(t *testing.T) {
+	service := NewTransactionService(nil, nil)
+
+	_, err := service.ParseSort("amount:asc,bogus:desc")
+
+	var fieldErr *InvalidSortFieldError
+	require.ErrorAs(t, err, &fieldErr)
+	assert.Equal(t, "bogus", fieldErr.Field)
+	assert.True(t, sort.StringsAreSorted(fieldErr.AllowedFields))
+	assert.Contains(t, fieldErr.AllowedFields, "tx_date_time")
+	assert.Contains(t, fieldErr.AllowedFields, "amount")
+	assert.Contains(t, fieldErr.AllowedFields, "success")
+	assert.Len(t, fieldErr.AllowedFields, len(config.FieldMappings)+len(config.SortableComputedFields))
+	assert.Contains(t, err.Error(), "invalid sort field: bogus")
+}
+
+func TestGetTransactions_DayFacet(t *testing.T) {
+	facets := []models.DayFacet{{Date: "2025-01-01", Count: 3}, {Date: "2025-01-02", Count: 5}}
+	repo := &fakeTransactionRepo{
+		listResult: &repositories.TransactionListResult{Page: 1, Limit: 10},
+		dayFacets:  facets,
+	}
+	service := NewTransactionService(repo, nil)
+
+	result, err := service.GetTransactions("merchant-1", &GetTransactionsParams{Facets: []string{"day"}, Timezone: "Africa/Johannesburg"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, facets, result.DayFacets)
+	assert.Equal(t, "Africa/Johannesburg", repo.lastTimezone)
+
+	repo.lastTimezone = ""
+	result, err = service.GetTransactions("merchant-1", &GetTransactionsParams{})
+
+	assert.NoError(t, err)
+	assert.Nil(t, result.DayFacets)
+	assert.Empty(t, repo.lastTimezone, "facets are opt-in")
+}
+
+func decodeSearchQuery(t *testing.T, body string) interface{} {
+	var query interface{}
+	if err := json.Unmarshal([]byte(body), &query); err != nil {
+		t.Fatalf("invalid test query: %v", err)
+	}
+	return query
+}
+
+func TestParseSearchQuery_BoolClauses(t *testing.T) {
+	service := NewTransactionService(nil, nil)
+	query := decodeSearchQuery(t, `{"bool": {
+		"must": [
+			{"term": {"device_id": "DEV1"}},
+			{"range": {"tx_date_time": {"gte": "2024-01-01", "lte": "2024-01-31"}}}
+		],
+		"should": [
+			{"term": {"response_code": "00"}},
+			{"match": {"merchant_name": "coffee"}}
+		],
+		"must_not": {"range": {"amount": {"gt": 5000}}}
+	}}`)
+
+	filter, err := service.ParseSearchQuery(query)
+
+	assert.NoError(t, err)
+	search := filter.Search
+	assert.Len(t, search.Must, 2)
+	assert.Equal(t, models.SearchClause{Field: "device_id", Operator: "=", Value: "DEV1"}, search.Must[0])
+
+	dateRange := search.Must[1].Bool.Must
+	assert.Len(t, dateRange, 2)
+	assert.Equal(t, ">=", dateRange[0].Operator)
+	assert.Equal(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), dateRange[0].Value)
+	assert.Equal(t, "<=", dateRange[1].Operator)
+	assert.Equal(t, time.Date(2024, 1, 31, 23, 59, 59, 999999999, time.UTC), dateRange[1].Value)
+
+	assert.Equal(t, []models.SearchClause{
+		{Field: "response_code", Operator: "=", Value: "00"},
+		{Field: "merchant_name", Operator: "ILIKE", Value: "coffee"},
+	}, search.Should)
+	assert.Equal(t, []models.SearchClause{{Field: "amount", Operator: ">", Value: int64(5000)}}, search.MustNot)
+}
+
+func TestParseSearchQuery_TermOnMappedFields(t *testing.T) {
+	service := NewTransactionService(nil, nil)
+
+	for _, body := range []string{
+		`{"term": {"rrn": "123456789012"}}`,
+		`{"term": {"tx_log_type": "refund"}}`,
+		`{"term": {"reversed": true}}`,
+		`{"term": {"amount": {"value": 1500}}}`,
+		`{"bool": {"filter": [{"term": {"user_ref": "INV-1"}}, {"match_all": {}}]}}`,
+	} {
+		filter, err := service.ParseSearchQuery(decodeSearchQuery(t, body))
+		assert.NoError(t, err, body)
+		assert.NotNil(t, filter.Search, body)
+	}
+}
+
+func TestParseSearchQuery_MatchAll(t *testing.T) {
+	service := NewTransactionService(nil, nil)
+
+	filter, err := service.ParseSearchQuery(decodeSearchQuery(t, `{"match_all": {}}`))
+	assert.NoError(t, err)
+	assert.Nil(t, filter.Search)
+
+	filter, err = service.ParseSearchQuery(nil)
+	assert.NoError(t, err)
+	assert.Nil(t, filter.Search)
+}
+
+func TestParseSearchQuery_UnsupportedClauses(t *testing.T) {
+	service := NewTransactionService(nil, nil)
+
+	tests := map[string]string{
+		`{"wildcard": {"merchant_name": "co*"}}`:                  "unsupported query clause",
+		`{"bool": {"minimum_should_match": 1}}`:                   "unsupported bool option",
+		`{"bool": {"must": [{"prefix": {"rrn": "12"}}]}}`:         "must: unsupported query clause",
+		`{"term": {"unknown": "x"}}`:                              "unknown field",
+		`{"term": {"pan": "411111"}}`:                             "not supported",
+		`{"term": {"device_id": ["a", "b"]}}`:                     "must be a string, number or boolean",
+		`{"term": {"device_id": "a", "rrn": "b"}}`:                "exactly one field",
+		`{"range": {"device_id": {"gte": "a"}}}`:                  "not supported",
+		`{"range": {"amount": {"gte": 1, "boost": 2}}}`:           "unsupported option",
+		`{"range": {"tx_date_time": {"gte": "yesterday"}}}`:       "invalid date",
+		`{"match": {"device_id": "DEV"}}`:                         "not supported",
+		`{"match": {"description": ""}}`:                          "non-empty string",
+		`{"bool": {"must_not": {"match_all": {}}}}`:               "exclude every transaction",
+		`{"term": {"merchant_id": "1"}, "range": {"amount": {}}}`: "exactly one key",
+	}
+
+	for body, message := range tests {
+		_, err := service.ParseSearchQuery(decodeSearchQuery(t, body))
+		if assert.Error(t, err, body) {
+			assert.Contains(t, err.Error(), message, body)
+		}
+	}
+}
+
+func TestParseSearchQuery_NestingDepth(t *testing.T) {
+	service := NewTransactionService(nil, nil)
+	t.Setenv("MAX_SEARCH_QUERY_DEPTH", "3")
+
+	nested := func(depth int) string {
+		return strings.Repeat(`{"bool": {"must": `, depth) + `{"term": {"rrn": "1"}}` + strings.Repeat(`}}`, depth)
+	}
+
+	_, err := service.ParseSearchQuery(decodeSearchQuery(t, nested(3)))
+	assert.NoError(t, err)
+
+	_, err = service.ParseSearchQuery(decodeSearchQuery(t, nested(4)))
+	assert.ErrorIs(t, err, ErrSearchQueryTooDeep)
+}
+
+func TestParseSearchQuery_SettlementTermRequiresConfig(t *testing.T) {
+	service := NewTransactionService(nil, nil)
+	query := decodeSearchQuery(t, `{"term": {"settlement_status": "settled"}}`)
+
+	t.Setenv("SETTLEMENT_COLUMNS_ENABLED", "false")
+	_, err := service.ParseSearchQuery(query)
+	assert.Error(t, err)
+
+	t.Setenv("SETTLEMENT_COLUMNS_ENABLED", "true")
+	_, err = service.ParseSearchQuery(query)
+	assert.NoError(t, err)
+}
+
+// concurrencyProbe records the highest number of overlapping queries across fake repositories
+type concurrencyProbe struct {
+	mu       sync.Mutex
+	inFlight int
+	max      int
+}
+
+func (p *concurrencyProbe) run() {
+	p.mu.Lock()
+	p.inFlight++
+	if p.inFlight > p.max {
+		p.max = p.inFlight
+	}
+	p.mu.Unlock()
+
+	time.Sleep(20 * time.Millisecond)
+
+	p.mu.Lock()
+	p.inFlight--
+	p.mu.Unlock()
+}
+
+// slowTransactionRepo is a fake repository whose queries take a while and report to a probe
+type slowTransactionRepo struct {
+	repositories.TransactionRepository
+	probe *concurrencyProbe
+}
+
+func (r *slowTransactionRepo) GetMerchantSummary(merchantID string, filter *models.TransactionFilter, metrics models.SummaryMetrics) (*models.MerchantSummary, error) {
+	r.probe.run()
+	return &models.MerchantSummary{MerchantID: merchantID, TotalTransactions: 1}, nil
+}
+
+func (r *slowTransactionRepo) GetTransactions(merchantID string, filter *models.TransactionFilter, fields []string, sort []models.SortParams, pagination models.PaginationParams, timezone string, panFormat string) (*repositories.TransactionListResult, error) {
+	r.probe.run()
+	return &repositories.TransactionListResult{Page: pagination.Page, Limit: pagination.Limit}, nil
+}
+
+func (r *slowTransactionRepo) GetDailyCounts(merchantID string, filter *models.TransactionFilter, timezone string) ([]models.DayFacet, error) {
+	r.probe.run()
+	return []models.DayFacet{{Date: "2025-01-01", Count: 1}}, nil
+}
+
+func TestGetMerchantSummary_ConcurrencyBound(t *testing.T) {
+	t.Setenv("MAX_CONCURRENT_QUERIES", "2")
+	probe := &concurrencyProbe{}
+
+	var shards []RollupSource
+	for i := 0; i < 5; i++ {
+		shards = append(shards, RollupSource{Name: fmt.Sprintf("shard-%d", i), Repo: &slowTransactionRepo{probe: probe}})
+	}
+	service := NewTransactionService(&slowTransactionRepo{probe: probe}, nil, shards...)
+
+	summary, err := service.GetMerchantSummary("provisioner-1", nil, nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 6, summary.TotalTransactions)
+	assert.Equal(t, 2, probe.max, "sources should overlap but never exceed the limit")
+}
+
+func TestGetMerchantSummary_ConcurrencyBoundOfOne(t *testing.T) {
+	t.Setenv("MAX_CONCURRENT_QUERIES", "1")
+	probe := &concurrencyProbe{}
+
+	service := NewTransactionService(&slowTransactionRepo{probe: probe}, nil,
+		RollupSource{Name: "shard-2", Repo: &slowTransactionRepo{probe: probe}},
+		RollupSource{Name: "shard-3", Repo: &slowTransactionRepo{probe: probe}})
+
+	_, err := service.GetMerchantSummary("provisioner-1", nil, nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, probe.max)
+}
+
+func TestGetTransactions_FacetQueriesShareConcurrencyBound(t *testing.T) {
+	probe := &concurrencyProbe{}
+	service := NewTransactionService(&slowTransactionRepo{probe: probe}, nil)
+
+	t.Setenv("MAX_CONCURRENT_QUERIES", "1")
+	result, err := service.GetTransactions("merchant-1", &GetTransactionsParams{Facets: []string{"day"}})
+	assert.NoError(t, err)
+	assert.Len(t, result.DayFacets, 1)
+	assert.Equal(t, 1, probe.max)
+
+	t.Setenv("MAX_CONCURRENT_QUERIES", "2")
+	_, err = service.GetTransactions("merchant-1", &GetTransactionsParams{Facets: []string{"day"}})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, probe.max, "data and facet queries run together when allowed")
+}
+
+func TestGetMerchantSummaries_Pagination(t *testing.T) {
+	repo := &fakeTransactionRepo{
+		summaries:      []models.MerchantSummary{{MerchantID: "child-1"}, {MerchantID: "child-2"}},
+		summariesTotal: 5,
+	}
+	service := NewTransactionService(repo, nil)
+
+	result, err := service.GetMerchantSummaries("provisioner-1", nil, 0, 2)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "provisioner-1", repo.lastMerchantID)
+	assert.Equal(t, models.PaginationParams{Page: 1, Limit: 2}, repo.lastPagination)
+	assert.Len(t, result.Summaries, 2)
+	assert.Equal(t, int64(5), result.TotalCount)
+	assert.Equal(t, 3, result.TotalPages)
+
+	_, err = service.GetMerchantSummaries("provisioner-1", nil, 1, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 100, repo.lastPagination.Limit)
+}
+
+func TestGetMerchantSummaries_Error(t *testing.T) {
+	service := NewTransactionService(&fakeTransactionRepo{summaryErr: errors.New("connection refused")}, nil)
+
+	result, err := service.GetMerchantSummaries("provisioner-1", nil, 1, 10)
+
+	assert.Error(t, err)
+	assert.Nil(t, result)
+}
+
+func TestFollowTransactions_RowsSharingATimestamp(t *testing.T) {
+	t1 := time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)
+	t2 := t1.Add(time.Second)
+	repo := &fakeTransactionRepo{pollRows: []models.Transaction{
+		{ID: "a", UpdatedAt: t1},
+		{ID: "b", UpdatedAt: t2},
+		{ID: "c", UpdatedAt: t2},
+	}}
+	service := NewTransactionService(repo, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var seen []string
+	err := service.FollowTransactions(ctx, "merchant-1", &GetTransactionsParams{}, time.Millisecond, 2, func(tx *models.Transaction) error {
+		seen = append(seen, tx.ID)
+		switch tx.ID {
+		case "c":
+			// A row committed after the poll, with the same updated_at as the last one seen
+			repo.pollRows = append(repo.pollRows, models.Transaction{ID: "d", UpdatedAt: t2})
+		case "d":
+			cancel()
+		}
+		return nil
+	})
+
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, []string{"a", "b", "c", "d"}, seen, "no row may be skipped or repeated")
+	assert.Equal(t, []*models.TransactionCursor{
+		nil,
+		{UpdatedAt: t2, ID: "b"},
+		{UpdatedAt: t2, ID: "c"},
+	}, repo.pollCursors)
+}
+
+func TestFollowTransactions_FullBatchPollsAgainImmediately(t *testing.T) {
+	t1 := time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)
+	repo := &fakeTransactionRepo{pollRows: []models.Transaction{
+		{ID: "a", UpdatedAt: t1},
+		{ID: "b", UpdatedAt: t1},
+		{ID: "c", UpdatedAt: t1},
+	}}
+	service := NewTransactionService(repo, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	var seen []string
+	start := time.Now()
+	err := service.FollowTransactions(ctx, "merchant-1", &GetTransactionsParams{}, time.Hour, 2, func(tx *models.Transaction) error {
+		seen = append(seen, tx.ID)
+		return nil
+	})
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, []string{"a", "b", "c"}, seen)
+	assert.Len(t, repo.pollCursors, 2, "only a partial batch waits for the poll interval")
+}
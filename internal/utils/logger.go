@@ -66,12 +66,30 @@ func init() {
 		TimestampFormat: time.RFC3339Nano,
 	})
 
-	// Set log level (can be configured via environment)
-	Logger.SetLevel(logrus.TraceLevel)
+	// Info until main applies LOG_LEVEL with SetLogLevel
+	Logger.SetLevel(logrus.InfoLevel)
+}
+
+// SetLogLevel sets the level of Logger from a level name such as "info" or "trace"; an
+// unknown name leaves the level unchanged
+func SetLogLevel(level string) error {
+	parsed, err := logrus.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	Logger.SetLevel(parsed)
+	return nil
 }
 
 // Helper functions to log with consistent format
+
+// LogHTTPRequest writes the access log entry of a request at info level. Query strings
+// carry filter values, so the url keeps its query only when trace logging is enabled.
 func LogHTTPRequest(method, url, status string, responseTime string, requestID string) {
+	if !Logger.IsLevelEnabled(logrus.TraceLevel) {
+		url, _, _ = strings.Cut(url, "?")
+	}
+
 	Logger.WithFields(logrus.Fields{
 		"source": "aken-reporting",
 		"meta": map[string]interface{}{
@@ -82,7 +100,7 @@ func LogHTTPRequest(method, url, status string, responseTime string, requestID s
 			"id":            requestID,
 			"response_time": responseTime,
 		},
-	}).Trace("HTTP Request")
+	}).Info("HTTP Request")
 }
 
 func LogTransactionRequest(merchantID, sessionID string, operation string, additionalFields map[string]interface{}) {
@@ -195,3 +213,19 @@ func LogWarn(message string, fields map[string]interface{}) {
 
 	Logger.WithFields(logFields).Warn(message)
 }
+
+// LogAudit records access to transaction data. Entries are logged at info level with
+// "audit": true in meta so they can be routed to the audit trail; callers must never
+// pass card numbers or other cardholder data in fields.
+func LogAudit(message string, fields map[string]interface{}) {
+	meta := map[string]interface{}{}
+	for key, value := range fields {
+		meta[key] = value
+	}
+	meta["audit"] = true
+
+	Logger.WithFields(logrus.Fields{
+		"source": "aken-reporting",
+		"meta":   meta,
+	}).Info(message)
+}
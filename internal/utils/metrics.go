@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MetricsContentType is the Prometheus text exposition format served by the metrics endpoint
+const MetricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// errorMetricKey labels an error response by route and error code
+type errorMetricKey struct {
+	endpoint string
+	code     string
+}
+
+// errorCounts counts the error responses sent through SendError
+var errorCounts = struct {
+	sync.Mutex
+	counts map[errorMetricKey]int64
+}{counts: make(map[errorMetricKey]int64)}
+
+// RecordError counts an error response under the request's endpoint and the error code
+func RecordError(c *gin.Context, code string) {
+	key := errorMetricKey{endpoint: metricEndpoint(c), code: code}
+
+	errorCounts.Lock()
+	defer errorCounts.Unlock()
+	errorCounts.counts[key]++
+}
+
+// ErrorCount returns how many error responses were sent for endpoint with code
+func ErrorCount(endpoint, code string) int64 {
+	errorCounts.Lock()
+	defer errorCounts.Unlock()
+	return errorCounts.counts[errorMetricKey{endpoint: endpoint, code: code}]
+}
+
+// metricEndpoint labels a request by method and route pattern rather than its path, so ids
+// in the path don't create a series per value. Requests that match no route share one label.
+func metricEndpoint(c *gin.Context) string {
+	route := c.FullPath()
+	if route == "" {
+		route = "unmatched"
+	}
+	if c.Request == nil {
+		return route
+	}
+	return c.Request.Method + " " + route
+}
+
+// metricLabelEscaper escapes label values as the text exposition format requires
+var metricLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// WriteMetrics writes the counters in the Prometheus text exposition format, in a stable order
+func WriteMetrics(w io.Writer) error {
+	errorCounts.Lock()
+	keys := make([]errorMetricKey, 0, len(errorCounts.counts))
+	for key := range errorCounts.counts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].endpoint != keys[j].endpoint {
+			return keys[i].endpoint < keys[j].endpoint
+		}
+		return keys[i].code < keys[j].code
+	})
+
+	var b strings.Builder
+	b.WriteString("# HELP aken_http_errors_total Error responses by endpoint and error code.\n")
+	b.WriteString("# TYPE aken_http_errors_total counter\n")
+	for _, key := range keys {
+		fmt.Fprintf(&b, "aken_http_errors_total{endpoint=\"%s\",code=\"%s\"} %d\n",
+			metricLabelEscaper.Replace(key.endpoint), metricLabelEscaper.Replace(key.code), errorCounts.counts[key])
+	}
+	errorCounts.Unlock()
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
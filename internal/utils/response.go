@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"aken_reporting_service/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SendError writes the error response shared by every handler and middleware, an "error"
+// object with code, message, timestamp, request_id and the optional details.
+// The message is localized from Accept-Language; a specific message is English only, so
+// other languages carry it in details.message instead. Every error is counted by
+// RecordError. It does not abort; middleware must.
+func SendError(c *gin.Context, status int, code, message string, details gin.H) {
+	RecordError(c, code)
+
+	language := config.ResolveLanguage(c.GetHeader("Accept-Language"))
+	userMessage := config.GetLocalizedMessage(code, language)
+	if message != "" {
+		if language == config.DefaultLanguage {
+			userMessage = message
+		} else {
+			// Copied so the caller's map is left as it was
+			localized := gin.H{}
+			for key, value := range details {
+				localized[key] = value
+			}
+			localized["message"] = message
+			details = localized
+		}
+	}
+
+	body := gin.H{
+		"code":       code,
+		"message":    userMessage,
+		"timestamp":  time.Now().UTC().Format(time.RFC3339),
+		"request_id": GetRequestID(c),
+	}
+	if details != nil {
+		body["details"] = details
+	}
+
+	// The body depends on Accept-Language, so caches must not share it across languages
+	AddVary(c, "Accept-Language")
+	c.Header("Content-Language", language)
+	c.JSON(status, gin.H{"error": body})
+}
+
+// AddVary adds a request header to the response's Vary header, unless it is already listed
+func AddVary(c *gin.Context, header string) {
+	for _, value := range c.Writer.Header().Values("Vary") {
+		for _, existing := range strings.Split(value, ",") {
+			if http.CanonicalHeaderKey(strings.TrimSpace(existing)) == http.CanonicalHeaderKey(header) {
+				return
+			}
+		}
+	}
+	c.Writer.Header().Add("Vary", header)
+}
+
+// GetRequestID returns the request's X-Request-ID, or a generated ID when it has none
+func GetRequestID(c *gin.Context) string {
+	requestID := c.GetHeader("X-Request-ID")
+	if requestID == "" {
+		requestID = fmt.Sprintf("req_%d_%d", time.Now().Unix(), time.Now().Nanosecond())
+	}
+	return requestID
+}
@@ -5,13 +5,18 @@ import (
 	"aken_reporting_service/api/routes"
 	"aken_reporting_service/internal/config"
 	"aken_reporting_service/internal/database"
+	"aken_reporting_service/internal/handlers"
 	"aken_reporting_service/internal/middleware"
+	"aken_reporting_service/internal/repositories"
 	"aken_reporting_service/internal/services"
 	"aken_reporting_service/internal/utils"
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
-	"strings"
+	"os/signal"
+	"syscall"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -19,6 +24,11 @@ import (
 
 func main() {
 	config.LoadEnv()     // Load environment variables from .env file
+	utils.SetLogLevel(config.GetLogLevel())
+	if err := config.ValidateAuthSettings(); err != nil {
+		utils.LogError("Refusing to start with unsafe auth settings", err, nil)
+		os.Exit(1)
+	}
 	database.ConnectDB() // Connect to the database
 
 	// Set Gin mode based on environment variable
@@ -53,21 +63,19 @@ func main() {
 	// Add custom logging middleware
 	r.Use(middleware.LoggingMiddleware())
 
+	// Compress large text responses for clients that accept gzip
+	r.Use(middleware.GzipMiddleware())
+
 	// Disable automatic redirects to prevent 301 redirects for trailing slashes
 	r.RedirectTrailingSlash = false
 	r.RedirectFixedPath = false
 
 	// Configure CORS
 	corsConfig := cors.DefaultConfig()
-	corsConfig.AllowOrigins = []string{
-		"http://localhost:8080",
-		"http://localhost:5173",
-		"http://localhost:3000",
-		"http://localhost:3001",
-		"https://aken-eu.staging.wizzitdigital.com",
-	}
-	corsConfig.AllowMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
-	corsConfig.AllowHeaders = []string{"Origin", "Content-Type", "Accept", "Authorization", "X-API-Key", "X-Request-ID"}
+	corsConfig.AllowOrigins = config.GetAllowedOrigins()
+	corsConfig.AllowMethods = config.CORSAllowedMethods
+	corsConfig.AllowHeaders = config.CORSAllowedHeaders
+	corsConfig.ExposeHeaders = config.CORSExposedHeaders
 	corsConfig.AllowCredentials = true
 	r.Use(cors.New(corsConfig))
 
@@ -133,22 +141,36 @@ func main() {
 		})
 	})
 
+	// The transaction handler serves the transaction routes and renders scheduled reports
+	transactionRepo := repositories.NewTransactionRepository(database.DB, database.MySQLDB)
+	var rollupSources []services.RollupSource
+	for _, rollup := range database.RollupDBs {
+		rollupSources = append(rollupSources, services.RollupSource{
+			Name: rollup.Name,
+			Repo: repositories.NewTransactionRepository(rollup.DB, nil),
+		})
+	}
+	transactionService := services.NewTransactionService(transactionRepo, cacheService, rollupSources...)
+	transactionHandler := handlers.NewTransactionHandler(transactionService)
+	transactionHandler.SetReportScheduleStore(services.NewReportScheduleStore(cacheService))
+	transactionHandler.SetExportJobStore(services.NewExportJobStore(cacheService))
+	if cacheService == nil || !config.IsRedisEnabled() {
+		utils.LogWarn("Report schedules and export jobs are kept in memory: they are not shared between instances and are lost on restart", nil)
+	}
+
 	// Setup all API routes
-	routes.SetupRoutes(r, database.DB, cacheService)
+	routes.SetupRoutes(r, transactionHandler, cacheService)
+
+	// Cancelled on SIGINT/SIGTERM to start the graceful shutdown
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Run scheduled reports in the background
+	reportScheduler := transactionHandler.NewReportScheduler()
+	reportScheduler.Start(ctx, config.GetReportSchedulerInterval())
 
 	// Handle 404 for unknown API routes
-	r.NoRoute(func(c *gin.Context) {
-		if strings.HasPrefix(c.Request.URL.Path, "/api/") {
-			c.JSON(http.StatusNotFound, gin.H{
-				"code":       "ENDPOINT_NOT_FOUND",
-				"message":    fmt.Sprintf("API endpoint %s %s not found", c.Request.Method, c.Request.URL.Path),
-				"timestamp":  "2025-01-28T10:30:00.000Z",
-				"request_id": c.GetHeader("X-Request-ID"),
-			})
-			return
-		}
-		c.JSON(http.StatusNotFound, gin.H{"message": "Endpoint not found"})
-	})
+	r.NoRoute(handlers.NotFound)
 
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -168,8 +190,61 @@ func main() {
 		},
 	})
 
-	if err := r.Run(fmt.Sprintf(":%s", port)); err != nil {
+	server := &http.Server{
+		Addr:    fmt.Sprintf(":%s", port),
+		Handler: r,
+	}
+	// Streams run for up to STREAM_MAX_DURATION_SECONDS, so they are ended as soon as the
+	// shutdown starts instead of being drained
+	server.RegisterOnShutdown(transactionHandler.CloseStreams)
+
+	serverErr := make(chan error, 1)
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+	}()
+
+	select {
+	case err := <-serverErr:
 		utils.LogError("Failed to start server", err, nil)
 		os.Exit(1)
+	case <-ctx.Done():
+	}
+	stop()
+
+	// Drain in-flight requests, such as streaming exports, before closing the backing stores
+	shutdownTimeout := config.GetShutdownTimeout()
+	utils.LogInfo("Shutdown signal received, draining in-flight requests", map[string]interface{}{
+		"timeout_seconds": int(shutdownTimeout.Seconds()),
+	})
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		utils.LogError("Server did not shut down gracefully", err, nil)
+	} else {
+		utils.LogInfo("HTTP server stopped", nil)
+	}
+
+	// The scheduler was stopped with ctx; a report it was delivering is aborted, but its
+	// outcome must still be recorded before the stores close
+	reportScheduler.Wait()
+	utils.LogInfo("Report scheduler stopped", nil)
+
+	if cacheService != nil {
+		if err := cacheService.Close(); err != nil {
+			utils.LogError("Failed to close Redis cache", err, nil)
+		} else {
+			utils.LogInfo("Redis cache closed", nil)
+		}
 	}
+
+	if err := database.CloseDB(); err != nil {
+		utils.LogError("Failed to close database connections", err, nil)
+	} else {
+		utils.LogInfo("Database connections closed", nil)
+	}
+
+	utils.LogInfo("AKEN Reporting Service stopped", nil)
 }